@@ -0,0 +1,96 @@
+package smartme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Action sets the value of a switchable OBIS register of a device, for
+// example ObisSwitchState to switch a relay.
+type Action struct {
+	ObisCode string  `json:"obisCode"`
+	Value    float64 `json:"value"`
+}
+
+// ActionType is the kind of an action a device offers.
+type ActionType int32
+
+const (
+	// ActionTypeOnOff switches between the values 0 and 1.
+	ActionTypeOnOff ActionType = 0
+	// ActionTypeAnalog sets a value between MinValue and MaxValue.
+	ActionTypeAnalog ActionType = 1
+)
+
+// ActionInfo describes an action a device offers.
+type ActionInfo struct {
+	Name       string     `json:"name"`
+	ObisCode   string     `json:"obisCode"`
+	ActionType ActionType `json:"actionType"`
+	MinValue   float64    `json:"minValue"`
+	MaxValue   float64    `json:"maxValue"`
+}
+
+// GetActions returns the actions a device offers.
+// Corresponds to the API call: GET /api/Actions/{id}
+func (c *Client) GetActions(ctx context.Context, deviceID string, opts ...CallOption) ([]ActionInfo, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("deviceID must not be empty")
+	}
+
+	path := fmt.Sprintf("api/Actions/%s", url.PathEscape(deviceID))
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var actions []ActionInfo
+	if _, err := c.do(req, &actions); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// actionsRequest is the body of the actions endpoint.
+type actionsRequest struct {
+	DeviceID string   `json:"deviceID"`
+	Actions  []Action `json:"actions"`
+}
+
+// PerformActions executes actions on a device.
+// Corresponds to the API call: POST /api/Actions
+func (c *Client) PerformActions(ctx context.Context, deviceID string, actions []Action, opts ...CallOption) error {
+	if deviceID == "" {
+		return fmt.Errorf("deviceID must not be empty")
+	}
+	if len(actions) == 0 {
+		return fmt.Errorf("actions must not be empty")
+	}
+
+	body := actionsRequest{DeviceID: deviceID, Actions: actions}
+	req, err := c.newJSONRequest(ctx, http.MethodPost, "api/Actions", body, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	_, err = c.do(req, nil)
+	return err
+}
+
+// newJSONRequest is like newRequest with v encoded as JSON body.
+func (c *Client) newJSONRequest(ctx context.Context, method, path string, v interface{}, opts ...CallOption) (*http.Request, error) {
+	var body io.Reader
+	if v != nil {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	return c.newRequest(ctx, method, path, body, opts...)
+}
@@ -0,0 +1,193 @@
+package smartme
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// AnomalyKind is the kind of an Anomaly.
+type AnomalyKind int
+
+const (
+	// AnomalySpike is a sustained value far above the usual level of the series.
+	AnomalySpike AnomalyKind = iota + 1
+	// AnomalyZero is a zero reading while most other meters report load,
+	// which usually means a meter or its communication failed.
+	AnomalyZero
+	// AnomalyOutOfRange is a value beyond the rating of the meter.
+	AnomalyOutOfRange
+)
+
+// String returns the name of the kind.
+func (k AnomalyKind) String() string {
+	switch k {
+	case AnomalySpike:
+		return "Spike"
+	case AnomalyZero:
+		return "Zero"
+	case AnomalyOutOfRange:
+		return "OutOfRange"
+	}
+	return fmt.Sprintf("AnomalyKind(%d)", int(k))
+}
+
+// Anomaly is a window of anomalous samples of one device.
+type Anomaly struct {
+	Kind     AnomalyKind
+	DeviceID string
+	// Start and End are the dates of the first and last anomalous sample.
+	Start, End time.Time
+	// Value is the most extreme value in the window.
+	Value float64
+	// Samples is the number of anomalous samples.
+	Samples int
+}
+
+// AnomalyDetector finds anomalies in power series. The zero value is usable.
+type AnomalyDetector struct {
+	// Sensitivity is how many robust standard deviations (derived from the
+	// median absolute deviation) a sample must lie above the median of its
+	// series to count as a spike. Lower values find more spikes (default 6).
+	Sensitivity float64
+	// MinSamples is the number of consecutive samples a spike must last,
+	// so single outliers are ignored (default 2).
+	MinSamples int
+	// MaxPower is the rating of the meters in W. Values beyond ±MaxPower
+	// are reported as out of range; 0 disables the check.
+	MaxPower float64
+	// LoadThreshold is the power in W above which a meter reports load
+	// (default 50 W).
+	LoadThreshold float64
+}
+
+// Detect returns the anomalies in the power series (in W) of several
+// devices, keyed by device ID, ordered by start date and device ID. Zero
+// readings are compared with the samples of the other devices at the same
+// date.
+func (ad AnomalyDetector) Detect(series map[string][]Value) []Anomaly {
+	sensitivity := ad.Sensitivity
+	if sensitivity <= 0 {
+		sensitivity = 6
+	}
+	minSamples := ad.MinSamples
+	if minSamples <= 0 {
+		minSamples = 2
+	}
+	loadThreshold := ad.LoadThreshold
+	if loadThreshold <= 0 {
+		loadThreshold = 50
+	}
+
+	// Count the meters with and without load at every date.
+	loaded := make(map[int64]int)
+	reporting := make(map[int64]int)
+	for _, values := range series {
+		for _, v := range values {
+			reporting[v.Date.UnixNano()]++
+			if v.Value > loadThreshold {
+				loaded[v.Date.UnixNano()]++
+			}
+		}
+	}
+
+	var anomalies []Anomaly
+	for id, values := range series {
+		values = append([]Value(nil), values...)
+		sort.SliceStable(values, func(i, j int) bool { return values[i].Date.Before(values[j].Date) })
+
+		spikeLimit := math.Inf(1)
+		if median, scale := robustScale(values); scale > 0 {
+			spikeLimit = median + sensitivity*scale
+		}
+
+		w := anomalyWindows{deviceID: id}
+		for _, v := range values {
+			others := reporting[v.Date.UnixNano()] - 1
+			switch {
+			case math.IsNaN(v.Value) || (ad.MaxPower > 0 && math.Abs(v.Value) > ad.MaxPower):
+				w.add(AnomalyOutOfRange, v)
+			case v.Value > spikeLimit:
+				w.add(AnomalySpike, v)
+			case v.Value == 0 && others > 0 && 2*loaded[v.Date.UnixNano()] > others:
+				w.add(AnomalyZero, v)
+			default:
+				w.add(0, v)
+			}
+		}
+		w.add(0, Value{})
+
+		for _, a := range w.found {
+			if a.Kind != AnomalySpike || a.Samples >= minSamples {
+				anomalies = append(anomalies, a)
+			}
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		if !anomalies[i].Start.Equal(anomalies[j].Start) {
+			return anomalies[i].Start.Before(anomalies[j].Start)
+		}
+		return anomalies[i].DeviceID < anomalies[j].DeviceID
+	})
+	return anomalies
+}
+
+// robustScale returns the median of the values and 1.4826 times their
+// median absolute deviation, which equals the standard deviation for
+// normally distributed values but ignores outliers.
+func robustScale(values []Value) (median, scale float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	xs := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v.Value) {
+			xs = append(xs, v.Value)
+		}
+	}
+	median = medianOf(xs)
+	for i, x := range xs {
+		xs[i] = math.Abs(x - median)
+	}
+	return median, 1.4826 * medianOf(xs)
+}
+
+func medianOf(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sort.Float64s(xs)
+	n := len(xs)
+	if n%2 == 1 {
+		return xs[n/2]
+	}
+	return (xs[n/2-1] + xs[n/2]) / 2
+}
+
+// anomalyWindows merges consecutive samples of the same kind.
+type anomalyWindows struct {
+	deviceID string
+	current  *Anomaly
+	found    []Anomaly
+}
+
+// add appends a sample; kind 0 is a normal sample that closes a window.
+func (w *anomalyWindows) add(kind AnomalyKind, v Value) {
+	if w.current != nil && w.current.Kind != kind {
+		w.found = append(w.found, *w.current)
+		w.current = nil
+	}
+	if kind == 0 {
+		return
+	}
+	if w.current == nil {
+		w.current = &Anomaly{Kind: kind, DeviceID: w.deviceID, Start: v.Date, Value: v.Value}
+	}
+	w.current.End = v.Date
+	w.current.Samples++
+	if math.Abs(v.Value) > math.Abs(w.current.Value) || math.IsNaN(v.Value) {
+		w.current.Value = v.Value
+	}
+}
@@ -0,0 +1,51 @@
+package smartme_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := func(values ...float64) []smartme.Value {
+		out := make([]smartme.Value, len(values))
+		for i, v := range values {
+			out[i] = smartme.Value{Date: start.Add(time.Duration(i) * 15 * time.Minute), Value: v}
+		}
+		return out
+	}
+
+	input := map[string][]smartme.Value{
+		// A sustained spike at samples 3-4 and a single outlier at 8.
+		"a": series(500, 520, 480, 9000, 9500, 510, 490, 505, 8000, 495),
+		// Drops to zero while the other meters have load.
+		"b": series(300, 310, 0, 0, 305, 295, 300, 310, 305, 300),
+		// An impossible value.
+		"c": series(200, 210, 190, 205, 200, 1e6, math.NaN(), 195, 200, 210),
+	}
+
+	got := smartme.AnomalyDetector{MaxPower: 80000}.Detect(input)
+	want := []smartme.Anomaly{
+		{Kind: smartme.AnomalyZero, DeviceID: "b", Start: start.Add(30 * time.Minute), End: start.Add(45 * time.Minute), Value: 0, Samples: 2},
+		{Kind: smartme.AnomalySpike, DeviceID: "a", Start: start.Add(45 * time.Minute), End: start.Add(60 * time.Minute), Value: 9500, Samples: 2},
+		{Kind: smartme.AnomalyOutOfRange, DeviceID: "c", Start: start.Add(75 * time.Minute), End: start.Add(90 * time.Minute), Samples: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("found %d anomalies, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Kind != w.Kind || g.DeviceID != w.DeviceID || !g.Start.Equal(w.Start) || !g.End.Equal(w.End) || g.Samples != w.Samples {
+			t.Errorf("anomaly %d = %+v, want %+v", i, g, w)
+		}
+		if w.Kind != smartme.AnomalyOutOfRange && g.Value != w.Value {
+			t.Errorf("anomaly %d value = %v, want %v", i, g.Value, w.Value)
+		}
+	}
+	if !math.IsNaN(got[2].Value) {
+		t.Errorf("out of range value = %v, want NaN", got[2].Value)
+	}
+}
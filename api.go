@@ -0,0 +1,43 @@
+package smartme
+
+import (
+	"context"
+	"time"
+)
+
+// API is the set of calls offered by the smart-me client.
+// Code that only needs to talk to smart-me can depend on API instead of
+// *Client, so tests can substitute a mock (see package smartmemock).
+type API interface {
+	GetDevices(ctx context.Context, opts ...CallOption) ([]Device, error)
+	GetDevice(ctx context.Context, deviceID string, opts ...CallOption) (*Device, error)
+	CreateOrUpdateDevice(ctx context.Context, device Device, opts ...CallOption) (*Device, error)
+	FindDeviceByName(ctx context.Context, name string, opts ...CallOption) (*Device, error)
+	GetActivePower(ctx context.Context, deviceID string, opts ...CallOption) (float64, error)
+	GetCounterReading(ctx context.Context, deviceID string, opts ...CallOption) (float64, error)
+	GetTemperature(ctx context.Context, deviceID string, opts ...CallOption) (float64, error)
+	GetValues(ctx context.Context, deviceID string, opts ...CallOption) (*DeviceValues, error)
+	GetDevicesWithValues(ctx context.Context, opts ...CallOption) ([]DeviceSnapshot, error)
+	GetValuesConcurrently(ctx context.Context, deviceIDs []string, opts ...CallOption) ([]DeviceValues, error)
+	GetValuesInPast(ctx context.Context, deviceID string, date time.Time, opts ...CallOption) (*Value, error)
+	GetValuesAt(ctx context.Context, deviceID string, at time.Time, opts ...CallOption) (*DeviceValues, error)
+	GetValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, opts ...CallOption) ([]Value, error)
+	StreamValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, fn func(Value) error, opts ...CallOption) error
+	GetActions(ctx context.Context, deviceID string, opts ...CallOption) ([]ActionInfo, error)
+	PerformActions(ctx context.Context, deviceID string, actions []Action, opts ...CallOption) error
+	ExecuteActionsBulk(ctx context.Context, actions map[string][]Action, opts ...CallOption) error
+	CheckActionsBulk(ctx context.Context, actions map[string][]Action, opts ...CallOption) error
+	SetActiveTariff(ctx context.Context, deviceID string, tariff int32, opts ...CallOption) error
+	StartCharging(ctx context.Context, deviceID string, opts ...CallOption) error
+	StopCharging(ctx context.Context, deviceID string, opts ...CallOption) error
+	SetMaxChargingCurrent(ctx context.Context, deviceID string, amps float64, opts ...CallOption) error
+	CompareConsumption(ctx context.Context, deviceIDs []string, periodA, periodB Period, opts ...CallOption) (*ConsumptionReport, error)
+	GetBatteryState(ctx context.Context, deviceID string, capacity float64, opts ...CallOption) (*BatteryState, error)
+	GetBatteryHistory(ctx context.Context, deviceID string, start, end time.Time, capacity float64, opts ...CallOption) ([]BatterySample, error)
+	GetChargingSessions(ctx context.Context, deviceID string, start, end time.Time, opts ...CallOption) ([]ChargingSession, error)
+	DailyConsumption(ctx context.Context, deviceID string, month time.Time, opts ...CallOption) ([]ConsumptionPoint, error)
+	MonthlyConsumption(ctx context.Context, deviceID string, year int, opts ...CallOption) ([]ConsumptionPoint, error)
+}
+
+// Ensure Client implements API.
+var _ API = (*Client)(nil)
@@ -0,0 +1,132 @@
+package smartme
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxAuditEntries is the number of entries kept in memory by AuditLog.
+const maxAuditEntries = 1000
+
+// maxAuditBody is the largest request body recorded in an AuditEntry.
+const maxAuditBody = 4096
+
+// AuditEntry records a call that changes a device or the account, such as
+// switching a relay.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	// Body is the JSON request body, e.g. the actions performed.
+	Body json.RawMessage `json:"body,omitempty"`
+	// Actor and Reason are set with ContextWithAudit.
+	Actor     string `json:"actor,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	// StatusCode is 0 if no response was received; Error describes the
+	// failure of an unsuccessful call.
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type auditKey struct{}
+
+type auditInfo struct {
+	actor, reason string
+}
+
+// ContextWithAudit returns a context that records who makes the calls done
+// with it and why, e.g. ContextWithAudit(ctx, "jane@example.com", "tenant
+// moved out"), in the audit log.
+func ContextWithAudit(ctx context.Context, actor, reason string) context.Context {
+	return context.WithValue(ctx, auditKey{}, auditInfo{actor, reason})
+}
+
+// WithAuditWriter writes every audit entry as a line of JSON to w, e.g. an
+// append-only file, in addition to keeping it in memory. Write errors do
+// not fail the call, since the change has already been made; wrap w to
+// handle them.
+func WithAuditWriter(w io.Writer) Option {
+	return func(c *Client) error {
+		if w == nil {
+			return errors.New("audit writer must not be nil")
+		}
+		c.auditWriter = w
+		return nil
+	}
+}
+
+// AuditLog returns the latest calls that changed a device or the account,
+// oldest first. Clients derived with With share the log of their parent.
+func (c *Client) AuditLog() []AuditEntry {
+	return c.audit.entries()
+}
+
+// auditLog keeps the latest audit entries.
+type auditLog struct {
+	mu   sync.Mutex
+	log  []AuditEntry
+	next int
+}
+
+func (a *auditLog) entries() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.log) < maxAuditEntries {
+		return append([]AuditEntry(nil), a.log...)
+	}
+	return append(append([]AuditEntry(nil), a.log[a.next:]...), a.log[:a.next]...)
+}
+
+// recordAudit records a request that is not safe after it was sent.
+func (c *Client) recordAudit(req *http.Request, resp *http.Response, err error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return
+	}
+
+	e := AuditEntry{
+		Time:      c.clock.Now(),
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		RequestID: req.Header.Get(RequestIDHeader),
+	}
+	if info, ok := req.Context().Value(auditKey{}).(auditInfo); ok {
+		e.Actor, e.Reason = info.actor, info.reason
+	}
+	if req.GetBody != nil {
+		if body, berr := req.GetBody(); berr == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxAuditBody+1))
+			body.Close()
+			if len(data) <= maxAuditBody && json.Valid(data) {
+				e.Body = data
+			}
+		}
+	}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	a := c.audit
+	a.mu.Lock()
+	if len(a.log) < maxAuditEntries {
+		a.log = append(a.log, e)
+	} else {
+		a.log[a.next] = e
+		a.next = (a.next + 1) % maxAuditEntries
+	}
+	a.mu.Unlock()
+
+	if c.auditWriter != nil {
+		if line, merr := json.Marshal(e); merr == nil {
+			c.auditWriter.Write(append(line, '\n'))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package smartme_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_AuditLog(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("apt-12"), SwitchOn: ptr(true)})
+
+	now := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	client, _ := srv.Client(smartme.WithClock(smartmetest.NewClock(now)), smartme.WithAuditWriter(&out))
+
+	ctx := smartme.ContextWithAudit(context.Background(), "jane", "tenant moved out")
+	off := []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 0}}
+	if err := client.PerformActions(ctx, "apt-12", off); err != nil {
+		t.Fatalf("PerformActions failed: %v", err)
+	}
+	srv.FailEndpoint("/api/Actions", 500)
+	client.PerformActions(context.Background(), "apt-12", off)
+	client.GetDevices(ctx) // reads are not audited
+
+	log := client.AuditLog()
+	if len(log) != 2 {
+		t.Fatalf("%d audit entries, want 2: %+v", len(log), log)
+	}
+	e := log[0]
+	if e.Actor != "jane" || e.Reason != "tenant moved out" || e.Method != "POST" || e.Path != "/api/Actions" ||
+		e.StatusCode != 200 || e.Error != "" || !e.Time.Equal(now) || e.RequestID == "" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if !strings.Contains(string(e.Body), `"deviceID":"apt-12"`) {
+		t.Errorf("body = %s", e.Body)
+	}
+	if log[1].StatusCode != 500 || log[1].Error == "" || log[1].Actor != "" {
+		t.Errorf("unexpected entry for the failed call: %+v", log[1])
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines written, want 2", len(lines))
+	}
+	var written smartme.AuditEntry
+	if err := json.Unmarshal([]byte(lines[0]), &written); err != nil || written.Actor != "jane" {
+		t.Errorf("written entry = %+v, %v", written, err)
+	}
+}
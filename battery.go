@@ -0,0 +1,117 @@
+package smartme
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BatteryState is the state of a virtual battery meter.
+type BatteryState struct {
+	DeviceID string
+	// StoredEnergy is the energy in the battery in kWh.
+	StoredEnergy float64
+	// Capacity is the usable capacity in kWh, as passed by the caller.
+	Capacity float64
+	// StateOfCharge is StoredEnergy relative to Capacity, between 0 and 1.
+	StateOfCharge float64
+	// ChargedEnergy and DischargedEnergy are the totals in kWh, if the
+	// device reports them.
+	ChargedEnergy, DischargedEnergy *float64
+	// Power is the active power in W, positive while charging.
+	Power *float64
+}
+
+// BatterySample is a historical state of charge.
+type BatterySample struct {
+	Date          time.Time
+	StoredEnergy  float64
+	StateOfCharge float64
+}
+
+// GetBatteryState returns the state of a virtual battery meter. The API
+// does not report the capacity of the simulated battery, so it has to be
+// given in kWh to compute the state of charge.
+func (c *Client) GetBatteryState(ctx context.Context, deviceID string, capacity float64, opts ...CallOption) (*BatteryState, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be positive, got %g", capacity)
+	}
+	d, factor, err := c.getBattery(ctx, deviceID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if d.CounterReading == nil {
+		return nil, fmt.Errorf("%w: stored energy of device %s", ErrValueNotAvailable, deviceID)
+	}
+
+	stored := *d.CounterReading * factor
+	state := &BatteryState{
+		DeviceID:         deviceID,
+		StoredEnergy:     stored,
+		Capacity:         capacity,
+		StateOfCharge:    stateOfCharge(stored, capacity),
+		ChargedEnergy:    scaled(d.CounterReadingImport, factor),
+		DischargedEnergy: scaled(d.CounterReadingExport, factor),
+	}
+	if p, err := d.ActivePowerWatts(); err == nil {
+		state.Power = &p
+	}
+	return state, nil
+}
+
+// GetBatteryHistory returns the state of charge of a virtual battery
+// between start and end. The counter history of a virtual battery is its
+// stored energy, in the counter unit of the device.
+func (c *Client) GetBatteryHistory(ctx context.Context, deviceID string, start, end time.Time, capacity float64, opts ...CallOption) ([]BatterySample, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be positive, got %g", capacity)
+	}
+	_, factor, err := c.getBattery(ctx, deviceID, opts)
+	if err != nil {
+		return nil, err
+	}
+	values, err := c.GetValuesInPastMultiple(ctx, deviceID, start, end, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]BatterySample, 0, len(values))
+	for _, v := range values {
+		stored := v.Value * factor
+		samples = append(samples, BatterySample{
+			Date:          v.Date,
+			StoredEnergy:  stored,
+			StateOfCharge: stateOfCharge(stored, capacity),
+		})
+	}
+	return samples, nil
+}
+
+// getBattery returns a virtual battery meter and the factor from its
+// counter unit to kWh.
+func (c *Client) getBattery(ctx context.Context, deviceID string, opts []CallOption) (*Device, float64, error) {
+	d, err := c.GetDevice(ctx, deviceID, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if d.MeterSubType == nil || *d.MeterSubType != MeterSubTypeVirtualBattery {
+		return nil, 0, fmt.Errorf("device %s is not a virtual battery", deviceID)
+	}
+	factor, err := unitFactor("stored energy", deviceID, d.CounterReadingUnit, "kWh", counterUnits)
+	if err != nil {
+		return nil, 0, err
+	}
+	return d, factor, nil
+}
+
+func scaled(v *float64, factor float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	x := *v * factor
+	return &x
+}
+
+func stateOfCharge(stored, capacity float64) float64 {
+	return min(max(stored/capacity, 0), 1)
+}
@@ -0,0 +1,94 @@
+package smartme_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_Battery(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	battery := srv.AddDevice(smartme.Device{
+		MeterSubType:         ptr(smartme.MeterSubTypeVirtualBattery),
+		CounterReading:       ptr(7.5),
+		CounterReadingUnit:   ptr("kWh"),
+		CounterReadingImport: ptr(120.0),
+		CounterReadingExport: ptr(110.0),
+		ActivePower:          ptr(-800.0),
+	})
+	meter := srv.AddDevice(smartme.Device{CounterReading: ptr(1.0)})
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddHistory(battery,
+		smartme.Value{Date: start, Value: 2},
+		smartme.Value{Date: start.Add(time.Hour), Value: 12},
+	)
+
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	state, err := client.GetBatteryState(ctx, battery, 10)
+	if err != nil {
+		t.Fatalf("GetBatteryState failed: %v", err)
+	}
+	if state.StateOfCharge != 0.75 || *state.ChargedEnergy != 120 || *state.Power != -800 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	if _, err := client.GetBatteryState(ctx, meter, 10); err == nil {
+		t.Error("GetBatteryState accepted a device that is no battery")
+	}
+	if _, err := client.GetBatteryState(ctx, battery, 0); err == nil {
+		t.Error("GetBatteryState accepted a zero capacity")
+	}
+
+	samples, err := client.GetBatteryHistory(ctx, battery, start, start.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("GetBatteryHistory failed: %v", err)
+	}
+	// The second sample exceeds the capacity and is clamped.
+	if len(samples) != 2 || samples[0].StateOfCharge != 0.2 || samples[1].StateOfCharge != 1 {
+		t.Errorf("unexpected samples: %+v", samples)
+	}
+}
+
+func TestClient_BatteryWh(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	battery := srv.AddDevice(smartme.Device{
+		MeterSubType:         ptr(smartme.MeterSubTypeVirtualBattery),
+		CounterReading:       ptr(7500.0),
+		CounterReadingUnit:   ptr("Wh"),
+		CounterReadingImport: ptr(120000.0),
+	})
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddHistory(battery,
+		smartme.Value{Date: start, Value: 2000},
+		smartme.Value{Date: start.Add(time.Hour), Value: 5000},
+	)
+
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	state, err := client.GetBatteryState(ctx, battery, 10)
+	if err != nil {
+		t.Fatalf("GetBatteryState failed: %v", err)
+	}
+	if state.StoredEnergy != 7.5 || state.StateOfCharge != 0.75 || *state.ChargedEnergy != 120 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	samples, err := client.GetBatteryHistory(ctx, battery, start, start.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("GetBatteryHistory failed: %v", err)
+	}
+	if len(samples) != 2 || samples[0].StoredEnergy != 2 || samples[1].StateOfCharge != 0.5 {
+		t.Errorf("unexpected samples: %+v", samples)
+	}
+}
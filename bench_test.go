@@ -0,0 +1,142 @@
+package smartme_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+// staticTransport answers every request with the same body without any
+// network I/O, so benchmarks measure only the client itself.
+type staticTransport struct {
+	body []byte
+}
+
+func (t *staticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Status:        "200 OK",
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(t.body)),
+		ContentLength: int64(len(t.body)),
+		Request:       req,
+	}, nil
+}
+
+func newStaticClient(tb testing.TB, v interface{}) *smartme.Client {
+	body, err := json.Marshal(v)
+	if err != nil {
+		tb.Fatalf("Marshal failed: %v", err)
+	}
+	client, err := smartme.NewClient("user", "pass",
+		smartme.WithHTTPClient(&http.Client{Transport: &staticTransport{body: body}}))
+	if err != nil {
+		tb.Fatalf("NewClient failed: %v", err)
+	}
+	return client
+}
+
+func generateValues(n int) []smartme.Value {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := make([]smartme.Value, n)
+	for i := range values {
+		values[i] = smartme.Value{Date: start.Add(time.Duration(i) * time.Minute), Value: 1000 + float64(i)*0.01}
+	}
+	return values
+}
+
+func BenchmarkGetDevices(b *testing.B) {
+	for _, n := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("devices=%d", n), func(b *testing.B) {
+			client := newStaticClient(b, smartmetest.GenerateDevices(n))
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.GetDevices(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetValues(b *testing.B) {
+	client := newStaticClient(b, smartme.DeviceValues{
+		DeviceID: "dev",
+		Date:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Values: []smartme.ObisValue{
+			{Obis: smartme.ObisActiveEnergyImport, Value: 12345.678},
+			{Obis: smartme.ObisActiveEnergyExport, Value: 234.5},
+			{Obis: smartme.ObisActivePower, Value: 1500},
+			{Obis: smartme.ObisVoltageL1, Value: 230.1},
+			{Obis: smartme.ObisCurrentL1, Value: 6.5},
+		},
+	})
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.GetValues(ctx, "dev"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetValuesInPastMultiple(b *testing.B) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, n := range []int{10_000, 1_000_000} {
+		client := newStaticClient(b, generateValues(n))
+		ctx := context.Background()
+
+		b.Run(fmt.Sprintf("values=%d/buffered", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.GetValuesInPastMultiple(ctx, "dev", start, start); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("values=%d/streamed", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err := client.StreamValuesInPastMultiple(ctx, "dev", start, start, func(smartme.Value) error { return nil })
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestAllocs_GetValues guards against allocation regressions in the hot
+// path used by collectors polling many devices. The limit has some
+// headroom over the measured baseline (see README).
+func TestAllocs_GetValues(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping allocation guard in short mode")
+	}
+	client := newStaticClient(t, smartme.DeviceValues{
+		DeviceID: "dev",
+		Values:   []smartme.ObisValue{{Obis: smartme.ObisActivePower, Value: 1500}},
+	})
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := client.GetValues(ctx, "dev"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	const maxAllocs = 60
+	if allocs > maxAllocs {
+		t.Errorf("GetValues allocates %.0f times per call, want <= %d", allocs, maxAllocs)
+	}
+}
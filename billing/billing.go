@@ -0,0 +1,238 @@
+// Package billing creates billing period reports for tenants with their
+// own meters, as property managers need them for the utility bill.
+//
+//	report, err := billing.Generate(ctx, client, tenants, billing.Period{Start: start, End: end}, tariff)
+//	report.WriteCSV(os.Stdout)
+//
+// Readings are the counter values of the meters at the period boundaries,
+// so consumption is exact even if the meters report irregularly. With
+// Tariff.RegisterPrices the tariff registers T1–T4 are read instead of the
+// total counter and billed at their own prices, e.g. for HT/NT tariffs
+// switched by smartme.Client.RunTariffSchedule.
+package billing
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Tenant is a billed party with its meter.
+type Tenant struct {
+	Name     string
+	DeviceID string
+}
+
+// Period is a half-open billing period [Start, End). Use smartme.MonthRange
+// for monthly periods.
+type Period = smartme.Period
+
+// Tariff holds the prices of a billing period.
+type Tariff struct {
+	// Currency is printed in the CSV header, e.g. "CHF".
+	Currency string
+	// EnergyPrice is the price per kWh.
+	EnergyPrice float64
+	// RegisterPrices, if set, are the prices per kWh of the tariff
+	// registers, starting with T1; at most 4. Each register is billed at
+	// its price and EnergyPrice is not used.
+	RegisterPrices []float64
+	// BaseFee is charged once per tenant and period.
+	BaseFee float64
+}
+
+// Bill is the result for one tenant.
+type Bill struct {
+	Tenant Tenant
+	// StartReading and EndReading are the counter readings in kWh. With
+	// register prices they are the sums of the registers.
+	StartReading, EndReading float64
+	// Consumption is the energy used in the period in kWh.
+	Consumption float64
+	// Registers holds the consumption per register if the tariff has
+	// register prices.
+	Registers  []RegisterBill
+	EnergyCost float64
+	BaseFee    float64
+	Total      float64
+}
+
+// RegisterBill is the consumption of one tariff register.
+type RegisterBill struct {
+	// Register is the tariff number, 1 for T1.
+	Register int
+	// StartReading and EndReading are the register readings in kWh.
+	StartReading, EndReading float64
+	Consumption              float64
+	EnergyCost               float64
+}
+
+// Report is the billing report of a period.
+type Report struct {
+	Period Period
+	Tariff Tariff
+	Bills  []Bill
+	// Consumption and Total are the sums over all bills.
+	Consumption float64
+	Total       float64
+}
+
+// Generate reads the meters of all tenants at the period boundaries and
+// computes their bills. It fails if any meter cannot be read, since an
+// incomplete bill is of no use.
+func Generate(ctx context.Context, api smartme.API, tenants []Tenant, period Period, tariff Tariff) (*Report, error) {
+	if !period.End.After(period.Start) {
+		return nil, errors.New("billing period must end after it starts")
+	}
+
+	if len(tariff.RegisterPrices) > 4 {
+		return nil, fmt.Errorf("%d register prices, at most 4 tariff registers exist", len(tariff.RegisterPrices))
+	}
+
+	report := &Report{Period: period, Tariff: tariff, Bills: make([]Bill, 0, len(tenants))}
+	for _, tenant := range tenants {
+		var (
+			bill Bill
+			err  error
+		)
+		if len(tariff.RegisterPrices) > 0 {
+			bill, err = registerBill(ctx, api, tenant, period, tariff)
+		} else {
+			bill, err = totalBill(ctx, api, tenant, period, tariff)
+		}
+		if err != nil {
+			return nil, err
+		}
+		bill.Tenant = tenant
+		bill.BaseFee = tariff.BaseFee
+		bill.Total = roundCents(bill.EnergyCost + bill.BaseFee)
+		report.Bills = append(report.Bills, bill)
+		report.Consumption += bill.Consumption
+		report.Total += bill.Total
+	}
+	report.Total = roundCents(report.Total)
+	return report, nil
+}
+
+// totalBill bills the total counter at the energy price.
+func totalBill(ctx context.Context, api smartme.API, tenant Tenant, period Period, tariff Tariff) (Bill, error) {
+	start, err := api.GetValuesInPast(ctx, tenant.DeviceID, period.Start)
+	if err != nil {
+		return Bill{}, fmt.Errorf("tenant %s: reading at start: %w", tenant.Name, err)
+	}
+	end, err := api.GetValuesInPast(ctx, tenant.DeviceID, period.End)
+	if err != nil {
+		return Bill{}, fmt.Errorf("tenant %s: reading at end: %w", tenant.Name, err)
+	}
+	consumption := end.Value - start.Value
+	if consumption < 0 {
+		return Bill{}, fmt.Errorf("tenant %s: counter went backwards from %g to %g kWh", tenant.Name, start.Value, end.Value)
+	}
+	return Bill{
+		StartReading: start.Value,
+		EndReading:   end.Value,
+		Consumption:  consumption,
+		EnergyCost:   roundCents(consumption * tariff.EnergyPrice),
+	}, nil
+}
+
+// registerBill bills each tariff register at its own price.
+func registerBill(ctx context.Context, api smartme.API, tenant Tenant, period Period, tariff Tariff) (Bill, error) {
+	start, err := api.GetValuesAt(ctx, tenant.DeviceID, period.Start)
+	if err != nil {
+		return Bill{}, fmt.Errorf("tenant %s: readings at start: %w", tenant.Name, err)
+	}
+	end, err := api.GetValuesAt(ctx, tenant.DeviceID, period.End)
+	if err != nil {
+		return Bill{}, fmt.Errorf("tenant %s: readings at end: %w", tenant.Name, err)
+	}
+
+	var bill Bill
+	for i, price := range tariff.RegisterPrices {
+		register := i + 1
+		obis := fmt.Sprintf("1-0:1.8.%d*255", register)
+		from, ok := obisValue(start, obis)
+		if !ok {
+			return Bill{}, fmt.Errorf("tenant %s: no reading of T%d at start", tenant.Name, register)
+		}
+		to, ok := obisValue(end, obis)
+		if !ok {
+			return Bill{}, fmt.Errorf("tenant %s: no reading of T%d at end", tenant.Name, register)
+		}
+		consumption := to - from
+		if consumption < 0 {
+			return Bill{}, fmt.Errorf("tenant %s: T%d went backwards from %g to %g kWh", tenant.Name, register, from, to)
+		}
+		rb := RegisterBill{
+			Register:     register,
+			StartReading: from,
+			EndReading:   to,
+			Consumption:  consumption,
+			EnergyCost:   roundCents(consumption * price),
+		}
+		bill.Registers = append(bill.Registers, rb)
+		bill.StartReading += from
+		bill.EndReading += to
+		bill.Consumption += consumption
+		bill.EnergyCost += rb.EnergyCost
+	}
+	bill.EnergyCost = roundCents(bill.EnergyCost)
+	return bill, nil
+}
+
+func obisValue(dv *smartme.DeviceValues, obis string) (float64, bool) {
+	for _, v := range dv.Values {
+		if smartme.NormalizeObis(v.Obis) == obis {
+			return v.Value, true
+		}
+	}
+	return 0, false
+}
+
+// WriteCSV writes one line per bill with a header line.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cur := r.Tariff.Currency
+	header := []string{
+		"tenant", "device", "start", "end",
+		"start reading (kWh)", "end reading (kWh)", "consumption (kWh)",
+		"energy cost (" + cur + ")", "base fee (" + cur + ")", "total (" + cur + ")",
+	}
+	for i := range r.Tariff.RegisterPrices {
+		header = append(header, fmt.Sprintf("T%d consumption (kWh)", i+1), fmt.Sprintf("T%d energy cost (%s)", i+1, cur))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, b := range r.Bills {
+		record := []string{
+			b.Tenant.Name, b.Tenant.DeviceID,
+			r.Period.Start.Format(time.RFC3339), r.Period.End.Format(time.RFC3339),
+			formatFloat(b.StartReading, 3), formatFloat(b.EndReading, 3), formatFloat(b.Consumption, 3),
+			formatFloat(b.EnergyCost, 2), formatFloat(b.BaseFee, 2), formatFloat(b.Total, 2),
+		}
+		for _, rb := range b.Registers {
+			record = append(record, formatFloat(rb.Consumption, 3), formatFloat(rb.EnergyCost, 2))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func formatFloat(v float64, decimals int) string {
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
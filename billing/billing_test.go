@@ -0,0 +1,119 @@
+package billing_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/billing"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestGenerate(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	start, end := smartme.MonthRange(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), time.UTC)
+	readings := map[string][2]float64{"apt-1": {1000, 1250.5}, "apt-2": {500, 580}}
+	for id, r := range readings {
+		srv.AddHistory(id,
+			smartme.Value{Date: start.Add(-time.Minute), Value: r[0]},
+			smartme.Value{Date: end.Add(-time.Minute), Value: r[1]},
+		)
+	}
+	client, _ := srv.Client()
+
+	tenants := []billing.Tenant{{Name: "Muster", DeviceID: "apt-1"}, {Name: "Beispiel", DeviceID: "apt-2"}}
+	tariff := billing.Tariff{Currency: "CHF", EnergyPrice: 0.3, BaseFee: 5}
+	report, err := billing.Generate(context.Background(), client, tenants, billing.Period{Start: start, End: end}, tariff)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if len(report.Bills) != 2 {
+		t.Fatalf("%d bills, want 2", len(report.Bills))
+	}
+	b := report.Bills[0]
+	if b.Consumption != 250.5 || b.EnergyCost != 75.15 || b.Total != 80.15 {
+		t.Errorf("unexpected bill: %+v", b)
+	}
+	if report.Consumption != 330.5 || report.Total != 109.15 {
+		t.Errorf("totals = %v kWh, %v CHF", report.Consumption, report.Total)
+	}
+
+	var sb strings.Builder
+	if err := report.WriteCSV(&sb); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "total (CHF)") {
+		t.Fatalf("unexpected CSV:\n%s", sb.String())
+	}
+	want := "Muster,apt-1,2025-01-01T00:00:00Z,2025-02-01T00:00:00Z,1000.000,1250.500,250.500,75.15,5.00,80.15"
+	if lines[1] != want {
+		t.Errorf("CSV line = %q, want %q", lines[1], want)
+	}
+}
+
+func TestGenerate_RegisterPrices(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	start, end := smartme.MonthRange(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), time.UTC)
+	snapshot := func(date time.Time, t1, t2 float64) smartme.DeviceValues {
+		return smartme.DeviceValues{DeviceID: "apt-1", Date: date, Values: []smartme.ObisValue{
+			{Obis: smartme.ObisActiveEnergyImport, Value: t1 + t2},
+			{Obis: smartme.ObisActiveEnergyImportT1, Value: t1},
+			{Obis: smartme.ObisActiveEnergyImportT2, Value: t2},
+		}}
+	}
+	srv.AddPastValues(snapshot(start.Add(-time.Minute), 600, 400), snapshot(end.Add(-time.Minute), 700, 450))
+	client, _ := srv.Client()
+
+	tariff := billing.Tariff{Currency: "CHF", RegisterPrices: []float64{0.3, 0.2}, BaseFee: 5}
+	report, err := billing.Generate(context.Background(), client,
+		[]billing.Tenant{{Name: "Muster", DeviceID: "apt-1"}}, billing.Period{Start: start, End: end}, tariff)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	b := report.Bills[0]
+	if len(b.Registers) != 2 || b.Registers[0].Consumption != 100 || b.Registers[1].Consumption != 50 {
+		t.Fatalf("registers = %+v, want 100 and 50 kWh", b.Registers)
+	}
+	if b.Consumption != 150 || b.EnergyCost != 40 || b.Total != 45 {
+		t.Errorf("unexpected bill: %+v", b)
+	}
+
+	var sb strings.Builder
+	if err := report.WriteCSV(&sb); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if !strings.HasSuffix(lines[0], "T2 energy cost (CHF)") || !strings.HasSuffix(lines[1], ",100.000,30.00,50.000,10.00") {
+		t.Errorf("unexpected CSV:\n%s", sb.String())
+	}
+
+	tariff.RegisterPrices = []float64{0.3, 0.2, 0.1}
+	_, err = billing.Generate(context.Background(), client,
+		[]billing.Tenant{{Name: "Muster", DeviceID: "apt-1"}}, billing.Period{Start: start, End: end}, tariff)
+	if err == nil || !strings.Contains(err.Error(), "T3") {
+		t.Errorf("error = %v, want an error for the missing T3", err)
+	}
+}
+
+func TestGenerate_MissingReading(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := billing.Generate(context.Background(), client,
+		[]billing.Tenant{{Name: "Muster", DeviceID: "apt-1"}},
+		billing.Period{Start: start, End: start.AddDate(0, 1, 0)}, billing.Tariff{})
+	if err == nil || !strings.Contains(err.Error(), "Muster") {
+		t.Errorf("error = %v, want an error naming the tenant", err)
+	}
+}
@@ -0,0 +1,108 @@
+package billing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrChecksumMismatch is returned by Manifest.Verify if an exported file
+// has been altered.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ManifestEntry describes one exported file, so auditors can verify that
+// it has not been altered afterwards.
+type ManifestEntry struct {
+	// File is the file name, relative to the manifest.
+	File    string    `json:"file"`
+	Devices []string  `json:"devices"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// Rows is the number of data rows, without the header.
+	Rows int `json:"rows"`
+	// SHA256 is the hex encoded SHA-256 checksum of the file.
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists exported files with their checksums.
+type Manifest struct {
+	Files []ManifestEntry `json:"files"`
+}
+
+// WriteCSVWithChecksum is like WriteCSV, but also returns the manifest
+// entry of the written data for the given file name.
+func (r *Report) WriteCSVWithChecksum(w io.Writer, file string) (ManifestEntry, error) {
+	h := sha256.New()
+	if err := r.WriteCSV(io.MultiWriter(w, h)); err != nil {
+		return ManifestEntry{}, err
+	}
+	devices := make([]string, len(r.Bills))
+	for i, b := range r.Bills {
+		devices[i] = b.Tenant.DeviceID
+	}
+	return ManifestEntry{
+		File:    file,
+		Devices: devices,
+		Start:   r.Period.Start,
+		End:     r.Period.End,
+		Rows:    len(r.Bills),
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// Add appends an entry to the manifest.
+func (m *Manifest) Add(e ManifestEntry) {
+	m.Files = append(m.Files, e)
+}
+
+// Write writes the manifest as indented JSON.
+func (m *Manifest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// ReadManifest reads a manifest written by Manifest.Write.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Verify checks the files of the manifest in dir against their checksums.
+// All altered or missing files are reported.
+func (m *Manifest) Verify(dir string) error {
+	var errs []error
+	for _, e := range m.Files {
+		sum, err := fileChecksum(filepath.Join(dir, e.File))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if sum != e.SHA256 {
+			errs = append(errs, fmt.Errorf("%s: %w", e.File, ErrChecksumMismatch))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,57 @@
+package billing_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client/billing"
+)
+
+func TestManifest(t *testing.T) {
+	report := &billing.Report{
+		Period: billing.Period{Start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		Tariff: billing.Tariff{Currency: "CHF", EnergyPrice: 0.3},
+		Bills: []billing.Bill{
+			{Tenant: billing.Tenant{Name: "Muster", DeviceID: "apt-1"}, Consumption: 10, EnergyCost: 3, Total: 3},
+		},
+	}
+
+	dir := t.TempDir()
+	var csv bytes.Buffer
+	entry, err := report.WriteCSVWithChecksum(&csv, "2025-01.csv")
+	if err != nil {
+		t.Fatalf("WriteCSVWithChecksum failed: %v", err)
+	}
+	if entry.Rows != 1 || len(entry.Devices) != 1 || entry.Devices[0] != "apt-1" || len(entry.SHA256) != 64 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if err := os.WriteFile(filepath.Join(dir, entry.File), csv.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var m billing.Manifest
+	m.Add(entry)
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := billing.ReadManifest(&buf)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if err := read.Verify(dir); err != nil {
+		t.Errorf("Verify of unchanged files failed: %v", err)
+	}
+
+	altered := bytes.Replace(csv.Bytes(), []byte("10.000"), []byte("1.000"), 1)
+	if err := os.WriteFile(filepath.Join(dir, entry.File), altered, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := read.Verify(dir); !errors.Is(err, billing.ErrChecksumMismatch) {
+		t.Errorf("Verify of altered file returned %v, want ErrChecksumMismatch", err)
+	}
+}
@@ -0,0 +1,218 @@
+package billing
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Meter is a node of a meter hierarchy: a meter with the submeters behind
+// it, e.g. the main meter of a building with the meters of the flats.
+type Meter struct {
+	DeviceID  string
+	Submeters []Meter
+}
+
+// Tolerance is the difference between a meter and the sum of its
+// submeters that is accepted as losses and measurement error. The larger
+// of both limits applies.
+type Tolerance struct {
+	// Relative is a fraction of the meter's consumption, e.g. 0.02 for 2%.
+	Relative float64
+	// Absolute is in kWh and covers intervals with little consumption.
+	Absolute float64
+}
+
+// allows reports whether diff is within the tolerance for consumption.
+func (t Tolerance) allows(consumption, diff float64) bool {
+	return math.Abs(diff) <= math.Max(t.Absolute, t.Relative*math.Abs(consumption))
+}
+
+// BalanceInterval compares a meter with its submeters in one interval.
+type BalanceInterval struct {
+	Period
+	// Meter and Submeters are the consumption in kWh of the meter and the
+	// sum of its submeters.
+	Meter, Submeters float64
+	// Difference is Meter minus Submeters. A positive difference is energy
+	// no submeter measured, e.g. a missing submeter; a negative one points
+	// to a miswired or double counted submeter.
+	Difference float64
+	// OK is true if the difference is within the tolerance.
+	OK bool
+	// Missing lists the meters without readings for the interval. The
+	// interval is not checked then and OK is false.
+	Missing []string
+}
+
+// Balance is the reconciliation of one meter with its submeters.
+type Balance struct {
+	DeviceID  string
+	Submeters []string
+	Intervals []BalanceInterval
+}
+
+// Discrepancies returns the intervals that are not OK.
+func (b *Balance) Discrepancies() []BalanceInterval {
+	var out []BalanceInterval
+	for _, in := range b.Intervals {
+		if !in.OK {
+			out = append(out, in)
+		}
+	}
+	return out
+}
+
+// Reconcile checks for every meter of the hierarchy with submeters whether
+// the submeters add up to the meter within tol, per interval of period:
+//
+//	balances, err := billing.Reconcile(ctx, client, building, billing.Period{Start: start, End: end}, time.Hour, billing.Tolerance{Relative: 0.02, Absolute: 0.1})
+//	for _, b := range balances {
+//		for _, d := range b.Discrepancies() {
+//			log.Printf("%s %s: %.1f kWh unaccounted", b.DeviceID, d.Start, d.Difference)
+//		}
+//	}
+//
+// An interval of zero checks the whole period at once. Consumption is the
+// difference of the last readings at or before the interval boundaries. A
+// meter without a reading in the interval length before a boundary has no
+// reliable consumption and is reported as missing.
+func Reconcile(ctx context.Context, api smartme.API, root Meter, period Period, interval time.Duration, tol Tolerance) ([]Balance, error) {
+	if !period.End.After(period.Start) {
+		return nil, errors.New("period must end after it starts")
+	}
+	if interval <= 0 {
+		interval = period.End.Sub(period.Start)
+	}
+	var bounds []time.Time
+	for t := period.Start; t.Before(period.End); t = t.Add(interval) {
+		bounds = append(bounds, t)
+	}
+	bounds = append(bounds, period.End)
+
+	r := reconciler{api: api, bounds: bounds, interval: interval, readings: make(map[string][]*smartme.Value)}
+	var balances []Balance
+	var walk func(m Meter) error
+	walk = func(m Meter) error {
+		if len(m.Submeters) == 0 {
+			return nil
+		}
+		b, err := r.balance(ctx, m, tol)
+		if err != nil {
+			return err
+		}
+		balances = append(balances, b)
+		for _, sub := range m.Submeters {
+			if err := walk(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// reconciler reads every meter once for all balances it appears in.
+type reconciler struct {
+	api      smartme.API
+	bounds   []time.Time
+	interval time.Duration
+	// readings holds the reading at every boundary per device, nil where
+	// there is none.
+	readings map[string][]*smartme.Value
+}
+
+func (r *reconciler) balance(ctx context.Context, m Meter, tol Tolerance) (Balance, error) {
+	b := Balance{DeviceID: m.DeviceID}
+	for _, sub := range m.Submeters {
+		b.Submeters = append(b.Submeters, sub.DeviceID)
+	}
+	for i := 1; i < len(r.bounds); i++ {
+		b.Intervals = append(b.Intervals, BalanceInterval{Period: Period{Start: r.bounds[i-1], End: r.bounds[i]}})
+	}
+
+	for _, id := range append([]string{m.DeviceID}, b.Submeters...) {
+		readings, err := r.read(ctx, id)
+		if err != nil {
+			return Balance{}, err
+		}
+		for i := range b.Intervals {
+			in := &b.Intervals[i]
+			start, end := readings[i], readings[i+1]
+			if start == nil || end == nil {
+				in.Missing = append(in.Missing, id)
+				continue
+			}
+			if id == m.DeviceID {
+				in.Meter = end.Value - start.Value
+			} else {
+				in.Submeters += end.Value - start.Value
+			}
+		}
+	}
+	for i := range b.Intervals {
+		in := &b.Intervals[i]
+		in.Difference = in.Meter - in.Submeters
+		in.OK = len(in.Missing) == 0 && tol.allows(in.Meter, in.Difference)
+	}
+	return b, nil
+}
+
+// read returns the readings of a device at the boundaries.
+func (r *reconciler) read(ctx context.Context, id string) ([]*smartme.Value, error) {
+	if readings, ok := r.readings[id]; ok {
+		return readings, nil
+	}
+	first, last := r.bounds[0], r.bounds[len(r.bounds)-1]
+	history, err := r.api.GetValuesInPastMultiple(ctx, id, first.Add(-r.interval), last)
+	if err != nil {
+		return nil, fmt.Errorf("meter %s: %w", id, err)
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Date.Before(history[j].Date) })
+
+	readings := make([]*smartme.Value, len(r.bounds))
+	for i, t := range r.bounds {
+		n := sort.Search(len(history), func(k int) bool { return history[k].Date.After(t) })
+		if n == 0 {
+			continue
+		}
+		if v := history[n-1]; t.Sub(v.Date) < r.interval {
+			readings[i] = &v
+		}
+	}
+	r.readings[id] = readings
+	return readings, nil
+}
+
+// WriteCSV writes one line per interval with a header line.
+func (b *Balance) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"meter", "start", "end", "meter (kWh)", "submeters (kWh)", "difference (kWh)", "ok", "missing"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, in := range b.Intervals {
+		record := []string{
+			b.DeviceID, in.Start.Format(time.RFC3339), in.End.Format(time.RFC3339),
+			formatFloat(in.Meter, 3), formatFloat(in.Submeters, 3), formatFloat(in.Difference, 3),
+			strconv.FormatBool(in.OK), strings.Join(in.Missing, " "),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
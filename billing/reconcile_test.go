@@ -0,0 +1,87 @@
+package billing_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/billing"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestReconcile(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Counter readings at 00:00, 01:00, 02:00 and 03:00. In the second
+	// hour 2 kWh are not measured by the submeters of main; sub-b stops
+	// reporting after 02:00.
+	readings := map[string][]float64{
+		"main":  {100, 110, 122, 130},
+		"sub-a": {50, 54, 58, 61},
+		"sub-b": {20, 26, 32},
+		"sub-c": {10, 13, 16, 18},
+		"sub-d": {5, 8, 11, 14},
+	}
+	for id, values := range readings {
+		for i, v := range values {
+			srv.AddHistory(id, smartme.Value{Date: start.Add(time.Duration(i) * time.Hour), Value: v})
+		}
+	}
+	client, _ := srv.Client()
+
+	root := billing.Meter{DeviceID: "main", Submeters: []billing.Meter{
+		{DeviceID: "sub-a"},
+		{DeviceID: "sub-b", Submeters: []billing.Meter{{DeviceID: "sub-c"}, {DeviceID: "sub-d"}}},
+	}}
+	period := billing.Period{Start: start, End: start.Add(3 * time.Hour)}
+	balances, err := billing.Reconcile(context.Background(), client, root, period, time.Hour, billing.Tolerance{Relative: 0.05, Absolute: 0.1})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(balances) != 2 || balances[0].DeviceID != "main" || balances[1].DeviceID != "sub-b" {
+		t.Fatalf("balances = %+v, want main and sub-b", balances)
+	}
+
+	main := balances[0]
+	if len(main.Intervals) != 3 {
+		t.Fatalf("%d intervals, want 3", len(main.Intervals))
+	}
+	if in := main.Intervals[0]; !in.OK || in.Meter != 10 || in.Submeters != 10 {
+		t.Errorf("first hour = %+v, want balanced", in)
+	}
+	if in := main.Intervals[1]; in.OK || in.Difference != 2 {
+		t.Errorf("second hour = %+v, want 2 kWh unaccounted", in)
+	}
+	if in := main.Intervals[2]; in.OK || len(in.Missing) != 1 || in.Missing[0] != "sub-b" {
+		t.Errorf("third hour = %+v, want sub-b missing", in)
+	}
+	if d := main.Discrepancies(); len(d) != 2 {
+		t.Errorf("%d discrepancies, want 2", len(d))
+	}
+
+	sub := balances[1]
+	if in := sub.Intervals[1]; !in.OK || in.Meter != 6 || in.Submeters != 6 {
+		t.Errorf("sub-b second hour = %+v, want balanced", in)
+	}
+
+	var sb strings.Builder
+	if err := main.WriteCSV(&sb); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	want := "main,2025-01-01T01:00:00Z,2025-01-01T02:00:00Z,12.000,10.000,2.000,false,"
+	if len(lines) != 4 || lines[2] != want {
+		t.Errorf("CSV =\n%s\nwant second interval %q", sb.String(), want)
+	}
+}
+
+func TestReconcile_InvalidPeriod(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := billing.Reconcile(context.Background(), nil, billing.Meter{}, billing.Period{Start: start, End: start}, 0, billing.Tolerance{}); err == nil {
+		t.Error("expected an error for an empty period")
+	}
+}
@@ -0,0 +1,223 @@
+package smartme
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Range is an inclusive plausibility range.
+type Range struct {
+	Min, Max float64
+}
+
+// Bounds holds plausibility ranges for the values returned by the client,
+// so corrupt outliers of flaky meters are caught before they poison
+// aggregates. Use it with WithBounds:
+//
+//	bounds := smartme.NewBounds().
+//		Set(smartme.MeterTypeElectricity, "activePower", -100000, 100000).
+//		Set(smartme.MeterTypeAllMeters, "voltage", 180, 260)
+//	bounds.Drop = true
+//	client, err := smartme.NewClient(user, pass, smartme.WithBounds(bounds))
+//
+// Historical values of GetValuesInPast, GetValuesInPastMultiple and
+// StreamValuesInPastMultiple are counter readings and are checked against
+// the range of "counterReading". History carries no energy type, so the
+// range of the device's type is used once the device has been read with
+// GetDevice or GetDevices, and the MeterTypeAllMeters range before that.
+type Bounds struct {
+	// Drop removes out-of-range values from responses: Device fields are
+	// set to nil, ObisValues and history values are removed, and
+	// GetValuesInPast fails with ErrNoData. Otherwise they are kept and
+	// only reported.
+	Drop bool
+	// OnViolation, if set, is called for every out-of-range value.
+	OnViolation func(Violation)
+
+	mu     sync.RWMutex
+	ranges map[boundKey]Range
+	// energyTypes holds the energy type of the devices seen so far, to
+	// check their history.
+	energyTypes map[string]MeterEnergyType
+}
+
+type boundKey struct {
+	energyType MeterEnergyType
+	quantity   string
+}
+
+// Violation is a value outside its plausibility range.
+type Violation struct {
+	DeviceID string
+	// Quantity is the JSON name of a Device field or an OBIS code.
+	Quantity string
+	Value    float64
+	Range    Range
+	// Dropped is true if the value was removed from the response.
+	Dropped bool
+}
+
+// Reason describes the violation, e.g. "voltage 12 below minimum 180".
+func (v Violation) Reason() string {
+	switch {
+	case math.IsNaN(v.Value):
+		return fmt.Sprintf("%s is not a number", v.Quantity)
+	case v.Value < v.Range.Min:
+		return fmt.Sprintf("%s %v below minimum %v", v.Quantity, v.Value, v.Range.Min)
+	default:
+		return fmt.Sprintf("%s %v above maximum %v", v.Quantity, v.Value, v.Range.Max)
+	}
+}
+
+// NewBounds creates empty Bounds.
+func NewBounds() *Bounds {
+	return &Bounds{
+		ranges:      make(map[boundKey]Range),
+		energyTypes: make(map[string]MeterEnergyType),
+	}
+}
+
+// Set sets the range of a quantity for devices of an energy type and
+// returns b. The quantity is the JSON name of a Device field, like
+// "activePower" or "voltageL1", or an OBIS code of the device values.
+// Ranges for MeterTypeAllMeters apply to all devices without a more
+// specific range. Values of ObisValues are matched with the energy type
+// of their code in the OBIS catalog.
+func (b *Bounds) Set(energyType MeterEnergyType, quantity string, min, max float64) *Bounds {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if strings.Contains(quantity, ":") {
+		quantity = NormalizeObis(quantity)
+	}
+	b.ranges[boundKey{energyType, quantity}] = Range{Min: min, Max: max}
+	return b
+}
+
+// WithBounds checks the values of Device and DeviceValues responses and
+// the counter history against the plausibility ranges in bounds.
+func WithBounds(bounds *Bounds) Option {
+	return func(c *Client) error {
+		if bounds == nil {
+			return fmt.Errorf("bounds must not be nil")
+		}
+		c.bounds = bounds
+		return nil
+	}
+}
+
+func (b *Bounds) lookup(energyType MeterEnergyType, quantity string) (Range, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if r, ok := b.ranges[boundKey{energyType, quantity}]; ok {
+		return r, true
+	}
+	r, ok := b.ranges[boundKey{MeterTypeAllMeters, quantity}]
+	return r, ok
+}
+
+// allows reports whether v is within the range of the quantity. If not,
+// the violation is reported and the result tells whether to drop v.
+func (b *Bounds) allows(deviceID string, energyType MeterEnergyType, quantity string, v float64) bool {
+	r, ok := b.lookup(energyType, quantity)
+	if !ok || (v >= r.Min && v <= r.Max) {
+		return true
+	}
+	if b.OnViolation != nil {
+		b.OnViolation(Violation{DeviceID: deviceID, Quantity: quantity, Value: v, Range: r, Dropped: b.Drop})
+	}
+	return !b.Drop
+}
+
+// check applies the bounds to a decoded response of req.
+func (b *Bounds) check(req *http.Request, v interface{}) error {
+	if b == nil {
+		return nil
+	}
+	switch v := v.(type) {
+	case *Value:
+		deviceID := requestDeviceID(req)
+		if !b.allowsHistory(deviceID, v.Value) {
+			return fmt.Errorf("%w: implausible value of device %s", ErrNoData, deviceID)
+		}
+	case *[]Value:
+		deviceID := requestDeviceID(req)
+		kept := (*v)[:0]
+		for _, hv := range *v {
+			if b.allowsHistory(deviceID, hv.Value) {
+				kept = append(kept, hv)
+			}
+		}
+		*v = kept
+	case *DeviceValues:
+		b.checkValues(v)
+	case *[]DeviceValues:
+		for i := range *v {
+			b.checkValues(&(*v)[i])
+		}
+	case *Device:
+		b.checkDevice(v)
+	case *[]Device:
+		for i := range *v {
+			b.checkDevice(&(*v)[i])
+		}
+	}
+	return nil
+}
+
+// allowsHistory is allows for a historical counter reading of a device.
+func (b *Bounds) allowsHistory(deviceID string, v float64) bool {
+	b.mu.RLock()
+	energyType, ok := b.energyTypes[deviceID]
+	b.mu.RUnlock()
+	if !ok {
+		energyType = MeterTypeAllMeters
+	}
+	return b.allows(deviceID, energyType, "counterReading", v)
+}
+
+func (b *Bounds) checkValues(dv *DeviceValues) {
+	kept := dv.Values[:0]
+	for _, o := range dv.Values {
+		energyType := MeterTypeAllMeters
+		if info, ok := LookupObis(o.Obis); ok {
+			energyType = info.EnergyType
+		}
+		if b.allows(dv.DeviceID, energyType, NormalizeObis(o.Obis), o.Value) {
+			kept = append(kept, o)
+		}
+	}
+	dv.Values = kept
+}
+
+func (b *Bounds) checkDevice(d *Device) {
+	var deviceID string
+	if d.Id != nil {
+		deviceID = *d.Id
+	}
+	energyType := MeterTypeAllMeters
+	if d.DeviceEnergyType != nil {
+		energyType = *d.DeviceEnergyType
+		if deviceID != "" {
+			b.mu.Lock()
+			b.energyTypes[deviceID] = energyType
+			b.mu.Unlock()
+		}
+	}
+
+	rv := reflect.ValueOf(d).Elem()
+	rt := rv.Type()
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Field(i)
+		if f.Kind() != reflect.Ptr || f.IsNil() || f.Elem().Kind() != reflect.Float64 {
+			continue
+		}
+		name := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+		if !b.allows(deviceID, energyType, name, f.Elem().Float()) {
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestWithBounds_History(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	energyType := smartme.MeterTypeElectricity
+	id := srv.AddDevice(smartme.Device{DeviceEnergyType: &energyType, CounterReading: ptr(100.0)})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddHistory(id,
+		smartme.Value{Date: start, Value: 100},
+		smartme.Value{Date: start.Add(time.Hour), Value: 1e12},
+		smartme.Value{Date: start.Add(2 * time.Hour), Value: 102},
+	)
+
+	var violations []smartme.Violation
+	bounds := smartme.NewBounds().
+		Set(smartme.MeterTypeAllMeters, "counterReading", 0, 1e15).
+		Set(smartme.MeterTypeElectricity, "counterReading", 0, 1e9)
+	bounds.Drop = true
+	bounds.OnViolation = func(v smartme.Violation) { violations = append(violations, v) }
+	client, _ := srv.Client(smartme.WithBounds(bounds))
+	ctx := context.Background()
+	end := start.Add(3 * time.Hour)
+
+	// Before the device is known, the range for all meters applies.
+	values, err := client.GetValuesInPastMultiple(ctx, id, start, end)
+	if err != nil || len(values) != 3 {
+		t.Fatalf("GetValuesInPastMultiple = %d values, %v, want 3", len(values), err)
+	}
+
+	if _, err := client.GetDevice(ctx, id); err != nil {
+		t.Fatalf("GetDevice failed: %v", err)
+	}
+	values, err = client.GetValuesInPastMultiple(ctx, id, start, end)
+	if err != nil || len(values) != 2 || values[1].Value != 102 {
+		t.Errorf("GetValuesInPastMultiple = %v, %v, want the outlier dropped", values, err)
+	}
+
+	var streamed []float64
+	err = client.StreamValuesInPastMultiple(ctx, id, start, end, func(v smartme.Value) error {
+		streamed = append(streamed, v.Value)
+		return nil
+	})
+	if err != nil || len(streamed) != 2 {
+		t.Errorf("StreamValuesInPastMultiple = %v, %v, want the outlier dropped", streamed, err)
+	}
+
+	if _, err := client.GetValuesInPast(ctx, id, start.Add(90*time.Minute)); !errors.Is(err, smartme.ErrNoData) {
+		t.Errorf("GetValuesInPast of the outlier: error = %v, want ErrNoData", err)
+	}
+
+	if len(violations) != 3 || violations[0].Quantity != "counterReading" || violations[0].DeviceID != id {
+		t.Errorf("violations = %+v, want 3 for counterReading of %s", violations, id)
+	}
+}
@@ -0,0 +1,213 @@
+package smartme
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultBulkConcurrency is the number of parallel requests used by bulk
+// calls without WithConcurrency.
+const defaultBulkConcurrency = 8
+
+// BulkError is returned by bulk calls if the request failed for some devices.
+// The results of the other devices are still returned.
+type BulkError struct {
+	// Errors maps the device ID to the error of its request.
+	Errors map[string]error
+}
+
+func (e *BulkError) Error() string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("%s: %v", id, e.Errors[id]))
+	}
+	return fmt.Sprintf("%d of the requests failed: %s", len(ids), strings.Join(parts, "; "))
+}
+
+// GetValuesConcurrently retrieves the last values of several devices with
+// one GetValues call per device. The API has no call returning the OBIS
+// values of several devices at once (GetDevices only contains the main
+// values), so this saves time, not round trips: the calls run concurrently
+// with a bounded number of parallel requests.
+// The result is in the order of deviceIDs and only contains the devices
+// that could be fetched; failures are reported with a *BulkError. Empty
+// and duplicate IDs are rejected.
+func (c *Client) GetValuesConcurrently(ctx context.Context, deviceIDs []string, opts ...CallOption) ([]DeviceValues, error) {
+	results := make([]*DeviceValues, len(deviceIDs))
+	err := c.forEachDevice(ctx, deviceIDs, func(i int, id string) error {
+		values, err := c.GetValues(ctx, id, opts...)
+		results[i] = values
+		return err
+	})
+	if err != nil && !isBulkError(err) {
+		return nil, err
+	}
+
+	values := make([]DeviceValues, 0, len(deviceIDs))
+	for _, v := range results {
+		if v != nil {
+			values = append(values, *v)
+		}
+	}
+	return values, err
+}
+
+// forEachDevice calls fn concurrently for every device, with a bounded
+// number of parallel calls. fn gets the index of the device in deviceIDs.
+// Failed devices are reported with a *BulkError; if ctx is done, its
+// error is returned instead. Since errors are reported by ID, empty and
+// duplicate IDs are rejected before any call.
+func (c *Client) forEachDevice(ctx context.Context, deviceIDs []string, fn func(i int, id string) error) error {
+	seen := make(map[string]bool, len(deviceIDs))
+	for _, id := range deviceIDs {
+		if id == "" {
+			return fmt.Errorf("deviceID must not be empty")
+		}
+		if seen[id] {
+			return fmt.Errorf("device %s is listed twice", id)
+		}
+		seen[id] = true
+	}
+
+	errs := make(map[string]error)
+	var mu sync.Mutex
+
+	sem := make(chan struct{}, c.workers())
+	var wg sync.WaitGroup
+	for i, id := range deviceIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(i, id); err != nil {
+				mu.Lock()
+				errs[id] = err
+				mu.Unlock()
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &BulkError{Errors: errs}
+	}
+	return nil
+}
+
+func isBulkError(err error) bool {
+	_, ok := err.(*BulkError)
+	return ok
+}
+
+// DeviceSnapshot is a device together with its current values.
+type DeviceSnapshot struct {
+	Device Device
+	// Values is nil if they could not be fetched; Err says why.
+	Values *DeviceValues
+	Err    error
+	// Time is when the snapshot was taken, the same for all devices.
+	Time time.Time
+}
+
+// GetDevicesWithValues fetches all devices and, concurrently, the current
+// values of each, as dashboards need both. A failure to fetch the values of
+// a device is reported in its snapshot; the call only fails if the device
+// list cannot be fetched or ctx is done.
+func (c *Client) GetDevicesWithValues(ctx context.Context, opts ...CallOption) ([]DeviceSnapshot, error) {
+	now := c.clock.Now()
+	devices, err := c.GetDevices(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]DeviceSnapshot, len(devices))
+	ids := make([]string, len(devices))
+	for i, d := range devices {
+		snapshots[i] = DeviceSnapshot{Device: d, Time: now}
+		if d.Id != nil {
+			ids[i] = *d.Id
+		}
+	}
+
+	err = c.forEachDevice(ctx, ids, func(i int, id string) error {
+		values, err := c.GetValues(ctx, id, opts...)
+		snapshots[i].Values, snapshots[i].Err = values, err
+		return err
+	})
+	if err != nil && !isBulkError(err) {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// ExecuteActionsBulk executes actions on several devices, keyed by device
+// ID, concurrently with a bounded number of parallel requests, e.g. to
+// switch many relays within seconds for load shedding. Devices whose
+// actions failed are reported with a *BulkError; the others are done.
+// Call CheckActionsBulk first to make sure all actions can be performed.
+func (c *Client) ExecuteActionsBulk(ctx context.Context, actions map[string][]Action, opts ...CallOption) error {
+	ids := sortedKeys(actions)
+	return c.forEachDevice(ctx, ids, func(_ int, id string) error {
+		return c.PerformActions(ctx, id, actions[id], opts...)
+	})
+}
+
+// CheckActionsBulk is a dry run of ExecuteActionsBulk: it validates the
+// actions and fetches every device without changing anything. It fails
+// with a *BulkError listing the devices whose actions cannot be performed,
+// e.g. because the device does not exist or switch actions target a device
+// without switch, so callers can apply all actions or none.
+func (c *Client) CheckActionsBulk(ctx context.Context, actions map[string][]Action, opts ...CallOption) error {
+	if c.readOnly {
+		return ErrReadOnlyClient
+	}
+	ids := sortedKeys(actions)
+	return c.forEachDevice(ctx, ids, func(_ int, id string) error {
+		if id == "" {
+			return fmt.Errorf("deviceID must not be empty")
+		}
+		if len(actions[id]) == 0 {
+			return fmt.Errorf("actions must not be empty")
+		}
+		d, err := c.GetDevice(ctx, id, opts...)
+		if err != nil {
+			return err
+		}
+		for _, a := range actions[id] {
+			if a.ObisCode == ObisSwitchState && !Capabilities(*d).Switch {
+				return fmt.Errorf("device has no switch")
+			}
+		}
+		return nil
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
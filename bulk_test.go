@@ -0,0 +1,128 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_GetValuesConcurrently(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	ids := []string{"a", "b", "c", "missing"}
+	for _, id := range ids[:3] {
+		srv.SetValues(smartme.DeviceValues{DeviceID: id, Values: []smartme.ObisValue{{Obis: smartme.ObisActivePower, Value: 1}}})
+	}
+	client, _ := srv.Client()
+
+	values, err := client.GetValuesConcurrently(context.Background(), ids)
+
+	var bulkErr *smartme.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("error = %v, want *BulkError", err)
+	}
+	var apiErr *smartme.APIError
+	if len(bulkErr.Errors) != 1 || !errors.As(bulkErr.Errors["missing"], &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected per-device errors: %v", bulkErr.Errors)
+	}
+
+	if len(values) != 3 {
+		t.Fatalf("got %d values, want 3", len(values))
+	}
+	for i, v := range values {
+		if v.DeviceID != ids[i] {
+			t.Errorf("values[%d].DeviceID = %q, want %q", i, v.DeviceID, ids[i])
+		}
+	}
+
+	// Errors are reported by ID, so IDs must be unique and not empty.
+	requests := srv.Requests("/api/Values")
+	for _, ids := range [][]string{{"a", "b", "a"}, {"a", ""}} {
+		if _, err := client.GetValuesConcurrently(context.Background(), ids); err == nil || isBulk(err) {
+			t.Errorf("GetValuesConcurrently(%q): error = %v, want it rejected", ids, err)
+		}
+	}
+	if n := srv.Requests("/api/Values"); n != requests {
+		t.Errorf("%d requests sent for rejected IDs", n-requests)
+	}
+}
+
+func isBulk(err error) bool {
+	var bulkErr *smartme.BulkError
+	return errors.As(err, &bulkErr)
+}
+
+func TestClient_GetDevicesWithValues(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	srv.AddDevice(smartme.Device{Id: ptr("a"), Name: ptr("A")})
+	srv.AddDevice(smartme.Device{Id: ptr("b"), Name: ptr("B")})
+	srv.SetValues(smartme.DeviceValues{DeviceID: "a", Values: []smartme.ObisValue{{Obis: smartme.ObisActivePower, Value: 1}}})
+	client, _ := srv.Client()
+
+	snapshots, err := client.GetDevicesWithValues(context.Background())
+	if err != nil {
+		t.Fatalf("GetDevicesWithValues failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(snapshots))
+	}
+	a, b := snapshots[0], snapshots[1]
+	if *a.Device.Name != "A" || a.Err != nil || a.Values == nil || a.Values.Values[0].Value != 1 {
+		t.Errorf("unexpected snapshot of a: %+v", a)
+	}
+	if *b.Device.Name != "B" || b.Err == nil || b.Values != nil {
+		t.Errorf("snapshot of b should report the missing values: %+v", b)
+	}
+	if !a.Time.Equal(b.Time) || a.Time.IsZero() {
+		t.Errorf("snapshot times differ: %v, %v", a.Time, b.Time)
+	}
+}
+
+func TestClient_ExecuteActionsBulk(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	srv.AddDevice(smartme.Device{Id: ptr("relay-1"), SwitchOn: ptr(true)})
+	srv.AddDevice(smartme.Device{Id: ptr("relay-2"), FamilyType: ptr(smartme.MeterFamilyType(8))})
+	srv.AddDevice(smartme.Device{Id: ptr("meter"), FamilyType: ptr(smartme.MeterFamilyTypeSmartMe3PhaseMeter80A)})
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	off := []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 0}}
+	actions := map[string][]smartme.Action{"relay-1": off, "relay-2": off, "meter": off, "missing": off}
+
+	err := client.CheckActionsBulk(ctx, actions)
+	var bulkErr *smartme.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("CheckActionsBulk error = %v, want *BulkError", err)
+	}
+	if len(bulkErr.Errors) != 2 || bulkErr.Errors["meter"] == nil || bulkErr.Errors["missing"] == nil {
+		t.Errorf("unexpected check errors: %v", bulkErr.Errors)
+	}
+	for _, id := range []string{"relay-1", "relay-2", "meter"} {
+		if got := srv.Actions(id); len(got) != 0 {
+			t.Errorf("dry run performed actions on %s: %v", id, got)
+		}
+	}
+
+	delete(actions, "meter")
+	delete(actions, "missing")
+	if err := client.CheckActionsBulk(ctx, actions); err != nil {
+		t.Fatalf("CheckActionsBulk failed: %v", err)
+	}
+	if err := client.ExecuteActionsBulk(ctx, actions); err != nil {
+		t.Fatalf("ExecuteActionsBulk failed: %v", err)
+	}
+	for _, id := range []string{"relay-1", "relay-2"} {
+		if got := srv.Actions(id); len(got) != 1 || got[0] != off[0] {
+			t.Errorf("actions of %s = %v, want %v", id, got, off)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package smartme
+
+import (
+	"net/http"
+	"time"
+)
+
+// historySettleDelay is the age after which historical values are
+// considered final and may be cached.
+const historySettleDelay = 24 * time.Hour
+
+// Cache stores raw API responses. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	// Get returns the data stored for key, if present and not expired.
+	Get(key string) ([]byte, bool)
+	// Set stores data for key. A ttl <= 0 means the entry does not expire.
+	Set(key string, data []byte, ttl time.Duration)
+}
+
+// historyCacheKey returns the cache key for a history request, or "" if the
+// client has no cache or the requested range may still change.
+func (c *Client) historyCacheKey(req *http.Request, until time.Time) string {
+	if c.cache == nil || !until.Before(c.clock.Now().Add(-historySettleDelay)) {
+		return ""
+	}
+	// The user is part of the key, so accounts sharing a cache directory
+	// or a client never see each other's data. The content type keeps
+	// responses of different codecs apart.
+	username, _, _ := req.BasicAuth()
+	return username + " " + c.codec.ContentType() + " " + req.Method + " " + req.URL.String()
+}
@@ -0,0 +1,147 @@
+package smartme_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_DiskCache(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	end := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+	start := end.Add(-2 * time.Hour)
+	devices := srv.Populate(1, start, time.Now())
+	id := *devices[0].Id
+
+	dir := t.TempDir()
+	newClient := func() *smartme.Client {
+		cache, err := smartme.NewDiskCache(dir, 0)
+		if err != nil {
+			t.Fatalf("NewDiskCache failed: %v", err)
+		}
+		client, _ := srv.Client(smartme.WithCache(cache, time.Hour))
+		return client
+	}
+
+	ctx := context.Background()
+	client := newClient()
+	first, err := client.GetValuesInPastMultiple(ctx, id, start, end)
+	if err != nil {
+		t.Fatalf("GetValuesInPastMultiple failed: %v", err)
+	}
+
+	// A new client with the same cache directory must not hit the server.
+	client = newClient()
+	second, err := client.GetValuesInPastMultiple(ctx, id, start, end)
+	if err != nil {
+		t.Fatalf("GetValuesInPastMultiple failed: %v", err)
+	}
+	if n := srv.Requests("/api/ValuesInPastMultiple"); n != 1 {
+		t.Errorf("server received %d requests, want 1", n)
+	}
+	if len(first) == 0 || len(first) != len(second) {
+		t.Errorf("cached result has %d values, want %d", len(second), len(first))
+	}
+
+	// The streaming variant shares the cache.
+	streamed := 0
+	err = client.StreamValuesInPastMultiple(ctx, id, start, end, func(smartme.Value) error {
+		streamed++
+		return nil
+	})
+	if err != nil || streamed != len(first) || srv.Requests("/api/ValuesInPastMultiple") != 1 {
+		t.Errorf("stream from cache: err=%v values=%d requests=%d", err, streamed, srv.Requests("/api/ValuesInPastMultiple"))
+	}
+
+	// Streamed responses are not stored in the cache.
+	earlier := start.Add(-24 * time.Hour)
+	for i := 0; i < 2; i++ {
+		err := client.StreamValuesInPastMultiple(ctx, id, earlier, start, func(smartme.Value) error { return nil })
+		if err != nil {
+			t.Fatalf("StreamValuesInPastMultiple failed: %v", err)
+		}
+	}
+	if n := srv.Requests("/api/ValuesInPastMultiple"); n != 3 {
+		t.Errorf("server received %d requests, want 3", n)
+	}
+
+	// Recent data is never cached.
+	recentEnd := time.Now().Add(-time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetValuesInPastMultiple(ctx, id, recentEnd.Add(-time.Hour), recentEnd); err != nil {
+			t.Fatalf("GetValuesInPastMultiple failed: %v", err)
+		}
+	}
+	if n := srv.Requests("/api/ValuesInPastMultiple"); n != 5 {
+		t.Errorf("server received %d requests, want 5", n)
+	}
+}
+
+func TestDiskCache_ExpiryAndSizeLimit(t *testing.T) {
+	cache, err := smartme.NewDiskCache(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+
+	cache.Set("expired", []byte("x"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("expired entry was returned")
+	}
+
+	cache.Set("a", make([]byte, 60), 0)
+	cache.Set("b", make([]byte, 60), 0)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("oldest entry should have been evicted")
+	}
+	if data, ok := cache.Get("b"); !ok || len(data) != 60 {
+		t.Error("newest entry should be kept")
+	}
+}
+
+func TestClient_CacheUsesClock(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	devices := srv.Populate(1, start, end)
+	id := *devices[0].Id
+
+	cache, err := smartme.NewDiskCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+	// Right after the range the data may still change and is not cached.
+	clock := smartmetest.NewClock(end.Add(time.Hour))
+	client, _ := srv.Client(smartme.WithCache(cache, time.Hour), smartme.WithClock(clock))
+
+	ctx := context.Background()
+	fetch := func() {
+		t.Helper()
+		if _, err := client.GetValuesInPastMultiple(ctx, id, start, end); err != nil {
+			t.Fatalf("GetValuesInPastMultiple failed: %v", err)
+		}
+	}
+
+	fetch()
+	fetch()
+	clock.Advance(48 * time.Hour)
+	fetch()
+	fetch()
+	if n := srv.Requests("/api/ValuesInPastMultiple"); n != 3 {
+		t.Errorf("server received %d requests, want 3", n)
+	}
+
+	// The entry expires after the TTL on the client clock.
+	clock.Advance(2 * time.Hour)
+	fetch()
+	if n := srv.Requests("/api/ValuesInPastMultiple"); n != 4 {
+		t.Errorf("server received %d requests after expiry, want 4", n)
+	}
+}
@@ -0,0 +1,92 @@
+package smartme_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestWithCalibration(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	srv.AddDevice(smartme.Device{Id: ptr("ct"), CounterReading: ptr(100.0), ActivePower: ptr(2.0), CurrentL1: ptr(4.0), Voltage: ptr(230.0)})
+	srv.AddDevice(smartme.Device{Id: ptr("ok"), CounterReading: ptr(100.0)})
+	srv.SetValues(smartme.DeviceValues{DeviceID: "ct", Values: []smartme.ObisValue{
+		{Obis: smartme.ObisActiveEnergyImport, Value: 100},
+		{Obis: smartme.ObisVoltageL1, Value: 230},
+		{Obis: "9-9:9.9.9*255", Value: 7},
+	}})
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddHistory("ct", smartme.Value{Date: start, Value: 10}, smartme.Value{Date: start.Add(time.Hour), Value: 20})
+
+	cal := smartme.NewCalibration().Set("ct", 1.5)
+	client, _ := srv.Client(smartme.WithCalibration(cal))
+	ctx := context.Background()
+
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	for _, d := range devices {
+		want := 100.0
+		if *d.Id == "ct" {
+			want = 150
+			if *d.ActivePower != 3 || *d.CurrentL1 != 6 || *d.Voltage != 230 {
+				t.Errorf("corrected device = power %v, current %v, voltage %v", *d.ActivePower, *d.CurrentL1, *d.Voltage)
+			}
+		}
+		if *d.CounterReading != want {
+			t.Errorf("device %s: CounterReading = %v, want %v", *d.Id, *d.CounterReading, want)
+		}
+	}
+
+	values, err := client.GetValues(ctx, "ct")
+	if err != nil {
+		t.Fatalf("GetValues failed: %v", err)
+	}
+	for i, want := range []float64{150, 230, 7} {
+		if got := values.Values[i].Value; got != want {
+			t.Errorf("%s = %v, want %v", values.Values[i].Obis, got, want)
+		}
+	}
+
+	history, err := client.GetValuesInPastMultiple(ctx, "ct", start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("GetValuesInPastMultiple failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Value != 15 || history[1].Value != 30 {
+		t.Errorf("history = %+v, want corrected values 15 and 30", history)
+	}
+	var streamed []float64
+	err = client.StreamValuesInPastMultiple(ctx, "ct", start, start.Add(2*time.Hour), func(v smartme.Value) error {
+		streamed = append(streamed, v.Value)
+		return nil
+	})
+	if err != nil || len(streamed) != 2 || streamed[1] != 30 {
+		t.Errorf("streamed %v, %v; want corrected values", streamed, err)
+	}
+	past, err := client.GetValuesInPast(ctx, "ct", start.Add(time.Hour))
+	if err != nil || past.Value != 30 {
+		t.Errorf("GetValuesInPast = %+v, %v; want 30", past, err)
+	}
+
+	raw, err := client.GetDevice(ctx, "ct", smartme.WithRawValues())
+	if err != nil {
+		t.Fatalf("GetDevice with WithRawValues failed: %v", err)
+	}
+	if *raw.CounterReading != 100 {
+		t.Errorf("raw CounterReading = %v, want 100", *raw.CounterReading)
+	}
+	rawPast, err := client.GetValuesInPast(ctx, "ct", start, smartme.WithRawValues())
+	if err != nil || rawPast.Value != 10 {
+		t.Errorf("raw GetValuesInPast = %+v, %v; want 10", rawPast, err)
+	}
+
+	if f := cal.Set("ct", 1).Factor("ct"); f != 1 {
+		t.Errorf("Factor after reset = %v", f)
+	}
+}
@@ -0,0 +1,122 @@
+package smartme
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CallOption is a functional option for a single API call.
+// It allows passing parameters the client does not know about yet.
+type CallOption func(*callConfig)
+
+// callConfig holds the settings of the CallOptions of a call.
+type callConfig struct {
+	query      url.Values
+	header     http.Header
+	dateFormat string
+	location   *time.Location
+	obis       map[string]bool
+	fields     map[string]bool
+	raw        bool
+
+	sessionThreshold float64
+}
+
+// newCallConfig applies the given options to a default configuration.
+func newCallConfig(opts []CallOption) *callConfig {
+	cfg := &callConfig{dateFormat: time.RFC3339}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// formatDate formats a date parameter with the configured layout, in the
+// location of the client if one is set.
+func (cfg *callConfig) formatDate(t time.Time) string {
+	if cfg.location != nil {
+		t = t.In(cfg.location)
+	}
+	return t.Format(cfg.dateFormat)
+}
+
+// callConfig returns the configuration of a call with the client defaults.
+func (c *Client) callConfig(opts []CallOption) *callConfig {
+	cfg := newCallConfig(opts)
+	cfg.location = c.location
+	return cfg
+}
+
+// apply adds the configured query parameters and headers to the request.
+func (cfg *callConfig) apply(req *http.Request) {
+	if len(cfg.query) > 0 {
+		q := req.URL.Query()
+		for key, values := range cfg.query {
+			q[key] = append(q[key], values...)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	for key, values := range cfg.header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+}
+
+// WithQueryParam adds a query parameter to the request.
+func WithQueryParam(key, value string) CallOption {
+	return func(cfg *callConfig) {
+		if cfg.query == nil {
+			cfg.query = make(url.Values)
+		}
+		cfg.query.Add(key, value)
+	}
+}
+
+// WithHeader adds a header to the request.
+func WithHeader(key, value string) CallOption {
+	return func(cfg *callConfig) {
+		if cfg.header == nil {
+			cfg.header = make(http.Header)
+		}
+		cfg.header.Add(key, value)
+	}
+}
+
+// WithDateFormat changes the layout used for date parameters
+// (time.RFC3339 by default).
+func WithDateFormat(layout string) CallOption {
+	return func(cfg *callConfig) {
+		cfg.dateFormat = layout
+	}
+}
+
+// WithObis keeps only the values with the given OBIS codes in the result of
+// GetValues, GetValuesConcurrently and GetDevicesWithValues. Codes may be given
+// with or without the "*255" suffix. The API always returns all registers,
+// so the filter is applied by the client.
+func WithObis(codes ...string) CallOption {
+	return func(cfg *callConfig) {
+		if cfg.obis == nil {
+			cfg.obis = make(map[string]bool, len(codes))
+		}
+		for _, code := range codes {
+			cfg.obis[NormalizeObis(code)] = true
+		}
+	}
+}
+
+// filterObis removes the values not selected with WithObis.
+func (cfg *callConfig) filterObis(dv *DeviceValues) {
+	if cfg.obis == nil {
+		return
+	}
+	kept := dv.Values[:0]
+	for _, v := range dv.Values {
+		if cfg.obis[NormalizeObis(v.Obis)] {
+			kept = append(kept, v)
+		}
+	}
+	dv.Values = kept
+}
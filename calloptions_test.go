@@ -0,0 +1,106 @@
+package smartme_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestCallOptions_QueryAndHeader(t *testing.T) {
+	client, mux, teardown := setup(t)
+	defer teardown()
+
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query()["filter"]; len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Errorf("Expected filter=a&filter=b, got %v", got)
+		}
+		if got := r.Header.Get("X-Trace"); got != "42" {
+			t.Errorf("Expected X-Trace header 42, got %q", got)
+		}
+		if got := r.Header.Get("Accept"); got != "application/json" {
+			t.Errorf("Default headers must be kept, got Accept %q", got)
+		}
+		w.Write([]byte("[]"))
+	})
+
+	_, err := client.GetDevices(context.Background(),
+		smartme.WithQueryParam("filter", "a"),
+		smartme.WithQueryParam("filter", "b"),
+		smartme.WithHeader("X-Trace", "42"),
+	)
+	if err != nil {
+		t.Fatalf("GetDevices returned an unexpected error: %v", err)
+	}
+}
+
+func TestCallOptions_DateFormat(t *testing.T) {
+	client, mux, teardown := setup(t)
+	defer teardown()
+
+	zone := time.FixedZone("CET", 3600)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, zone)
+	end := start.Add(time.Hour)
+
+	var gotStart, gotEnd string
+	mux.HandleFunc("/api/ValuesInPastMultiple/dev 1", func(w http.ResponseWriter, r *http.Request) {
+		gotStart = r.URL.Query().Get("startDate")
+		gotEnd = r.URL.Query().Get("endDate")
+		w.Write([]byte("[]"))
+	})
+
+	// The offset contains a "+" that must be escaped in the query.
+	if _, err := client.GetValuesInPastMultiple(context.Background(), "dev 1", start, end); err != nil {
+		t.Fatalf("GetValuesInPastMultiple returned an unexpected error: %v", err)
+	}
+	if gotStart != "2025-01-01T00:00:00+01:00" || gotEnd != "2025-01-01T01:00:00+01:00" {
+		t.Errorf("Unexpected dates with default format: %q, %q", gotStart, gotEnd)
+	}
+
+	_, err := client.GetValuesInPastMultiple(context.Background(), "dev 1", start, end, smartme.WithDateFormat("2006-01-02T15:04:05"))
+	if err != nil {
+		t.Fatalf("GetValuesInPastMultiple returned an unexpected error: %v", err)
+	}
+	if gotStart != "2025-01-01T00:00:00" || gotEnd != "2025-01-01T01:00:00" {
+		t.Errorf("Unexpected dates with custom format: %q, %q", gotStart, gotEnd)
+	}
+}
+
+func TestCallOptions_Obis(t *testing.T) {
+	client, mux, teardown := setup(t)
+	defer teardown()
+
+	mux.HandleFunc("/api/Values/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"deviceId":"dev-1","date":"2025-01-01T00:00:00Z","values":[
+			{"obis":"1-0:1.8.0*255","value":1200.5},
+			{"obis":"1-0:1.7.0*255","value":350},
+			{"obis":"1-0:2.8.0*255","value":10}]}`))
+	})
+
+	ctx := context.Background()
+	all, err := client.GetValues(ctx, "dev-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Values) != 3 {
+		t.Errorf("without WithObis got %d values, want 3", len(all.Values))
+	}
+
+	dv, err := client.GetValues(ctx, "dev-1", smartme.WithObis("1-0:1.8.0", smartme.ObisActiveEnergyExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dv.Values) != 2 || dv.Values[0].Value != 1200.5 || dv.Values[1].Value != 10 {
+		t.Errorf("filtered values = %+v, want 1.8.0 and 2.8.0", dv.Values)
+	}
+
+	bulk, err := client.GetValuesConcurrently(ctx, []string{"dev-1"}, smartme.WithObis(smartme.ObisActivePower))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bulk) != 1 || len(bulk[0].Values) != 1 || bulk[0].Values[0].Value != 350 {
+		t.Errorf("bulk values = %+v, want only active power", bulk)
+	}
+}
@@ -0,0 +1,77 @@
+package smartme
+
+import "strings"
+
+// DeviceCapabilities describes what a device supports. Use it to adapt UIs
+// and behavior, e.g. to show a switch toggle only for devices with a relay,
+// instead of checking family types or single fields.
+type DeviceCapabilities struct {
+	// Switch is set for devices with a relay (see PerformActions).
+	Switch bool
+	// PerPhase is set for devices that measure each of three phases.
+	PerPhase bool
+	// Tariffs is set for devices that report an active tariff.
+	Tariffs bool
+	// Outputs is set for devices with digital or analog outputs.
+	Outputs bool
+	// FlowRate is set for devices that measure a flow rate.
+	FlowRate bool
+	// Temperature is set for devices that measure a temperature.
+	Temperature bool
+	// ChargingStation is set for charging stations.
+	ChargingStation bool
+}
+
+// Capabilities reports what d supports. It combines the metadata of the
+// family type, the sub type and the fields populated by the API, so a
+// capability is reported if any of them indicates it.
+func Capabilities(d Device) DeviceCapabilities {
+	var caps DeviceCapabilities
+	if d.FamilyType != nil {
+		caps.Switch = d.FamilyType.HasSwitch()
+		caps.PerPhase = d.FamilyType.Phases() == 3
+	}
+	var subType MeterSubType
+	if d.MeterSubType != nil {
+		subType = *d.MeterSubType
+	}
+	var energyType MeterEnergyType
+	if d.DeviceEnergyType != nil {
+		energyType = *d.DeviceEnergyType
+	}
+
+	caps.Switch = caps.Switch || d.SwitchOn != nil || d.SwitchPhaseL10n != nil
+	caps.PerPhase = caps.PerPhase || d.ActivePowerL2 != nil || d.ActivePowerL3 != nil
+	caps.Tariffs = d.ActiveTariff != nil
+	caps.Outputs = d.DigitalOutput1 != nil || d.DigitalOutput2 != nil || d.AnalogOutput1 != nil || d.AnalogOutput2 != nil
+	caps.FlowRate = d.FlowRate != nil
+	caps.Temperature = d.Temperature != nil || subType == TemperatureMeter || energyType == MeterTypeTemperature
+	caps.ChargingStation = d.ChargeStationState != nil || subType == MeterSubTypeChargingStation
+	return caps
+}
+
+// String returns the supported capabilities as a comma separated list like
+// "switch,per-phase", or "none".
+func (c DeviceCapabilities) String() string {
+	var names []string
+	for _, f := range []struct {
+		name string
+		ok   bool
+	}{
+		{"switch", c.Switch},
+		{"per-phase", c.PerPhase},
+		{"tariffs", c.Tariffs},
+		{"outputs", c.Outputs},
+		{"flow-rate", c.FlowRate},
+		{"temperature", c.Temperature},
+		{"charging", c.ChargingStation},
+	} {
+		if f.ok {
+			names = append(names, f.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
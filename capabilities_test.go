@@ -0,0 +1,42 @@
+package smartme_test
+
+import (
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		name   string
+		device smartme.Device
+		want   string
+	}{
+		{"empty", smartme.Device{}, "none"},
+		{
+			"family type only",
+			smartme.Device{FamilyType: ptr(smartme.MeterFamilyType(8))},
+			"switch,per-phase",
+		},
+		{
+			"populated fields",
+			smartme.Device{ActivePowerL3: ptr(1.0), ActiveTariff: ptr(int32(1)), DigitalOutput1: ptr(true), SwitchOn: ptr(false)},
+			"switch,per-phase,tariffs,outputs",
+		},
+		{
+			"water meter",
+			smartme.Device{DeviceEnergyType: ptr(smartme.MeterTypeWater), FlowRate: ptr(0.0), Temperature: ptr(12.5)},
+			"flow-rate,temperature",
+		},
+		{
+			"charging station by sub type",
+			smartme.Device{MeterSubType: ptr(smartme.MeterSubTypeChargingStation)},
+			"charging",
+		},
+	}
+	for _, tt := range tests {
+		if got := smartme.Capabilities(tt.device).String(); got != tt.want {
+			t.Errorf("%s: Capabilities = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
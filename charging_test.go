@@ -0,0 +1,108 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_ChargingControl(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	charger := srv.AddDevice(smartme.Device{ChargeStationState: ptr(smartme.ReadyCarConnected)})
+	idle := srv.AddDevice(smartme.Device{ChargeStationState: ptr(smartme.ReadyNoCarConnected)})
+	meter := srv.AddDevice(smartme.Device{})
+
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	if err := client.StartCharging(ctx, charger); err != nil {
+		t.Fatalf("StartCharging failed: %v", err)
+	}
+	// Starting again is a no-op.
+	if err := client.StartCharging(ctx, charger); err != nil {
+		t.Fatalf("second StartCharging failed: %v", err)
+	}
+	if err := client.StopCharging(ctx, charger); err != nil {
+		t.Fatalf("StopCharging failed: %v", err)
+	}
+
+	want := []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 1}, {ObisCode: smartme.ObisSwitchState, Value: 0}}
+	got := srv.Actions(charger)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("actions = %+v, want %+v", got, want)
+	}
+
+	if err := client.StartCharging(ctx, idle); !errors.Is(err, smartme.ErrInvalidChargeState) {
+		t.Errorf("StartCharging without car: error = %v, want ErrInvalidChargeState", err)
+	}
+	if err := client.StartCharging(ctx, meter); !errors.Is(err, smartme.ErrInvalidChargeState) {
+		t.Errorf("StartCharging on a meter: error = %v, want ErrInvalidChargeState", err)
+	}
+	if len(srv.Actions(idle)) != 0 || len(srv.Actions(meter)) != 0 {
+		t.Error("actions were sent to devices in an invalid state")
+	}
+}
+
+func TestClient_ChargingReadOnly(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	charger := srv.AddDevice(smartme.Device{ChargeStationState: ptr(smartme.ReadyCarConnected)})
+	client, _ := srv.Client(smartme.WithReadOnly())
+
+	if err := client.StartCharging(context.Background(), charger); !errors.Is(err, smartme.ErrReadOnlyClient) {
+		t.Errorf("error = %v, want ErrReadOnlyClient", err)
+	}
+	if n := srv.Requests(""); n != 0 {
+		t.Errorf("read-only client sent %d requests", n)
+	}
+}
+
+func TestClient_MaxChargingCurrent(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	charger := srv.AddDevice(smartme.Device{ChargeStationState: ptr(smartme.Charging)})
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	if err := client.SetMaxChargingCurrent(ctx, charger, 10); err != nil {
+		t.Fatalf("SetMaxChargingCurrent failed: %v", err)
+	}
+	if got := srv.ChargingCurrent(charger); got != 10 {
+		t.Errorf("limit = %v A, want 10 A", got)
+	}
+	want := smartme.Action{ObisCode: smartmetest.ChargingCurrentObis, Value: 10}
+	if actions := srv.Actions(charger); len(actions) != 1 || actions[0] != want {
+		t.Errorf("actions = %+v, want %+v", actions, want)
+	}
+
+	// Above the maximum of the station, the limit is capped.
+	if err := client.SetMaxChargingCurrent(ctx, charger, 80); err != nil {
+		t.Fatalf("SetMaxChargingCurrent failed: %v", err)
+	}
+	if got := srv.ChargingCurrent(charger); got != 32 {
+		t.Errorf("limit = %v A, want the maximum of 32 A", got)
+	}
+
+	for _, amps := range []float64{0, 5.9, -1, math.NaN(), math.Inf(1)} {
+		if err := client.SetMaxChargingCurrent(ctx, charger, amps); err == nil {
+			t.Errorf("SetMaxChargingCurrent(%v) succeeded", amps)
+		}
+	}
+	if n := srv.Requests("/api/Actions"); n != 4 {
+		t.Errorf("server received %d requests, want 4", n)
+	}
+
+	// A device without a current action is rejected.
+	meter := srv.AddDevice(smartme.Device{SwitchOn: ptr(true)})
+	if err := client.SetMaxChargingCurrent(ctx, meter, 10); err == nil {
+		t.Error("SetMaxChargingCurrent succeeded on a device without a current action")
+	}
+}
@@ -2,12 +2,14 @@
 package smartme
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"time"
 )
 
@@ -22,6 +24,27 @@ type Client struct {
 	baseURL    *url.URL
 	username   string
 	password   string
+
+	disableCompression bool
+	transport          *transportConfig
+	cache              Cache
+	cacheTTL           time.Duration
+	clock              Clock
+	location           *time.Location
+	readOnly           bool
+	rounding           *rounding
+	acceptLanguage     string
+	stats              *statsRecorder
+	codec              Codec
+	bounds             *Bounds
+	hooks              decodeHooks
+	calibration        *Calibration
+	offline            *offlineState
+	audit              *auditLog
+	auditWriter        io.Writer
+	apiVersion         string
+	endpointVersions   map[string]string
+	limiter            *limiter
 }
 
 // NewClient creates a new instance of the smart-me API client.
@@ -30,6 +53,9 @@ func NewClient(username, password string, opts ...Option) (*Client, error) {
 	if username == "" {
 		return nil, fmt.Errorf("username must not be empty")
 	}
+	if password == "" {
+		return nil, fmt.Errorf("password must not be empty")
+	}
 
 	baseURL, _ := url.Parse(defaultBaseURL)
 
@@ -37,11 +63,48 @@ func NewClient(username, password string, opts ...Option) (*Client, error) {
 		baseURL:  baseURL,
 		username: username,
 		password: password,
+		clock:    SystemClock{},
+		codec:    JSONCodec{},
+	}
+
+	if err := c.apply(opts); err != nil {
+		return nil, err
+	}
+	c.stats = newStatsRecorder(c.clock.Now())
+	c.audit = &auditLog{}
+	return c, nil
+}
+
+// With returns a copy of the client with the given options applied on top
+// of its current configuration. The copy shares credentials, cache and
+// transport with c, so connections are reused; c itself is not changed.
+// Use it for code paths that need, for example, a different timeout.
+// WithTransportTuning and WithHTTP2 have no effect on the shared transport.
+func (c *Client) With(opts ...Option) (*Client, error) {
+	d := *c
+	httpClient := *c.httpClient
+	d.httpClient = &httpClient
+	if c.transport != nil {
+		tc := *c.transport
+		d.transport = &tc
+	}
+	if err := d.apply(opts); err != nil {
+		return nil, err
 	}
+	return &d, nil
+}
 
-	// Apply functional options
+// apply applies functional options and completes the configuration.
+// All invalid options are reported together.
+func (c *Client) apply(opts []Option) error {
+	var errs []error
 	for _, opt := range opts {
-		opt(c)
+		if err := opt(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid client options: %w", errors.Join(errs...))
 	}
 
 	if c.httpClient == nil {
@@ -49,38 +112,172 @@ func NewClient(username, password string, opts ...Option) (*Client, error) {
 			Timeout: defaultTimeout,
 		}
 	}
-
-	return c, nil
+	if c.transport != nil && c.httpClient.Transport == nil {
+		c.httpClient.Transport = c.transport.build()
+	}
+	if cu, ok := c.cache.(clockUser); ok {
+		cu.setClock(c.clock)
+	}
+	return nil
 }
 
 // newRequest creates a new HTTP request with the necessary headers.
-func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
-	rel, err := url.Parse(path)
+// The call options add their query parameters and headers.
+// A read-only client refuses to create requests that are not safe.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader, opts ...CallOption) (*http.Request, error) {
+	if c.readOnly && method != http.MethodGet && method != http.MethodHead {
+		return nil, ErrReadOnlyClient
+	}
+
+	rel, err := url.Parse(c.versionedPath(path))
 	if err != nil {
 		return nil, err
 	}
 	// Create the full URL
-	fullURL := c.baseURL.ResolveReference(rel)
+	baseURL := c.baseURL
+	if u, ok := ctx.Value(baseURLKey{}).(*url.URL); ok {
+		baseURL = u
+	}
+	fullURL := baseURL.ResolveReference(rel)
 
-	req, err := http.NewRequestWithContext(ctx, method, fullURL.String(), body)
+	cfg := newCallConfig(opts)
+	req, err := http.NewRequestWithContext(withRawValues(ctx, cfg), method, fullURL.String(), body)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set Basic Authentication
-	req.SetBasicAuth(c.username, c.password)
-	req.Header.Set("Accept", "application/json")
+	username, password := c.username, c.password
+	if creds, ok := ctx.Value(credentialsKey{}).(Credentials); ok {
+		username, password = creds.Username, creds.Password
+	}
+	req.SetBasicAuth(username, password)
+	req.Header.Set("Accept", c.codec.ContentType())
+	if c.disableCompression {
+		// Without an explicit header net/http asks for gzip by itself.
+		req.Header.Set("Accept-Encoding", "identity")
+	} else {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if id := requestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	cfg.apply(req)
+	if c.acceptLanguage != "" && req.Header.Get("Accept-Language") == "" {
+		req.Header.Set("Accept-Language", c.acceptLanguage)
+	}
 
 	return req, nil
 }
 
 // do executes the request and decodes the response into the provided struct.
 func (c *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
+	return c.doCached(req, v, "")
+}
+
+// doCached is like do, but first looks up cacheKey in the response cache
+// and stores the response there on success. An empty key disables caching.
+// On a cache hit the returned response is nil.
+func (c *Client) doCached(req *http.Request, v interface{}, cacheKey string) (*http.Response, error) {
+	if cacheKey != "" && v != nil {
+		if data, ok := c.cache.Get(cacheKey); ok && c.codec.Unmarshal(data, v) == nil {
+			return nil, c.postprocess(req, v)
+		}
+	}
+
+	if c.offline.skipAPI(c.clock.Now()) {
+		return c.serveOffline(req, v, ErrOffline)
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		if c.offline != nil && unreachable(err) {
+			c.offline.setOffline(c.clock.Now())
+			return c.serveOffline(req, v, err)
+		}
+		if resp != nil {
+			c.offline.setOnline()
+		}
+		return resp, err
+	}
+	defer resp.Body.Close()
+	c.offline.setOnline()
+
+	if v == nil {
+		return resp, nil
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if resp.ContentLength > 0 && resp.ContentLength <= maxPooledBufferSize {
+		buf.Grow(int(resp.ContentLength))
+	}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return resp, fmt.Errorf("error reading response: %w", err)
+	}
+
+	// Some endpoints answer with 204 No Content or an empty body when a
+	// device has no data.
+	if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+		if reflect.TypeOf(v).Elem().Kind() == reflect.Slice {
+			return resp, nil
+		}
+		return resp, ErrNoData
+	}
+
+	preallocate(v, resp.ContentLength)
+	if err := c.codec.Unmarshal(buf.Bytes(), v); err != nil {
+		return resp, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if cacheKey != "" {
+		c.cache.Set(cacheKey, bytes.Clone(buf.Bytes()), c.cacheTTL)
+	}
+	c.storeOffline(req, buf.Bytes())
+	if err := c.postprocess(req, v); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// postprocess applies the calibration, the decode hooks, the rounding
+// policy and the plausibility bounds to a decoded response of req.
+func (c *Client) postprocess(req *http.Request, v interface{}) error {
+	c.calibration.apply(req, v)
+	if err := c.hooks.run(v); err != nil {
+		return err
+	}
+	c.rounding.round(v)
+	return c.bounds.check(req, v)
+}
+
+// send executes the request and checks the status code.
+// On success the caller must close the response body.
+// Calls that change a device or the account are recorded in the audit log.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	release, err := c.limiter.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.sendRequest(req)
+	release()
+	c.recordAudit(req, resp, err)
+	return resp, err
+}
+
+func (c *Client) sendRequest(req *http.Request) (*http.Response, error) {
+	stats := c.stats.endpoint(req)
+	stats.calls.Add(1)
+	if req.ContentLength > 0 {
+		stats.sent.Add(req.ContentLength)
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		stats.errors.Add(1)
 		// Catch context errors (e.g., timeout)
 		select {
 		case <-req.Context().Done():
@@ -89,17 +286,24 @@ func (c *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
 		}
 		return nil, err
 	}
-	defer resp.Body.Close()
+
+	c.stats.observeRateLimit(resp.Header, c.clock.Now())
+	resp.Body = countingReader{resp.Body, &stats.received}
 
 	if resp.StatusCode >= 400 {
-		// Implement more robust error handling here
-		return resp, fmt.Errorf("API error: %s (status code: %d)", resp.Status, resp.StatusCode)
+		stats.errors.Add(1)
+		resp.Body.Close()
+		return resp, &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("API error: %s (status code: %d)", resp.Status, resp.StatusCode),
+			RequestID:  req.Header.Get(RequestIDHeader),
+		}
 	}
 
-	if v != nil {
-		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-			return resp, fmt.Errorf("error decoding response: %w", err)
-		}
+	if err := decompress(resp); err != nil {
+		stats.errors.Add(1)
+		resp.Body.Close()
+		return resp, fmt.Errorf("error decompressing response: %w", err)
 	}
 
 	return resp, nil
@@ -107,12 +311,16 @@ func (c *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
 
 // GetDevices retrieves the list of all devices.
 // Corresponds to the API call: GET /api/Devices
-func (c *Client) GetDevices(ctx context.Context) ([]Device, error) {
-	req, err := c.newRequest(ctx, http.MethodGet, "api/Devices", nil)
+func (c *Client) GetDevices(ctx context.Context, opts ...CallOption) ([]Device, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "api/Devices", nil, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	if cfg := newCallConfig(opts); cfg.fields != nil {
+		return c.getDevicesFields(req, cfg.fields, false)
+	}
+
 	var devices []Device
 	_, err = c.do(req, &devices)
 	if err != nil {
@@ -122,15 +330,68 @@ func (c *Client) GetDevices(ctx context.Context) ([]Device, error) {
 	return devices, nil
 }
 
+// GetDevice retrieves a single device.
+// Corresponds to the API call: GET /api/Devices/{id}
+func (c *Client) GetDevice(ctx context.Context, deviceID string, opts ...CallOption) (*Device, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("deviceID must not be empty")
+	}
+
+	path := fmt.Sprintf("api/Devices/%s", url.PathEscape(deviceID))
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if cfg := newCallConfig(opts); cfg.fields != nil {
+		devices, err := c.getDevicesFields(req, cfg.fields, true)
+		if err != nil {
+			return nil, err
+		}
+		return &devices[0], nil
+	}
+
+	var device Device
+	_, err = c.do(req, &device)
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+// CreateOrUpdateDevice creates a device, or updates the device with the
+// same ID. It returns the device as stored by the API, including the ID
+// assigned to a new device.
+// Corresponds to the API call: POST /api/Devices
+func (c *Client) CreateOrUpdateDevice(ctx context.Context, device Device, opts ...CallOption) (*Device, error) {
+	if device.Name == nil || *device.Name == "" {
+		return nil, fmt.Errorf("device name must not be empty")
+	}
+
+	req, err := c.newJSONRequest(ctx, http.MethodPost, "api/Devices", device, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var stored Device
+	_, err = c.do(req, &stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
 // GetValues retrieves the last values of a specific device.
 // Corresponds to the API call: GET /api/Values/{id}
-func (c *Client) GetValues(ctx context.Context, deviceID string) (*DeviceValues, error) {
+func (c *Client) GetValues(ctx context.Context, deviceID string, opts ...CallOption) (*DeviceValues, error) {
 	if deviceID == "" {
 		return nil, fmt.Errorf("deviceID must not be empty")
 	}
 
-	path := fmt.Sprintf("api/Values/%s", deviceID)
-	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	path := fmt.Sprintf("api/Values/%s", url.PathEscape(deviceID))
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -140,25 +401,27 @@ func (c *Client) GetValues(ctx context.Context, deviceID string) (*DeviceValues,
 	if err != nil {
 		return nil, err
 	}
+	newCallConfig(opts).filterObis(&deviceValues)
 
 	return &deviceValues, nil
 }
 
 // GetValuesInPast retrieves the first value found before a given date for a specific device.
 // Corresponds to the API call: GET /api/ValuesInPast/{id}?date={date}
-func (c *Client) GetValuesInPast(ctx context.Context, deviceID string, date time.Time) (*Value, error) {
+func (c *Client) GetValuesInPast(ctx context.Context, deviceID string, date time.Time, opts ...CallOption) (*Value, error) {
 	if deviceID == "" {
 		return nil, fmt.Errorf("deviceID must not be empty")
 	}
 
-	path := fmt.Sprintf("api/ValuesInPast/%s?date=%s", deviceID, date.Format(time.RFC3339))
-	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	query := url.Values{"date": {c.callConfig(opts).formatDate(date)}}
+	path := fmt.Sprintf("api/ValuesInPast/%s?%s", url.PathEscape(deviceID), query.Encode())
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	var value Value
-	_, err = c.do(req, &value)
+	_, err = c.doCached(req, &value, c.historyCacheKey(req, date))
 	if err != nil {
 		return nil, err
 	}
@@ -169,22 +432,96 @@ func (c *Client) GetValuesInPast(ctx context.Context, deviceID string, date time
 // GetValuesInPastMultiple retrieves multiple values of a device within a given time range.
 // Note: This call might require a professional license for the smart-me account.
 // Corresponds to the API call: GET /api/ValuesInPastMultiple/{id}?startDate={startDate}&endDate={endDate}
-func (c *Client) GetValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time) ([]Value, error) {
+func (c *Client) GetValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, opts ...CallOption) ([]Value, error) {
 	if deviceID == "" {
 		return nil, fmt.Errorf("deviceID must not be empty")
 	}
 
-	path := fmt.Sprintf("api/ValuesInPastMultiple/%s?startDate=%s&endDate=%s", deviceID, startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
-	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	req, err := c.newValuesInPastMultipleRequest(ctx, deviceID, startDate, endDate, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
 	var values []Value
-	_, err = c.do(req, &values)
+	_, err = c.doCached(req, &values, c.historyCacheKey(req, endDate))
 	if err != nil {
 		return nil, err
 	}
 
 	return values, nil
 }
+
+// StreamValuesInPastMultiple is like GetValuesInPastMultiple, but decodes the
+// response incrementally and calls fn for every value instead of buffering
+// the whole array. This keeps memory usage flat for long ranges with
+// per-minute data. If fn returns an error, streaming stops and that error
+// is returned. A cached response is used if present, but the streamed
+// response is not added to the cache.
+//
+// In offline mode, the call is answered from the response stored by
+// GetValuesInPastMultiple for the same range while the API is unreachable,
+// and fails with ErrOffline if there is none. Streamed responses are not
+// stored themselves.
+func (c *Client) StreamValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, fn func(Value) error, opts ...CallOption) error {
+	if deviceID == "" {
+		return fmt.Errorf("deviceID must not be empty")
+	}
+
+	req, err := c.newValuesInPastMultipleRequest(ctx, deviceID, startDate, endDate, opts)
+	if err != nil {
+		return err
+	}
+
+	if c.rounding != nil || c.calibration != nil || c.bounds != nil {
+		next := fn
+		deviceID := requestDeviceID(req)
+		fn = func(v Value) error {
+			c.calibration.apply(req, &v)
+			c.rounding.round(&v)
+			if c.bounds != nil && !c.bounds.allowsHistory(deviceID, v.Value) {
+				return nil
+			}
+			return next(v)
+		}
+	}
+
+	// Streamed responses are served from the cache but never stored in
+	// it: keeping a copy would cost the memory streaming is meant to save.
+	if cacheKey := c.historyCacheKey(req, endDate); cacheKey != "" {
+		if data, ok := c.cache.Get(cacheKey); ok {
+			return c.decodeValues(bytes.NewReader(data), fn)
+		}
+	}
+
+	if c.offline.skipAPI(c.clock.Now()) {
+		return c.streamOffline(req, fn, ErrOffline)
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		if c.offline != nil && unreachable(err) {
+			c.offline.setOffline(c.clock.Now())
+			return c.streamOffline(req, fn, err)
+		}
+		if resp != nil {
+			c.offline.setOnline()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	c.offline.setOnline()
+	return c.decodeValues(resp.Body, fn)
+}
+
+func (c *Client) newValuesInPastMultipleRequest(ctx context.Context, deviceID string, startDate, endDate time.Time, opts []CallOption) (*http.Request, error) {
+	cfg := c.callConfig(opts)
+	query := url.Values{
+		"startDate": {cfg.formatDate(startDate)},
+		"endDate":   {cfg.formatDate(endDate)},
+	}
+	path := fmt.Sprintf("api/ValuesInPastMultiple/%s?%s", url.PathEscape(deviceID), query.Encode())
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return req, nil
+}
@@ -4,11 +4,13 @@ package smartme_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/rolacher/go-smartme-client"
 )
@@ -103,3 +105,115 @@ func TestClient_GetDevices_ServerError(t *testing.T) {
 		t.Errorf("Error message was '%s', want '%s'", err.Error(), expectedErrorMsg)
 	}
 }
+
+func TestClient_StreamValuesInPastMultiple(t *testing.T) {
+	client, mux, teardown := setup(t)
+	defer teardown()
+
+	mux.HandleFunc("/api/ValuesInPastMultiple/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("startDate") == "" || r.URL.Query().Get("endDate") == "" {
+			t.Errorf("startDate or endDate missing in %s", r.URL)
+		}
+		fmt.Fprint(w, `[{"date":"2025-01-01T00:00:00Z","value":1},{"date":"2025-01-01T00:15:00Z","value":2},{"date":"2025-01-01T00:30:00Z","value":3}]`)
+	})
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var got []float64
+	err := client.StreamValuesInPastMultiple(context.Background(), "dev-1", start, start.Add(time.Hour), func(v smartme.Value) error {
+		got = append(got, v.Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamValuesInPastMultiple returned an unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []float64{1, 2, 3}) {
+		t.Errorf("streamed values %v, want [1 2 3]", got)
+	}
+
+	// Returning an error from the callback stops the stream.
+	stop := errors.New("stop")
+	calls := 0
+	err = client.StreamValuesInPastMultiple(context.Background(), "dev-1", start, start.Add(time.Hour), func(v smartme.Value) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("got error %v after %d calls, want stop after 1 call", err, calls)
+	}
+}
+
+func TestClient_EmptyResponses(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	calls := []struct {
+		name    string
+		path    string
+		call    func(ctx context.Context, c *smartme.Client) (int, error)
+		wantErr error
+	}{
+		{"GetDevices", "/api/Devices", func(ctx context.Context, c *smartme.Client) (int, error) {
+			devices, err := c.GetDevices(ctx)
+			return len(devices), err
+		}, nil},
+		{"GetDevice", "/api/Devices/dev-1", func(ctx context.Context, c *smartme.Client) (int, error) {
+			_, err := c.GetDevice(ctx, "dev-1")
+			return 0, err
+		}, smartme.ErrNoData},
+		{"GetValues", "/api/Values/dev-1", func(ctx context.Context, c *smartme.Client) (int, error) {
+			_, err := c.GetValues(ctx, "dev-1")
+			return 0, err
+		}, smartme.ErrNoData},
+		{"GetValuesInPast", "/api/ValuesInPast/dev-1", func(ctx context.Context, c *smartme.Client) (int, error) {
+			_, err := c.GetValuesInPast(ctx, "dev-1", start)
+			return 0, err
+		}, smartme.ErrNoData},
+		{"GetValuesInPastMultiple", "/api/ValuesInPastMultiple/dev-1", func(ctx context.Context, c *smartme.Client) (int, error) {
+			values, err := c.GetValuesInPastMultiple(ctx, "dev-1", start, end)
+			return len(values), err
+		}, nil},
+		{"StreamValuesInPastMultiple", "/api/ValuesInPastMultiple/dev-1", func(ctx context.Context, c *smartme.Client) (int, error) {
+			n := 0
+			err := c.StreamValuesInPastMultiple(ctx, "dev-1", start, end, func(smartme.Value) error {
+				n++
+				return nil
+			})
+			return n, err
+		}, nil},
+		{"GetActivePower", "/api/Devices/dev-1", func(ctx context.Context, c *smartme.Client) (int, error) {
+			_, err := c.GetActivePower(ctx, "dev-1")
+			return 0, err
+		}, smartme.ErrNoData},
+	}
+
+	responses := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"204", http.StatusNoContent, ""},
+		{"empty 200", http.StatusOK, ""},
+		{"whitespace 200", http.StatusOK, " \n"},
+	}
+
+	for _, tc := range calls {
+		for _, r := range responses {
+			t.Run(tc.name+"/"+r.name, func(t *testing.T) {
+				client, mux, teardown := setup(t)
+				defer teardown()
+				mux.HandleFunc(tc.path, func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(r.status)
+					fmt.Fprint(w, r.body)
+				})
+
+				n, err := tc.call(context.Background(), client)
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tc.wantErr)
+				}
+				if n != 0 {
+					t.Errorf("got %d results, want none", n)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package smartme
+
+import "time"
+
+// Clock tells the client the current time and creates timers. Replace it
+// with WithClock to test time dependent code deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current
+	// time on the returned channel, like time.After.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock based on the time package. It is the default
+// of all Clock fields of this module.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// After returns time.After(d).
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockUser is implemented by caches that need the time of the client.
+type clockUser interface {
+	setClock(Clock)
+}
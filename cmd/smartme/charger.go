@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+const chargerUsage = "usage: smartme charger status|start|stop|set-current [flags] <device> [amps]"
+
+func runCharger(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(chargerUsage)
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("charger "+action, flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	watch := fs.Bool("watch", false, "status: follow the station and show the live session")
+	interval := fs.Duration("interval", 10*time.Second, "status: poll interval with -watch")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	wantArgs := 1
+	if action == "set-current" {
+		wantArgs = 2
+	}
+	if fs.NArg() != wantArgs {
+		return errors.New(chargerUsage)
+	}
+	deviceID := fs.Arg(0)
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	if action == "status" && *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return watchCharger(ctx, client, deviceID, *interval, stdout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch action {
+	case "status":
+		return chargerStatus(ctx, client, deviceID, stdout)
+	case "start":
+		if err := client.StartCharging(ctx, deviceID); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "charging started on %s\n", deviceID)
+	case "stop":
+		if err := client.StopCharging(ctx, deviceID); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "charging stopped on %s\n", deviceID)
+	case "set-current":
+		amps, err := strconv.ParseFloat(fs.Arg(1), 64)
+		if err != nil {
+			return fmt.Errorf("invalid current %q", fs.Arg(1))
+		}
+		if err := client.SetMaxChargingCurrent(ctx, deviceID, amps); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "charging current of %s limited to %g A\n", deviceID, amps)
+	default:
+		return fmt.Errorf("unknown charger command %q; %s", action, chargerUsage)
+	}
+	return nil
+}
+
+// chargerStatus prints the state, power, counter and the range of the
+// current limit of a charging station.
+func chargerStatus(ctx context.Context, api smartme.API, deviceID string, w io.Writer) error {
+	d, err := api.GetDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if d.ChargeStationState == nil {
+		return fmt.Errorf("device %s is not a charging station", deviceID)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "state:\t%s\n", *d.ChargeStationState)
+	if power, err := d.ActivePowerWatts(); err == nil {
+		fmt.Fprintf(tw, "power:\t%.0f W\n", power)
+	}
+	if counter, err := d.CounterReadingValue(); err == nil {
+		fmt.Fprintf(tw, "counter:\t%.3f kWh\n", counter)
+	}
+	// Not every station offers a current action; the status is useful
+	// without.
+	if action, err := smartme.ChargingCurrentAction(ctx, api, deviceID); err == nil {
+		fmt.Fprintf(tw, "current range:\t%g-%g A\n", math.Max(action.MinValue, smartme.MinChargingCurrent), action.MaxValue)
+	}
+	return tw.Flush()
+}
+
+// watchCharger follows a charging station until ctx is done and prints its
+// state transitions and the live session.
+func watchCharger(ctx context.Context, api smartme.API, deviceID string, interval time.Duration, w io.Writer) error {
+	view := &sessionView{w: w, now: time.Now}
+	watcher := smartme.NewWatcher(api, deviceID)
+	watcher.Interval = interval
+	watcher.OnDevice = view.device
+	watcher.OnChargeEvent = view.event
+	watcher.OnError = func(_ string, err error) {
+		fmt.Fprintf(os.Stderr, "device %s: %v\n", deviceID, err)
+	}
+	if err := watcher.Run(ctx); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// sessionView prints the live view of a charging station: one line per
+// poll with the energy delivered and the duration of the running session,
+// and one line per state transition.
+type sessionView struct {
+	w   io.Writer
+	now func() time.Time
+	// start and startCounter are the time and counter reading at the start
+	// of the running session; start is zero if the station is not charging.
+	start        time.Time
+	startCounter float64
+	// counter is the counter reading of the last poll in kWh.
+	counter float64
+	seen    bool
+}
+
+func (v *sessionView) device(d smartme.Device) {
+	counter, err := d.CounterReadingValue()
+	if err != nil {
+		return
+	}
+	v.counter = counter
+	now := v.now()
+	if !v.seen && d.ChargeStationState != nil {
+		// The watcher reports changes only, so print the state found at
+		// the start. A running session is shown from now on.
+		v.seen = true
+		fmt.Fprintf(v.w, "%s  %s\n", now.Format(time.TimeOnly), *d.ChargeStationState)
+		if *d.ChargeStationState == smartme.Charging {
+			v.start, v.startCounter = now, counter
+		}
+	}
+	if v.start.IsZero() {
+		return
+	}
+	power, _ := d.ActivePowerWatts()
+	fmt.Fprintf(v.w, "%s  charging  %.0f W  %.3f kWh  %s\n",
+		now.Format(time.TimeOnly), power, v.counter-v.startCounter, now.Sub(v.start).Round(time.Second))
+}
+
+func (v *sessionView) event(e smartme.ChargeEvent) {
+	fmt.Fprintf(v.w, "%s  %s -> %s (%s)\n", e.Time.Format(time.TimeOnly), e.From, e.To, e.Type)
+	switch e.Type {
+	case smartme.ChargingStarted:
+		v.start, v.startCounter = e.Time, v.counter
+	case smartme.ChargingStopped:
+		if !v.start.IsZero() {
+			fmt.Fprintf(v.w, "session: %.3f kWh in %s\n", v.counter-v.startCounter, e.Time.Sub(v.start).Round(time.Second))
+		}
+		v.start = time.Time{}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+)
+
+func TestChargerStatus(t *testing.T) {
+	mock := &smartmemock.API{
+		GetDeviceFunc: func(ctx context.Context, id string) (*smartme.Device, error) {
+			return &smartme.Device{
+				Id:                 ptr(id),
+				ChargeStationState: ptr(smartme.Charging),
+				ActivePower:        ptr(7.2),
+				ActivePowerUnit:    ptr("kW"),
+				CounterReading:     ptr(1234.5),
+			}, nil
+		},
+		GetActionsFunc: func(ctx context.Context, id string) ([]smartme.ActionInfo, error) {
+			return []smartme.ActionInfo{{ObisCode: "current", ActionType: smartme.ActionTypeAnalog, MinValue: 6, MaxValue: 16}}, nil
+		},
+	}
+
+	var out bytes.Buffer
+	if err := chargerStatus(context.Background(), mock, "pico", &out); err != nil {
+		t.Fatalf("chargerStatus failed: %v", err)
+	}
+	for _, want := range []string{"Charging", "7200 W", "1234.500 kWh", "6-16 A"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("status does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestSessionView(t *testing.T) {
+	start := time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC)
+	now := start
+	var out bytes.Buffer
+	v := &sessionView{w: &out, now: func() time.Time { return now }}
+
+	station := func(state smartme.ChargeStationState, counter float64) smartme.Device {
+		return smartme.Device{ChargeStationState: ptr(state), ActivePower: ptr(7200.0), CounterReading: ptr(counter)}
+	}
+	v.device(station(smartme.ReadyCarConnected, 100))
+	v.event(smartme.ChargeEvent{Type: smartme.ChargingStarted, From: smartme.ReadyCarConnected, To: smartme.Charging, Time: now})
+	now = now.Add(30 * time.Minute)
+	v.device(station(smartme.Charging, 103.6))
+	now = now.Add(30 * time.Minute)
+	v.device(station(smartme.ReadyCarConnected, 107.2))
+	v.event(smartme.ChargeEvent{Type: smartme.ChargingStopped, From: smartme.Charging, To: smartme.ReadyCarConnected, Time: now})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"18:00:00  ReadyCarConnected",
+		"18:00:00  ReadyCarConnected -> Charging (ChargingStarted)",
+		"18:30:00  charging  7200 W  3.600 kWh  30m0s",
+		"19:00:00  charging  7200 W  7.200 kWh  1h0m0s",
+		"19:00:00  Charging -> ReadyCarConnected (ChargingStopped)",
+		"session: 7.200 kWh in 1h0m0s",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("view:\n%s\nwant:\n%s", out.String(), strings.Join(want, "\n"))
+	}
+}
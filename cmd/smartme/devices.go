@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func runDevices(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("devices", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	tagsFile := fs.String("tags", "", "JSON file with the tags of the devices, keyed by device ID")
+	filter := fs.String("filter", "", "only list devices with these tags, e.g. building=A,floor=3")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	want, err := smartme.ParseTags(*filter)
+	if err != nil {
+		return err
+	}
+	store := smartme.NewTagStore()
+	if *tagsFile != "" {
+		data, err := os.ReadFile(*tagsFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, store); err != nil {
+			return fmt.Errorf("invalid tags file %s: %w", *tagsFile, err)
+		}
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return listDevices(ctx, client, store, want, stdout)
+}
+
+// listDevices prints the devices whose tags match want.
+func listDevices(ctx context.Context, api smartme.API, store *smartme.TagStore, want smartme.Tags, w io.Writer) error {
+	devices, err := api.GetDevices(ctx)
+	if err != nil {
+		return err
+	}
+	devices = smartme.FilterDevices(devices, smartme.ByTags(store, want))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tCAPABILITIES\tTAGS")
+	for _, d := range devices {
+		var id, name string
+		if d.Id != nil {
+			id = *d.Id
+		}
+		if d.Name != nil {
+			name = *d.Name
+		}
+		energyType := "unknown"
+		if d.DeviceEnergyType != nil {
+			energyType = energyTypeName(*d.DeviceEnergyType)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", id, name, energyType, smartme.Capabilities(d), store.Tags(id))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+)
+
+func TestListDevices(t *testing.T) {
+	mock := &smartmemock.API{
+		GetDevicesFunc: func(ctx context.Context) ([]smartme.Device, error) {
+			return []smartme.Device{
+				{Id: ptr("a"), Name: ptr("Flat 1"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity), SwitchOn: ptr(true)},
+				{Id: ptr("b"), Name: ptr("Flat 2")},
+			}, nil
+		},
+	}
+	store := smartme.NewTagStore()
+	store.Set("a", smartme.Tags{"building": "A", "floor": "3"})
+
+	var out bytes.Buffer
+	if err := listDevices(context.Background(), mock, store, smartme.Tags{"building": "A"}, &out); err != nil {
+		t.Fatalf("listDevices failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want header and one device:\n%s", len(lines), out.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "a Flat 1 electricity switch building=A,floor=3" {
+		t.Errorf("device line = %q", lines[1])
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+const defaultBaseURL = "https://api.smart-me.com/"
+
+// severity classifies a doctor finding.
+type severity int
+
+const (
+	sevOK severity = iota
+	sevWarn
+	sevFail
+)
+
+func (s severity) String() string {
+	switch s {
+	case sevOK:
+		return "OK"
+	case sevWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// finding is the result of a single doctor check.
+type finding struct {
+	check    string
+	severity severity
+	message  string
+	hint     string
+}
+
+// doctor runs a series of checks against the smart-me API.
+type doctor struct {
+	client     *smartme.Client
+	httpClient *http.Client
+	baseURL    string
+	maxSkew    time.Duration
+	maxAge     time.Duration
+	now        func() time.Time
+}
+
+func runDoctor(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	maxSkew := fs.Duration("max-skew", 30*time.Second, "maximum tolerated clock skew")
+	maxAge := fs.Duration("max-age", time.Hour, "maximum age of the latest device value")
+	timeout := fs.Duration("timeout", 30*time.Second, "overall timeout for all checks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cf.username == "" || cf.password == "" {
+		printFindings(stdout, []finding{{
+			check:    "credentials",
+			severity: sevFail,
+			message:  "username or password missing",
+			hint:     "set SMARTME_USERNAME and SMARTME_PASSWORD or pass -username and -password",
+		}})
+		return errors.New("checks failed")
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+
+	baseURL := cf.baseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	d := &doctor{
+		client:     client,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		baseURL:    baseURL,
+		maxSkew:    *maxSkew,
+		maxAge:     *maxAge,
+		now:        time.Now,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	findings := d.run(ctx)
+	printFindings(stdout, findings)
+	for _, f := range findings {
+		if f.severity == sevFail {
+			return errors.New("checks failed")
+		}
+	}
+	return nil
+}
+
+// run executes all checks and returns their findings in order.
+// Checks that depend on a working connection are skipped if it fails.
+func (d *doctor) run(ctx context.Context) []finding {
+	var findings []finding
+
+	findings = append(findings, d.checkClock(ctx))
+
+	devices, f := d.checkDevices(ctx)
+	findings = append(findings, f)
+	if f.severity == sevFail {
+		return findings
+	}
+
+	findings = append(findings, d.checkLicense(ctx, devices))
+	findings = append(findings, d.checkFreshness(devices)...)
+
+	return findings
+}
+
+// checkClock compares the local clock with the Date header of the API server.
+func (d *doctor) checkClock(ctx context.Context) finding {
+	f := finding{check: "clock"}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.baseURL, nil)
+	if err != nil {
+		f.severity = sevFail
+		f.message = err.Error()
+		return f
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		f.severity = sevFail
+		f.message = fmt.Sprintf("API not reachable: %v", err)
+		f.hint = "check network access and proxy settings for " + d.baseURL
+		return f
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		f.severity = sevWarn
+		f.message = "server did not send a usable Date header"
+		return f
+	}
+
+	skew := d.now().Sub(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > d.maxSkew {
+		f.severity = sevWarn
+		f.message = fmt.Sprintf("local clock differs from server by %s", skew.Round(time.Second))
+		f.hint = "enable NTP; history queries use local timestamps"
+		return f
+	}
+
+	f.message = fmt.Sprintf("skew %s", skew.Round(time.Second))
+	return f
+}
+
+// checkDevices verifies credentials and reachability by listing devices.
+func (d *doctor) checkDevices(ctx context.Context) ([]smartme.Device, finding) {
+	f := finding{check: "credentials"}
+
+	devices, err := d.client.GetDevices(ctx)
+	if err != nil {
+		f.severity = sevFail
+		f.message = err.Error()
+		var apiErr *smartme.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			f.hint = "username or password is wrong"
+		} else {
+			f.hint = "check network access to " + d.baseURL
+		}
+		return nil, f
+	}
+
+	f.message = fmt.Sprintf("authenticated, %d devices visible", len(devices))
+	if len(devices) == 0 {
+		f.severity = sevWarn
+		f.hint = "the account has no devices or the user lacks access to them"
+	}
+	return devices, f
+}
+
+// checkLicense probes an endpoint that requires a professional license.
+func (d *doctor) checkLicense(ctx context.Context, devices []smartme.Device) finding {
+	f := finding{check: "license"}
+
+	var deviceID string
+	for _, dev := range devices {
+		if dev.Id != nil {
+			deviceID = *dev.Id
+			break
+		}
+	}
+	if deviceID == "" {
+		f.severity = sevWarn
+		f.message = "no device available to probe professional features"
+		return f
+	}
+
+	end := d.now()
+	_, err := d.client.GetValuesInPastMultiple(ctx, deviceID, end.Add(-time.Hour), end)
+	if err != nil {
+		var apiErr *smartme.APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+			f.severity = sevWarn
+			f.message = "professional features not available"
+			f.hint = "ValuesInPastMultiple requires a professional license"
+			return f
+		}
+		f.severity = sevWarn
+		f.message = fmt.Sprintf("could not probe professional features: %v", err)
+		return f
+	}
+
+	f.message = "professional features available"
+	return f
+}
+
+// checkFreshness reports devices whose latest value is older than maxAge.
+func (d *doctor) checkFreshness(devices []smartme.Device) []finding {
+	var findings []finding
+	stale := 0
+
+	for _, dev := range devices {
+		name := deviceLabel(dev)
+		if dev.ValueDate == nil {
+			findings = append(findings, finding{
+				check:    "freshness",
+				severity: sevWarn,
+				message:  fmt.Sprintf("%s has never reported a value", name),
+				hint:     "check the device's network connection",
+			})
+			stale++
+			continue
+		}
+		valueDate, err := parseValueDate(*dev.ValueDate)
+		if err != nil {
+			findings = append(findings, finding{
+				check:    "freshness",
+				severity: sevWarn,
+				message:  fmt.Sprintf("%s has an unparsable value date %q", name, *dev.ValueDate),
+			})
+			stale++
+			continue
+		}
+		if age := d.now().Sub(valueDate); age > d.maxAge {
+			findings = append(findings, finding{
+				check:    "freshness",
+				severity: sevWarn,
+				message:  fmt.Sprintf("%s last reported %s ago", name, age.Round(time.Minute)),
+				hint:     "check the device's power and network connection",
+			})
+			stale++
+		}
+	}
+
+	if stale == 0 {
+		findings = append(findings, finding{
+			check:   "freshness",
+			message: fmt.Sprintf("all %d devices reported within %s", len(devices), d.maxAge),
+		})
+	}
+	return findings
+}
+
+// parseValueDate parses the valueDate field of a device, which may come
+// with or without a time zone offset.
+func parseValueDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04:05.999999999", s)
+}
+
+func deviceLabel(d smartme.Device) string {
+	switch {
+	case d.Name != nil && *d.Name != "":
+		return *d.Name
+	case d.Id != nil:
+		return *d.Id
+	default:
+		return "unnamed device"
+	}
+}
+
+func printFindings(w io.Writer, findings []finding) {
+	for _, f := range findings {
+		fmt.Fprintf(w, "[%-4s] %-12s %s\n", f.severity, f.check, f.message)
+		if f.hint != "" && f.severity != sevOK {
+			fmt.Fprintf(w, "       %-12s -> %s\n", "", f.hint)
+		}
+	}
+}
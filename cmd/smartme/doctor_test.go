@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestDoctor_Run(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", now.Add(2*time.Minute).Format(http.TimeFormat))
+	})
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		id, name, fresh, stale := "dev-1", "Fresh", now.Add(-time.Minute).Format(time.RFC3339), "2025-05-01T00:00:00"
+		json.NewEncoder(w).Encode([]smartme.Device{
+			{Id: &id, Name: &name, ValueDate: &fresh},
+			{Name: ptr("Stale"), ValueDate: &stale},
+		})
+	})
+	mux.HandleFunc("/api/ValuesInPastMultiple/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := smartme.NewClient("user", "pass", smartme.WithBaseURL(server.URL+"/"))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	d := &doctor{
+		client:     client,
+		httpClient: server.Client(),
+		baseURL:    server.URL + "/",
+		maxSkew:    30 * time.Second,
+		maxAge:     time.Hour,
+		now:        func() time.Time { return now },
+	}
+
+	findings := d.run(context.Background())
+
+	want := []struct {
+		check    string
+		severity severity
+	}{
+		{"clock", sevWarn},
+		{"credentials", sevOK},
+		{"license", sevWarn},
+		{"freshness", sevWarn},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i, w := range want {
+		if findings[i].check != w.check || findings[i].severity != w.severity {
+			t.Errorf("finding %d = %s/%s, want %s/%s", i, findings[i].check, findings[i].severity, w.check, w.severity)
+		}
+	}
+}
+
+func TestDoctor_Unauthorized(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, _ := smartme.NewClient("user", "wrong", smartme.WithBaseURL(server.URL+"/"))
+	d := &doctor{client: client, now: time.Now}
+
+	_, f := d.checkDevices(context.Background())
+	if f.severity != sevFail {
+		t.Fatalf("severity = %s, want FAIL", f.severity)
+	}
+	if f.hint != "username or password is wrong" {
+		t.Errorf("hint = %q", f.hint)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/importer"
+)
+
+func runImport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	format := fs.String("format", "", "input format, csv or ndjson (default from the file extension)")
+	batch := fs.Int("batch", importer.DefaultBatchSize, "readings per batch")
+	dryRun := fs.Bool("dry-run", false, "validate the readings without submitting them")
+	skipInvalid := fs.Bool("skip-invalid", false, "skip invalid readings instead of stopping")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: smartme import [flags] <file|->")
+	}
+
+	name := fs.Arg(0)
+	in := io.Reader(os.Stdin)
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return importReadings(ctx, client, in, *format, importer.Importer{BatchSize: *batch, DryRun: *dryRun}, *skipInvalid, stdout)
+}
+
+// importReadings imports the readings of in and prints the progress.
+func importReadings(ctx context.Context, api smartme.API, in io.Reader, format string, im importer.Importer, skipInvalid bool, w io.Writer) error {
+	var r importer.Reader
+	switch format {
+	case "csv":
+		r = importer.NewCSVReader(in)
+	case "ndjson", "jsonl":
+		r = importer.NewNDJSONReader(in)
+	default:
+		return fmt.Errorf("unknown format %q, use -format csv or ndjson", format)
+	}
+
+	im.OnProgress = func(p importer.Progress) { fmt.Fprintln(w, p) }
+	if skipInvalid {
+		im.OnInvalid = func(err *importer.RowError) { fmt.Fprintf(w, "skipped %v\n", err) }
+	}
+	p, err := im.Import(ctx, api, r)
+	if err != nil {
+		return fmt.Errorf("%w (%v)", err, p)
+	}
+	if im.DryRun {
+		fmt.Fprintln(w, "dry run, nothing was submitted")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/importer"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+)
+
+func TestImportReadings(t *testing.T) {
+	mock := &smartmemock.API{
+		GetDeviceFunc: func(ctx context.Context, id string) (*smartme.Device, error) {
+			return &smartme.Device{Id: ptr(id), Name: ptr("Flat 1")}, nil
+		},
+		CreateOrUpdateDeviceFunc: func(ctx context.Context, d smartme.Device) (*smartme.Device, error) {
+			return &d, nil
+		},
+	}
+	input := "device,date,value\na,2024-01-01T00:00:00Z,1\na,2024-01-01T01:00:00Z,0.5\na,2024-01-01T02:00:00Z,2\n"
+
+	var out bytes.Buffer
+	if err := importReadings(context.Background(), mock, strings.NewReader(input), "csv", importer.Importer{}, true, &out); err != nil {
+		t.Fatalf("importReadings failed: %v", err)
+	}
+	want := "skipped line 3: device a: counter decreases from 1 to 0.5\n3 read, 2 submitted, 1 skipped\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if n := mock.CallCount("CreateOrUpdateDevice"); n != 2 {
+		t.Errorf("%d readings submitted, want 2", n)
+	}
+
+	if err := importReadings(context.Background(), mock, strings.NewReader(input), "xml", importer.Importer{}, true, &out); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
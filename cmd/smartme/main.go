@@ -0,0 +1,94 @@
+// Command smartme is a small command line tool for the smart-me API.
+//
+// Credentials are read from the SMARTME_USERNAME and SMARTME_PASSWORD
+// environment variables unless given with the -username and -password flags.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// command is a single subcommand of the CLI.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "charger", usage: "show, start, stop or limit a charging station", run: runCharger},
+		{name: "devices", usage: "list devices with their tags, optionally filtered by tag", run: runDevices},
+		{name: "doctor", usage: "check credentials, connectivity and device data freshness", run: runDoctor},
+		{name: "import", usage: "submit counter readings from a CSV or NDJSON file", run: runImport},
+		{name: "obis", usage: "explain an OBIS code or list all known codes", run: runObis},
+		{name: "report", usage: "print the consumption and cost of a device for a month", run: runReport},
+		{name: "serve", usage: "push live device data to WebSocket clients", run: runServe},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "smartme %s: %v\n", name, err)
+				var apiErr *smartme.APIError
+				if errors.As(err, &apiErr) && apiErr.RequestID != "" {
+					fmt.Fprintf(os.Stderr, "request ID: %s\n", apiErr.RequestID)
+				}
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "smartme: unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(2)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: smartme <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// clientFlags holds the connection flags shared by all commands.
+type clientFlags struct {
+	username string
+	password string
+	baseURL  string
+}
+
+// register adds the shared connection flags to the given flag set.
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.username, "username", os.Getenv("SMARTME_USERNAME"), "smart-me username (default $SMARTME_USERNAME)")
+	fs.StringVar(&f.password, "password", os.Getenv("SMARTME_PASSWORD"), "smart-me password (default $SMARTME_PASSWORD)")
+	fs.StringVar(&f.baseURL, "base-url", "", "override the API base URL")
+}
+
+// newClient creates a smartme.Client from the shared connection flags.
+func (f *clientFlags) newClient() (*smartme.Client, error) {
+	var opts []smartme.Option
+	if f.baseURL != "" {
+		opts = append(opts, smartme.WithBaseURL(f.baseURL))
+	}
+	return smartme.NewClient(f.username, f.password, opts...)
+}
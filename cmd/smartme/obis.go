@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func runObis(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("obis", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: smartme obis <code> | smartme obis list")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected an OBIS code or \"list\"")
+	}
+
+	if fs.Arg(0) == "list" {
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CODE\tUNIT\tMETER\tFAMILIES\tDESCRIPTION")
+		for _, info := range smartme.ObisCatalog() {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", info.Code, info.Unit, energyTypeName(info.EnergyType), len(info.Families()), info.Description)
+		}
+		return tw.Flush()
+	}
+
+	info, ok := smartme.LookupObis(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("unknown OBIS code %q", smartme.NormalizeObis(fs.Arg(0)))
+	}
+	fmt.Fprintf(stdout, "Code:        %s\n", info.Code)
+	fmt.Fprintf(stdout, "Description: %s\n", info.Description)
+	if info.Unit != "" {
+		fmt.Fprintf(stdout, "Unit:        %s\n", info.Unit)
+	}
+	fmt.Fprintf(stdout, "Reported by: %s meters\n", energyTypeName(info.EnergyType))
+	label := "Families:"
+	for _, f := range info.Families() {
+		fmt.Fprintf(stdout, "%-12s %s\n", label, f.Description())
+		label = ""
+	}
+	return nil
+}
+
+func energyTypeName(t smartme.MeterEnergyType) string {
+	switch t {
+	case smartme.MeterTypeElectricity:
+		return "electricity"
+	case smartme.MeterTypeWater:
+		return "water"
+	case smartme.MeterTypeGas:
+		return "gas"
+	case smartme.MeterTypeHeat:
+		return "heat"
+	case smartme.MeterTypeAllMeters:
+		return "all"
+	default:
+		return fmt.Sprintf("type %d", t)
+	}
+}
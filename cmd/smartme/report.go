@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/billing"
+)
+
+func runReport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	deviceID := fs.String("device", "", "device ID")
+	month := fs.String("month", "", "month of the report, e.g. 2025-06")
+	tariffFile := fs.String("tariff", "", "tariff file (JSON)")
+	format := fs.String("format", "table", "output format: table, csv or json")
+	timeout := fs.Duration("timeout", 2*time.Minute, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *deviceID == "" || *month == "" || *tariffFile == "" {
+		return errors.New("usage: smartme report -device <id> -month 2025-06 -tariff tariff.json [-format table|csv|json]")
+	}
+
+	m, err := time.ParseInLocation("2006-01", *month, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid month %q, want e.g. 2025-06", *month)
+	}
+	tariff, err := loadTariff(*tariffFile)
+	if err != nil {
+		return err
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	r, err := buildReport(ctx, client, *deviceID, m, tariff)
+	if err != nil {
+		return err
+	}
+	return r.write(stdout, *format)
+}
+
+// tariffSpec is the tariff file, which is JSON.
+type tariffSpec struct {
+	Currency string `json:"currency"`
+	// EnergyPrice is the price per kWh of the total counter.
+	EnergyPrice float64 `json:"energyPrice"`
+	// RegisterPrices are the prices per kWh of the registers T1, T2, ...
+	RegisterPrices []float64 `json:"registerPrices"`
+	BaseFee        float64   `json:"baseFee"`
+}
+
+func loadTariff(path string) (billing.Tariff, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return billing.Tariff{}, fmt.Errorf("tariff file %s: tariff files must be JSON", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return billing.Tariff{}, err
+	}
+	var spec tariffSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return billing.Tariff{}, fmt.Errorf("invalid tariff file %s: %w", path, err)
+	}
+	return billing.Tariff{
+		Currency:       spec.Currency,
+		EnergyPrice:    spec.EnergyPrice,
+		RegisterPrices: spec.RegisterPrices,
+		BaseFee:        spec.BaseFee,
+	}, nil
+}
+
+// report is the consumption and cost of a device in one month.
+type report struct {
+	Device      string       `json:"device"`
+	Month       string       `json:"month"`
+	Currency    string       `json:"currency"`
+	Consumption float64      `json:"consumption"`
+	EnergyCost  float64      `json:"energyCost"`
+	BaseFee     float64      `json:"baseFee"`
+	Total       float64      `json:"total"`
+	Registers   []reportLine `json:"registers,omitempty"`
+	Days        []reportDay  `json:"days"`
+}
+
+// reportLine is the consumption of a tariff register.
+type reportLine struct {
+	Register    string  `json:"register"`
+	Consumption float64 `json:"consumption"`
+	Cost        float64 `json:"cost"`
+}
+
+// reportDay is the consumption of a day. The cost is only known with a
+// single energy price, since the day is not split by register.
+type reportDay struct {
+	Date        string   `json:"date"`
+	Consumption float64  `json:"consumption"`
+	Cost        *float64 `json:"cost,omitempty"`
+	Partial     bool     `json:"partial,omitempty"`
+}
+
+// buildReport bills the month with billing.Generate and adds the daily
+// consumption.
+func buildReport(ctx context.Context, api smartme.API, deviceID string, month time.Time, tariff billing.Tariff) (*report, error) {
+	start, end := smartme.MonthRange(month, nil)
+	bills, err := billing.Generate(ctx, api, []billing.Tenant{{Name: deviceID, DeviceID: deviceID}}, billing.Period{Start: start, End: end}, tariff)
+	if err != nil {
+		return nil, err
+	}
+	bill := bills.Bills[0]
+	r := &report{
+		Device:      deviceID,
+		Month:       start.Format("2006-01"),
+		Currency:    tariff.Currency,
+		Consumption: bill.Consumption,
+		EnergyCost:  bill.EnergyCost,
+		BaseFee:     bill.BaseFee,
+		Total:       bill.Total,
+	}
+	for _, rb := range bill.Registers {
+		r.Registers = append(r.Registers, reportLine{
+			Register:    fmt.Sprintf("T%d", rb.Register),
+			Consumption: rb.Consumption,
+			Cost:        rb.EnergyCost,
+		})
+	}
+
+	days, err := api.DailyConsumption(ctx, deviceID, start)
+	if err != nil {
+		return nil, fmt.Errorf("daily consumption: %w", err)
+	}
+	for _, p := range days {
+		day := reportDay{Date: p.Start.Format(time.DateOnly), Consumption: p.Consumption, Partial: p.Partial}
+		if len(tariff.RegisterPrices) == 0 {
+			cost := math.Round(p.Consumption*tariff.EnergyPrice*100) / 100
+			day.Cost = &cost
+		}
+		r.Days = append(r.Days, day)
+	}
+	return r, nil
+}
+
+func (r *report) write(w io.Writer, format string) error {
+	switch format {
+	case "table":
+		return r.writeTable(w)
+	case "csv":
+		return r.writeCSV(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	return fmt.Errorf("unknown format %q, want table, csv or json", format)
+}
+
+// writeTable writes the registers and totals followed by the days.
+func (r *report) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tKWH\tCOST (%s)\n", r.Month, r.Currency)
+	for _, l := range r.Registers {
+		fmt.Fprintf(tw, "%s\t%.3f\t%.2f\n", l.Register, l.Consumption, l.Cost)
+	}
+	fmt.Fprintf(tw, "energy\t%.3f\t%.2f\n", r.Consumption, r.EnergyCost)
+	fmt.Fprintf(tw, "base fee\t\t%.2f\n", r.BaseFee)
+	fmt.Fprintf(tw, "total\t\t%.2f\n", r.Total)
+	for _, d := range r.Days {
+		var cost string
+		if d.Cost != nil {
+			cost = strconv.FormatFloat(*d.Cost, 'f', 2, 64)
+		}
+		if d.Partial {
+			cost += " (so far)"
+		}
+		fmt.Fprintf(tw, "%s\t%.3f\t%s\n", d.Date, d.Consumption, cost)
+	}
+	return tw.Flush()
+}
+
+// writeCSV writes one line per register, the totals and one line per day.
+func (r *report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"device", "month", "item", "consumption (kWh)", "cost (" + r.Currency + ")"})
+	line := func(item string, consumption float64, cost string) {
+		cw.Write([]string{r.Device, r.Month, item, strconv.FormatFloat(consumption, 'f', 3, 64), cost})
+	}
+	for _, l := range r.Registers {
+		line(l.Register, l.Consumption, strconv.FormatFloat(l.Cost, 'f', 2, 64))
+	}
+	line("energy", r.Consumption, strconv.FormatFloat(r.EnergyCost, 'f', 2, 64))
+	cw.Write([]string{r.Device, r.Month, "base fee", "", strconv.FormatFloat(r.BaseFee, 'f', 2, 64)})
+	cw.Write([]string{r.Device, r.Month, "total", "", strconv.FormatFloat(r.Total, 'f', 2, 64)})
+	for _, d := range r.Days {
+		var cost string
+		if d.Cost != nil {
+			cost = strconv.FormatFloat(*d.Cost, 'f', 2, 64)
+		}
+		line(d.Date, d.Consumption, cost)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestBuildReport(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	for day := 0; day <= 30; day++ {
+		at := start.AddDate(0, 0, day)
+		t1, t2 := 1000+float64(day)*8, 500+float64(day)*2
+		srv.AddHistory("flat", smartme.Value{Date: at, Value: t1 + t2})
+		srv.AddPastValues(smartme.DeviceValues{DeviceID: "flat", Date: at, Values: []smartme.ObisValue{
+			{Obis: smartme.ObisActiveEnergyImportT1, Value: t1},
+			{Obis: smartme.ObisActiveEnergyImportT2, Value: t2},
+		}})
+	}
+	client, _ := srv.Client()
+
+	path := filepath.Join(t.TempDir(), "tariff.json")
+	os.WriteFile(path, []byte(`{"currency": "CHF", "registerPrices": [0.3, 0.2], "baseFee": 5}`), 0o600)
+	tariff, err := loadTariff(path)
+	if err != nil {
+		t.Fatalf("loadTariff failed: %v", err)
+	}
+
+	r, err := buildReport(context.Background(), client, "flat", start, tariff)
+	if err != nil {
+		t.Fatalf("buildReport failed: %v", err)
+	}
+	if r.Consumption != 300 || r.EnergyCost != 84 || r.Total != 89 {
+		t.Errorf("totals = %v kWh, %v energy, %v total", r.Consumption, r.EnergyCost, r.Total)
+	}
+	if len(r.Registers) != 2 || r.Registers[0].Consumption != 240 || r.Registers[1].Cost != 12 {
+		t.Errorf("registers = %+v", r.Registers)
+	}
+	if len(r.Days) != 30 || r.Days[0].Date != "2025-06-01" || r.Days[0].Consumption != 10 || r.Days[0].Cost != nil {
+		t.Errorf("days = %d, first %+v", len(r.Days), r.Days[0])
+	}
+
+	var out bytes.Buffer
+	if err := r.write(&out, "csv"); err != nil {
+		t.Fatalf("write csv failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1+2+3+30 || lines[1] != "flat,2025-06,T1,240.000,72.00" || lines[5] != "flat,2025-06,total,,89.00" {
+		t.Errorf("unexpected CSV:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := r.write(&out, "json"); err != nil {
+		t.Fatalf("write json failed: %v", err)
+	}
+	var decoded report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Total != 89 || len(decoded.Days) != 30 {
+		t.Errorf("JSON = %v, %v", decoded, err)
+	}
+
+	if err := r.write(&out, "xml"); err == nil {
+		t.Error("write should reject an unknown format")
+	}
+}
+
+func TestLoadTariff_YAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tariff.yaml")
+	os.WriteFile(path, []byte("currency: CHF\nenergyPrice: 0.3\n"), 0o600)
+	if _, err := loadTariff(path); err == nil || !strings.Contains(err.Error(), "must be JSON") {
+		t.Errorf("loadTariff of a YAML file: err = %v, want a hint to use JSON", err)
+	}
+}
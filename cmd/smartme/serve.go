@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/wsbridge"
+)
+
+func runServe(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	addr := fs.String("addr", "localhost:8080", "listen address of the WebSocket server")
+	path := fs.String("path", "/live", "URL path of the WebSocket endpoint")
+	interval := fs.Duration("interval", 30*time.Second, "poll interval of the devices")
+	origins := fs.String("origin", "", "comma separated origins of other pages allowed to connect, e.g. http://dashboard:1880")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Without device IDs, all devices of the account are watched.
+	ids := fs.Args()
+	if len(ids) == 0 {
+		devices, err := client.GetDevices(ctx)
+		if err != nil {
+			return err
+		}
+		for _, d := range devices {
+			if d.Id != nil {
+				ids = append(ids, *d.Id)
+			}
+		}
+	}
+
+	hub := wsbridge.NewHub()
+	if *origins != "" {
+		allowed := make(map[string]bool)
+		for _, o := range strings.Split(*origins, ",") {
+			allowed[strings.TrimSpace(o)] = true
+		}
+		hub.CheckOrigin = func(r *http.Request) bool {
+			o := r.Header.Get("Origin")
+			return o == "" || allowed[o] || o == "http://"+r.Host
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle(*path, hub)
+	srv := &http.Server{Addr: *addr, Handler: mux}
+
+	w := smartme.NewWatcher(client, ids...)
+	w.Interval = *interval
+	w.OnDevice = hub.Publish
+	w.OnError = func(id string, err error) {
+		fmt.Fprintf(os.Stderr, "device %s: %v\n", id, err)
+	}
+	go w.Run(ctx)
+
+	go func() {
+		<-ctx.Done()
+		hub.Close()
+		srv.Close()
+	}()
+	fmt.Fprintf(stdout, "serving %d devices on ws://%s%s\n", len(ids), *addr, *path)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package smartme
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Codec decodes API responses. The client uses JSONCodec by default; use
+// WithCodec to select XMLCodec when responses arrive as XML, e.g. behind
+// middleware that transforms them. Request bodies are always JSON.
+type Codec interface {
+	// ContentType is the media type sent in the Accept header.
+	ContentType() string
+	// Unmarshal decodes a response body into v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec decodes JSON responses.
+type JSONCodec struct{}
+
+// ContentType returns "application/json".
+func (JSONCodec) ContentType() string { return "application/json" }
+
+// Unmarshal decodes data with encoding/json.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// XMLCodec decodes the XML responses of the API, which uses the element
+// names of the .NET models, like <ArrayOfDevice><Device><Id>...</Id>.
+// Enumerations must be sent as numbers.
+type XMLCodec struct{}
+
+// ContentType returns "application/xml".
+func (XMLCodec) ContentType() string { return "application/xml" }
+
+// Unmarshal decodes data with encoding/xml. If v points to a slice, every
+// child element of the root element is decoded into an element of the
+// slice, whatever its name.
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return xml.Unmarshal(data, v)
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	slice := rv.Elem()
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if depth > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				depth++
+				continue
+			}
+			elem := reflect.New(slice.Type().Elem())
+			if err := dec.DecodeElement(elem.Interface(), &t); err != nil {
+				return err
+			}
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				if slice.IsNil() {
+					slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// WithCodec sets the codec used to decode responses.
+func WithCodec(codec Codec) Option {
+	return func(c *Client) error {
+		if codec == nil {
+			return errors.New("codec must not be nil")
+		}
+		c.codec = codec
+		return nil
+	}
+}
+
+// decodeValues calls fn for every value of a response body. JSON is
+// decoded incrementally, other formats are decoded at once.
+func (c *Client) decodeValues(r io.Reader, fn func(Value) error) error {
+	if _, ok := c.codec.(JSONCodec); ok {
+		return decodeValueStream(r, fn)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var values []Value
+	if err := c.codec.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	for _, v := range values {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalText decodes a timestamp from XML character data.
+func (t *apiTime) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if s == "" {
+		return nil
+	}
+	parsed, err := parseAPITime(s)
+	if err != nil {
+		return err
+	}
+	*t = apiTime(parsed)
+	return nil
+}
+
+// UnmarshalText decodes a number from XML character data.
+func (f *apiFloat) UnmarshalText(text []byte) error {
+	return f.UnmarshalJSON([]byte(fmt.Sprintf("%q", strings.TrimSpace(string(text)))))
+}
+
+// UnmarshalXML decodes a Value from <Date> and <Value> elements.
+func (v *Value) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw struct {
+		Date  apiTime  `xml:"Date"`
+		Value apiFloat `xml:"Value"`
+	}
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	v.Date = time.Time(raw.Date)
+	v.Value = float64(raw.Value)
+	return nil
+}
+
+// UnmarshalXML decodes an ObisValue from <Obis> and <Value> elements.
+func (o *ObisValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw struct {
+		Obis  string   `xml:"Obis"`
+		Value apiFloat `xml:"Value"`
+	}
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	o.Obis = strings.TrimSpace(raw.Obis)
+	o.Value = float64(raw.Value)
+	return nil
+}
+
+// UnmarshalXML decodes DeviceValues. The values are the children of the
+// <Values> element.
+func (dv *DeviceValues) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw struct {
+		DeviceID string  `xml:"DeviceId"`
+		Date     apiTime `xml:"Date"`
+		Values   struct {
+			Items []ObisValue `xml:",any"`
+		} `xml:"Values"`
+	}
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	dv.DeviceID = strings.TrimSpace(raw.DeviceID)
+	dv.Date = time.Time(raw.Date)
+	dv.Values = raw.Values.Items
+	if dv.Values == nil {
+		dv.Values = []ObisValue{}
+	}
+	return nil
+}
+
+// deviceBoolFields holds the lower-case JSON names of the bool fields of
+// Device, whose XML text "true" and "false" is decoded as a bool.
+var deviceBoolFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	typ := reflect.TypeOf(Device{})
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Bool {
+			fields[strings.ToLower(strings.Split(f.Tag.Get("json"), ",")[0])] = true
+		}
+	}
+	return fields
+}()
+
+// UnmarshalXML decodes a Device. The child elements are matched with the
+// fields like JSON keys, so the same conversions apply; elements marked
+// nil are skipped.
+func (dev *Device) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw struct {
+		Fields []struct {
+			XMLName xml.Name
+			Nil     string `xml:"nil,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:",any"`
+	}
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+
+	fields := make(map[string]interface{}, len(raw.Fields))
+	for _, f := range raw.Fields {
+		if f.Nil == "true" {
+			continue
+		}
+		text := strings.TrimSpace(f.Text)
+		if deviceBoolFields[strings.ToLower(f.XMLName.Local)] && (text == "true" || text == "false") {
+			fields[f.XMLName.Local] = text == "true"
+			continue
+		}
+		fields[f.XMLName.Local] = text
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	*dev = Device{}
+	return dev.UnmarshalJSON(data)
+}
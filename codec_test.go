@@ -0,0 +1,108 @@
+package smartme_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestXMLCodec(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != "application/xml" {
+			t.Errorf("Accept = %q, want application/xml", got)
+		}
+		w.Write([]byte(`<ArrayOfDevice xmlns:i="http://www.w3.org/2001/XMLSchema-instance">
+			<Device><Id>dev-1</Id><Name>Flat 1</Name><DeviceEnergyType>1</DeviceEnergyType>
+				<ActivePower>1.5</ActivePower><SwitchOn>true</SwitchOn><Temperature i:nil="true"/></Device>
+			<Device><Id>dev-2</Id><Name>true</Name></Device>
+		</ArrayOfDevice>`))
+	})
+	mux.HandleFunc("/api/Values/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<DeviceValue><DeviceId>dev-1</DeviceId><Date>2025-01-01T12:00:00</Date>
+			<Values><ObisValue><Obis>1-0:1.8.0*255</Obis><Value>1234.5</Value></ObisValue></Values></DeviceValue>`))
+	})
+	historyRequests := 0
+	mux.HandleFunc("/api/ValuesInPastMultiple/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		historyRequests++
+		if r.Header.Get("Accept") == "application/json" {
+			w.Write([]byte(`[{"Date":"2025-01-01T00:00:00Z","Value":3}]`))
+			return
+		}
+		w.Write([]byte(`<ArrayOfValue><Value><Date>2025-01-01T00:00:00Z</Date><Value>1</Value></Value>
+			<Value><Date>2025-01-01T00:15:00Z</Date><Value>2</Value></Value></ArrayOfValue>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cache, err := smartme.NewDiskCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+	client, err := smartme.NewClient("test-user", "test-pass", smartme.WithBaseURL(srv.URL),
+		smartme.WithCodec(smartme.XMLCodec{}), smartme.WithCache(cache, time.Hour))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("got %d devices, want 2", len(devices))
+	}
+	d := devices[0]
+	if *d.Id != "dev-1" || *d.Name != "Flat 1" || *d.DeviceEnergyType != smartme.MeterTypeElectricity ||
+		*d.ActivePower != 1.5 || !*d.SwitchOn || d.Temperature != nil {
+		t.Errorf("unexpected device: %+v", d)
+	}
+	if *devices[1].Name != "true" {
+		t.Errorf("Name = %q, want \"true\"", *devices[1].Name)
+	}
+
+	values, err := client.GetValues(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetValues failed: %v", err)
+	}
+	if values.DeviceID != "dev-1" || !values.Date.Equal(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)) ||
+		len(values.Values) != 1 || values.Values[0].Value != 1234.5 {
+		t.Errorf("unexpected values: %+v", values)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var streamed []float64
+	err = client.StreamValuesInPastMultiple(ctx, "dev-1", start, start.Add(time.Hour), func(v smartme.Value) error {
+		streamed = append(streamed, v.Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamValuesInPastMultiple failed: %v", err)
+	}
+	if len(streamed) != 2 || streamed[1] != 2 {
+		t.Errorf("streamed %v, want [1 2]", streamed)
+	}
+
+	// A JSON client sharing the cache keeps its own entry; neither client
+	// is served the other's response.
+	jsonClient, _ := smartme.NewClient("test-user", "test-pass", smartme.WithBaseURL(srv.URL), smartme.WithCache(cache, time.Hour))
+	for i, c := range []*smartme.Client{client, jsonClient, client, jsonClient} {
+		want := 2
+		if c == jsonClient {
+			want = 1
+		}
+		history, err := c.GetValuesInPastMultiple(ctx, "dev-1", start, start.Add(time.Hour))
+		if err != nil || len(history) != want {
+			t.Errorf("call %d: got %v, %v, want %d values", i, history, err, want)
+		}
+	}
+	// One streamed request and one per codec.
+	if historyRequests != 3 {
+		t.Errorf("server received %d history requests, want 3", historyRequests)
+	}
+}
@@ -0,0 +1,123 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ConsumptionComparison compares the consumption of one device in two periods.
+type ConsumptionComparison struct {
+	DeviceID string
+	// A and B are the consumption in the two periods in kWh.
+	A, B float64
+	// Delta is B - A.
+	Delta float64
+	// Change is Delta relative to A, e.g. 0.1 for 10 % more. It is 0 if A is 0.
+	Change float64
+	// RankA and RankB are the positions by consumption among all compared
+	// devices, 1 being the highest.
+	RankA, RankB int
+	// VsAverage is B relative to the average B of all devices, e.g. -0.2
+	// for 20 % below average. It is 0 if the average is 0.
+	VsAverage float64
+}
+
+// ConsumptionReport is the result of CompareConsumption.
+type ConsumptionReport struct {
+	PeriodA, PeriodB Period
+	// Devices is in the order of the requested device IDs.
+	Devices []ConsumptionComparison
+	// TotalA, TotalB and the averages are computed over Devices.
+	TotalA, TotalB     float64
+	AverageA, AverageB float64
+}
+
+// CompareConsumption computes the consumption of several devices in two
+// periods, e.g. this month and last month, with per-device deltas,
+// rankings and the deviation from the average. Consumption is the
+// difference of the counter readings at the period boundaries. The devices
+// are fetched concurrently; devices that fail are left out and reported
+// with a *BulkError.
+func (c *Client) CompareConsumption(ctx context.Context, deviceIDs []string, periodA, periodB Period, opts ...CallOption) (*ConsumptionReport, error) {
+	results := make([]*ConsumptionComparison, len(deviceIDs))
+	err := c.forEachDevice(ctx, deviceIDs, func(i int, id string) error {
+		a, err := c.consumption(ctx, id, periodA, opts)
+		if err != nil {
+			return err
+		}
+		b, err := c.consumption(ctx, id, periodB, opts)
+		if err != nil {
+			return err
+		}
+		results[i] = &ConsumptionComparison{DeviceID: id, A: a, B: b, Delta: b - a}
+		return nil
+	})
+	if err != nil && !isBulkError(err) {
+		return nil, err
+	}
+
+	report := &ConsumptionReport{PeriodA: periodA, PeriodB: periodB}
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		if r.A != 0 {
+			r.Change = r.Delta / r.A
+		}
+		report.Devices = append(report.Devices, *r)
+		report.TotalA += r.A
+		report.TotalB += r.B
+	}
+	if n := len(report.Devices); n > 0 {
+		report.AverageA = report.TotalA / float64(n)
+		report.AverageB = report.TotalB / float64(n)
+	}
+	for i := range report.Devices {
+		if report.AverageB != 0 {
+			report.Devices[i].VsAverage = report.Devices[i].B/report.AverageB - 1
+		}
+	}
+	rank(report.Devices, func(d *ConsumptionComparison) float64 { return d.A }, func(d *ConsumptionComparison, r int) { d.RankA = r })
+	rank(report.Devices, func(d *ConsumptionComparison) float64 { return d.B }, func(d *ConsumptionComparison, r int) { d.RankB = r })
+
+	return report, err
+}
+
+// consumption returns the difference of the counter readings at the
+// boundaries of p.
+func (c *Client) consumption(ctx context.Context, deviceID string, p Period, opts []CallOption) (float64, error) {
+	if !p.End.After(p.Start) {
+		return 0, errors.New("period must end after it starts")
+	}
+	start, err := c.GetValuesInPast(ctx, deviceID, p.Start, opts...)
+	if err != nil {
+		return 0, fmt.Errorf("reading at %s: %w", p.Start.Format("2006-01-02"), err)
+	}
+	end, err := c.GetValuesInPast(ctx, deviceID, p.End, opts...)
+	if err != nil {
+		return 0, fmt.Errorf("reading at %s: %w", p.End.Format("2006-01-02"), err)
+	}
+	return end.Value - start.Value, nil
+}
+
+// rank sets the 1-based rank by descending value. Equal values share a rank.
+func rank(devices []ConsumptionComparison, value func(*ConsumptionComparison) float64, set func(*ConsumptionComparison, int)) {
+	order := make([]int, len(devices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return value(&devices[order[i]]) > value(&devices[order[j]])
+	})
+	prev := 0
+	for pos, i := range order {
+		r := pos + 1
+		if pos > 0 && value(&devices[i]) == value(&devices[order[pos-1]]) {
+			r = prev
+		}
+		set(&devices[i], r)
+		prev = r
+	}
+}
@@ -0,0 +1,61 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_CompareConsumption(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := jan.AddDate(0, 1, 0)
+	mar := feb.AddDate(0, 1, 0)
+	// Counter readings at the start of January, February and March.
+	readings := map[string][3]float64{
+		"apt-1": {0, 100, 250},
+		"apt-2": {0, 200, 300},
+		"apt-3": {0, 100, 150},
+	}
+	for id, r := range readings {
+		srv.AddHistory(id,
+			smartme.Value{Date: jan, Value: r[0]},
+			smartme.Value{Date: feb, Value: r[1]},
+			smartme.Value{Date: mar, Value: r[2]},
+		)
+	}
+	client, _ := srv.Client()
+
+	ids := []string{"apt-1", "apt-2", "apt-3", "missing"}
+	report, err := client.CompareConsumption(context.Background(), ids,
+		smartme.Period{Start: jan, End: feb}, smartme.Period{Start: feb, End: mar})
+
+	var bulkErr *smartme.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Errors) != 1 || bulkErr.Errors["missing"] == nil {
+		t.Fatalf("error = %v, want a BulkError for the missing device", err)
+	}
+	if len(report.Devices) != 3 || report.TotalA != 400 || report.TotalB != 300 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	want := []smartme.ConsumptionComparison{
+		{DeviceID: "apt-1", A: 100, B: 150, Delta: 50, Change: 0.5, RankA: 2, RankB: 1, VsAverage: 0.5},
+		{DeviceID: "apt-2", A: 200, B: 100, Delta: -100, Change: -0.5, RankA: 1, RankB: 2, VsAverage: 0},
+		{DeviceID: "apt-3", A: 100, B: 50, Delta: -50, Change: -0.5, RankA: 2, RankB: 3, VsAverage: -0.5},
+	}
+	for i, w := range want {
+		got := report.Devices[i]
+		if got.DeviceID != w.DeviceID || got.A != w.A || got.B != w.B || got.Delta != w.Delta ||
+			got.RankA != w.RankA || got.RankB != w.RankB ||
+			math.Abs(got.Change-w.Change) > 1e-9 || math.Abs(got.VsAverage-w.VsAverage) > 1e-9 {
+			t.Errorf("device %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
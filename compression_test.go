@@ -0,0 +1,69 @@
+package smartme_test
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// newGzipServer returns a server that gzips the device list if the client
+// asks for it and records the Accept-Encoding header it received.
+func newGzipServer(t *testing.T, acceptEncoding *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*acceptEncoding = r.Header.Get("Accept-Encoding")
+		body := `[{"id":"dev-1","name":"Compressed"}]`
+		if *acceptEncoding != "gzip" {
+			w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(body))
+		zw.Close()
+	}))
+}
+
+func TestClient_Gzip(t *testing.T) {
+	var acceptEncoding string
+	server := newGzipServer(t, &acceptEncoding)
+	defer server.Close()
+
+	// A transport with compression disabled does not decode gzip by itself.
+	transport := &http.Transport{DisableCompression: true}
+	client, _ := smartme.NewClient("user", "pass",
+		smartme.WithBaseURL(server.URL+"/"),
+		smartme.WithHTTPClient(&http.Client{Transport: transport}))
+
+	devices, err := client.GetDevices(context.Background())
+	if err != nil {
+		t.Fatalf("GetDevices returned an unexpected error: %v", err)
+	}
+	if acceptEncoding != "gzip" {
+		t.Errorf("Accept-Encoding = %q, want gzip", acceptEncoding)
+	}
+	if len(devices) != 1 || *devices[0].Name != "Compressed" {
+		t.Errorf("GetDevices returned %+v", devices)
+	}
+}
+
+func TestClient_WithoutCompression(t *testing.T) {
+	var acceptEncoding string
+	server := newGzipServer(t, &acceptEncoding)
+	defer server.Close()
+
+	// The default client, whose transport would ask for gzip by itself.
+	client, _ := smartme.NewClient("user", "pass",
+		smartme.WithBaseURL(server.URL+"/"),
+		smartme.WithoutCompression())
+
+	if _, err := client.GetDevices(context.Background()); err != nil {
+		t.Fatalf("GetDevices returned an unexpected error: %v", err)
+	}
+	if acceptEncoding != "identity" {
+		t.Errorf("Accept-Encoding = %q, want identity", acceptEncoding)
+	}
+}
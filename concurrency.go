@@ -0,0 +1,175 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// WithConcurrency bounds the number of requests in flight to n, shared by
+// everything that uses the client and the copies made with With: bulk
+// calls, comparisons, watchers and subsystems like scenes or billing. The
+// limit is global, so features running side by side cannot together
+// exceed what the account may send. Bulk calls also use n workers instead
+// of 8. A request holds its slot until the response headers arrive.
+//
+// Requests waiting for a slot are served by their Priority, see
+// ContextWithPriority.
+//
+// Without this option requests are not limited and every bulk call runs up
+// to 8 requests in parallel on its own.
+func WithConcurrency(n int) Option {
+	return func(c *Client) error {
+		if n <= 0 {
+			return errors.New("concurrency must be positive")
+		}
+		c.limiter = newLimiter(n)
+		return nil
+	}
+}
+
+// Priority is the class of a request when requests wait for a slot of
+// WithConcurrency. Lower values are served first.
+type Priority int
+
+const (
+	// PriorityInteractive is for requests a user waits for, like a
+	// dashboard loading. It is the default.
+	PriorityInteractive Priority = iota
+	// PriorityWatcher is for live polling; Watchers use it unless their
+	// context has a priority.
+	PriorityWatcher
+	// PriorityBackfill is for bulk historical fetches that can wait.
+	PriorityBackfill
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "Interactive"
+	case PriorityWatcher:
+		return "Watcher"
+	case PriorityBackfill:
+		return "Backfill"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+type priorityKey struct{}
+
+// ContextWithPriority returns a context whose requests wait for a slot of
+// WithConcurrency with priority p, so a large backfill cannot starve the
+// live polls sharing the limit:
+//
+//	ctx = smartme.ContextWithPriority(ctx, smartme.PriorityBackfill)
+//	values, err := client.GetValuesInPastMultiple(ctx, id, start, end)
+//
+// A waiting request gets a free slot only when no request of a higher
+// priority waits; requests of the same priority are served in order.
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set with ContextWithPriority,
+// if any.
+func PriorityFromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	return p, ok
+}
+
+// limiter is a semaphore bounding the requests in flight that hands free
+// slots to the waiters of the highest priority first. A nil limiter does
+// not limit.
+type limiter struct {
+	size int
+
+	mu    sync.Mutex
+	used  int
+	queue [PriorityBackfill + 1][]*waiter
+}
+
+// waiter is a request waiting for a slot. ready is closed when it got one.
+type waiter struct {
+	ready chan struct{}
+}
+
+func newLimiter(size int) *limiter {
+	return &limiter{size: size}
+}
+
+// acquire waits for a slot and returns the function that releases it.
+func (l *limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	p, _ := PriorityFromContext(ctx)
+	p = min(max(p, PriorityInteractive), PriorityBackfill)
+
+	l.mu.Lock()
+	if l.used < l.size && l.waiting() == 0 {
+		l.used++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	w := &waiter{ready: make(chan struct{})}
+	l.queue[p] = append(l.queue[p], w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.release, nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, q := range l.queue[p] {
+		if q == w {
+			l.queue[p] = append(l.queue[p][:i], l.queue[p][i+1:]...)
+			return nil, ctx.Err()
+		}
+	}
+	// The slot was handed over while ctx was done; pass it on.
+	l.handOver()
+	return nil, ctx.Err()
+}
+
+// release frees a slot.
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handOver()
+}
+
+// handOver gives the slot of a finished request to the first waiter of the
+// highest priority, or frees it. l.mu must be held.
+func (l *limiter) handOver() {
+	for p := range l.queue {
+		if len(l.queue[p]) > 0 {
+			w := l.queue[p][0]
+			l.queue[p] = l.queue[p][1:]
+			close(w.ready)
+			return
+		}
+	}
+	l.used--
+}
+
+// waiting returns the number of waiting requests. l.mu must be held.
+func (l *limiter) waiting() int {
+	n := 0
+	for _, q := range l.queue {
+		n += len(q)
+	}
+	return n
+}
+
+// workers returns the number of parallel calls of bulk operations.
+func (c *Client) workers() int {
+	if c.limiter != nil {
+		return c.limiter.size
+	}
+	return defaultBulkConcurrency
+}
@@ -0,0 +1,119 @@
+package smartme_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestWithConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, `{"deviceId":"x","values":[]}`)
+	}))
+	defer srv.Close()
+
+	client, err := smartme.NewClient("user", "pass", smartme.WithBaseURL(srv.URL+"/"), smartme.WithConcurrency(3))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	// Copies share the limit.
+	copied, err := client.With(smartme.WithTimeout(time.Minute))
+	if err != nil {
+		t.Fatalf("With failed: %v", err)
+	}
+
+	ids := []string{"a", "b", "c", "d", "e", "f"}
+	var wg sync.WaitGroup
+	for _, c := range []*smartme.Client{client, copied, client} {
+		wg.Add(1)
+		go func(c *smartme.Client) {
+			defer wg.Done()
+			if _, err := c.GetValuesConcurrently(context.Background(), ids); err != nil {
+				t.Errorf("GetValuesConcurrently failed: %v", err)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if got := maxInFlight.Load(); got > 3 {
+		t.Errorf("%d requests in flight, want at most 3", got)
+	}
+}
+
+func TestWithConcurrency_ContextDone(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"deviceId":"x","values":[]}`)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, _ := smartme.NewClient("user", "pass", smartme.WithBaseURL(srv.URL+"/"), smartme.WithConcurrency(1))
+	go client.GetValues(context.Background(), "busy")
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetValues(ctx, "waiting"); err != context.DeadlineExceeded {
+		t.Errorf("error = %v, want context.DeadlineExceeded while waiting for a slot", err)
+	}
+}
+
+func TestContextWithPriority(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/api/Values/first" {
+			<-release
+		}
+		fmt.Fprint(w, `{"deviceId":"x","values":[]}`)
+	}))
+	defer srv.Close()
+
+	client, _ := smartme.NewClient("user", "pass", smartme.WithBaseURL(srv.URL+"/"), smartme.WithConcurrency(1))
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	get := func(ctx context.Context, id string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetValues(ctx, id); err != nil {
+				t.Errorf("GetValues(%s) failed: %v", id, err)
+			}
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	get(ctx, "first")
+	get(smartme.ContextWithPriority(ctx, smartme.PriorityBackfill), "backfill")
+	get(smartme.ContextWithPriority(ctx, smartme.PriorityWatcher), "watcher")
+	get(ctx, "interactive")
+	close(release)
+	wg.Wait()
+
+	want := []string{"/api/Values/first", "/api/Values/interactive", "/api/Values/watcher", "/api/Values/backfill"}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
@@ -0,0 +1,282 @@
+//go:build contract
+
+// contract_test.go
+package smartme_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// The contract tests compare the Go models and client paths with the
+// published OpenAPI (swagger 2.0) document of the smart-me API. They fail
+// when the upstream API drifts from the models. Run them with:
+//
+//	go test -v -tags=contract .
+//
+// SMARTME_SWAGGER may point to a local copy of the document (file path or URL).
+const defaultSwaggerURL = "https://api.smart-me.com/swagger/docs/v1"
+
+type swaggerDoc struct {
+	Paths       map[string]map[string]swaggerOperation `json:"paths"`
+	Definitions map[string]swaggerSchema               `json:"definitions"`
+}
+
+type swaggerOperation struct {
+	Parameters []struct {
+		In     string         `json:"in"`
+		Schema *swaggerSchema `json:"schema"`
+	} `json:"parameters"`
+	Responses map[string]struct {
+		Schema *swaggerSchema `json:"schema"`
+	} `json:"responses"`
+}
+
+type swaggerSchema struct {
+	Ref        string                   `json:"$ref"`
+	Type       string                   `json:"type"`
+	Format     string                   `json:"format"`
+	Items      *swaggerSchema           `json:"items"`
+	Enum       []json.Number            `json:"enum"`
+	Properties map[string]swaggerSchema `json:"properties"`
+	Required   []string                 `json:"required"`
+}
+
+// knownEnumValues lists the constants defined for each enum type.
+var knownEnumValues = map[reflect.Type][]int64{
+	reflect.TypeOf(smartme.MeterEnergyType(0)):    {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14},
+	reflect.TypeOf(smartme.MeterSubType(0)):       {0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	reflect.TypeOf(smartme.MeterFamilyType(0)):    {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 14, 16, 17, 18, 19, 20, 21, 65, 70, 1001, 1002},
+	reflect.TypeOf(smartme.ChargeStationState(0)): {0, 1, 2, 3, 4, 5, 6, 7},
+}
+
+// clientEndpoints maps the paths used by the client to the model returned
+// by GET, or sent as body by other methods.
+var clientEndpoints = []struct {
+	method string
+	path   string
+	model  reflect.Type
+}{
+	{"get", "/api/Devices", reflect.TypeOf(smartme.Device{})},
+	{"post", "/api/Devices", reflect.TypeOf(smartme.Device{})},
+	{"get", "/api/Devices/{id}", reflect.TypeOf(smartme.Device{})},
+	{"get", "/api/Values/{id}", reflect.TypeOf(smartme.DeviceValues{})},
+	{"get", "/api/ValuesInPast/{id}", reflect.TypeOf(smartme.Value{})},
+	{"get", "/api/ValuesInPastMultiple/{id}", reflect.TypeOf(smartme.Value{})},
+	{"get", "/api/Actions/{id}", reflect.TypeOf(smartme.ActionInfo{})},
+	{"post", "/api/Actions", reflect.TypeOf(smartme.ActionsRequest{})},
+	{"get", "/api/MeterValues/{id}", reflect.TypeOf(smartme.DeviceValues{})},
+}
+
+func loadSwagger(t *testing.T) *swaggerDoc {
+	t.Helper()
+
+	source := os.Getenv("SMARTME_SWAGGER")
+	if source == "" {
+		source = defaultSwaggerURL
+	}
+
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		var resp *http.Response
+		resp, err = client.Get(source)
+		if err == nil {
+			defer resp.Body.Close()
+			data, err = io.ReadAll(resp.Body)
+		}
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		t.Skipf("Skipping contract test: cannot load swagger document from %s: %v", source, err)
+	}
+
+	var doc swaggerDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to parse swagger document: %v", err)
+	}
+	return &doc
+}
+
+// resolve follows a $ref to its definition.
+func (d *swaggerDoc) resolve(s swaggerSchema) (swaggerSchema, string) {
+	if s.Ref == "" {
+		return s, ""
+	}
+	name := strings.TrimPrefix(s.Ref, "#/definitions/")
+	return d.Definitions[name], name
+}
+
+// pathSchema finds a path case-insensitively and returns the 200 schema of
+// GET or the body schema of other methods.
+func (d *swaggerDoc) pathSchema(method, path string) (*swaggerSchema, bool) {
+	for p, ops := range d.Paths {
+		if !strings.EqualFold(p, path) {
+			continue
+		}
+		op, ok := ops[method]
+		if !ok {
+			return nil, false
+		}
+		if method != "get" {
+			for _, param := range op.Parameters {
+				if param.In == "body" {
+					return param.Schema, true
+				}
+			}
+			return nil, false
+		}
+		resp, ok := op.Responses["200"]
+		return resp.Schema, ok
+	}
+	return nil, false
+}
+
+func TestContract_Paths(t *testing.T) {
+	doc := loadSwagger(t)
+
+	for _, ep := range clientEndpoints {
+		schema, ok := doc.pathSchema(ep.method, ep.path)
+		if !ok {
+			t.Errorf("path %s %s used by the client is not in the API document", strings.ToUpper(ep.method), ep.path)
+			continue
+		}
+		if schema == nil {
+			continue
+		}
+		s := *schema
+		if s.Type == "array" && s.Items != nil {
+			s = *s.Items
+		}
+		def, name := doc.resolve(s)
+		t.Run(strings.ToUpper(ep.method)+" "+ep.path, func(t *testing.T) {
+			checkModel(t, doc, name, def, ep.model)
+		})
+	}
+}
+
+// checkModel compares the exported fields of a Go struct with a swagger definition.
+func checkModel(t *testing.T, doc *swaggerDoc, defName string, def swaggerSchema, model reflect.Type) {
+	t.Helper()
+
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < model.NumField(); i++ {
+		f := model.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f
+	}
+
+	props := make([]string, 0, len(def.Properties))
+	for p := range def.Properties {
+		props = append(props, p)
+	}
+	sort.Strings(props)
+
+	for _, prop := range props {
+		schema := def.Properties[prop]
+		field, ok := fields[strings.ToLower(prop)]
+		if !ok {
+			t.Errorf("%s.%s is not mapped in %s", defName, prop, model.Name())
+			continue
+		}
+		delete(fields, strings.ToLower(prop))
+		checkField(t, doc, defName+"."+prop, schema, field.Type)
+	}
+
+	extra := make([]string, 0, len(fields))
+	for name := range fields {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		t.Errorf("%s field %q does not exist in %s", model.Name(), name, defName)
+	}
+
+	for _, req := range def.Required {
+		if _, ok := def.Properties[req]; !ok {
+			t.Errorf("%s requires unknown property %s", defName, req)
+			continue
+		}
+		for i := 0; i < model.NumField(); i++ {
+			f := model.Field(i)
+			tag := f.Tag.Get("json")
+			if strings.EqualFold(strings.Split(tag, ",")[0], req) && strings.Contains(tag, "omitempty") {
+				t.Errorf("%s.%s is required but %s.%s is omitempty", defName, req, model.Name(), f.Name)
+			}
+		}
+	}
+}
+
+// checkField compares the type of a single field with its schema.
+func checkField(t *testing.T, doc *swaggerDoc, name string, schema swaggerSchema, typ reflect.Type) {
+	t.Helper()
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	schema, _ = doc.resolve(schema)
+
+	if known, ok := knownEnumValues[typ]; ok && len(schema.Enum) > 0 {
+		have := make(map[int64]bool, len(known))
+		for _, v := range known {
+			have[v] = true
+		}
+		for _, e := range schema.Enum {
+			v, err := e.Int64()
+			if err != nil {
+				t.Errorf("%s: enum value %s is not numeric", name, e)
+				continue
+			}
+			if !have[v] {
+				t.Errorf("%s: enum value %d has no constant in %s", name, v, typ.Name())
+			}
+		}
+	}
+
+	var want string
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		want = "string"
+	case typ.Kind() == reflect.String:
+		want = "string"
+	case typ.Kind() == reflect.Bool:
+		want = "boolean"
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		want = "integer"
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		want = "number"
+	case typ.Kind() == reflect.Slice:
+		want = "array"
+	case typ.Kind() == reflect.Struct:
+		want = "object"
+	}
+
+	got := schema.Type
+	if got == "" && len(schema.Properties) > 0 {
+		got = "object"
+	}
+	if got != "" && got != want {
+		t.Errorf("%s has type %q in the API but %s in Go", name, got, typ)
+	}
+	if want == "integer" && schema.Format == "int64" && typ.Bits() < 64 {
+		t.Errorf("%s is int64 in the API but %s in Go", name, typ)
+	}
+	if typ.Kind() == reflect.Slice && schema.Items != nil && typ.Elem().Kind() == reflect.Struct {
+		def, defName := doc.resolve(*schema.Items)
+		checkModel(t, doc, defName, def, typ.Elem())
+	}
+}
@@ -0,0 +1,42 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+)
+
+// Credentials are the Basic Auth credentials of a smart-me account.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+type credentialsKey struct{}
+
+type baseURLKey struct{}
+
+// WithContextCredentials returns a context that makes all requests made
+// with it use creds instead of the credentials of the client. A server
+// that acts on behalf of different accounts can share one client, and
+// thereby its connections, between them. Cached history is kept apart per
+// account.
+func WithContextCredentials(ctx context.Context, creds Credentials) (context.Context, error) {
+	if creds.Username == "" {
+		return nil, errors.New("username must not be empty")
+	}
+	if creds.Password == "" {
+		return nil, errors.New("password must not be empty")
+	}
+	return context.WithValue(ctx, credentialsKey{}, creds), nil
+}
+
+// WithContextBaseURL returns a context that makes all requests made with
+// it go to baseURL instead of the base URL of the client. The URL is
+// validated like in WithBaseURL.
+func WithContextBaseURL(ctx context.Context, baseURL string) (context.Context, error) {
+	u, err := parseBaseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, baseURLKey{}, u), nil
+}
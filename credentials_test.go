@@ -0,0 +1,64 @@
+package smartme_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestWithContextCredentials(t *testing.T) {
+	var users []string
+	handler := func(prefix string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, _, _ := r.BasicAuth()
+			users = append(users, prefix+user)
+			w.Write([]byte("[]"))
+		}
+	}
+	main := httptest.NewServer(handler("main:"))
+	defer main.Close()
+	other := httptest.NewServer(handler("other:"))
+	defer other.Close()
+
+	client, err := smartme.NewClient("test-user", "test-pass", smartme.WithBaseURL(main.URL))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	ctx := context.Background()
+	tenant, err := smartme.WithContextCredentials(ctx, smartme.Credentials{Username: "tenant", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := smartme.WithContextBaseURL(tenant, other.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []context.Context{ctx, tenant, moved} {
+		if _, err := client.GetDevices(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := "main:test-user main:tenant other:tenant"
+	if got := strings.Join(users, " "); got != want {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestWithContextCredentials_Validation(t *testing.T) {
+	ctx := context.Background()
+	if _, err := smartme.WithContextCredentials(ctx, smartme.Credentials{Password: "secret"}); err == nil || !strings.Contains(err.Error(), "username must not be empty") {
+		t.Errorf("empty username: err = %v", err)
+	}
+	if _, err := smartme.WithContextCredentials(ctx, smartme.Credentials{Username: "tenant"}); err == nil || !strings.Contains(err.Error(), "password must not be empty") {
+		t.Errorf("empty password: err = %v", err)
+	}
+	if _, err := smartme.WithContextBaseURL(ctx, "api.smart-me.com"); err == nil || !strings.Contains(err.Error(), "scheme must be http or https") {
+		t.Errorf("relative base URL: err = %v", err)
+	}
+}
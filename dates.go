@@ -0,0 +1,36 @@
+package smartme
+
+import "time"
+
+// Period is a half-open time range [Start, End), as returned by DayRange
+// and MonthRange.
+type Period struct {
+	Start, End time.Time
+}
+
+// DayRange returns the start of the day containing date in loc and the
+// start of the following day. The range is half-open, so the end can be
+// passed as the end date of the next range. Days with a DST change are 23
+// or 25 hours long. A nil loc uses the location of date.
+func DayRange(date time.Time, loc *time.Location) (start, end time.Time) {
+	if loc == nil {
+		loc = date.Location()
+	}
+	y, m, d := date.In(loc).Date()
+	start = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	end = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	return start, end
+}
+
+// MonthRange returns the start of the month containing date in loc and
+// the start of the following month, like DayRange. It gives the boundaries
+// of monthly billing periods.
+func MonthRange(date time.Time, loc *time.Location) (start, end time.Time) {
+	if loc == nil {
+		loc = date.Location()
+	}
+	y, m, _ := date.In(loc).Date()
+	start = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	end = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+	return start, end
+}
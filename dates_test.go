@@ -0,0 +1,95 @@
+package smartme_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestDayRange_DST(t *testing.T) {
+	zurich := loadLocation(t, "Europe/Zurich")
+
+	tests := []struct {
+		name  string
+		date  time.Time
+		hours float64
+	}{
+		{"normal day", time.Date(2025, 3, 29, 12, 0, 0, 0, zurich), 24},
+		{"spring forward", time.Date(2025, 3, 30, 12, 0, 0, 0, zurich), 23},
+		{"fall back", time.Date(2025, 10, 26, 12, 0, 0, 0, zurich), 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := smartme.DayRange(tt.date, zurich)
+			if start.Hour() != 0 || end.Hour() != 0 || start.Day() != tt.date.Day() {
+				t.Errorf("DayRange(%v) = %v, %v", tt.date, start, end)
+			}
+			if got := end.Sub(start).Hours(); got != tt.hours {
+				t.Errorf("day has %v hours, want %v", got, tt.hours)
+			}
+		})
+	}
+
+	// A UTC instant late in the evening already belongs to the next day in Zurich.
+	start, _ := smartme.DayRange(time.Date(2025, 6, 1, 23, 30, 0, 0, time.UTC), zurich)
+	if want := time.Date(2025, 6, 2, 0, 0, 0, 0, zurich); !start.Equal(want) {
+		t.Errorf("DayRange start = %v, want %v", start, want)
+	}
+}
+
+func TestMonthRange(t *testing.T) {
+	zurich := loadLocation(t, "Europe/Zurich")
+
+	start, end := smartme.MonthRange(time.Date(2025, 12, 15, 0, 0, 0, 0, zurich), zurich)
+	if want := time.Date(2025, 12, 1, 0, 0, 0, 0, zurich); !start.Equal(want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, zurich); !end.Equal(want) {
+		t.Errorf("end = %v, want %v", end, want)
+	}
+
+	// March has a DST change, so it is one hour shorter than 31 days.
+	start, end = smartme.MonthRange(time.Date(2025, 3, 10, 0, 0, 0, 0, zurich), zurich)
+	if got := end.Sub(start); got != 31*24*time.Hour-time.Hour {
+		t.Errorf("March has %v, want 743h", got)
+	}
+}
+
+func TestClient_WithLocation(t *testing.T) {
+	zurich := loadLocation(t, "Europe/Zurich")
+
+	mux := http.NewServeMux()
+	var gotDate string
+	mux.HandleFunc("/api/ValuesInPast/dev", func(w http.ResponseWriter, r *http.Request) {
+		gotDate = r.URL.Query().Get("date")
+		w.Write([]byte(`{"date":"2025-07-01T00:00:00Z","value":1}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client, err := smartme.NewClient("test-user", "test-pass", smartme.WithBaseURL(srv.URL), smartme.WithLocation(zurich))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	date := time.Date(2025, 7, 1, 22, 0, 0, 0, time.UTC)
+	if _, err := client.GetValuesInPast(context.Background(), "dev", date); err != nil {
+		t.Fatalf("GetValuesInPast failed: %v", err)
+	}
+	if want := "2025-07-02T00:00:00+02:00"; gotDate != want {
+		t.Errorf("date = %q, want %q", gotDate, want)
+	}
+}
@@ -0,0 +1,237 @@
+package smartme
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiTimeLayouts are the timestamp formats seen in API responses.
+// Timestamps without an offset are interpreted as UTC.
+var apiTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseAPITime parses a timestamp in any of the formats used by the API.
+func parseAPITime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range apiTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// apiTime is a time.Time that accepts the lenient formats of parseAPITime.
+// null and "" decode to the zero time.
+type apiTime time.Time
+
+func (t *apiTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("timestamp must be a string: %w", err)
+	}
+	if s == "" {
+		return nil
+	}
+	parsed, err := parseAPITime(s)
+	if err != nil {
+		return err
+	}
+	*t = apiTime(parsed)
+	return nil
+}
+
+// apiFloat is a float64 that also accepts numbers encoded as strings.
+// null and "" decode to 0.
+type apiFloat float64
+
+func (f *apiFloat) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		*f = apiFloat(v)
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = apiFloat(v)
+	return nil
+}
+
+// UnmarshalJSON decodes a Value, accepting timestamps without offset and
+// numbers encoded as strings.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Date  apiTime  `json:"date"`
+		Value apiFloat `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	v.Date = time.Time(raw.Date)
+	v.Value = float64(raw.Value)
+	return nil
+}
+
+// UnmarshalJSON decodes an ObisValue, accepting numbers encoded as strings.
+func (o *ObisValue) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Obis  string   `json:"obis"`
+		Value apiFloat `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	o.Obis = raw.Obis
+	o.Value = float64(raw.Value)
+	return nil
+}
+
+// UnmarshalJSON decodes DeviceValues, accepting timestamps without offset.
+// A null list of values decodes to an empty slice.
+func (dv *DeviceValues) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		DeviceID string      `json:"deviceId"`
+		Date     apiTime     `json:"date"`
+		Values   []ObisValue `json:"values"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	dv.DeviceID = raw.DeviceID
+	dv.Date = time.Time(raw.Date)
+	dv.Values = raw.Values
+	if dv.Values == nil {
+		dv.Values = []ObisValue{}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a Device. Numeric fields that the API sends as
+// strings are converted; if that is not possible the error is returned.
+func (d *Device) UnmarshalJSON(data []byte) error {
+	type device Device
+	err := json.Unmarshal(data, (*device)(d))
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*json.UnmarshalTypeError); !ok {
+		return err
+	}
+
+	fixed, ferr := unquoteNumericFields(data, reflect.TypeOf(Device{}))
+	if ferr != nil {
+		return err
+	}
+	*d = Device{}
+	return json.Unmarshal(fixed, (*device)(d))
+}
+
+// unquoteNumericFields rewrites string values of numeric struct fields as
+// JSON numbers. Strings that do not hold a number are left untouched.
+func unquoteNumericFields(data []byte, typ reflect.Type) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	numeric := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			numeric[strings.ToLower(name)] = true
+		}
+	}
+
+	for key, value := range fields {
+		if !numeric[strings.ToLower(key)] || len(value) == 0 || value[0] != '"' {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			continue
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			fields[key] = json.RawMessage("null")
+			continue
+		}
+		var f float64
+		if err := json.Unmarshal([]byte(s), &f); err == nil {
+			fields[key] = json.RawMessage(s)
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// decodeValueStream decodes a JSON array of values element by element.
+// A null body is treated as an empty array.
+func decodeValueStream(r io.Reader, fn func(Value) error) error {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err == io.EOF {
+		// Empty body, e.g. 204 No Content.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("error decoding response: expected array, got %v", tok)
+	}
+
+	for dec.More() {
+		var v Value
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
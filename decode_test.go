@@ -0,0 +1,124 @@
+package smartme_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestValue_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantDate time.Time
+		want     float64
+		wantErr  bool
+	}{
+		{"rfc3339", `{"date":"2025-01-01T12:00:00Z","value":1.5}`, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), 1.5, false},
+		{"no offset", `{"date":"2025-01-01T12:00:00.123","value":2}`, time.Date(2025, 1, 1, 12, 0, 0, 123000000, time.UTC), 2, false},
+		{"numeric string", `{"date":"2025-01-01T12:00:00Z","value":" 3.25 "}`, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), 3.25, false},
+		{"nulls", `{"date":null,"value":null}`, time.Time{}, 0, false},
+		{"bad date", `{"date":"yesterday","value":1}`, time.Time{}, 0, true},
+		{"bad number", `{"date":"2025-01-01T12:00:00Z","value":"NaN"}`, time.Time{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v smartme.Value
+			err := json.Unmarshal([]byte(tt.input), &v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !v.Date.Equal(tt.wantDate) || v.Value != tt.want {
+				t.Errorf("got %+v, want date %s value %v", v, tt.wantDate, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceValues_UnmarshalJSON_NullValues(t *testing.T) {
+	var dv smartme.DeviceValues
+	if err := json.Unmarshal([]byte(`{"deviceId":"d","date":"2025-01-01T12:00:00","values":null}`), &dv); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if dv.Values == nil || len(dv.Values) != 0 {
+		t.Errorf("Values = %#v, want empty slice", dv.Values)
+	}
+}
+
+func TestDevice_UnmarshalJSON_NumericStrings(t *testing.T) {
+	var d smartme.Device
+	input := `{"id":"d","serial":"12345","activePower":"1500.5","voltage":"","switchOn":true}`
+	if err := json.Unmarshal([]byte(input), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if *d.Id != "d" || *d.Serial != 12345 || *d.ActivePower != 1500.5 || d.Voltage != nil || !*d.SwitchOn {
+		t.Errorf("unexpected device %+v", d)
+	}
+
+	if err := json.Unmarshal([]byte(`{"activePower":"high"}`), &d); err == nil {
+		t.Error("Unmarshal should fail for non-numeric strings")
+	}
+}
+
+func FuzzDecodeDevice(f *testing.F) {
+	f.Add([]byte(`[{"id":"a","serial":1,"activePower":1.5,"valueDate":"2025-01-01T00:00:00Z","chargeStationState":4}]`))
+	f.Add([]byte(`[{"activePower":"12.5","serial":"7"}]`))
+	f.Add([]byte(`null`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var devices []smartme.Device
+		if err := json.Unmarshal(data, &devices); err != nil {
+			return
+		}
+		out, err := json.Marshal(devices)
+		if err != nil {
+			t.Fatalf("Marshal of decoded devices failed: %v", err)
+		}
+		if err := json.Unmarshal(out, &devices); err != nil {
+			t.Fatalf("re-decoding %s failed: %v", out, err)
+		}
+	})
+}
+
+func FuzzDecodeDeviceValues(f *testing.F) {
+	f.Add([]byte(`{"deviceId":"a","date":"2025-01-01T00:00:00Z","values":[{"obis":"1-0:1.8.0*255","value":1}]}`))
+	f.Add([]byte(`{"date":"2025-01-01T00:00:00","values":null}`))
+	f.Add([]byte(`{"values":[{"value":"3"}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var dv smartme.DeviceValues
+		if err := json.Unmarshal(data, &dv); err != nil {
+			return
+		}
+		out, err := json.Marshal(dv)
+		if err != nil {
+			t.Fatalf("Marshal of decoded values failed: %v", err)
+		}
+		if err := json.Unmarshal(out, &dv); err != nil {
+			t.Fatalf("re-decoding %s failed: %v", out, err)
+		}
+	})
+}
+
+func FuzzDecodeValue(f *testing.F) {
+	f.Add([]byte(`{"date":"2025-01-01T00:00:00Z","value":1}`))
+	f.Add([]byte(`{"date":"2025-01-01 00:00:00","value":"2.5"}`))
+	f.Add([]byte(`[{"date":null,"value":null}]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var values []smartme.Value
+		if err := json.Unmarshal(data, &values); err != nil {
+			return
+		}
+		out, err := json.Marshal(values)
+		if err != nil {
+			t.Fatalf("Marshal of decoded values failed: %v", err)
+		}
+		if err := json.Unmarshal(out, &values); err != nil {
+			t.Fatalf("re-decoding %s failed: %v", out, err)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package smartme
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConflictPolicy decides which value wins when the same timestamp occurs
+// twice with different values.
+type ConflictPolicy int
+
+const (
+	// KeepFirst keeps the value seen first.
+	KeepFirst ConflictPolicy = iota
+	// KeepLast keeps the value seen last.
+	KeepLast
+	// FailOnConflict returns a *ConflictError.
+	FailOnConflict
+)
+
+// ConflictError reports two different values for the same sample.
+type ConflictError struct {
+	DeviceID, Obis string
+	Date           time.Time
+	First, Second  float64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting values for %s %s at %s: %v and %v",
+		e.DeviceID, e.Obis, e.Date.Format(time.RFC3339), e.First, e.Second)
+}
+
+// DedupValues returns the values of one series sorted by date with
+// duplicate timestamps removed, e.g. after merging overlapping history
+// requests. Equal duplicates are always merged; for different values the
+// policy decides. The input is not modified.
+func DedupValues(values []Value, policy ConflictPolicy) ([]Value, error) {
+	sorted := make([]Value, len(values))
+	copy(sorted, values)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	out := sorted[:0]
+	for _, v := range sorted {
+		if n := len(out); n > 0 && out[n-1].Date.Equal(v.Date) {
+			last := &out[n-1]
+			if last.Value == v.Value {
+				continue
+			}
+			switch policy {
+			case KeepLast:
+				last.Value = v.Value
+			case FailOnConflict:
+				return nil, &ConflictError{Date: v.Date, First: last.Value, Second: v.Value}
+			}
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Deduplicator filters samples of a stream, such as retried or overlapping
+// history fetches, so each (device, OBIS code, timestamp) is written only
+// once. It is safe for concurrent use.
+type Deduplicator struct {
+	policy ConflictPolicy
+
+	mu   sync.Mutex
+	seen map[dedupKey]float64
+}
+
+type dedupKey struct {
+	deviceID, obis string
+	date           int64
+}
+
+// NewDeduplicator creates a Deduplicator with the given conflict policy.
+func NewDeduplicator(policy ConflictPolicy) *Deduplicator {
+	return &Deduplicator{policy: policy, seen: make(map[dedupKey]float64)}
+}
+
+// Add records a sample and reports whether it should be written. A sample
+// seen before with the same value is skipped. With a different value,
+// KeepFirst skips it, KeepLast returns true so the sink overwrites the
+// earlier value, and FailOnConflict returns a *ConflictError.
+func (d *Deduplicator) Add(deviceID, obis string, v Value) (bool, error) {
+	key := dedupKey{deviceID, obis, v.Date.UnixNano()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev, ok := d.seen[key]
+	switch {
+	case !ok:
+	case prev == v.Value:
+		return false, nil
+	case d.policy == KeepFirst:
+		return false, nil
+	case d.policy == FailOnConflict:
+		return false, &ConflictError{DeviceID: deviceID, Obis: obis, Date: v.Date, First: prev, Second: v.Value}
+	}
+	d.seen[key] = v.Value
+	return true, nil
+}
+
+// Forget removes the samples dated earlier than before; samples dated
+// exactly at before are kept. It bounds the memory of a long-running
+// stream once older data can no longer be repeated.
+func (d *Deduplicator) Forget(before time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key := range d.seen {
+		if key.date < before.UnixNano() {
+			delete(d.seen, key)
+		}
+	}
+}
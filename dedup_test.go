@@ -0,0 +1,76 @@
+package smartme_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestDedupValues(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(min int, v float64) smartme.Value {
+		return smartme.Value{Date: t0.Add(time.Duration(min) * time.Minute), Value: v}
+	}
+	// Two overlapping chunks, the second with a corrected value at 15.
+	values := []smartme.Value{at(0, 1), at(15, 2), at(30, 3), at(15, 2.5), at(30, 3), at(45, 4)}
+
+	tests := []struct {
+		policy smartme.ConflictPolicy
+		want   []float64
+	}{
+		{smartme.KeepFirst, []float64{1, 2, 3, 4}},
+		{smartme.KeepLast, []float64{1, 2.5, 3, 4}},
+	}
+	for _, tt := range tests {
+		got, err := smartme.DedupValues(values, tt.policy)
+		if err != nil {
+			t.Fatalf("policy %d: %v", tt.policy, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("policy %d: got %v", tt.policy, got)
+		}
+		for i, v := range got {
+			if v.Value != tt.want[i] {
+				t.Errorf("policy %d: value %d = %v, want %v", tt.policy, i, v.Value, tt.want[i])
+			}
+		}
+	}
+
+	var conflict *smartme.ConflictError
+	if _, err := smartme.DedupValues(values, smartme.FailOnConflict); !errors.As(err, &conflict) || conflict.Second != 2.5 {
+		t.Errorf("FailOnConflict returned %v", err)
+	}
+	if values[3].Value != 2.5 {
+		t.Error("DedupValues modified its input")
+	}
+}
+
+func TestDeduplicator(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := smartme.NewDeduplicator(smartme.KeepLast)
+
+	add := func(id string, v float64) bool {
+		t.Helper()
+		ok, err := d.Add(id, smartme.ObisActiveEnergyImport, smartme.Value{Date: t0, Value: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !add("a", 1) || add("a", 1) || !add("b", 1) || !add("a", 2) {
+		t.Error("unexpected dedup decisions")
+	}
+
+	// The bound is exclusive: samples at t0 are kept.
+	d.Forget(t0)
+	if add("b", 1) {
+		t.Error("sample at the bound of Forget was forgotten")
+	}
+
+	d.Forget(t0.Add(time.Minute))
+	if !add("a", 2) {
+		t.Error("forgotten sample should be written again")
+	}
+}
@@ -0,0 +1,168 @@
+package smartme
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DiskCache is a Cache that stores entries as files in a directory, so
+// they survive process restarts. The total size of the entries is kept
+// below a limit by removing the least recently written ones.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	clock   Clock
+	entries map[string]diskEntry // by file name
+	size    int64
+}
+
+type diskEntry struct {
+	size    int64
+	written time.Time
+}
+
+// diskCacheExt is the file extension of cache entries.
+const diskCacheExt = ".smartme-cache"
+
+// NewDiskCache opens (or creates) a disk cache in dir. maxSize limits the
+// total size of all entries in bytes; <= 0 means no limit.
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	dc := &DiskCache{dir: dir, maxSize: maxSize, clock: SystemClock{}, entries: make(map[string]diskEntry)}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != diskCacheExt {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		dc.entries[f.Name()] = diskEntry{size: info.Size(), written: info.ModTime()}
+		dc.size += info.Size()
+	}
+
+	return dc, nil
+}
+
+// Get implements Cache.
+func (dc *DiskCache) Get(key string) ([]byte, bool) {
+	name := dc.fileName(key)
+	data, err := os.ReadFile(filepath.Join(dc.dir, name))
+	if err != nil || len(data) < 8 {
+		return nil, false
+	}
+
+	expires := int64(binary.BigEndian.Uint64(data[:8]))
+	if expires != 0 && dc.now().UnixNano() > expires {
+		dc.mu.Lock()
+		dc.remove(name)
+		dc.mu.Unlock()
+		return nil, false
+	}
+	return data[8:], true
+}
+
+// Set implements Cache. Errors writing the file are ignored, the entry is
+// then simply not cached.
+func (dc *DiskCache) Set(key string, data []byte, ttl time.Duration) {
+	var expires int64
+	if ttl > 0 {
+		expires = dc.now().Add(ttl).UnixNano()
+	}
+
+	buf := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(buf, uint64(expires))
+	copy(buf[8:], data)
+
+	name := dc.fileName(key)
+	path := filepath.Join(dc.dir, name)
+
+	// Write to a temporary file first, so readers never see partial entries.
+	tmp, err := os.CreateTemp(dc.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(buf)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if old, ok := dc.entries[name]; ok {
+		dc.size -= old.size
+	}
+	dc.entries[name] = diskEntry{size: int64(len(buf)), written: dc.clock.Now()}
+	dc.size += int64(len(buf))
+	dc.evict()
+}
+
+// setClock makes the cache use the clock of the client it is passed to.
+func (dc *DiskCache) setClock(clock Clock) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.clock = clock
+}
+
+// now returns the current time of the cache clock.
+func (dc *DiskCache) now() time.Time {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.clock.Now()
+}
+
+// evict removes the oldest entries until the cache fits maxSize.
+// The caller must hold dc.mu.
+func (dc *DiskCache) evict() {
+	if dc.maxSize <= 0 || dc.size <= dc.maxSize {
+		return
+	}
+
+	names := make([]string, 0, len(dc.entries))
+	for name := range dc.entries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return dc.entries[names[i]].written.Before(dc.entries[names[j]].written)
+	})
+
+	for _, name := range names {
+		if dc.size <= dc.maxSize {
+			break
+		}
+		dc.remove(name)
+	}
+}
+
+// remove deletes an entry. The caller must hold dc.mu.
+func (dc *DiskCache) remove(name string) {
+	os.Remove(filepath.Join(dc.dir, name))
+	if e, ok := dc.entries[name]; ok {
+		dc.size -= e.size
+		delete(dc.entries, name)
+	}
+}
+
+func (dc *DiskCache) fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + diskCacheExt
+}
@@ -1,11 +1,25 @@
 // errors.go
 package smartme
 
+import "errors"
+
+// ErrReadOnlyClient is returned by calls that would change the state of a
+// device or account on a client created with WithReadOnly.
+var ErrReadOnlyClient = errors.New("client is read-only")
+
+// ErrNoData is returned by calls for a single object when the API answers
+// with an empty body or 204 No Content, typically for a device that has not
+// reported any data. Calls returning a list return an empty list instead.
+var ErrNoData = errors.New("no data")
+
 // APIError represents an error returned by the smart-me API.
 // You can extend this struct to map the error details from the API.
 type APIError struct {
 	StatusCode int
 	Message    string
+	// RequestID is the correlation ID sent with the failed request. Quote
+	// it when contacting smart-me support.
+	RequestID string
 	// Body []byte // Useful for debugging
 }
 
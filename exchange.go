@@ -0,0 +1,126 @@
+package smartme
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MeterExchange is the replacement of the physical meter of a measuring
+// point, e.g. after a defect or when its calibration expired.
+type MeterExchange struct {
+	// Date is the date of the first reading of the new meter.
+	Date time.Time
+	// OldSerial and NewSerial are the serial numbers, 0 if unknown.
+	OldSerial, NewSerial int64
+	// FinalReading is the last reading of the old meter, InitialReading
+	// the first reading of the new one.
+	FinalReading, InitialReading float64
+}
+
+// DetectExchanges finds counter resets in a series of readings that are
+// not a rollover of a register with the given integer digits (see Rates)
+// and returns them as meter exchanges without serial numbers.
+func DetectExchanges(values []Value, digits int) []MeterExchange {
+	sorted := make([]Value, len(values))
+	copy(sorted, values)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	var exchanges []MeterExchange
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		if cur.Value >= prev.Value || !cur.Date.After(prev.Date) {
+			continue
+		}
+		if _, rollover := rolloverModulus(prev.Value, cur.Value, digits); rollover {
+			continue
+		}
+		exchanges = append(exchanges, MeterExchange{Date: cur.Date, FinalReading: prev.Value, InitialReading: cur.Value})
+	}
+	return exchanges
+}
+
+// MeasuringPoint is a billing point that outlives its physical meters. It
+// records the meter exchanges and maps the readings of all meters to one
+// continuous series. It is safe for concurrent use.
+type MeasuringPoint struct {
+	mu        sync.Mutex
+	exchanges []MeterExchange
+	last      *Device
+}
+
+// Record adds exchanges, e.g. from DetectExchanges or from the
+// installation records. An exchange at the date of a recorded one replaces
+// it.
+func (mp *MeasuringPoint) Record(exchanges ...MeterExchange) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	for _, e := range exchanges {
+		i := sort.Search(len(mp.exchanges), func(i int) bool { return !mp.exchanges[i].Date.Before(e.Date) })
+		if i < len(mp.exchanges) && mp.exchanges[i].Date.Equal(e.Date) {
+			mp.exchanges[i] = e
+			continue
+		}
+		mp.exchanges = append(mp.exchanges, MeterExchange{})
+		copy(mp.exchanges[i+1:], mp.exchanges[i:])
+		mp.exchanges[i] = e
+	}
+}
+
+// Exchanges returns the recorded exchanges sorted by date.
+func (mp *MeasuringPoint) Exchanges() []MeterExchange {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return append([]MeterExchange(nil), mp.exchanges...)
+}
+
+// Observe compares the device with the previous observation, e.g. from a
+// Watcher, and records an exchange if its serial number changed. The
+// exchange is dated at the value date of the new meter, or at now if the
+// device has none.
+func (mp *MeasuringPoint) Observe(d Device, now time.Time) (MeterExchange, bool) {
+	mp.mu.Lock()
+	last := mp.last
+	mp.last = &d
+	mp.mu.Unlock()
+
+	if last == nil || last.Serial == nil || d.Serial == nil || *last.Serial == *d.Serial {
+		return MeterExchange{}, false
+	}
+	e := MeterExchange{Date: now, OldSerial: *last.Serial, NewSerial: *d.Serial}
+	if d.ValueDate != nil {
+		if t, err := parseAPITime(*d.ValueDate); err == nil {
+			e.Date = t
+		}
+	}
+	if last.CounterReading != nil {
+		e.FinalReading = *last.CounterReading
+	}
+	if d.CounterReading != nil {
+		e.InitialReading = *d.CounterReading
+	}
+	mp.Record(e)
+	return e, true
+}
+
+// Continuous returns the readings as one continuous series across the
+// recorded exchanges: readings from the date of an exchange on are shifted
+// by the final reading of the old meter minus the initial reading of the
+// new one. The consumption between those two readings is unknown and
+// counted as zero.
+func (mp *MeasuringPoint) Continuous(values []Value) []Value {
+	exchanges := mp.Exchanges()
+	out := make([]Value, len(values))
+	copy(out, values)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Date.Before(out[j].Date) })
+
+	offset, next := 0.0, 0
+	for i := range out {
+		for next < len(exchanges) && !exchanges[next].Date.After(out[i].Date) {
+			offset += exchanges[next].FinalReading - exchanges[next].InitialReading
+			next++
+		}
+		out[i].Value += offset
+	}
+	return out
+}
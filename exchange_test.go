@@ -0,0 +1,64 @@
+package smartme_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestMeasuringPoint_Continuous(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int, v float64) smartme.Value {
+		return smartme.Value{Date: t0.AddDate(0, 0, n), Value: v}
+	}
+	history := []smartme.Value{
+		day(0, 5000), day(1, 5010), day(2, 3), // first exchange
+		day(3, 13), day(4, 99999.5), day(5, 1.5), // rollover, not an exchange
+		day(6, 0.5), day(7, 10.5), // second exchange
+	}
+
+	exchanges := smartme.DetectExchanges(history, 5)
+	if len(exchanges) != 2 || !exchanges[0].Date.Equal(t0.AddDate(0, 0, 2)) || exchanges[1].FinalReading != 1.5 {
+		t.Fatalf("DetectExchanges = %+v", exchanges)
+	}
+
+	var mp smartme.MeasuringPoint
+	mp.Record(exchanges...)
+	mp.Record(exchanges[0]) // recorded twice
+	if n := len(mp.Exchanges()); n != 2 {
+		t.Fatalf("%d exchanges recorded, want 2", n)
+	}
+
+	// The rollover stays in the series; Rates handles it.
+	want := []float64{5000, 5010, 5010, 5020, 105006.5, 5008.5, 5008.5, 5018.5}
+	got := mp.Continuous(history)
+	for i := range want {
+		if got[i].Value != want[i] {
+			t.Errorf("value %d = %v, want %v", i, got[i].Value, want[i])
+		}
+	}
+}
+
+func TestMeasuringPoint_Observe(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var mp smartme.MeasuringPoint
+
+	if _, ok := mp.Observe(smartme.Device{Serial: ptr(int64(1)), CounterReading: ptr(812.5)}, now); ok {
+		t.Error("first observation cannot be an exchange")
+	}
+	if _, ok := mp.Observe(smartme.Device{Serial: ptr(int64(1)), CounterReading: ptr(813.0)}, now); ok {
+		t.Error("same serial reported as an exchange")
+	}
+	e, ok := mp.Observe(smartme.Device{Serial: ptr(int64(2)), CounterReading: ptr(0.2), ValueDate: ptr("2025-03-01T11:00:00")}, now)
+	if !ok {
+		t.Fatal("serial change not detected")
+	}
+	want := smartme.MeterExchange{Date: now.Add(-time.Hour), OldSerial: 1, NewSerial: 2, FinalReading: 813, InitialReading: 0.2}
+	if e != want {
+		t.Errorf("exchange = %+v, want %+v", e, want)
+	}
+	if len(mp.Exchanges()) != 1 {
+		t.Error("exchange not recorded")
+	}
+}
@@ -0,0 +1,5 @@
+package smartme
+
+// ActionsRequest exposes the body of the actions endpoint to the contract
+// tests.
+type ActionsRequest = actionsRequest
@@ -0,0 +1,93 @@
+package smartme
+
+import "fmt"
+
+// Connectivity is the way a device reports its data to the smart-me cloud.
+type Connectivity int
+
+const (
+	ConnectivityUnknown Connectivity = iota
+	// ConnectivityWiFi is used by devices with a WiFi module.
+	ConnectivityWiFi
+	// ConnectivityMobile is used by devices with a mobile (GPRS/LTE) module.
+	ConnectivityMobile
+	// ConnectivityCloud is used by devices that only exist in the cloud,
+	// like REST API and virtual meters.
+	ConnectivityCloud
+)
+
+// String returns the name of the connectivity.
+func (c Connectivity) String() string {
+	switch c {
+	case ConnectivityWiFi:
+		return "WiFi"
+	case ConnectivityMobile:
+		return "Mobile"
+	case ConnectivityCloud:
+		return "Cloud"
+	}
+	return "Unknown"
+}
+
+// familyInfo is the metadata of a MeterFamilyType.
+type familyInfo struct {
+	description  string
+	hasSwitch    bool
+	phases       int
+	connectivity Connectivity
+}
+
+// familyCatalog contains the family types known to the client. Phases is 0
+// for modules and gateways that read foreign meters.
+var familyCatalog = map[MeterFamilyType]familyInfo{
+	The_Family_Type_is_unknown_all_M_BUS_Meters_S0_meters_usw:     {"Unknown (M-Bus, S0 and other meters)", false, 0, ConnectivityUnknown},
+	smart_me_connect_Meter_Plugin_Power_Meter:                     {"smart-me connect plug-in power meter", true, 1, ConnectivityWiFi},
+	smart_me_Meter_1_Phase_DIN_Rail_Meter_without_switch:          {"smart-me 1-phase DIN rail meter", false, 1, ConnectivityWiFi},
+	smart_me_Meter_1_Phase_DIN_Rail_Meter_with_a_Switch:           {"smart-me 1-phase DIN rail meter with switch", true, 1, ConnectivityWiFi},
+	smart_me_M_BUS_Gateway_V1:                                     {"smart-me M-Bus gateway", false, 0, ConnectivityWiFi},
+	smart_me_RS_485_Gateway_V1:                                    {"smart-me RS-485 gateway", false, 0, ConnectivityWiFi},
+	MeterFamilyTypeKamstrupModule:                                 {"smart-me Kamstrup module", false, 0, ConnectivityWiFi},
+	MeterFamilyTypeSmartMe3PhaseMeter80A:                          {"smart-me 3-phase meter 80 A", false, 3, ConnectivityWiFi},
+	smart_me_3_Phase_Meter_32A_with_Switch:                        {"smart-me 3-phase meter 32 A with switch", true, 3, ConnectivityWiFi},
+	smart_me_3_Phase_Meter_Transformer_Edition:                    {"smart-me 3-phase meter, transformer edition", false, 3, ConnectivityWiFi},
+	smart_me_Landis_Gyr_Module:                                    {"smart-me Landis+Gyr module", false, 0, ConnectivityWiFi},
+	Optical_module_for_the_FNN_meters:                             {"Optical module for FNN meters", false, 0, ConnectivityWiFi},
+	smart_me_3_Phase_Meter_80A_with_the_new_WiFi_V2:               {"smart-me 3-phase meter 80 A (WiFi V2)", false, 3, ConnectivityWiFi},
+	smart_me_3_Phase_Meter_80A_with_Mobile:                        {"smart-me 3-phase meter 80 A (mobile)", false, 3, ConnectivityMobile},
+	smart_me_1_Phase_Meter_80A_with_the_new_WiFi_V2:               {"smart-me 1-phase meter 80 A (WiFi V2)", false, 1, ConnectivityWiFi},
+	smart_me_1_Phase_Meter_32A_with_the_new_WiFi_V2:               {"smart-me 1-phase meter 32 A with switch (WiFi V2)", true, 1, ConnectivityWiFi},
+	smart_me_1_Phase_Meter_80A_with_GPRS:                          {"smart-me 1-phase meter 80 A (GPRS)", false, 1, ConnectivityMobile},
+	smart_me_1_Phase_Meter_32A_with_GPRS:                          {"smart-me 1-phase meter 32 A with switch (GPRS)", true, 1, ConnectivityMobile},
+	smart_me_Wirless_M_BUS_Gateway_V1:                             {"smart-me wireless M-Bus gateway", false, 0, ConnectivityWiFi},
+	smart_me_3_Phase_Meter_Transformer_Edition_with_mobile_module: {"smart-me 3-phase meter, transformer edition (mobile)", false, 3, ConnectivityMobile},
+	smart_me_3_phase_Meter_Nimbus_3_point_meter:                   {"smart-me Nimbus 3-phase meter (3-point)", false, 3, ConnectivityWiFi},
+	Mithral_hall_charging_station_Version_1:                       {"Charging station", false, 3, ConnectivityWiFi},
+	REST_API_Meter:                                                {"REST API meter", false, 0, ConnectivityCloud},
+	Virtual_billing_Meter:                                         {"Virtual billing meter", false, 0, ConnectivityCloud},
+}
+
+// Description returns a human-readable name of the family type, like
+// "smart-me 3-phase meter 80 A".
+func (f MeterFamilyType) Description() string {
+	if info, ok := familyCatalog[f]; ok {
+		return info.description
+	}
+	return fmt.Sprintf("MeterFamilyType(%d)", int32(f))
+}
+
+// HasSwitch reports whether devices of the family have a relay that can be
+// switched with PerformActions.
+func (f MeterFamilyType) HasSwitch() bool {
+	return familyCatalog[f].hasSwitch
+}
+
+// Phases returns the number of phases measured by devices of the family,
+// or 0 if it depends on the connected meter or is unknown.
+func (f MeterFamilyType) Phases() int {
+	return familyCatalog[f].phases
+}
+
+// Connectivity returns how devices of the family report their data.
+func (f MeterFamilyType) Connectivity() Connectivity {
+	return familyCatalog[f].connectivity
+}
@@ -0,0 +1,49 @@
+package smartme_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestMeterFamilyType_Metadata(t *testing.T) {
+	tests := []struct {
+		family       smartme.MeterFamilyType
+		description  string
+		hasSwitch    bool
+		phases       int
+		connectivity smartme.Connectivity
+	}{
+		{smartme.MeterFamilyTypeSmartMe3PhaseMeter80A, "smart-me 3-phase meter 80 A", false, 3, smartme.ConnectivityWiFi},
+		{smartme.MeterFamilyType(19), "smart-me 1-phase meter 32 A with switch (GPRS)", true, 1, smartme.ConnectivityMobile},
+		{smartme.MeterFamilyType(1002), "Virtual billing meter", false, 0, smartme.ConnectivityCloud},
+		{smartme.MeterFamilyType(13), "MeterFamilyType(13)", false, 0, smartme.ConnectivityUnknown},
+	}
+	for _, tt := range tests {
+		f := tt.family
+		if got := f.Description(); got != tt.description {
+			t.Errorf("%d: Description() = %q, want %q", f, got, tt.description)
+		}
+		if got := f.HasSwitch(); got != tt.hasSwitch {
+			t.Errorf("%d: HasSwitch() = %v, want %v", f, got, tt.hasSwitch)
+		}
+		if got := f.Phases(); got != tt.phases {
+			t.Errorf("%d: Phases() = %d, want %d", f, got, tt.phases)
+		}
+		if got := f.Connectivity(); got != tt.connectivity {
+			t.Errorf("%d: Connectivity() = %v, want %v", f, got, tt.connectivity)
+		}
+	}
+}
+
+// TestMeterFamilyType_AllDescribed keeps the metadata in sync with the
+// values checked by the contract tests.
+func TestMeterFamilyType_AllDescribed(t *testing.T) {
+	for _, v := range []int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 14, 16, 17, 18, 19, 20, 21, 65, 70, 1001, 1002} {
+		f := smartme.MeterFamilyType(v)
+		if strings.HasPrefix(f.Description(), "MeterFamilyType(") {
+			t.Errorf("family type %d has no description", v)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package smartme
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// WithFields keeps only the given Device fields, named by their JSON names
+// like "activePower" or "counterReading", in the results of GetDevices and
+// GetDevice; the ID is always kept. The API has no field selection, so the
+// full response is transferred, but only the selected fields are decoded.
+// Unknown names make the call fail.
+func WithFields(names ...string) CallOption {
+	return func(cfg *callConfig) {
+		if cfg.fields == nil {
+			cfg.fields = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			cfg.fields[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+}
+
+// fieldSelection decodes only some fields of a Device.
+type fieldSelection struct {
+	// typ is a struct with the selected fields of Device.
+	typ reflect.Type
+	// index holds the index in Device of each field of typ.
+	index []int
+}
+
+// newFieldSelection returns the selection of the given lower-case JSON
+// names plus the ID.
+func newFieldSelection(names map[string]bool) (*fieldSelection, error) {
+	deviceType := reflect.TypeOf(Device{})
+	found := make(map[string]bool, len(names))
+	s := &fieldSelection{}
+	var fields []reflect.StructField
+	for i := 0; i < deviceType.NumField(); i++ {
+		f := deviceType.Field(i)
+		name := strings.ToLower(strings.Split(f.Tag.Get("json"), ",")[0])
+		if name != "id" && !names[name] {
+			continue
+		}
+		found[name] = true
+		fields = append(fields, reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag})
+		s.index = append(s.index, i)
+	}
+	var unknown []string
+	for name := range names {
+		if !found[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown device fields: %s", strings.Join(unknown, ", "))
+	}
+	s.typ = reflect.StructOf(fields)
+	return s, nil
+}
+
+// decode decodes the selected fields of a JSON device into d.
+func (s *fieldSelection) decode(data []byte, d *Device) error {
+	partial := reflect.New(s.typ)
+	if err := json.Unmarshal(data, partial.Interface()); err != nil {
+		// The API sends some numbers as strings, which Device handles.
+		var full Device
+		if err := json.Unmarshal(data, &full); err != nil {
+			return err
+		}
+		*d = s.trim(full)
+		return nil
+	}
+	target := reflect.ValueOf(d).Elem()
+	for j, i := range s.index {
+		target.Field(i).Set(partial.Elem().Field(j))
+	}
+	return nil
+}
+
+// trim returns a copy of d with only the selected fields.
+func (s *fieldSelection) trim(d Device) Device {
+	var trimmed Device
+	src, dst := reflect.ValueOf(d), reflect.ValueOf(&trimmed).Elem()
+	for _, i := range s.index {
+		dst.Field(i).Set(src.Field(i))
+	}
+	return trimmed
+}
+
+// getDevicesFields implements GetDevices and GetDevice with WithFields.
+// With the JSON codec, the response is split into the raw devices and only
+// the selected fields are decoded; other codecs decode all fields.
+func (c *Client) getDevicesFields(req *http.Request, names map[string]bool, single bool) ([]Device, error) {
+	sel, err := newFieldSelection(names)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := c.codec.(JSONCodec); !ok {
+		var devices []Device
+		if single {
+			var device Device
+			_, err = c.do(req, &device)
+			devices = []Device{device}
+		} else {
+			_, err = c.do(req, &devices)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range devices {
+			devices[i] = sel.trim(devices[i])
+		}
+		return devices, nil
+	}
+
+	var raws []json.RawMessage
+	if single {
+		var raw json.RawMessage
+		if _, err := c.do(req, &raw); err != nil {
+			return nil, err
+		}
+		if len(raw) == 0 {
+			return nil, ErrNoData
+		}
+		raws = []json.RawMessage{raw}
+	} else if _, err := c.do(req, &raws); err != nil {
+		return nil, err
+	}
+
+	devices := make([]Device, len(raws))
+	for i, raw := range raws {
+		if err := sel.decode(raw, &devices[i]); err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+	if err := c.postprocess(req, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
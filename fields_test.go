@@ -0,0 +1,51 @@
+package smartme_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestWithFields(t *testing.T) {
+	client, mux, teardown := setup(t)
+	defer teardown()
+
+	device := `{"id":"dev-1","name":"Meter","activePower":"1200.5","counterReading":4711,"voltage":230,"switchOn":true}`
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[" + device + "]"))
+	})
+	mux.HandleFunc("/api/Devices/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(device))
+	})
+
+	ctx := context.Background()
+	devices, err := client.GetDevices(ctx, smartme.WithFields("activePower", "CounterReading"))
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("got %d devices, want 1", len(devices))
+	}
+	d := devices[0]
+	if d.Id == nil || *d.Id != "dev-1" || d.ActivePower == nil || *d.ActivePower != 1200.5 || d.CounterReading == nil || *d.CounterReading != 4711 {
+		t.Errorf("selected fields missing: %+v", d)
+	}
+	if d.Name != nil || d.Voltage != nil || d.SwitchOn != nil {
+		t.Errorf("unselected fields decoded: %+v", d)
+	}
+
+	single, err := client.GetDevice(ctx, "dev-1", smartme.WithFields("voltage"))
+	if err != nil {
+		t.Fatalf("GetDevice failed: %v", err)
+	}
+	if single.Voltage == nil || *single.Voltage != 230 || single.ActivePower != nil {
+		t.Errorf("GetDevice with fields = %+v", single)
+	}
+
+	if _, err := client.GetDevices(ctx, smartme.WithFields("activePower", "bogus")); err == nil || !strings.Contains(err.Error(), "unknown device fields: bogus") {
+		t.Errorf("unknown field: err = %v", err)
+	}
+}
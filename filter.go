@@ -0,0 +1,96 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ErrDeviceNotFound is returned when no device matches a lookup.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// DeviceFilter reports whether a device should be selected.
+type DeviceFilter func(Device) bool
+
+// FilterDevices returns the devices that match all filters, in their
+// original order. Without filters all devices are returned.
+func FilterDevices(devices []Device, filters ...DeviceFilter) []Device {
+	var selected []Device
+	for _, d := range devices {
+		if matchAll(d, filters) {
+			selected = append(selected, d)
+		}
+	}
+	return selected
+}
+
+func matchAll(d Device, filters []DeviceFilter) bool {
+	for _, f := range filters {
+		if !f(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// ByEnergyType selects devices of any of the given energy types.
+func ByEnergyType(types ...MeterEnergyType) DeviceFilter {
+	return func(d Device) bool {
+		if d.DeviceEnergyType == nil {
+			return false
+		}
+		for _, t := range types {
+			if *d.DeviceEnergyType == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByNameGlob selects devices whose name matches a shell pattern like
+// "Apartment *" (see path.Match). An invalid pattern matches nothing.
+func ByNameGlob(pattern string) DeviceFilter {
+	return func(d Device) bool {
+		if d.Name == nil {
+			return false
+		}
+		ok, err := path.Match(pattern, *d.Name)
+		return err == nil && ok
+	}
+}
+
+// BySwitchCapable selects devices that can be switched, see
+// Capabilities.
+func BySwitchCapable() DeviceFilter {
+	return func(d Device) bool {
+		return Capabilities(d).Switch
+	}
+}
+
+// Not inverts a filter.
+func Not(f DeviceFilter) DeviceFilter {
+	return func(d Device) bool {
+		return !f(d)
+	}
+}
+
+// FindDeviceByName returns the first device with exactly the given name.
+// It returns ErrDeviceNotFound if there is none.
+func (c *Client) FindDeviceByName(ctx context.Context, name string, opts ...CallOption) (*Device, error) {
+	devices, err := c.GetDevices(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return findDeviceByName(devices, name)
+}
+
+func findDeviceByName(devices []Device, name string) (*Device, error) {
+	for i := range devices {
+		if devices[i].Name != nil && *devices[i].Name == name {
+			return &devices[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrDeviceNotFound, name)
+}
@@ -0,0 +1,84 @@
+package smartme_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestFilterDevices(t *testing.T) {
+	devices := []smartme.Device{
+		{Name: ptr("Apartment 1"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity), SwitchOn: ptr(true)},
+		{Name: ptr("Apartment 2"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity)},
+		{Name: ptr("Apartment 4"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity), FamilyType: ptr(smartme.MeterFamilyType(8))},
+		{Name: ptr("Water 3"), DeviceEnergyType: ptr(smartme.MeterTypeWater)},
+		{DeviceEnergyType: ptr(smartme.MeterTypeHeat)},
+	}
+
+	names := func(ds []smartme.Device) []string {
+		var out []string
+		for _, d := range ds {
+			if d.Name != nil {
+				out = append(out, *d.Name)
+			} else {
+				out = append(out, "<nil>")
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		filters []smartme.DeviceFilter
+		want    []string
+	}{
+		{"no filters", nil, []string{"Apartment 1", "Apartment 2", "Apartment 4", "Water 3", "<nil>"}},
+		{"energy type", []smartme.DeviceFilter{smartme.ByEnergyType(smartme.MeterTypeWater, smartme.MeterTypeHeat)}, []string{"Water 3", "<nil>"}},
+		{"name glob", []smartme.DeviceFilter{smartme.ByNameGlob("Apartment *")}, []string{"Apartment 1", "Apartment 2", "Apartment 4"}},
+		{"invalid glob", []smartme.DeviceFilter{smartme.ByNameGlob("[")}, nil},
+		{"combined", []smartme.DeviceFilter{smartme.ByNameGlob("Apartment *"), smartme.BySwitchCapable()}, []string{"Apartment 1", "Apartment 4"}},
+		{"not", []smartme.DeviceFilter{smartme.Not(smartme.ByEnergyType(smartme.MeterTypeElectricity))}, []string{"Water 3", "<nil>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(smartme.FilterDevices(devices, tt.filters...))
+			if len(got) != len(tt.want) {
+				t.Fatalf("FilterDevices = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("FilterDevices = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_FindDeviceByName(t *testing.T) {
+	client, mux, teardown := setup(t)
+	defer teardown()
+
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]smartme.Device{
+			{Id: ptr("1"), Name: ptr("Garage")},
+			{Id: ptr("2"), Name: ptr("Heat pump")},
+		})
+	})
+
+	d, err := client.FindDeviceByName(context.Background(), "Heat pump")
+	if err != nil {
+		t.Fatalf("FindDeviceByName returned an unexpected error: %v", err)
+	}
+	if *d.Id != "2" {
+		t.Errorf("found device %s, want 2", *d.Id)
+	}
+
+	_, err = client.FindDeviceByName(context.Background(), "heat pump")
+	if !errors.Is(err, smartme.ErrDeviceNotFound) {
+		t.Errorf("error = %v, want ErrDeviceNotFound", err)
+	}
+}
@@ -0,0 +1,189 @@
+package smartme_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// The fixture tests decode the payload corpus in testdata/payloads, see
+// its README for how to add captured payloads.
+
+// readFixtures returns the payloads of a corpus directory by file name
+// without extension. It fails for payloads without an expectation, so every
+// added capture is checked.
+func readFixtures[T any](t *testing.T, dir string, expectations map[string]T) map[string][]byte {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "payloads", dir, "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures in %s: %v", dir, err)
+	}
+	fixtures := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if _, ok := expectations[name]; !ok {
+			t.Errorf("fixture %s/%s has no expectation", dir, name)
+		}
+		fixtures[name] = data
+	}
+	for name := range expectations {
+		if _, ok := fixtures[name]; !ok {
+			t.Errorf("expectation for missing fixture %s/%s", dir, name)
+		}
+	}
+	return fixtures
+}
+
+// roundTrip checks that encoding and decoding v again yields v.
+func roundTrip[T any](t *testing.T, v T) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var again T
+	if err := json.Unmarshal(data, &again); err != nil {
+		t.Fatalf("decoding %s again failed: %v", data, err)
+	}
+	if !reflect.DeepEqual(v, again) {
+		t.Errorf("round trip changed the value:\n got %+v\nwant %+v", again, v)
+	}
+}
+
+func TestFixtures_Devices(t *testing.T) {
+	tests := map[string]struct {
+		family       smartme.MeterFamilyType
+		energyType   smartme.MeterEnergyType
+		capabilities string
+		counter      float64
+	}{
+		"1phase-switch":    {3, smartme.MeterTypeElectricity, "switch,tariffs,temperature", 1523.476},
+		"3phase-80a-wifi":  {12, smartme.MeterTypeElectricity, "per-phase,tariffs", 48211.902},
+		"charging-station": {70, smartme.MeterTypeElectricity, "per-phase,charging", 3310.07},
+		"mbus-water":       {0, smartme.MeterTypeWater, "flow-rate,temperature", 812.344},
+		"mbus-heat":        {0, smartme.MeterTypeHeat, "flow-rate", 15234},
+		"virtual-billing":  {1002, smartme.MeterTypeVirtualMeter, "none", 412.5},
+		"rest-api-meter":   {1001, smartme.MeterTypeCustomDevice, "outputs", 77.1},
+	}
+	for name, data := range readFixtures(t, "devices", tests) {
+		tt := tests[name]
+		t.Run(name, func(t *testing.T) {
+			var d smartme.Device
+			if err := json.Unmarshal(data, &d); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if d.Id == nil || *d.Id == "" {
+				t.Error("device has no ID")
+			}
+			if d.FamilyType == nil || *d.FamilyType != tt.family {
+				t.Errorf("FamilyType = %v, want %d", d.FamilyType, tt.family)
+			}
+			if d.DeviceEnergyType == nil || *d.DeviceEnergyType != tt.energyType {
+				t.Errorf("DeviceEnergyType = %v, want %d", d.DeviceEnergyType, tt.energyType)
+			}
+			if got := smartme.Capabilities(d).String(); got != tt.capabilities {
+				t.Errorf("Capabilities = %s, want %s", got, tt.capabilities)
+			}
+			if d.CounterReading == nil || *d.CounterReading != tt.counter {
+				t.Errorf("CounterReading = %v, want %g", d.CounterReading, tt.counter)
+			}
+			if d.ValueDate == nil {
+				t.Error("device has no ValueDate")
+			}
+			roundTrip(t, d)
+		})
+	}
+}
+
+func TestFixtures_Values(t *testing.T) {
+	tests := map[string]struct {
+		date   time.Time
+		values int
+		obis   string
+		value  float64
+	}{
+		"3phase":      {time.Date(2025, 2, 3, 10, 15, 7, 123e6, time.UTC), 8, smartme.ObisActivePower, 5873},
+		"null-values": {time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC), 0, "", 0},
+	}
+	for name, data := range readFixtures(t, "values", tests) {
+		tt := tests[name]
+		t.Run(name, func(t *testing.T) {
+			var dv smartme.DeviceValues
+			if err := json.Unmarshal(data, &dv); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if dv.DeviceID == "" {
+				t.Error("values have no device ID")
+			}
+			if !dv.Date.Equal(tt.date) {
+				t.Errorf("Date = %v, want %v", dv.Date, tt.date)
+			}
+			if len(dv.Values) != tt.values {
+				t.Fatalf("got %d values, want %d", len(dv.Values), tt.values)
+			}
+			if tt.obis != "" {
+				found := false
+				for _, v := range dv.Values {
+					if v.Obis == tt.obis {
+						found = true
+						if v.Value != tt.value {
+							t.Errorf("%s = %g, want %g", tt.obis, v.Value, tt.value)
+						}
+					}
+				}
+				if !found {
+					t.Errorf("no value for %s", tt.obis)
+				}
+			}
+			roundTrip(t, dv)
+		})
+	}
+}
+
+func TestFixtures_ValuesInPast(t *testing.T) {
+	tests := map[string][]smartme.Value{
+		"single": {
+			{Date: time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC), Value: 47890.113},
+		},
+		"multiple": {
+			{Date: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), Value: 47890.113},
+			{Date: time.Date(2025, 2, 1, 0, 15, 0, 0, time.UTC), Value: 47890.402},
+			{Date: time.Date(2025, 2, 1, 0, 30, 0, 0, time.UTC), Value: 47890.688},
+			{Date: time.Date(2025, 1, 31, 23, 45, 0, 0, time.UTC), Value: 0},
+		},
+	}
+	for name, data := range readFixtures(t, "valuesinpast", tests) {
+		want := tests[name]
+		t.Run(name, func(t *testing.T) {
+			var got []smartme.Value
+			if len(want) == 1 && !strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+				var v smartme.Value
+				if err := json.Unmarshal(data, &v); err != nil {
+					t.Fatalf("Unmarshal failed: %v", err)
+				}
+				got = []smartme.Value{v}
+			} else if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d values, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !got[i].Date.Equal(want[i].Date) || got[i].Value != want[i].Value {
+					t.Errorf("value %d = %v %g, want %v %g", i, got[i].Date, got[i].Value, want[i].Date, want[i].Value)
+				}
+			}
+			roundTrip(t, got)
+		})
+	}
+}
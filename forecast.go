@@ -0,0 +1,88 @@
+package smartme
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Forecast is the projected consumption after the last reading.
+type Forecast struct {
+	// From is the date of the last reading, To is From plus the horizon.
+	From, To time.Time
+	// Consumption is the projected consumption between From and To.
+	Consumption float64
+	// Reading is the projected counter reading at To.
+	Reading float64
+	// Seasonal is true if the daily load profile was used, false for a
+	// linear projection of the average rate.
+	Seasonal bool
+}
+
+// ForecastConsumption projects the consumption of the next horizon from a
+// counter history, e.g. until the end of the month:
+//
+//	_, end := smartme.MonthRange(now, loc)
+//	f, err := smartme.ForecastConsumption(history, end.Sub(history[len(history)-1].Date))
+//
+// With at least a day of history the forecast is seasonal: every hour of
+// the horizon gets the average rate measured at that hour of day, so a
+// household's evening peak is projected into the coming evenings. Shorter
+// histories are projected linearly. Decreasing readings (counter resets)
+// are ignored.
+func ForecastConsumption(history []Value, horizon time.Duration) (Forecast, error) {
+	if len(history) < 2 {
+		return Forecast{}, errors.New("at least two readings are needed")
+	}
+	if horizon < 0 {
+		return Forecast{}, errors.New("horizon must not be negative")
+	}
+	values := append([]Value(nil), history...)
+	sort.SliceStable(values, func(i, j int) bool { return values[i].Date.Before(values[j].Date) })
+
+	first, last := values[0], values[len(values)-1]
+	f := Forecast{From: last.Date, To: last.Date.Add(horizon), Reading: last.Value}
+
+	// Average rates in units per hour, overall and per hour of day.
+	var total, hours float64
+	var slotTotal, slotHours [24]float64
+	for i := 1; i < len(values); i++ {
+		prev, cur := values[i-1], values[i]
+		d := cur.Date.Sub(prev.Date).Hours()
+		delta := cur.Value - prev.Value
+		if d <= 0 || delta < 0 {
+			continue
+		}
+		total += delta
+		hours += d
+		slot := prev.Date.Hour()
+		slotTotal[slot] += delta
+		slotHours[slot] += d
+	}
+	if hours == 0 {
+		return Forecast{}, errors.New("history contains no usable readings")
+	}
+	avg := total / hours
+
+	if last.Date.Sub(first.Date) < 24*time.Hour {
+		f.Consumption = avg * horizon.Hours()
+		f.Reading += f.Consumption
+		return f, nil
+	}
+
+	f.Seasonal = true
+	for t := f.From; t.Before(f.To); {
+		next := t.Truncate(time.Hour).Add(time.Hour)
+		if next.After(f.To) {
+			next = f.To
+		}
+		rate := avg
+		if slot := t.Hour(); slotHours[slot] > 0 {
+			rate = slotTotal[slot] / slotHours[slot]
+		}
+		f.Consumption += rate * next.Sub(t).Hours()
+		t = next
+	}
+	f.Reading += f.Consumption
+	return f, nil
+}
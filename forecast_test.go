@@ -0,0 +1,79 @@
+package smartme_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestForecastConsumption_Seasonal(t *testing.T) {
+	// 1 kWh per hour at night (0-12h) and 3 kWh per hour during the day.
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var history []smartme.Value
+	reading := 100.0
+	for h := 0; h <= 48; h++ {
+		history = append(history, smartme.Value{Date: start.Add(time.Duration(h) * time.Hour), Value: reading})
+		if h%24 < 12 {
+			reading++
+		} else {
+			reading += 3
+		}
+	}
+
+	f, err := smartme.ForecastConsumption(history, 18*time.Hour)
+	if err != nil {
+		t.Fatalf("ForecastConsumption failed: %v", err)
+	}
+	// 12 night hours and 6 day hours.
+	if !f.Seasonal || f.Consumption != 12*1+6*3 || f.Reading != history[48].Value+30 {
+		t.Errorf("forecast = %+v", f)
+	}
+	if !f.To.Equal(start.Add(66 * time.Hour)) {
+		t.Errorf("To = %v", f.To)
+	}
+}
+
+func TestForecastConsumption_LinearAndErrors(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []smartme.Value{
+		{Date: start, Value: 10},
+		{Date: start.Add(2 * time.Hour), Value: 14},
+	}
+	f, err := smartme.ForecastConsumption(history, 5*time.Hour)
+	if err != nil || f.Seasonal || f.Consumption != 10 || f.Reading != 24 {
+		t.Errorf("forecast = %+v, %v", f, err)
+	}
+
+	if _, err := smartme.ForecastConsumption(history[:1], time.Hour); err == nil {
+		t.Error("a single reading was accepted")
+	}
+	flat := []smartme.Value{{Date: start, Value: 5}, {Date: start.Add(time.Hour), Value: 5}}
+	if f, err := smartme.ForecastConsumption(flat, time.Hour); err != nil || f.Consumption != 0 {
+		t.Errorf("forecast without consumption = %+v, %v", f, err)
+	}
+	reset := []smartme.Value{{Date: start, Value: 5}, {Date: start.Add(time.Hour), Value: 1}}
+	if _, err := smartme.ForecastConsumption(reset, time.Hour); err == nil {
+		t.Error("a history with only a counter reset was accepted")
+	}
+}
+
+func TestForecastConsumption_GeneratedMonth(t *testing.T) {
+	d := smartme.Device{Id: ptr("house")}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	full := smartmetest.GenerateHistory(d, start, start.AddDate(0, 0, 28), smartmetest.ProfileHousehold)
+
+	// Forecast the last two weeks from the first two.
+	half := len(full) / 2
+	last := full[len(full)-1]
+	f, err := smartme.ForecastConsumption(full[:half+1], last.Date.Sub(full[half].Date))
+	if err != nil {
+		t.Fatalf("ForecastConsumption failed: %v", err)
+	}
+	actual := last.Value - full[half].Value
+	if math.Abs(f.Consumption-actual)/actual > 0.1 {
+		t.Errorf("forecast %.1f kWh, actual %.1f kWh", f.Consumption, actual)
+	}
+}
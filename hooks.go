@@ -0,0 +1,96 @@
+package smartme
+
+import (
+	"errors"
+	"fmt"
+)
+
+// decodeHooks are the hooks configured with WithDecodeHook and
+// WithValuesDecodeHook, run in the order they were added.
+type decodeHooks struct {
+	devices []func(*Device) error
+	values  []func(*DeviceValues) error
+}
+
+// WithDecodeHook adds a hook that is called for every device the client
+// decodes, e.g. to normalize names, apply calibration factors or enrich
+// devices from another source in one place instead of at every call site:
+//
+//	client, err := smartme.NewClient(user, pass, smartme.WithDecodeHook(func(d *smartme.Device) error {
+//		if d.Name != nil {
+//			name := strings.TrimSpace(*d.Name)
+//			d.Name = &name
+//		}
+//		return nil
+//	}))
+//
+// Hooks run in the order they were added, before the rounding of
+// WithPrecision and the checks of WithBounds, and also for responses from
+// the cache. An error of a hook fails the call.
+func WithDecodeHook(hook func(*Device) error) Option {
+	return func(c *Client) error {
+		if hook == nil {
+			return errors.New("decode hook must not be nil")
+		}
+		// Copy, so derived clients do not add to the hooks of the parent.
+		c.hooks.devices = append(c.hooks.devices[:len(c.hooks.devices):len(c.hooks.devices)], hook)
+		return nil
+	}
+}
+
+// WithValuesDecodeHook adds a hook that is called for the DeviceValues of
+// GetValues, GetValuesConcurrently and GetValuesAt, like WithDecodeHook.
+func WithValuesDecodeHook(hook func(*DeviceValues) error) Option {
+	return func(c *Client) error {
+		if hook == nil {
+			return errors.New("values decode hook must not be nil")
+		}
+		c.hooks.values = append(c.hooks.values[:len(c.hooks.values):len(c.hooks.values)], hook)
+		return nil
+	}
+}
+
+// run applies the hooks to a decoded response.
+func (h *decodeHooks) run(v interface{}) error {
+	switch v := v.(type) {
+	case *Device:
+		return h.runDevice(v)
+	case *[]Device:
+		for i := range *v {
+			if err := h.runDevice(&(*v)[i]); err != nil {
+				return err
+			}
+		}
+	case *DeviceValues:
+		return h.runValues(v)
+	case *[]DeviceValues:
+		for i := range *v {
+			if err := h.runValues(&(*v)[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *decodeHooks) runDevice(d *Device) error {
+	for _, hook := range h.devices {
+		if err := hook(d); err != nil {
+			id := ""
+			if d.Id != nil {
+				id = *d.Id
+			}
+			return fmt.Errorf("decode hook for device %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (h *decodeHooks) runValues(dv *DeviceValues) error {
+	for _, hook := range h.values {
+		if err := hook(dv); err != nil {
+			return fmt.Errorf("values decode hook for device %s: %w", dv.DeviceID, err)
+		}
+	}
+	return nil
+}
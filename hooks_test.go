@@ -0,0 +1,74 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestWithDecodeHook(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("a"), Name: ptr("  Kitchen "), CounterReading: ptr(100.0)})
+	srv.SetValues(smartme.DeviceValues{DeviceID: "a", Values: []smartme.ObisValue{{Obis: smartme.ObisActiveEnergyImport, Value: 100}}})
+
+	trim := func(d *smartme.Device) error {
+		name := strings.TrimSpace(*d.Name)
+		d.Name = &name
+		return nil
+	}
+	calibrate := func(d *smartme.Device) error {
+		v := *d.CounterReading * 1.0123
+		d.CounterReading = &v
+		return nil
+	}
+	client, _ := srv.Client(
+		smartme.WithDecodeHook(trim),
+		smartme.WithDecodeHook(calibrate),
+		smartme.WithPrecision(2, smartme.RoundHalfAwayFromZero),
+		smartme.WithValuesDecodeHook(func(dv *smartme.DeviceValues) error {
+			for i := range dv.Values {
+				dv.Values[i].Value *= 2
+			}
+			return nil
+		}),
+	)
+	ctx := context.Background()
+
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	if *devices[0].Name != "Kitchen" {
+		t.Errorf("Name = %q, want the trimmed name", *devices[0].Name)
+	}
+	// The hooks run before the rounding.
+	if *devices[0].CounterReading != 101.23 {
+		t.Errorf("CounterReading = %v, want 101.23", *devices[0].CounterReading)
+	}
+
+	values, err := client.GetValues(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetValues failed: %v", err)
+	}
+	if values.Values[0].Value != 200 {
+		t.Errorf("value = %v, want 200 from the values hook", values.Values[0].Value)
+	}
+
+	errUnknown := errors.New("unknown device")
+	failing, err := client.With(smartme.WithDecodeHook(func(d *smartme.Device) error { return errUnknown }))
+	if err != nil {
+		t.Fatalf("With failed: %v", err)
+	}
+	if _, err := failing.GetDevice(ctx, "a"); !errors.Is(err, errUnknown) {
+		t.Errorf("GetDevice with a failing hook: err = %v, want %v", err, errUnknown)
+	}
+	// The hook of the derived client does not apply to the parent.
+	if _, err := client.GetDevice(ctx, "a"); err != nil {
+		t.Errorf("GetDevice of the parent failed: %v", err)
+	}
+}
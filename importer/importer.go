@@ -0,0 +1,265 @@
+// Package importer submits counter readings from other systems to
+// smart-me, e.g. to migrate the history of meters into the account:
+//
+//	f, err := os.Open("readings.csv")
+//	im := &importer.Importer{OnProgress: func(p importer.Progress) { log.Print(p) }}
+//	progress, err := im.Import(ctx, client, importer.NewCSVReader(f))
+//
+// Every reading is stored with CreateOrUpdateDevice, which sets the
+// counter reading of a device at the given date; the devices must exist.
+// Requests are sent with smartme.PriorityBackfill unless ctx has a
+// priority, so a client limited with WithConcurrency keeps serving live
+// calls first.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Defaults of the Importer fields.
+const (
+	DefaultBatchSize   = 100
+	DefaultConcurrency = 4
+)
+
+// Importer submits readings in batches. The zero value is usable.
+type Importer struct {
+	// BatchSize is the number of readings validated and submitted together
+	// (default 100). Progress is reported after every batch.
+	BatchSize int
+	// Concurrency is the number of devices submitted in parallel within a
+	// batch (default 4). The readings of a device are submitted in order.
+	Concurrency int
+	// DryRun validates the readings and checks the devices without
+	// submitting anything.
+	DryRun bool
+	// Clock is used to reject readings in the future. Defaults to the
+	// system clock.
+	Clock smartme.Clock
+
+	// OnProgress is called after every batch.
+	OnProgress func(Progress)
+	// OnInvalid is called for every invalid reading, which is then
+	// skipped. Without it, Import stops at the first invalid reading.
+	OnInvalid func(*RowError)
+}
+
+// Progress counts the readings of an import.
+type Progress struct {
+	// Read is the number of readings read, including invalid ones.
+	Read int
+	// Submitted is the number of readings stored in smart-me, or that
+	// would have been with DryRun.
+	Submitted int
+	// Skipped is the number of invalid readings.
+	Skipped int
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("%d read, %d submitted, %d skipped", p.Read, p.Submitted, p.Skipped)
+}
+
+// device is what the importer knows about a device.
+type device struct {
+	// identity holds the fields of the device that are sent with every
+	// reading, since POST api/Devices resets the type of the device
+	// without them.
+	identity smartme.Device
+	// last is the last accepted reading.
+	last *Reading
+}
+
+// Import reads all readings from r and submits them. Readings must be in
+// chronological order per device, counters must not decrease, and dates
+// must not lie in the future. It stops at the first error of the API or
+// of r; Progress tells how many readings were submitted until then.
+func (im *Importer) Import(ctx context.Context, api smartme.API, r Reader) (Progress, error) {
+	if _, ok := smartme.PriorityFromContext(ctx); !ok {
+		ctx = smartme.ContextWithPriority(ctx, smartme.PriorityBackfill)
+	}
+	batchSize := im.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var progress Progress
+	devices := make(map[string]*device)
+	batch := make([]Reading, 0, batchSize)
+	for {
+		rd, err := r.Next()
+		eof := err == io.EOF
+		if !eof {
+			progress.Read++
+		}
+		var rowErr *RowError
+		switch {
+		case eof:
+		case errors.As(err, &rowErr):
+			if err := im.invalid(&progress, rowErr); err != nil {
+				return progress, err
+			}
+		case err != nil:
+			return progress, err
+		default:
+			if err := im.check(ctx, api, devices, rd); err != nil {
+				if !errors.As(err, &rowErr) {
+					return progress, err
+				}
+				if err := im.invalid(&progress, rowErr); err != nil {
+					return progress, err
+				}
+				break
+			}
+			batch = append(batch, rd)
+		}
+
+		if len(batch) == batchSize || (eof && len(batch) > 0) {
+			n, err := im.submit(ctx, api, devices, batch)
+			progress.Submitted += n
+			if err != nil {
+				return progress, err
+			}
+			batch = batch[:0]
+			if im.OnProgress != nil {
+				im.OnProgress(progress)
+			}
+		}
+		if eof {
+			return progress, nil
+		}
+	}
+}
+
+// invalid skips an invalid reading or returns it as error.
+func (im *Importer) invalid(progress *Progress, err *RowError) error {
+	if im.OnInvalid == nil {
+		return err
+	}
+	progress.Skipped++
+	im.OnInvalid(err)
+	return nil
+}
+
+// check validates a reading and looks up its device. Invalid readings are
+// returned as *RowError.
+func (im *Importer) check(ctx context.Context, api smartme.API, devices map[string]*device, rd Reading) error {
+	invalid := func(format string, args ...interface{}) error {
+		return &RowError{Line: rd.Line, DeviceID: rd.DeviceID, Err: fmt.Errorf(format, args...)}
+	}
+	switch {
+	case rd.DeviceID == "":
+		return invalid("device ID is missing")
+	case rd.Value < 0:
+		return invalid("negative counter reading %g", rd.Value)
+	case rd.Date.After(im.now()):
+		return invalid("date %s is in the future", rd.Date.Format(time.RFC3339))
+	}
+
+	d, ok := devices[rd.DeviceID]
+	if !ok {
+		found, err := api.GetDevice(ctx, rd.DeviceID)
+		var apiErr *smartme.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return invalid("%w", smartme.ErrDeviceNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("device %s: %w", rd.DeviceID, err)
+		}
+		d = &device{identity: smartme.Device{
+			Name:             found.Name,
+			Serial:           found.Serial,
+			DeviceEnergyType: found.DeviceEnergyType,
+			MeterSubType:     found.MeterSubType,
+			FamilyType:       found.FamilyType,
+		}}
+		devices[rd.DeviceID] = d
+	}
+	if d.last != nil {
+		if !rd.Date.After(d.last.Date) {
+			return invalid("date %s is not after the previous reading of line %d", rd.Date.Format(time.RFC3339), d.last.Line)
+		}
+		if rd.Value < d.last.Value {
+			return invalid("counter decreases from %g to %g", d.last.Value, rd.Value)
+		}
+	}
+	last := rd
+	d.last = &last
+	return nil
+}
+
+// submit stores a batch and returns the number of stored readings.
+func (im *Importer) submit(ctx context.Context, api smartme.API, devices map[string]*device, batch []Reading) (int, error) {
+	if im.DryRun {
+		return len(batch), nil
+	}
+
+	var order []string
+	byDevice := make(map[string][]Reading)
+	for _, rd := range batch {
+		if _, ok := byDevice[rd.DeviceID]; !ok {
+			order = append(order, rd.DeviceID)
+		}
+		byDevice[rd.DeviceID] = append(byDevice[rd.DeviceID], rd)
+	}
+
+	workers := im.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	var (
+		mu        sync.Mutex
+		submitted int
+		errs      []error
+		wg        sync.WaitGroup
+	)
+	sem := make(chan struct{}, workers)
+	for _, id := range order {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(readings []Reading, identity smartme.Device) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for _, rd := range readings {
+				if err := im.store(ctx, api, rd, identity); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					return
+				}
+				mu.Lock()
+				submitted++
+				mu.Unlock()
+			}
+		}(byDevice[id], devices[id].identity)
+	}
+	wg.Wait()
+	return submitted, errors.Join(errs...)
+}
+
+// store submits one reading together with the identity of the device.
+func (im *Importer) store(ctx context.Context, api smartme.API, rd Reading, identity smartme.Device) error {
+	id, value := rd.DeviceID, rd.Value
+	date := rd.Date.UTC().Format(time.RFC3339)
+	d := identity
+	d.Id, d.CounterReading, d.ValueDate = &id, &value, &date
+	_, err := api.CreateOrUpdateDevice(ctx, d)
+	if err != nil {
+		return fmt.Errorf("line %d: device %s: %w", rd.Line, rd.DeviceID, err)
+	}
+	return nil
+}
+
+func (im *Importer) now() time.Time {
+	if im.Clock == nil {
+		return smartme.SystemClock{}.Now()
+	}
+	return im.Clock.Now()
+}
@@ -0,0 +1,163 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/importer"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// newMock returns a mock with the devices dev-1 and dev-2 that records the
+// submitted devices.
+func newMock(submitted *[]smartme.Device) *smartmemock.API {
+	var mu sync.Mutex
+	return &smartmemock.API{
+		GetDeviceFunc: func(ctx context.Context, id string) (*smartme.Device, error) {
+			if id != "dev-1" && id != "dev-2" {
+				return nil, &smartme.APIError{StatusCode: http.StatusNotFound, Message: "not found"}
+			}
+			return &smartme.Device{Id: ptr(id), Name: ptr("Meter " + id)}, nil
+		},
+		CreateOrUpdateDeviceFunc: func(ctx context.Context, d smartme.Device) (*smartme.Device, error) {
+			if p, ok := smartme.PriorityFromContext(ctx); !ok || p != smartme.PriorityBackfill {
+				return nil, errors.New("not sent as backfill")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			*submitted = append(*submitted, d)
+			return &d, nil
+		},
+	}
+}
+
+func TestImport_CSV(t *testing.T) {
+	input := `device,date,value,comment
+dev-1,2024-01-01T00:00:00Z,100,
+dev-2,2024-01-01 00:00:00,50,
+dev-1,2024-01-01T01:00:00Z,99,counter decreases
+dev-1,2024-01-01T02:00:00Z,110,
+dev-3,2024-01-01T00:00:00Z,1,unknown device
+dev-2,not a date,51,
+dev-2,2030-01-01T00:00:00Z,60,future
+dev-2,2024-01-01T01:00:00+01:00,55,same instant as the first
+dev-2,2024-01-01T02:00:00Z,55.5,
+`
+	var submitted []smartme.Device
+	var invalid []string
+	var progress []importer.Progress
+	im := &importer.Importer{
+		BatchSize:  2,
+		Clock:      smartmetest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+		OnProgress: func(p importer.Progress) { progress = append(progress, p) },
+		OnInvalid:  func(err *importer.RowError) { invalid = append(invalid, err.Error()) },
+	}
+	got, err := im.Import(context.Background(), newMock(&submitted), importer.NewCSVReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if got != (importer.Progress{Read: 9, Submitted: 4, Skipped: 5}) {
+		t.Errorf("progress = %v", got)
+	}
+	if len(progress) != 2 || progress[1] != got {
+		t.Errorf("progress reports = %v, want 2 ending with the result", progress)
+	}
+	wantInvalid := []string{
+		"line 4: device dev-1: counter decreases from 100 to 99",
+		"line 6: device dev-3: device not found",
+		`line 7: device dev-2: invalid date "not a date"`,
+		"line 8: device dev-2: date 2030-01-01T00:00:00Z is in the future",
+		"line 9: device dev-2: date 2024-01-01T01:00:00+01:00 is not after the previous reading of line 3",
+	}
+	if strings.Join(invalid, "\n") != strings.Join(wantInvalid, "\n") {
+		t.Errorf("invalid readings:\n%s\nwant:\n%s", strings.Join(invalid, "\n"), strings.Join(wantInvalid, "\n"))
+	}
+
+	if len(submitted) != 4 {
+		t.Fatalf("%d readings submitted, want 4", len(submitted))
+	}
+	var last smartme.Device
+	for _, d := range submitted {
+		if *d.Id == "dev-1" {
+			last = d
+		}
+	}
+	if *last.Name != "Meter dev-1" || *last.CounterReading != 110 || *last.ValueDate != "2024-01-01T02:00:00Z" {
+		t.Errorf("last reading of dev-1 = %s %v %s", *last.Name, *last.CounterReading, *last.ValueDate)
+	}
+}
+
+func TestImport_KeepsDeviceType(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{
+		Id:               ptr("dev-1"),
+		Name:             ptr("Heat meter"),
+		Serial:           ptr(int64(4711)),
+		DeviceEnergyType: ptr(smartme.MeterTypeHeat),
+	})
+	client, _ := srv.Client()
+
+	input := "device,date,value\ndev-1,2024-01-01T00:00:00Z,1\ndev-1,2024-01-01T01:00:00Z,2\n"
+	im := &importer.Importer{Clock: smartmetest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))}
+	if _, err := im.Import(context.Background(), client, importer.NewCSVReader(strings.NewReader(input))); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	d, err := client.GetDevice(context.Background(), "dev-1")
+	if err != nil {
+		t.Fatalf("GetDevice failed: %v", err)
+	}
+	if d.DeviceEnergyType == nil || *d.DeviceEnergyType != smartme.MeterTypeHeat || d.Serial == nil || *d.Serial != 4711 ||
+		*d.Name != "Heat meter" || *d.CounterReading != 2 {
+		t.Errorf("device after import = %+v, want the type, serial and name kept", d)
+	}
+}
+
+func TestImport_StopsAtInvalid(t *testing.T) {
+	input := `{"deviceId":"dev-1","date":"2024-01-01T00:00:00Z","value":1}
+
+{"deviceId":"dev-1","date":"2024-01-01T01:00:00Z"}
+{"deviceId":"dev-1","date":"2024-01-01T02:00:00Z","value":2}
+`
+	var submitted []smartme.Device
+	im := &importer.Importer{}
+	got, err := im.Import(context.Background(), newMock(&submitted), importer.NewNDJSONReader(strings.NewReader(input)))
+	var rowErr *importer.RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 3 {
+		t.Fatalf("error = %v, want the missing value in line 3", err)
+	}
+	if got.Submitted != 0 || len(submitted) != 0 {
+		t.Errorf("submitted %d readings before the invalid one", got.Submitted)
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	input := "deviceId,timestamp,counterReading\ndev-1,2024-01-01T00:00:00Z,1\ndev-2,2024-01-01T00:00:00Z,2\n"
+	var submitted []smartme.Device
+	im := &importer.Importer{DryRun: true}
+	got, err := im.Import(context.Background(), newMock(&submitted), importer.NewCSVReader(strings.NewReader(input)))
+	if err != nil || got.Submitted != 2 || len(submitted) != 0 {
+		t.Errorf("dry run = %v, %v with %d submitted", got, err, len(submitted))
+	}
+}
+
+func TestNewCSVReader_MissingColumn(t *testing.T) {
+	r := importer.NewCSVReader(strings.NewReader("device,value\ndev-1,1\n"))
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("error = %v, want a missing date column", err)
+	}
+}
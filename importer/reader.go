@@ -0,0 +1,207 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reading is a counter reading of a device, in the unit of the device,
+// like kWh for electricity meters.
+type Reading struct {
+	DeviceID string
+	Date     time.Time
+	Value    float64
+	// Line is the line of the reading in the input, for error messages.
+	Line int
+}
+
+// Reader reads readings one at a time. Next returns io.EOF after the last
+// reading, and a *RowError for a line that cannot be parsed; reading can
+// continue after a *RowError.
+type Reader interface {
+	Next() (Reading, error)
+}
+
+// RowError is an invalid reading.
+type RowError struct {
+	Line     int
+	DeviceID string
+	Err      error
+}
+
+func (e *RowError) Error() string {
+	if e.DeviceID != "" {
+		return fmt.Sprintf("line %d: device %s: %v", e.Line, e.DeviceID, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// dateLayouts are the accepted timestamp formats. Timestamps without an
+// offset are UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// csvColumns maps accepted header names to the fields of a reading.
+var csvColumns = map[string]string{
+	"device": "device", "deviceid": "device", "id": "device",
+	"date": "date", "timestamp": "date", "time": "date",
+	"value": "value", "reading": "value", "counterreading": "value",
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	err     error
+}
+
+// NewCSVReader returns a Reader for CSV with a header line naming the
+// columns device, date and value; deviceId, timestamp and counterReading
+// are accepted as well, other columns are ignored:
+//
+//	device,date,value
+//	dev-1,2024-01-01T00:00:00Z,1234.5
+func NewCSVReader(r io.Reader) Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return &csvReader{r: cr}
+}
+
+func (c *csvReader) Next() (Reading, error) {
+	if c.err != nil {
+		return Reading{}, c.err
+	}
+	if c.columns == nil {
+		if err := c.readHeader(); err != nil {
+			c.err = err
+			return Reading{}, err
+		}
+	}
+
+	record, err := c.r.Read()
+	if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return Reading{}, &RowError{Line: perr.Line, Err: perr.Err}
+		}
+		return Reading{}, err
+	}
+	line, _ := c.r.FieldPos(0)
+	field := func(name string) string {
+		if i := c.columns[name]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	rd := Reading{DeviceID: field("device"), Line: line}
+	if rd.Date, err = parseDate(field("date")); err != nil {
+		return Reading{}, &RowError{Line: line, DeviceID: rd.DeviceID, Err: err}
+	}
+	if rd.Value, err = parseValue(field("value")); err != nil {
+		return Reading{}, &RowError{Line: line, DeviceID: rd.DeviceID, Err: err}
+	}
+	return rd, nil
+}
+
+func (c *csvReader) readHeader() error {
+	header, err := c.r.Read()
+	if err == io.EOF {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %w", err)
+	}
+	c.columns = make(map[string]int)
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[key]; ok {
+			c.columns[field] = i
+		}
+	}
+	for _, field := range []string{"device", "date", "value"} {
+		if _, ok := c.columns[field]; !ok {
+			return fmt.Errorf("CSV header has no %s column", field)
+		}
+	}
+	return nil
+}
+
+func parseValue(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+type ndjsonReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewNDJSONReader returns a Reader for newline-delimited JSON with one
+// reading per line; blank lines are skipped:
+//
+//	{"deviceId":"dev-1","date":"2024-01-01T00:00:00Z","value":1234.5}
+func NewNDJSONReader(r io.Reader) Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	return &ndjsonReader{s: s}
+}
+
+func (n *ndjsonReader) Next() (Reading, error) {
+	for n.s.Scan() {
+		n.line++
+		data := strings.TrimSpace(n.s.Text())
+		if data == "" {
+			continue
+		}
+		var raw struct {
+			DeviceID string   `json:"deviceId"`
+			Date     string   `json:"date"`
+			Value    *float64 `json:"value"`
+		}
+		if err := json.Unmarshal([]byte(data), &raw); err != nil {
+			return Reading{}, &RowError{Line: n.line, Err: err}
+		}
+		rd := Reading{DeviceID: raw.DeviceID, Line: n.line}
+		var err error
+		if rd.Date, err = parseDate(raw.Date); err != nil {
+			return Reading{}, &RowError{Line: n.line, DeviceID: rd.DeviceID, Err: err}
+		}
+		if raw.Value == nil {
+			return Reading{}, &RowError{Line: n.line, DeviceID: rd.DeviceID, Err: errors.New("value is missing")}
+		}
+		rd.Value = *raw.Value
+		return rd, nil
+	}
+	if err := n.s.Err(); err != nil {
+		return Reading{}, err
+	}
+	return Reading{}, io.EOF
+}
@@ -6,56 +6,144 @@ package smartme_test
 import (
 	"context"
 	"encoding/json"
+	"flag"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
 )
 
 // testConfig holds the credentials for the integration tests.
 type testConfig struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	DeviceID string `json:"deviceId"`
 }
 
-const configFileName = ".smartme-client-config.json"
+const (
+	configFileName = ".smartme-client-config.json"
+	fixtureDir     = "testdata/integration"
+)
+
+var (
+	config testConfig
 
-var config testConfig
+	endpointsFlag = flag.String("smartme.endpoints", os.Getenv("SMARTME_TEST_ENDPOINTS"),
+		"comma separated list of endpoints to test (devices,values,valuesinpast,valuesinpastmultiple); empty runs all")
+	recordFlag = flag.Bool("smartme.record", os.Getenv("SMARTME_TEST_RECORD") != "",
+		"record the API interactions to "+fixtureDir+" for replay without credentials")
+)
 
-// init loads the configuration from the user's home directory.
+// init loads the configuration from the environment, falling back to the
+// config file in the user's home directory for values not set there.
 func init() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return // Cannot find home directory, tests will be skipped.
+	if home, err := os.UserHomeDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, configFileName)); err == nil {
+			_ = json.Unmarshal(data, &config)
+		}
 	}
 
-	configPath := filepath.Join(home, configFileName)
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return // Config file not found, tests will be skipped.
+	if v := os.Getenv("SMARTME_TEST_USERNAME"); v != "" {
+		config.Username = v
+	}
+	if v := os.Getenv("SMARTME_TEST_PASSWORD"); v != "" {
+		config.Password = v
+	}
+	if v := os.Getenv("SMARTME_TEST_DEVICE_ID"); v != "" {
+		config.DeviceID = v
+	}
+}
+
+// endpointEnabled reports whether the given endpoint was selected with
+// -smartme.endpoints (or SMARTME_TEST_ENDPOINTS).
+func endpointEnabled(name string) bool {
+	if *endpointsFlag == "" {
+		return true
+	}
+	for _, e := range strings.Split(*endpointsFlag, ",") {
+		if strings.EqualFold(strings.TrimSpace(e), name) {
+			return true
+		}
 	}
-	_ = json.Unmarshal(data, &config)
+	return false
 }
 
 // setupIntegrationTest creates a real client for integration tests.
-// It skips the test if credentials are not available.
-func setupIntegrationTest(t *testing.T) *smartme.Client {
-	if config.Username == "" || config.Password == "" {
-		t.Skipf("Skipping integration test: credentials not found in ~/%s", configFileName)
+// It skips the test if the endpoint is not selected or if neither
+// credentials nor a recorded fixture are available.
+func setupIntegrationTest(t *testing.T, endpoint string) *smartme.Client {
+	if !endpointEnabled(endpoint) {
+		t.Skipf("Skipping integration test: endpoint %q not selected", endpoint)
 	}
 
-	client, err := smartme.NewClient(config.Username, config.Password)
+	fixture := filepath.Join(fixtureDir, t.Name()+".json")
+	haveCredentials := config.Username != "" && config.Password != ""
+
+	var mode smartmetest.Mode
+	switch {
+	case haveCredentials && *recordFlag:
+		mode = smartmetest.ModeRecord
+	case haveCredentials:
+		client, err := smartme.NewClient(config.Username, config.Password)
+		if err != nil {
+			t.Fatalf("Failed to create client for integration test: %v", err)
+		}
+		return client
+	default:
+		if _, err := os.Stat(fixture); err != nil {
+			t.Skipf("Skipping integration test: set SMARTME_TEST_USERNAME/SMARTME_TEST_PASSWORD or create ~/%s", configFileName)
+		}
+		mode = smartmetest.ModeReplay
+	}
+
+	rec, err := smartmetest.NewRecorder(fixture, mode, nil)
 	if err != nil {
-		t.Fatalf("Failed to create client for integration test: %v", err)
+		t.Fatalf("Failed to create recorder: %v", err)
 	}
+	t.Cleanup(func() {
+		if err := rec.Save(); err != nil {
+			t.Errorf("Failed to save fixture: %v", err)
+		}
+	})
 
+	username, password := config.Username, config.Password
+	if mode == smartmetest.ModeReplay {
+		username, password = "replay", "replay"
+	}
+	client, err := smartme.NewClient(username, password, smartme.WithHTTPClient(&http.Client{Transport: rec}))
+	if err != nil {
+		t.Fatalf("Failed to create client for integration test: %v", err)
+	}
 	return client
 }
 
+// testDeviceID returns the configured test device or the first device
+// of the account.
+func testDeviceID(t *testing.T, client *smartme.Client) string {
+	if config.DeviceID != "" {
+		return config.DeviceID
+	}
+	devices, err := client.GetDevices(context.Background())
+	if err != nil {
+		t.Fatalf("client.GetDevices() returned an error: %v", err)
+	}
+	for _, d := range devices {
+		if d.Id != nil {
+			return *d.Id
+		}
+	}
+	t.Skip("Skipping integration test: the account has no devices")
+	return ""
+}
+
 // TestIntegration_GetDevices performs a real API call to get devices.
 func TestIntegration_GetDevices(t *testing.T) {
-	client := setupIntegrationTest(t)
+	client := setupIntegrationTest(t, "devices")
 
 	devices, err := client.GetDevices(context.Background())
 	if err != nil {
@@ -82,3 +170,45 @@ func TestIntegration_GetDevices(t *testing.T) {
 		t.Logf("-> First device found: Name='%s', ID='%s'", deviceName, deviceID)
 	}
 }
+
+// TestIntegration_GetValues performs a real API call to get the current values of a device.
+func TestIntegration_GetValues(t *testing.T) {
+	client := setupIntegrationTest(t, "values")
+	deviceID := testDeviceID(t, client)
+
+	values, err := client.GetValues(context.Background(), deviceID)
+	if err != nil {
+		t.Fatalf("client.GetValues() returned an error: %v", err)
+	}
+
+	t.Logf("Retrieved %d values for device %s at %s.", len(values.Values), deviceID, values.Date)
+}
+
+// TestIntegration_GetValuesInPast performs a real API call to get a historical value.
+func TestIntegration_GetValuesInPast(t *testing.T) {
+	client := setupIntegrationTest(t, "valuesinpast")
+	deviceID := testDeviceID(t, client)
+
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	value, err := client.GetValuesInPast(context.Background(), deviceID, date)
+	if err != nil {
+		t.Fatalf("client.GetValuesInPast() returned an error: %v", err)
+	}
+
+	t.Logf("Value of device %s before %s: %v at %s.", deviceID, date, value.Value, value.Date)
+}
+
+// TestIntegration_GetValuesInPastMultiple performs a real API call to get a range of
+// historical values. It requires a professional license.
+func TestIntegration_GetValuesInPastMultiple(t *testing.T) {
+	client := setupIntegrationTest(t, "valuesinpastmultiple")
+	deviceID := testDeviceID(t, client)
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	values, err := client.GetValuesInPastMultiple(context.Background(), deviceID, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("client.GetValuesInPastMultiple() returned an error: %v", err)
+	}
+
+	t.Logf("Retrieved %d historical values for device %s.", len(values), deviceID)
+}
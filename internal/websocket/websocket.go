@@ -0,0 +1,163 @@
+// Package websocket implements the parts of RFC 6455 shared by the
+// WebSocket server of wsbridge and the OCPP client: the accept key of the
+// opening handshake and the framing of small messages.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// acceptGUID is appended to the client key in the opening handshake.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageSize limits the messages accepted from the peer.
+const MaxMessageSize = 64 << 10
+
+// Opcodes of frames.
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xa
+)
+
+// AcceptKey computes the Sec-WebSocket-Accept header for a client key.
+func AcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// WriteFrame writes a frame with the FIN bit set. Clients mask their
+// frames with a random key, servers do not.
+func WriteFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = maskBit | byte(n)
+	case n <= 0xffff:
+		header[1] = maskBit | 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = maskBit | 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if masked {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		header = append(header, mask[:]...)
+		out := make([]byte, len(payload))
+		for i := range payload {
+			out[i] = payload[i] ^ mask[i%4]
+		}
+		payload = out
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// ReadFrame reads a frame. masked tells whether the frames of the peer
+// must be masked, as those of clients, or must not be, as those of
+// servers.
+func ReadFrame(r *bufio.Reader, masked bool) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0f
+	switch isMasked := head[1]&0x80 != 0; {
+	case masked && !isMasked:
+		return false, 0, nil, errors.New("websocket: client frame is not masked")
+	case !masked && isMasked:
+		return false, 0, nil, errors.New("websocket: server frame is masked")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > MaxMessageSize {
+		return false, 0, nil, fmt.Errorf("websocket: frame of %d bytes is too large", n)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// ReadMessage reads the next text or binary message, joining fragmented
+// ones. Pings are answered and a close frame is echoed with write, after
+// which io.EOF is returned.
+func ReadMessage(r *bufio.Reader, masked bool, write func(opcode byte, payload []byte) error) ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ReadFrame(r, masked)
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case OpPing:
+			write(OpPong, payload)
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			write(OpClose, payload)
+			return nil, io.EOF
+		case OpText, OpBinary:
+			message = payload
+		case OpContinuation:
+			if len(message)+len(payload) > MaxMessageSize {
+				return nil, fmt.Errorf("websocket: message is larger than %d bytes", MaxMessageSize)
+			}
+			message = append(message, payload...)
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %d", opcode)
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
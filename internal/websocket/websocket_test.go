@@ -0,0 +1,62 @@
+package websocket_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client/internal/websocket"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455, section 1.3.
+	if got := websocket.AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("AcceptKey = %q", got)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	for _, masked := range []bool{false, true} {
+		var buf bytes.Buffer
+		websocket.WriteFrame(&buf, websocket.OpPing, []byte("p"), masked)
+		long := strings.Repeat("x", 200) // with a 16-bit length
+		// A fragmented text message: FIN is only set on the last frame.
+		start := buf.Len()
+		websocket.WriteFrame(&buf, websocket.OpText, []byte(long), masked)
+		buf.Bytes()[start] &^= 0x80
+		websocket.WriteFrame(&buf, websocket.OpContinuation, []byte("!"), masked)
+		websocket.WriteFrame(&buf, websocket.OpClose, nil, masked)
+
+		var written []byte
+		write := func(opcode byte, payload []byte) error {
+			written = append(written, opcode)
+			return nil
+		}
+		r := bufio.NewReader(&buf)
+		msg, err := websocket.ReadMessage(r, masked, write)
+		if err != nil || string(msg) != long+"!" {
+			t.Fatalf("masked %v: ReadMessage = %q, %v", masked, msg, err)
+		}
+		if _, err := websocket.ReadMessage(r, masked, write); err != io.EOF {
+			t.Errorf("masked %v: ReadMessage after close = %v, want EOF", masked, err)
+		}
+		if string(written) != string([]byte{websocket.OpPong, websocket.OpClose}) {
+			t.Errorf("masked %v: answered with opcodes %v, want pong and close", masked, written)
+		}
+	}
+}
+
+func TestReadFrame_Masking(t *testing.T) {
+	var buf bytes.Buffer
+	websocket.WriteFrame(&buf, websocket.OpText, []byte("hi"), false)
+	if _, _, _, err := websocket.ReadFrame(bufio.NewReader(&buf), true); err == nil {
+		t.Error("unmasked frame accepted from a client")
+	}
+	buf.Reset()
+	websocket.WriteFrame(&buf, websocket.OpText, []byte("hi"), true)
+	if _, _, _, err := websocket.ReadFrame(bufio.NewReader(&buf), false); err == nil {
+		t.Error("masked frame accepted from a server")
+	}
+}
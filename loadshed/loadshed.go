@@ -0,0 +1,344 @@
+// Package loadshed keeps the power drawn by a site below a limit by
+// switching off loads, as needed for peak shaving:
+//
+//	ctrl := loadshed.NewController(client, loadshed.Config{
+//		GridMeterID: gridID,
+//		Limit:       40000,
+//		Loads: []loadshed.Load{
+//			{DeviceID: heatPumpID, Priority: 1, Power: 6000},
+//			{DeviceID: boilerID, Priority: 2, Power: 3000},
+//		},
+//	})
+//	err := ctrl.Run(ctx)
+//
+// Loads are switched with the relay of their smart-me meter. Loads with
+// the highest Priority value are shed first and restored last. The round
+// after a shed sheds nothing and the round after a shed or a restore
+// restores nothing, since the grid meter may not show the change yet.
+//
+// When Run returns, the shed loads are switched on again. Loads that
+// cannot be switched on then, or are left off by a crash, are restored by
+// the next run if the shed loads are kept in a StateFile.
+package loadshed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Defaults of the Config fields.
+const (
+	DefaultInterval   = 10 * time.Second
+	DefaultHysteresis = 1000.0
+	DefaultMinOffTime = 5 * time.Minute
+)
+
+// Load is a switchable consumer.
+type Load struct {
+	DeviceID string
+	// Priority orders the loads: loads with a higher value are less
+	// important and are shed first. Loads with the same value form a tier.
+	Priority int
+	// Power is the expected power of the load in W when it is on. If it is
+	// 0, one load is shed or restored per round.
+	Power float64
+}
+
+// Config configures a Controller. Zero values select the defaults.
+type Config struct {
+	// GridMeterID is the meter at the grid connection point. It must
+	// report import as positive active power.
+	GridMeterID string
+	// Limit is the maximum grid import in W.
+	Limit float64
+	// Loads are the loads the controller may switch off.
+	Loads []Load
+
+	// Interval is the time between two rounds (default 10s).
+	Interval time.Duration
+	// Hysteresis in W: loads are restored only while the grid import stays
+	// this far below Limit, including the power of the restored load
+	// (default 1000 W).
+	Hysteresis float64
+	// MinOffTime is how long a shed load stays off at least (default 5m),
+	// so devices like compressors are not switched too often.
+	MinOffTime time.Duration
+	// StateFile stores the shed loads and when they were switched off, so
+	// a later run restores loads left off by a crash. Without it, only the
+	// loads shed by the running controller are restored.
+	StateFile string
+
+	// Clock is used by the watcher and for the minimum off time. Defaults
+	// to the system clock.
+	Clock smartme.Clock
+	// OnDecision is called after every round.
+	OnDecision func(Decision)
+	// OnError is called for errors that do not stop the controller.
+	OnError func(err error)
+}
+
+// Decision describes one round.
+type Decision struct {
+	Time time.Time
+	// GridPower is the measured grid import in W.
+	GridPower float64
+	// Shed and Restored are the loads switched off and on in this round.
+	Shed, Restored []string
+	// Off are the loads that are off after this round.
+	Off []string
+}
+
+// Controller sheds and restores loads to keep the grid import below the
+// limit.
+type Controller struct {
+	api   smartme.API
+	cfg   Config
+	loads []Load
+	// off maps the shed loads to the time they were switched off.
+	off map[string]time.Time
+	// shedSettling and restoreSettling are set after a round that shed
+	// or restored loads.
+	shedSettling, restoreSettling bool
+}
+
+// Controller can be supervised by a smartme.Runner.
+var _ smartme.Service = (*Controller)(nil)
+
+// NewController returns a Controller for the loads in cfg.
+func NewController(api smartme.API, cfg Config) *Controller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = DefaultHysteresis
+	}
+	if cfg.MinOffTime <= 0 {
+		cfg.MinOffTime = DefaultMinOffTime
+	}
+	// Shedding order: least important first.
+	loads := append([]Load(nil), cfg.Loads...)
+	sort.SliceStable(loads, func(i, j int) bool { return loads[i].Priority > loads[j].Priority })
+	return &Controller{api: api, cfg: cfg, loads: loads, off: make(map[string]time.Time)}
+}
+
+// Run controls the loads until ctx is done and returns ctx.Err(). Loads
+// are only switched on again if the controller switched them off; the
+// loads that are off when ctx is done are switched on before Run returns.
+func (c *Controller) Run(ctx context.Context) error {
+	if c.cfg.Limit <= 0 {
+		return errors.New("loadshed: limit must be positive")
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+	w := smartme.NewWatcher(c.api, c.cfg.GridMeterID)
+	w.Interval = c.cfg.Interval
+	w.Clock = c.cfg.Clock
+	w.OnError = func(_ string, err error) { c.report(err) }
+	w.OnDevice = func(d smartme.Device) {
+		power, err := d.ActivePowerWatts()
+		if err != nil {
+			c.report(err)
+			return
+		}
+		c.adjust(ctx, power)
+	}
+	err := w.Run(ctx)
+
+	// Do not leave the loads off; ctx is done, so switch without it.
+	var ids []string
+	for i := len(c.loads) - 1; i >= 0; i-- {
+		if _, ok := c.off[c.loads[i].DeviceID]; ok {
+			ids = append(ids, c.loads[i].DeviceID)
+		}
+	}
+	c.switchLoads(context.WithoutCancel(ctx), ids, true, c.now())
+	return err
+}
+
+// adjust runs one round.
+func (c *Controller) adjust(ctx context.Context, gridPower float64) {
+	now := c.now()
+	d := Decision{Time: now, GridPower: gridPower}
+
+	shedSettling, restoreSettling := c.shedSettling, c.restoreSettling
+	switch {
+	case gridPower > c.cfg.Limit && shedSettling:
+		// The reading may predate the last shed.
+	case gridPower > c.cfg.Limit:
+		d.Shed = c.shed(ctx, gridPower-c.cfg.Limit, now)
+	case gridPower < c.cfg.Limit-c.cfg.Hysteresis && (shedSettling || restoreSettling):
+		// The reading may predate the last switch; restoring on it could
+		// overshoot the limit.
+	case gridPower < c.cfg.Limit-c.cfg.Hysteresis:
+		d.Restored = c.restore(ctx, c.cfg.Limit-c.cfg.Hysteresis-gridPower, now)
+	}
+	c.shedSettling = len(d.Shed) > 0
+	c.restoreSettling = len(d.Restored) > 0
+
+	for _, l := range c.loads {
+		if _, ok := c.off[l.DeviceID]; ok {
+			d.Off = append(d.Off, l.DeviceID)
+		}
+	}
+	if c.cfg.OnDecision != nil {
+		c.cfg.OnDecision(d)
+	}
+}
+
+// shed switches off loads, least important first, until their power
+// covers excess.
+func (c *Controller) shed(ctx context.Context, excess float64, now time.Time) []string {
+	var ids []string
+	for _, l := range c.loads {
+		if excess <= 0 {
+			break
+		}
+		if _, ok := c.off[l.DeviceID]; ok {
+			continue
+		}
+		ids = append(ids, l.DeviceID)
+		if l.Power <= 0 {
+			break
+		}
+		excess -= l.Power
+	}
+	return c.switchLoads(ctx, ids, false, now)
+}
+
+// restore switches on shed loads, most important first, while their power
+// fits into headroom.
+func (c *Controller) restore(ctx context.Context, headroom float64, now time.Time) []string {
+	var ids []string
+	for i := len(c.loads) - 1; i >= 0; i-- {
+		l := c.loads[i]
+		since, ok := c.off[l.DeviceID]
+		if !ok {
+			continue
+		}
+		if now.Sub(since) < c.cfg.MinOffTime || l.Power > headroom {
+			// Restore in order; a more important load must not be
+			// overtaken by a less important one.
+			break
+		}
+		ids = append(ids, l.DeviceID)
+		if l.Power <= 0 {
+			break
+		}
+		headroom -= l.Power
+	}
+	return c.switchLoads(ctx, ids, true, now)
+}
+
+// switchLoads switches the loads and returns those that were switched.
+func (c *Controller) switchLoads(ctx context.Context, ids []string, on bool, now time.Time) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	value := 0.0
+	if on {
+		value = 1
+	}
+	actions := make(map[string][]smartme.Action, len(ids))
+	for _, id := range ids {
+		actions[id] = []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: value}}
+	}
+
+	var failed map[string]error
+	if err := c.api.ExecuteActionsBulk(ctx, actions); err != nil {
+		var bulkErr *smartme.BulkError
+		if !errors.As(err, &bulkErr) {
+			c.report(err)
+			return nil
+		}
+		c.report(err)
+		failed = bulkErr.Errors
+	}
+
+	var switched []string
+	for _, id := range ids {
+		if failed[id] != nil {
+			continue
+		}
+		if on {
+			delete(c.off, id)
+		} else {
+			c.off[id] = now
+		}
+		switched = append(switched, id)
+	}
+	if len(switched) > 0 {
+		c.save()
+	}
+	return switched
+}
+
+// state is the content of the state file.
+type state struct {
+	// Off maps the shed loads to the time they were switched off.
+	Off map[string]time.Time `json:"off"`
+}
+
+// load reads the shed loads from the state file. Loads that are no longer
+// configured are ignored.
+func (c *Controller) load() error {
+	if c.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.cfg.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("invalid loadshed state %s: %w", c.cfg.StateFile, err)
+	}
+	for _, l := range c.loads {
+		if since, ok := st.Off[l.DeviceID]; ok {
+			c.off[l.DeviceID] = since
+		}
+	}
+	return nil
+}
+
+// save writes the state file atomically. Errors are reported with OnError,
+// since the controller can go on without it.
+func (c *Controller) save() {
+	if c.cfg.StateFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(state{Off: c.off}, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(c.cfg.StateFile), "."+filepath.Base(c.cfg.StateFile)+".tmp")
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, c.cfg.StateFile)
+		}
+	}
+	if err != nil {
+		c.report(fmt.Errorf("loadshed: saving state: %w", err))
+	}
+}
+
+func (c *Controller) now() time.Time {
+	if c.cfg.Clock != nil {
+		return c.cfg.Clock.Now()
+	}
+	return smartme.SystemClock{}.Now()
+}
+
+func (c *Controller) report(err error) {
+	if c.cfg.OnError != nil {
+		c.cfg.OnError(err)
+	}
+}
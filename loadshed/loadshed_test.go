@@ -0,0 +1,259 @@
+package loadshed_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/loadshed"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestController(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	setGrid := func(w float64) {
+		srv.AddDevice(smartme.Device{Id: ptr("grid"), ActivePower: ptr(w), ActivePowerUnit: ptr("W")})
+	}
+	srv.AddDevice(smartme.Device{Id: ptr("heatpump"), SwitchOn: ptr(true)})
+	srv.AddDevice(smartme.Device{Id: ptr("boiler"), SwitchOn: ptr(true)})
+
+	clock := smartmetest.NewClock(time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC))
+	var mu sync.Mutex
+	var decisions []loadshed.Decision
+	ctrl := loadshed.NewController(client, loadshed.Config{
+		GridMeterID: "grid",
+		Limit:       40000,
+		Loads: []loadshed.Load{
+			{DeviceID: "heatpump", Priority: 1, Power: 6000},
+			{DeviceID: "boiler", Priority: 2, Power: 3000},
+		},
+		Interval:   time.Minute,
+		MinOffTime: 3 * time.Minute,
+		Clock:      clock,
+		OnDecision: func(d loadshed.Decision) {
+			mu.Lock()
+			defer mu.Unlock()
+			decisions = append(decisions, d)
+		},
+		OnError: func(err error) { t.Errorf("controller error: %v", err) },
+	})
+
+	steps := []struct {
+		name           string
+		grid           float64
+		shed, restored []string
+	}{
+		{"peak sheds both tiers", 45000, []string{"boiler", "heatpump"}, nil},
+		{"minimum off time", 33000, nil, nil},
+		{"still off", 33000, nil, nil},
+		{"most important first", 33000, nil, []string{"heatpump"}},
+		{"within hysteresis", 39500, nil, nil},
+		{"headroom for the boiler", 35000, nil, []string{"boiler"}},
+	}
+
+	setGrid(steps[0].grid)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ctrl.Run(ctx) }()
+
+	for i, step := range steps {
+		clock.WaitForTimers(t, 1)
+		mu.Lock()
+		if len(decisions) != i+1 {
+			mu.Unlock()
+			t.Fatalf("step %q: %d decisions, want %d", step.name, len(decisions), i+1)
+		}
+		d := decisions[i]
+		mu.Unlock()
+		if !reflect.DeepEqual(d.Shed, step.shed) || !reflect.DeepEqual(d.Restored, step.restored) {
+			t.Errorf("step %q: shed %v restored %v, want %v %v", step.name, d.Shed, d.Restored, step.shed, step.restored)
+		}
+		if i+1 < len(steps) {
+			setGrid(steps[i+1].grid)
+		}
+		clock.Advance(time.Minute)
+	}
+	cancel()
+	<-done
+
+	for _, id := range []string{"heatpump", "boiler"} {
+		actions := srv.Actions(id)
+		if len(actions) != 2 || actions[0].Value != 0 || actions[1].Value != 1 {
+			t.Errorf("actions of %s = %v, want off and on", id, actions)
+		}
+	}
+}
+
+// runRounds runs a controller for cfg with one round per grid reading and
+// returns its decisions. The loads in cfg start switched on.
+func runRounds(t *testing.T, cfg loadshed.Config, grid []float64) []loadshed.Decision {
+	t.Helper()
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+	setGrid := func(w float64) {
+		srv.AddDevice(smartme.Device{Id: ptr(cfg.GridMeterID), ActivePower: ptr(w), ActivePowerUnit: ptr("W")})
+	}
+	for _, l := range cfg.Loads {
+		srv.AddDevice(smartme.Device{Id: ptr(l.DeviceID), SwitchOn: ptr(true)})
+	}
+
+	clock := smartmetest.NewClock(time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC))
+	decisions := make(chan loadshed.Decision, 1)
+	cfg.Interval = time.Minute
+	cfg.Clock = clock
+	cfg.OnDecision = func(d loadshed.Decision) { decisions <- d }
+	cfg.OnError = func(err error) { t.Errorf("controller error: %v", err) }
+	ctrl := loadshed.NewController(client, cfg)
+
+	setGrid(grid[0])
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ctrl.Run(ctx) }()
+
+	var got []loadshed.Decision
+	for i := range grid {
+		got = append(got, <-decisions)
+		if i+1 < len(grid) {
+			setGrid(grid[i+1])
+			clock.WaitForTimers(t, 1)
+			clock.Advance(time.Minute)
+		}
+	}
+	cancel()
+	<-done
+	return got
+}
+
+func TestController_SettlesAfterShed(t *testing.T) {
+	// The grid meter still reports the power from before the shed.
+	decisions := runRounds(t, loadshed.Config{
+		GridMeterID: "grid",
+		Limit:       40000,
+		Loads: []loadshed.Load{
+			{DeviceID: "heatpump", Priority: 1},
+			{DeviceID: "boiler", Priority: 2},
+		},
+	}, []float64{45000, 45000, 45000})
+
+	want := [][]string{{"boiler"}, nil, {"heatpump"}}
+	for i, shed := range want {
+		if d := decisions[i]; !reflect.DeepEqual(d.Shed, shed) {
+			t.Errorf("round %d: shed %v, want %v", i, d.Shed, shed)
+		}
+	}
+}
+
+func TestController_SettlesAfterRestore(t *testing.T) {
+	// After the heat pump is restored, the grid meter still reports the
+	// power from before, which would leave room for the boiler as well.
+	decisions := runRounds(t, loadshed.Config{
+		GridMeterID: "grid",
+		Limit:       40000,
+		Loads: []loadshed.Load{
+			{DeviceID: "heatpump", Priority: 1, Power: 6000},
+			{DeviceID: "boiler", Priority: 2, Power: 3000},
+		},
+		MinOffTime: time.Minute,
+	}, []float64{45000, 32000, 32000, 32000, 38000})
+
+	want := []struct{ shed, restored []string }{
+		{[]string{"boiler", "heatpump"}, nil},
+		{nil, nil},
+		{nil, []string{"heatpump"}},
+		{nil, nil},
+		{nil, nil},
+	}
+	for i, w := range want {
+		d := decisions[i]
+		if !reflect.DeepEqual(d.Shed, w.shed) || !reflect.DeepEqual(d.Restored, w.restored) {
+			t.Errorf("round %d: shed %v restored %v, want %v %v", i, d.Shed, d.Restored, w.shed, w.restored)
+		}
+	}
+}
+
+func TestController_RestoresOnExit(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+	srv.AddDevice(smartme.Device{Id: ptr("heatpump"), SwitchOn: ptr(true)})
+	srv.AddDevice(smartme.Device{Id: ptr("boiler"), SwitchOn: ptr(true)})
+	stateFile := filepath.Join(t.TempDir(), "loadshed.json")
+	clock := smartmetest.NewClock(time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC))
+
+	// run runs a controller for one round at the grid power and returns
+	// its decision and the state file as written during the round.
+	run := func(grid float64) (loadshed.Decision, string) {
+		srv.AddDevice(smartme.Device{Id: ptr("grid"), ActivePower: ptr(grid), ActivePowerUnit: ptr("W")})
+		var d loadshed.Decision
+		var saved []byte
+		round := make(chan struct{})
+		ctrl := loadshed.NewController(client, loadshed.Config{
+			GridMeterID: "grid",
+			Limit:       40000,
+			Loads: []loadshed.Load{
+				{DeviceID: "heatpump", Priority: 1, Power: 6000},
+				{DeviceID: "boiler", Priority: 2, Power: 3000},
+			},
+			MinOffTime: 3 * time.Minute,
+			StateFile:  stateFile,
+			Clock:      clock,
+			OnDecision: func(dec loadshed.Decision) {
+				d = dec
+				saved, _ = os.ReadFile(stateFile)
+				close(round)
+			},
+			OnError: func(err error) { t.Errorf("controller error: %v", err) },
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- ctrl.Run(ctx) }()
+		<-round
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+		return d, string(saved)
+	}
+	switchedOn := func(id string) bool {
+		d, _ := client.GetDevice(context.Background(), id)
+		return *d.SwitchOn
+	}
+
+	// The shed loads are kept in the state file and switched on when Run
+	// returns.
+	d, saved := run(45000)
+	if len(d.Shed) != 2 || !strings.Contains(saved, "heatpump") || !strings.Contains(saved, "boiler") {
+		t.Errorf("shed %v with state %s, want both loads", d.Shed, saved)
+	}
+	if !switchedOn("heatpump") || !switchedOn("boiler") {
+		t.Error("loads not switched on after Run returned")
+	}
+
+	// After a crash, the state file still lists the loads that were shed;
+	// the next run restores them once the minimum off time has passed.
+	os.WriteFile(stateFile, []byte(`{"off": {"heatpump": "2025-01-15T17:50:00Z", "boiler": "2025-01-15T17:58:00Z"}}`), 0o644)
+	client.PerformActions(context.Background(), "heatpump", []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 0}})
+	client.PerformActions(context.Background(), "boiler", []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 0}})
+	d, _ = run(20000)
+	if !reflect.DeepEqual(d.Restored, []string{"heatpump"}) || !reflect.DeepEqual(d.Off, []string{"boiler"}) {
+		t.Errorf("restored %v, off %v, want the heat pump restored and the boiler still off", d.Restored, d.Off)
+	}
+	if !switchedOn("heatpump") || !switchedOn("boiler") {
+		t.Error("loads not switched on after Run returned")
+	}
+}
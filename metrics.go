@@ -0,0 +1,133 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrValueNotAvailable is returned by the one-shot getters if the device
+// does not report the requested quantity.
+var ErrValueNotAvailable = errors.New("value not available")
+
+// powerUnits maps power units to their factor to W. The keys are spelled
+// exactly, since "mW" and "MW" differ by a factor of 10⁹.
+var powerUnits = map[string]float64{
+	"W":  1,
+	"mW": 1e-3,
+	"kW": 1e3,
+	"MW": 1e6,
+}
+
+// counterUnits maps counter units to their factor to kWh for energy and
+// to m³ for volume. The keys are spelled exactly, see powerUnits.
+var counterUnits = map[string]float64{
+	"Wh":  1e-3,
+	"mWh": 1e-6,
+	"kWh": 1,
+	"MWh": 1e3,
+	"m³":  1,
+	"m3":  1,
+	"l":   1e-3,
+}
+
+// GetActivePower returns the current active power of a device in W.
+func (c *Client) GetActivePower(ctx context.Context, deviceID string, opts ...CallOption) (float64, error) {
+	d, err := c.GetDevice(ctx, deviceID, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return d.ActivePowerWatts()
+}
+
+// ActivePowerWatts returns the active power of the device in W.
+func (d *Device) ActivePowerWatts() (float64, error) {
+	var id string
+	if d.Id != nil {
+		id = *d.Id
+	}
+	return normalize("active power", id, d.ActivePower, d.ActivePowerUnit, "W", powerUnits)
+}
+
+// GetCounterReading returns the counter reading of a device, in kWh for
+// energy meters and in m³ for water and gas meters.
+func (c *Client) GetCounterReading(ctx context.Context, deviceID string, opts ...CallOption) (float64, error) {
+	d, err := c.GetDevice(ctx, deviceID, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return d.CounterReadingValue()
+}
+
+// CounterReadingValue returns the counter reading of the device, in kWh for
+// energy meters and in m³ for water and gas meters.
+func (d *Device) CounterReadingValue() (float64, error) {
+	var id string
+	if d.Id != nil {
+		id = *d.Id
+	}
+	return normalize("counter reading", id, d.CounterReading, d.CounterReadingUnit, "kWh", counterUnits)
+}
+
+// GetTemperature returns the temperature measured by a device in °C.
+func (c *Client) GetTemperature(ctx context.Context, deviceID string, opts ...CallOption) (float64, error) {
+	d, err := c.GetDevice(ctx, deviceID, opts...)
+	if err != nil {
+		return 0, err
+	}
+	if d.Temperature == nil {
+		return 0, fmt.Errorf("%w: temperature of device %s", ErrValueNotAvailable, deviceID)
+	}
+	return *d.Temperature, nil
+}
+
+// normalize converts value to the base unit of units. A missing unit is
+// taken as defaultUnit.
+func normalize(quantity, deviceID string, value *float64, unit *string, defaultUnit string, units map[string]float64) (float64, error) {
+	if value == nil {
+		return 0, fmt.Errorf("%w: %s of device %s", ErrValueNotAvailable, quantity, deviceID)
+	}
+	factor, err := unitFactor(quantity, deviceID, unit, defaultUnit, units)
+	if err != nil {
+		return 0, err
+	}
+	return *value * factor, nil
+}
+
+// unitFactor returns the factor from unit to the base unit of units. A
+// missing unit is taken as defaultUnit.
+func unitFactor(quantity, deviceID string, unit *string, defaultUnit string, units map[string]float64) (float64, error) {
+	u := defaultUnit
+	if unit != nil && strings.TrimSpace(*unit) != "" {
+		u = *unit
+	}
+	factor, ok := lookupUnit(units, strings.TrimSpace(u))
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q for %s of device %s", u, quantity, deviceID)
+	}
+	return factor, nil
+}
+
+// lookupUnit returns the factor of unit. An exact match wins; otherwise the
+// unit is matched ignoring case, but only if that match is unique, so that
+// "kw" is read as kW while "mw" is rejected as either mW or MW.
+func lookupUnit(units map[string]float64, unit string) (float64, bool) {
+	if factor, ok := units[unit]; ok {
+		return factor, true
+	}
+	var (
+		factor float64
+		found  bool
+	)
+	for u, f := range units {
+		if !strings.EqualFold(u, unit) {
+			continue
+		}
+		if found {
+			return 0, false
+		}
+		factor, found = f, true
+	}
+	return factor, found
+}
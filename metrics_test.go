@@ -0,0 +1,97 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_OneShotGetters(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	meter := srv.AddDevice(smartme.Device{
+		ActivePower:        ptr(2.5),
+		ActivePowerUnit:    ptr("kW"),
+		CounterReading:     ptr(1234567.0),
+		CounterReadingUnit: ptr("Wh"),
+	})
+	water := srv.AddDevice(smartme.Device{
+		CounterReading:     ptr(1500.0),
+		CounterReadingUnit: ptr("L"),
+		Temperature:        ptr(12.5),
+	})
+	odd := srv.AddDevice(smartme.Device{ActivePower: ptr(1.0), ActivePowerUnit: ptr("hp")})
+
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		get     func(context.Context, string, ...smartme.CallOption) (float64, error)
+		id      string
+		want    float64
+		wantErr error
+	}{
+		{"power in kW", client.GetActivePower, meter, 2500, nil},
+		{"counter in Wh", client.GetCounterReading, meter, 1234.567, nil},
+		{"counter in L", client.GetCounterReading, water, 1.5, nil},
+		{"temperature", client.GetTemperature, water, 12.5, nil},
+		{"missing power", client.GetActivePower, water, 0, smartme.ErrValueNotAvailable},
+		{"missing temperature", client.GetTemperature, meter, 0, smartme.ErrValueNotAvailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get(ctx, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := client.GetActivePower(ctx, odd); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+
+	for _, tt := range []struct {
+		unit string
+		want float64
+	}{
+		{"mW", 0.5e-3},
+		{"MW", 0.5e6},
+		{"kw", 0.5e3},
+	} {
+		id := srv.AddDevice(smartme.Device{ActivePower: ptr(0.5), ActivePowerUnit: ptr(tt.unit)})
+		if got, err := client.GetActivePower(ctx, id); err != nil || math.Abs(got-tt.want) > 1e-9*tt.want {
+			t.Errorf("power in %s = %v, %v, want %v", tt.unit, got, err, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		unit string
+		want float64
+	}{
+		{"mWh", 0.5e-6},
+		{"MWh", 0.5e3},
+	} {
+		id := srv.AddDevice(smartme.Device{CounterReading: ptr(0.5), CounterReadingUnit: ptr(tt.unit)})
+		if got, err := client.GetCounterReading(ctx, id); err != nil || math.Abs(got-tt.want) > 1e-9*tt.want {
+			t.Errorf("counter in %s = %v, %v, want %v", tt.unit, got, err, tt.want)
+		}
+	}
+	ambiguous := srv.AddDevice(smartme.Device{ActivePower: ptr(1.0), ActivePowerUnit: ptr("mw")})
+	if _, err := client.GetActivePower(ctx, ambiguous); err == nil {
+		t.Error("expected an error for the ambiguous unit mw")
+	}
+
+	var apiErr *smartme.APIError
+	if _, err := client.GetActivePower(ctx, "unknown"); !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("error for unknown device = %v, want 404", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package mscons exports load profiles of meters as EDIFACT MSCONS
+// messages, the format grid operators and billing providers in Germany,
+// Austria and Switzerland request from submetering operators:
+//
+//	profile, err := mscons.ReadLoadProfile(ctx, client, deviceID, period, mscons.DefaultInterval)
+//	ic := &mscons.Interchange{
+//		Sender:    mscons.Party{ID: "9900000000001"},
+//		Receiver:  mscons.Party{ID: "9900000000002"},
+//		Reference: "42",
+//		Profiles:  []mscons.LoadProfile{{MeteringPoint: "CH1012301234500000000000000012345", Period: period, Intervals: profile}},
+//	}
+//	err = ic.Write(w)
+//
+// The messages follow the MSCONS D.04B load profile structure (version
+// 2.4c): one message per metering point with the energy of every interval
+// as true or substitute value. Further segments of the application
+// guides of the receiver, like the check identifier, are supported as far
+// as load profiles need them; validate the output with the receiver when
+// setting up an exchange.
+package mscons
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// ObisLoadProfile is the OBIS code of a metered active energy load profile.
+const ObisLoadProfile = "1-1:1.29.0"
+
+// CodeList is the agency that assigned a party ID.
+type CodeList int
+
+const (
+	// CodeBDEW is for market partner IDs of the BDEW (Germany), the
+	// default.
+	CodeBDEW CodeList = iota
+	// CodeDVGW is for market partner IDs of the DVGW, used for gas.
+	CodeDVGW
+	// CodeGS1 is for GS1 global location numbers, used in Switzerland
+	// and Austria.
+	CodeGS1
+)
+
+// qualifiers returns the code of the agency in UNB and in NAD segments.
+func (c CodeList) qualifiers() (unb, nad string) {
+	switch c {
+	case CodeDVGW:
+		return "502", "332"
+	case CodeGS1:
+		return "14", "9"
+	default:
+		return "500", "293"
+	}
+}
+
+// Party is the sender or receiver of an interchange.
+type Party struct {
+	// ID is the market partner ID, e.g. a 13-digit BDEW code.
+	ID       string
+	CodeList CodeList
+}
+
+// LoadProfile is the load profile of a metering point.
+type LoadProfile struct {
+	// MeteringPoint is the ID of the metering point, e.g. the 33-character
+	// metering point ID or the 11-character market location ID.
+	MeteringPoint string
+	// Obis is the OBIS code of the profile (default ObisLoadProfile).
+	Obis      string
+	Period    smartme.Period
+	Intervals []Interval
+}
+
+// Interchange is an MSCONS interchange with one message per load profile.
+type Interchange struct {
+	Sender, Receiver Party
+	// Reference identifies the interchange and must be unique per
+	// sender; the messages are numbered within it.
+	Reference string
+	// CheckID is the check identifier ("Pruefidentifikator") required by
+	// the application guide of the receiver, written as RFF+Z13 if set.
+	CheckID string
+	// Created is the time of the interchange (default now).
+	Created  time.Time
+	Profiles []LoadProfile
+}
+
+// Write writes the interchange, with one segment per line.
+func (ic *Interchange) Write(w io.Writer) error {
+	if ic.Sender.ID == "" || ic.Receiver.ID == "" {
+		return errors.New("sender and receiver are required")
+	}
+	if ic.Reference == "" {
+		return errors.New("reference is required")
+	}
+	if len(ic.Profiles) == 0 {
+		return errors.New("no load profiles")
+	}
+	created := ic.Created
+	if created.IsZero() {
+		created = smartme.SystemClock{}.Now()
+	}
+	created = created.UTC()
+
+	bw := bufio.NewWriter(w)
+	seg := func(s string) { bw.WriteString(s + "'\n") }
+	senderUNB, senderNAD := ic.Sender.CodeList.qualifiers()
+	receiverUNB, receiverNAD := ic.Receiver.CodeList.qualifiers()
+
+	seg("UNA:+.? ")
+	seg(fmt.Sprintf("UNB+UNOC:3+%s:%s+%s:%s+%s:%s+%s",
+		escape(ic.Sender.ID), senderUNB, escape(ic.Receiver.ID), receiverUNB,
+		created.Format("060102"), created.Format("1504"), escape(ic.Reference)))
+	for i, p := range ic.Profiles {
+		if p.MeteringPoint == "" {
+			return fmt.Errorf("profile %d: metering point is required", i+1)
+		}
+		obis := p.Obis
+		if obis == "" {
+			obis = ObisLoadProfile
+		}
+		ref := fmt.Sprintf("%s%d", ic.Reference, i+1)
+		var segs []string
+		add := func(format string, args ...interface{}) { segs = append(segs, fmt.Sprintf(format, args...)) }
+
+		add("UNH+%s+MSCONS:D:04B:UN:2.4c", escape(ref))
+		add("BGM+7+%s+9", escape(ref))
+		add("DTM+137:%s:303", formatDate(created))
+		if ic.CheckID != "" {
+			add("RFF+Z13:%s", escape(ic.CheckID))
+		}
+		add("NAD+MS+%s::%s", escape(ic.Sender.ID), senderNAD)
+		add("NAD+MR+%s::%s", escape(ic.Receiver.ID), receiverNAD)
+		add("UNS+D")
+		add("NAD+DP")
+		add("LOC+172+%s", escape(p.MeteringPoint))
+		add("DTM+163:%s:303", formatDate(p.Period.Start))
+		add("DTM+164:%s:303", formatDate(p.Period.End))
+		add("LIN+1")
+		add("PIA+5+%s:SRW", escape(obis))
+		for _, iv := range p.Intervals {
+			qualifier := "220"
+			if iv.Substituted {
+				qualifier = "67"
+			}
+			add("QTY+%s:%s:KWH", qualifier, strconv.FormatFloat(smartme.Round(iv.Energy, 3, smartme.RoundHalfAwayFromZero), 'f', 3, 64))
+			add("DTM+163:%s:303", formatDate(iv.Start))
+			add("DTM+164:%s:303", formatDate(iv.End))
+		}
+		add("UNT+%d+%s", len(segs)+1, escape(ref))
+		for _, s := range segs {
+			seg(s)
+		}
+	}
+	seg(fmt.Sprintf("UNZ+%d+%s", len(ic.Profiles), escape(ic.Reference)))
+	return bw.Flush()
+}
+
+// formatDate formats t in UTC with format 303 (CCYYMMDDHHMMZZZ).
+func formatDate(t time.Time) string {
+	return t.UTC().Format("200601021504") + "?+00"
+}
+
+var escaper = strings.NewReplacer("?", "??", "+", "?+", ":", "?:", "'", "?'")
+
+// escape escapes the EDIFACT service characters with the release
+// character.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
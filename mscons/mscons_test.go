@@ -0,0 +1,142 @@
+package mscons_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/mscons"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+)
+
+var start = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// newMock returns a mock whose history of dev-1 has the given readings,
+// one every 15 minutes from start; NaN skips a reading.
+func newMock(readings ...float64) *smartmemock.API {
+	var values []smartme.Value
+	for i, r := range readings {
+		if math.IsNaN(r) {
+			continue
+		}
+		values = append(values, smartme.Value{Date: start.Add(time.Duration(i) * 15 * time.Minute), Value: r})
+	}
+	return &smartmemock.API{
+		GetValuesInPastMultipleFunc: func(ctx context.Context, id string, from, to time.Time) ([]smartme.Value, error) {
+			return values, nil
+		},
+	}
+}
+
+func TestReadLoadProfile(t *testing.T) {
+	api := newMock(10, 11.5, math.NaN(), 14.5, 15)
+	period := smartme.Period{Start: start, End: start.Add(time.Hour)}
+
+	got, err := mscons.ReadLoadProfile(context.Background(), api, "dev-1", period, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mscons.Interval{
+		{Start: start, End: start.Add(15 * time.Minute), Energy: 1.5},
+		{Start: start.Add(15 * time.Minute), End: start.Add(30 * time.Minute), Energy: 1.5, Substituted: true},
+		{Start: start.Add(30 * time.Minute), End: start.Add(45 * time.Minute), Energy: 1.5, Substituted: true},
+		{Start: start.Add(45 * time.Minute), End: start.Add(time.Hour), Energy: 0.5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d intervals, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("interval %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReadLoadProfile_Errors(t *testing.T) {
+	period := smartme.Period{Start: start, End: start.Add(time.Hour)}
+	ctx := context.Background()
+
+	if _, err := mscons.ReadLoadProfile(ctx, newMock(10, 11, 12), "dev-1", period, 0); !errors.Is(err, mscons.ErrIncomplete) {
+		t.Errorf("short history: err = %v, want ErrIncomplete", err)
+	}
+	if _, err := mscons.ReadLoadProfile(ctx, newMock(10, 11, 9, 12, 13), "dev-1", period, 0); err == nil || !strings.Contains(err.Error(), "decreases") {
+		t.Errorf("decreasing counter: err = %v", err)
+	}
+	if _, err := mscons.ReadLoadProfile(ctx, newMock(10, 11, 12, 13, 14), "dev-1", period, 25*time.Minute); err == nil {
+		t.Error("interval not dividing the period: want error")
+	}
+}
+
+func TestInterchange_Write(t *testing.T) {
+	period := smartme.Period{Start: start, End: start.Add(30 * time.Minute)}
+	ic := &mscons.Interchange{
+		Sender:    mscons.Party{ID: "9900000000001"},
+		Receiver:  mscons.Party{ID: "7610000000002", CodeList: mscons.CodeGS1},
+		Reference: "R1",
+		CheckID:   "13025",
+		Created:   time.Date(2024, 3, 2, 6, 30, 0, 0, time.UTC),
+		Profiles: []mscons.LoadProfile{{
+			MeteringPoint: "CH10123:01'",
+			Period:        period,
+			Intervals: []mscons.Interval{
+				{Start: start, End: start.Add(15 * time.Minute), Energy: 1.23456},
+				{Start: start.Add(15 * time.Minute), End: start.Add(30 * time.Minute), Energy: 2, Substituted: true},
+			},
+		}},
+	}
+
+	var b strings.Builder
+	if err := ic.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `UNA:+.? '
+UNB+UNOC:3+9900000000001:500+7610000000002:14+240302:0630+R1'
+UNH+R11+MSCONS:D:04B:UN:2.4c'
+BGM+7+R11+9'
+DTM+137:202403020630?+00:303'
+RFF+Z13:13025'
+NAD+MS+9900000000001::293'
+NAD+MR+7610000000002::9'
+UNS+D'
+NAD+DP'
+LOC+172+CH10123?:01?''
+DTM+163:202403010000?+00:303'
+DTM+164:202403010030?+00:303'
+LIN+1'
+PIA+5+1-1?:1.29.0:SRW'
+QTY+220:1.235:KWH'
+DTM+163:202403010000?+00:303'
+DTM+164:202403010015?+00:303'
+QTY+67:2.000:KWH'
+DTM+163:202403010015?+00:303'
+DTM+164:202403010030?+00:303'
+UNT+20+R11'
+UNZ+1+R1'
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInterchange_WriteInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		ic   mscons.Interchange
+	}{
+		{"no sender", mscons.Interchange{Receiver: mscons.Party{ID: "2"}, Reference: "R", Profiles: []mscons.LoadProfile{{MeteringPoint: "M"}}}},
+		{"no reference", mscons.Interchange{Sender: mscons.Party{ID: "1"}, Receiver: mscons.Party{ID: "2"}, Profiles: []mscons.LoadProfile{{MeteringPoint: "M"}}}},
+		{"no profiles", mscons.Interchange{Sender: mscons.Party{ID: "1"}, Receiver: mscons.Party{ID: "2"}, Reference: "R"}},
+		{"no metering point", mscons.Interchange{Sender: mscons.Party{ID: "1"}, Receiver: mscons.Party{ID: "2"}, Reference: "R", Profiles: []mscons.LoadProfile{{}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ic.Write(&strings.Builder{}); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
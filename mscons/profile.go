@@ -0,0 +1,97 @@
+package mscons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// DefaultInterval is the interval of load profiles, 15 minutes as
+// required for metered load profiles.
+const DefaultInterval = 15 * time.Minute
+
+// ErrIncomplete is returned by ReadLoadProfile if the history of a device
+// does not cover the whole period.
+var ErrIncomplete = errors.New("history does not cover the period")
+
+// historyMargin is how far the history is read beyond the period, to find
+// the readings around its boundaries.
+const historyMargin = 24 * time.Hour
+
+// Interval is the energy of one interval of a load profile.
+type Interval struct {
+	Start, End time.Time
+	// Energy is the consumption in the interval in kWh.
+	Energy float64
+	// Substituted is set if a boundary reading was interpolated because
+	// the meter reported no reading at that time. Such values are sent as
+	// substitute values.
+	Substituted bool
+}
+
+// ReadLoadProfile returns the load profile of a device in the period, with
+// intervals of the given length (DefaultInterval if 0) aligned to the
+// period start. The energy of an interval is the difference of the counter
+// readings at its boundaries; boundary readings that are missing are
+// interpolated linearly between the neighboring readings.
+func ReadLoadProfile(ctx context.Context, api smartme.API, deviceID string, period smartme.Period, interval time.Duration) ([]Interval, error) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if !period.End.After(period.Start) {
+		return nil, errors.New("period must end after it starts")
+	}
+	if period.End.Sub(period.Start)%interval != 0 {
+		return nil, fmt.Errorf("period is not a multiple of %v", interval)
+	}
+
+	values, err := api.GetValuesInPastMultiple(ctx, deviceID, period.Start.Add(-historyMargin), period.End.Add(historyMargin))
+	if err != nil {
+		return nil, fmt.Errorf("device %s: %w", deviceID, err)
+	}
+	sort.SliceStable(values, func(i, j int) bool { return values[i].Date.Before(values[j].Date) })
+
+	var (
+		intervals []Interval
+		prev      float64
+		prevSub   bool
+	)
+	for t, i := period.Start, 0; !t.After(period.End); t, i = t.Add(interval), i+1 {
+		counter, exact, ok := readingAt(values, t)
+		if !ok {
+			return nil, fmt.Errorf("device %s: %w: no readings around %s", deviceID, ErrIncomplete, t.Format(time.RFC3339))
+		}
+		if i > 0 {
+			if counter < prev {
+				return nil, fmt.Errorf("device %s: counter decreases from %g to %g at %s", deviceID, prev, counter, t.Format(time.RFC3339))
+			}
+			intervals = append(intervals, Interval{
+				Start:       t.Add(-interval),
+				End:         t,
+				Energy:      counter - prev,
+				Substituted: prevSub || !exact,
+			})
+		}
+		prev, prevSub = counter, !exact
+	}
+	return intervals, nil
+}
+
+// readingAt returns the counter at t from readings sorted by date: the
+// reading at t, or the interpolation of the readings before and after t.
+func readingAt(values []smartme.Value, t time.Time) (counter float64, exact, ok bool) {
+	i := sort.Search(len(values), func(i int) bool { return !values[i].Date.Before(t) })
+	if i < len(values) && values[i].Date.Equal(t) {
+		return values[i].Value, true, true
+	}
+	if i == 0 || i == len(values) {
+		return 0, false, false
+	}
+	before, after := values[i-1], values[i]
+	f := float64(t.Sub(before.Date)) / float64(after.Date.Sub(before.Date))
+	return before.Value + f*(after.Value-before.Value), false, true
+}
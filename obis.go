@@ -0,0 +1,134 @@
+package smartme
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Commonly used OBIS codes as reported by the /api/Values endpoint.
+const (
+	ObisActiveEnergyImport   = "1-0:1.8.0*255"
+	ObisActiveEnergyImportT1 = "1-0:1.8.1*255"
+	ObisActiveEnergyImportT2 = "1-0:1.8.2*255"
+	ObisActiveEnergyExport   = "1-0:2.8.0*255"
+	ObisActivePower          = "1-0:1.7.0*255"
+	ObisVoltageL1            = "1-0:32.7.0*255"
+	ObisCurrentL1            = "1-0:31.7.0*255"
+	ObisActiveTariff         = "0-0:96.14.0*255"
+	ObisSwitchState          = "0-0:96.3.10*255"
+)
+
+// ObisInfo describes the meaning of an OBIS code.
+type ObisInfo struct {
+	Code        string
+	Description string
+	Unit        string
+	// EnergyType is the kind of meter that reports the code.
+	// MeterTypeAllMeters is used for codes that are not specific to a medium.
+	EnergyType MeterEnergyType
+}
+
+// obisCatalog contains the OBIS codes (IEC 62056-61) known to the client.
+var obisCatalog = []ObisInfo{
+	{ObisActiveEnergyImport, "Active energy import, total", "kWh", MeterTypeElectricity},
+	{ObisActiveEnergyImportT1, "Active energy import, tariff 1", "kWh", MeterTypeElectricity},
+	{ObisActiveEnergyImportT2, "Active energy import, tariff 2", "kWh", MeterTypeElectricity},
+	{"1-0:1.8.3*255", "Active energy import, tariff 3", "kWh", MeterTypeElectricity},
+	{"1-0:1.8.4*255", "Active energy import, tariff 4", "kWh", MeterTypeElectricity},
+	{ObisActiveEnergyExport, "Active energy export, total", "kWh", MeterTypeElectricity},
+	{"1-0:2.8.1*255", "Active energy export, tariff 1", "kWh", MeterTypeElectricity},
+	{"1-0:2.8.2*255", "Active energy export, tariff 2", "kWh", MeterTypeElectricity},
+	{"1-0:3.8.0*255", "Reactive energy import, total", "kvarh", MeterTypeElectricity},
+	{"1-0:4.8.0*255", "Reactive energy export, total", "kvarh", MeterTypeElectricity},
+	{ObisActivePower, "Active power, total", "W", MeterTypeElectricity},
+	{"1-0:2.7.0*255", "Active power export, total", "W", MeterTypeElectricity},
+	{"1-0:21.7.0*255", "Active power L1", "W", MeterTypeElectricity},
+	{"1-0:41.7.0*255", "Active power L2", "W", MeterTypeElectricity},
+	{"1-0:61.7.0*255", "Active power L3", "W", MeterTypeElectricity},
+	{ObisVoltageL1, "Voltage L1", "V", MeterTypeElectricity},
+	{"1-0:52.7.0*255", "Voltage L2", "V", MeterTypeElectricity},
+	{"1-0:72.7.0*255", "Voltage L3", "V", MeterTypeElectricity},
+	{ObisCurrentL1, "Current L1", "A", MeterTypeElectricity},
+	{"1-0:51.7.0*255", "Current L2", "A", MeterTypeElectricity},
+	{"1-0:71.7.0*255", "Current L3", "A", MeterTypeElectricity},
+	{"1-0:13.7.0*255", "Power factor, total", "", MeterTypeElectricity},
+	{"1-0:33.7.0*255", "Power factor L1", "", MeterTypeElectricity},
+	{"1-0:53.7.0*255", "Power factor L2", "", MeterTypeElectricity},
+	{"1-0:73.7.0*255", "Power factor L3", "", MeterTypeElectricity},
+	{"1-0:14.7.0*255", "Grid frequency", "Hz", MeterTypeElectricity},
+	{"6-0:1.0.0*255", "Heat energy, total", "kWh", MeterTypeHeat},
+	{"7-0:3.0.0*255", "Gas volume, total", "m³", MeterTypeGas},
+	{"8-0:1.0.0*255", "Water volume, total", "m³", MeterTypeWater},
+	{ObisActiveTariff, "Active tariff", "", MeterTypeAllMeters},
+	{ObisSwitchState, "Switch (disconnect control) state", "", MeterTypeAllMeters},
+}
+
+// NormalizeObis brings an OBIS code into the full form used by the API.
+// A missing billing period suffix ("*255") is added.
+func NormalizeObis(code string) string {
+	code = strings.TrimSpace(code)
+	if code != "" && !strings.Contains(code, "*") {
+		code += "*255"
+	}
+	return code
+}
+
+// LookupObis returns the catalog entry for the given OBIS code.
+// The code may be given with or without the "*255" suffix.
+func LookupObis(code string) (ObisInfo, bool) {
+	code = NormalizeObis(code)
+	for _, info := range obisCatalog {
+		if info.Code == code {
+			return info, true
+		}
+	}
+	return ObisInfo{}, false
+}
+
+// Families returns the smart-me meter families that report the code
+// themselves, according to their metadata: electricity codes are reported
+// by the families that measure the phase of the code, the switch state by
+// the families with a relay. Modules, gateways and cloud meters are not
+// listed since their codes depend on the meter they read, and neither are
+// families for codes the metadata says nothing about, like the tariff.
+func (info ObisInfo) Families() []MeterFamilyType {
+	var families []MeterFamilyType
+	for f, fi := range familyCatalog {
+		var ok bool
+		switch {
+		case info.Code == ObisSwitchState:
+			ok = fi.hasSwitch
+		case info.EnergyType == MeterTypeElectricity:
+			ok = fi.phases > 0 && fi.phases >= obisPhase(info.Code)
+		}
+		if ok {
+			families = append(families, f)
+		}
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i] < families[j] })
+	return families
+}
+
+// obisPhase returns the phase of an electricity code, or 0 if it is not
+// specific to a phase. Value group C is 21-40 for L1, 41-60 for L2 and
+// 61-80 for L3.
+func obisPhase(code string) int {
+	_, rest, _ := strings.Cut(code, ":")
+	c, _, _ := strings.Cut(rest, ".")
+	n, err := strconv.Atoi(c)
+	if err != nil || n < 21 || n > 80 {
+		return 0
+	}
+	return (n - 1) / 20
+}
+
+// ObisCatalog returns all known OBIS codes sorted by code.
+func ObisCatalog() []ObisInfo {
+	catalog := make([]ObisInfo, len(obisCatalog))
+	copy(catalog, obisCatalog)
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Code < catalog[j].Code
+	})
+	return catalog
+}
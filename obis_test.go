@@ -0,0 +1,74 @@
+package smartme_test
+
+import (
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestLookupObis(t *testing.T) {
+	tests := []struct {
+		code     string
+		wantOK   bool
+		wantUnit string
+	}{
+		{"1-0:1.8.0*255", true, "kWh"},
+		{"1-0:1.7.0", true, "W"},
+		{" 1-0:32.7.0 ", true, "V"},
+		{"9-9:9.9.9*255", false, ""},
+		{"", false, ""},
+	}
+
+	for _, tt := range tests {
+		info, ok := smartme.LookupObis(tt.code)
+		if ok != tt.wantOK {
+			t.Errorf("LookupObis(%q) ok = %v, want %v", tt.code, ok, tt.wantOK)
+			continue
+		}
+		if info.Unit != tt.wantUnit {
+			t.Errorf("LookupObis(%q) unit = %q, want %q", tt.code, info.Unit, tt.wantUnit)
+		}
+	}
+}
+
+func TestObisCatalog_Sorted(t *testing.T) {
+	catalog := smartme.ObisCatalog()
+	for i := 1; i < len(catalog); i++ {
+		if catalog[i-1].Code >= catalog[i].Code {
+			t.Fatalf("catalog not sorted or has duplicates at %q, %q", catalog[i-1].Code, catalog[i].Code)
+		}
+	}
+}
+
+func TestObisInfo_Families(t *testing.T) {
+	has := func(code string, family smartme.MeterFamilyType) bool {
+		info, _ := smartme.LookupObis(code)
+		for _, f := range info.Families() {
+			if f == family {
+				return true
+			}
+		}
+		return false
+	}
+	onePhase := smartme.MeterFamilyType(2) // 1-phase DIN rail meter
+	threePhase := smartme.MeterFamilyTypeSmartMe3PhaseMeter80A
+
+	tests := []struct {
+		code   string
+		family smartme.MeterFamilyType
+		want   bool
+	}{
+		{smartme.ObisActiveEnergyImport, onePhase, true},
+		{smartme.ObisVoltageL1, onePhase, true},
+		{"1-0:52.7.0*255", onePhase, false}, // voltage L2
+		{"1-0:52.7.0*255", threePhase, true},
+		{smartme.ObisSwitchState, threePhase, false},
+		{smartme.ObisSwitchState, smartme.MeterFamilyType(1), true}, // plug-in meter
+		{"7-0:3.0.0*255", threePhase, false},
+	}
+	for _, tt := range tests {
+		if got := has(tt.code, tt.family); got != tt.want {
+			t.Errorf("%s reported by %s = %v, want %v", tt.code, tt.family.Description(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package ocpp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rolacher/go-smartme-client/internal/websocket"
+)
+
+// conn is a WebSocket connection to the central system.
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	// done is closed when the connection failed.
+	done chan struct{}
+
+	writeMu sync.Mutex
+}
+
+// dial opens a WebSocket connection with the OCPP 1.6 subprotocol.
+func dial(ctx context.Context, rawURL string, header http.Header) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host += ":80"
+		}
+	case "wss":
+		if u.Port() == "" {
+			host += ":443"
+		}
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+
+	var nc net.Conn
+	if u.Scheme == "wss" {
+		d := &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		nc, err = d.DialContext(ctx, "tcp", host)
+	} else {
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Host:       u.Host,
+		Header:     header.Clone(),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), pass)
+		u.User = nil
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", subprotocol)
+	if err := req.Write(nc); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if err := checkAccept(resp, key); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+	return &conn{nc: nc, r: r, done: make(chan struct{})}, nil
+}
+
+// checkAccept validates the response of the central system to the
+// opening handshake.
+func checkAccept(resp *http.Response, key string) error {
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("websocket: handshake failed with status %s", resp.Status)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		return errors.New("websocket: server did not upgrade the connection")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocket.AcceptKey(key) {
+		return errors.New("websocket: invalid accept key")
+	}
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != subprotocol {
+		return fmt.Errorf("websocket: server selected subprotocol %q, want %q", p, subprotocol)
+	}
+	return nil
+}
+
+// write sends a frame, masked as clients send them. Frames of different
+// goroutines do not interleave.
+func (c *conn) write(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return websocket.WriteFrame(c.nc, opcode, payload, true)
+}
+
+// readMessage reads the next message, answering pings on the way.
+func (c *conn) readMessage() ([]byte, error) {
+	return websocket.ReadMessage(c.r, false, c.write)
+}
+
+// close sends a close frame and closes the connection.
+func (c *conn) close() error {
+	c.write(websocket.OpClose, []byte{0x03, 0xe8})
+	return c.nc.Close()
+}
@@ -0,0 +1,202 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// subprotocol is the WebSocket subprotocol of OCPP 1.6J.
+const subprotocol = "ocpp1.6"
+
+// Message types of OCPP-J.
+const (
+	typeCall       = 2
+	typeCallResult = 3
+	typeCallError  = 4
+)
+
+// Error codes of OCPP-J call errors.
+const (
+	errNotImplemented     = "NotImplemented"
+	errFormationViolation = "FormationViolation"
+	errInternalError      = "InternalError"
+)
+
+// CallError is returned for a request that the central system answered
+// with an error.
+type CallError struct {
+	Action      string
+	Code        string
+	Description string
+}
+
+func (e *CallError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("ocpp: %s: %s", e.Action, e.Code)
+	}
+	return fmt.Sprintf("ocpp: %s: %s: %s", e.Action, e.Code, e.Description)
+}
+
+// frame is a decoded OCPP-J message.
+type frame struct {
+	typ     int
+	id      string
+	action  string // of calls
+	payload json.RawMessage
+	// code and description of call errors
+	code, description string
+}
+
+// decodeFrame decodes an OCPP-J message array.
+func decodeFrame(data []byte) (frame, error) {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return frame{}, err
+	}
+	if len(parts) < 3 {
+		return frame{}, errors.New("message has too few elements")
+	}
+	var f frame
+	if err := json.Unmarshal(parts[0], &f.typ); err != nil {
+		return frame{}, fmt.Errorf("message type: %w", err)
+	}
+	if err := json.Unmarshal(parts[1], &f.id); err != nil {
+		return frame{}, fmt.Errorf("message id: %w", err)
+	}
+	switch f.typ {
+	case typeCall:
+		if len(parts) != 4 {
+			return frame{}, errors.New("call must have 4 elements")
+		}
+		if err := json.Unmarshal(parts[2], &f.action); err != nil {
+			return frame{}, fmt.Errorf("action: %w", err)
+		}
+		f.payload = parts[3]
+	case typeCallResult:
+		f.payload = parts[2]
+	case typeCallError:
+		if len(parts) < 4 {
+			return frame{}, errors.New("call error must have 5 elements")
+		}
+		json.Unmarshal(parts[2], &f.code)
+		json.Unmarshal(parts[3], &f.description)
+	default:
+		return frame{}, fmt.Errorf("unknown message type %d", f.typ)
+	}
+	return f, nil
+}
+
+// Requests sent by the charge point and their confirmations.
+
+type bootNotificationRequest struct {
+	ChargePointVendor string `json:"chargePointVendor"`
+	ChargePointModel  string `json:"chargePointModel"`
+	ChargePointSerial string `json:"chargePointSerialNumber,omitempty"`
+}
+
+type bootNotificationConf struct {
+	Status      string    `json:"status"`
+	CurrentTime time.Time `json:"currentTime"`
+	Interval    int       `json:"interval"`
+}
+
+type heartbeatRequest struct{}
+
+type statusNotificationRequest struct {
+	ConnectorID int       `json:"connectorId"`
+	ErrorCode   string    `json:"errorCode"`
+	Status      Status    `json:"status"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type idTagInfo struct {
+	Status string `json:"status"`
+}
+
+type startTransactionRequest struct {
+	ConnectorID int       `json:"connectorId"`
+	IDTag       string    `json:"idTag"`
+	MeterStart  int64     `json:"meterStart"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type startTransactionConf struct {
+	IDTagInfo     idTagInfo `json:"idTagInfo"`
+	TransactionID int       `json:"transactionId"`
+}
+
+type stopTransactionRequest struct {
+	TransactionID int       `json:"transactionId"`
+	IDTag         string    `json:"idTag,omitempty"`
+	MeterStop     int64     `json:"meterStop"`
+	Timestamp     time.Time `json:"timestamp"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+type meterValuesRequest struct {
+	ConnectorID   int          `json:"connectorId"`
+	TransactionID *int         `json:"transactionId,omitempty"`
+	MeterValue    []meterValue `json:"meterValue"`
+}
+
+type meterValue struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	SampledValue []sampledValue `json:"sampledValue"`
+}
+
+type sampledValue struct {
+	Value     string `json:"value"`
+	Context   string `json:"context,omitempty"`
+	Measurand string `json:"measurand,omitempty"`
+	Unit      string `json:"unit,omitempty"`
+}
+
+// Requests sent by the central system and their confirmations.
+
+type remoteStartTransactionRequest struct {
+	ConnectorID *int   `json:"connectorId"`
+	IDTag       string `json:"idTag"`
+}
+
+type remoteStopTransactionRequest struct {
+	TransactionID int `json:"transactionId"`
+}
+
+type setChargingProfileRequest struct {
+	ConnectorID        int             `json:"connectorId"`
+	CsChargingProfiles chargingProfile `json:"csChargingProfiles"`
+}
+
+type chargingProfile struct {
+	ChargingProfileID      int              `json:"chargingProfileId"`
+	TransactionID          *int             `json:"transactionId,omitempty"`
+	StackLevel             int              `json:"stackLevel"`
+	ChargingProfilePurpose string           `json:"chargingProfilePurpose"`
+	ValidFrom              *time.Time       `json:"validFrom,omitempty"`
+	ValidTo                *time.Time       `json:"validTo,omitempty"`
+	ChargingSchedule       chargingSchedule `json:"chargingSchedule"`
+}
+
+type chargingSchedule struct {
+	Duration               *int                     `json:"duration,omitempty"`
+	ChargingRateUnit       string                   `json:"chargingRateUnit"`
+	ChargingSchedulePeriod []chargingSchedulePeriod `json:"chargingSchedulePeriod"`
+}
+
+type chargingSchedulePeriod struct {
+	StartPeriod int     `json:"startPeriod"`
+	Limit       float64 `json:"limit"`
+}
+
+type clearChargingProfileRequest struct {
+	ID                     *int   `json:"id,omitempty"`
+	ConnectorID            *int   `json:"connectorId,omitempty"`
+	ChargingProfilePurpose string `json:"chargingProfilePurpose,omitempty"`
+	StackLevel             *int   `json:"stackLevel,omitempty"`
+}
+
+type statusConf struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,671 @@
+// Package ocpp exposes a smart-me charging station as an OCPP 1.6J charge
+// point, so it can be managed by standard charge point operator backends
+// (central systems):
+//
+//	cp := ocpp.NewChargePoint(client, deviceID, "wss://csms.example.com/ocpp")
+//	cp.Header = http.Header{"Authorization": {"Basic ..."}}
+//	err := cp.Run(ctx)
+//
+// The charge point connects to the URL with its ID appended, sends a
+// BootNotification and then the status of its single connector, heartbeats
+// and, during transactions, meter values read from the station. The central
+// system can start and stop transactions remotely, which start and stop
+// charging on the station, and limit the charging current with charging
+// profiles. Since the station has a single current limit, only default
+// and maximum profiles (TxDefaultProfile and ChargePointMaxProfile) with a
+// single period that does not end are supported; clearing the last one
+// lifts the limit to the maximum of the station. A limit below 6 A stops
+// charging until a higher limit is in effect. Sessions started locally
+// on the station, e.g. with an RFID card, are reported as status only,
+// since the API does not tell the card that started them. Messages are not
+// queued while the connection is down.
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/internal/websocket"
+)
+
+// Defaults of a ChargePoint.
+const (
+	DefaultMeterValueInterval = time.Minute
+	DefaultReconnectDelay     = 30 * time.Second
+)
+
+// defaultHeartbeatInterval is used if the central system does not set one.
+const defaultHeartbeatInterval = 5 * time.Minute
+
+// callTimeout limits the wait for the confirmation of a request.
+const callTimeout = 30 * time.Second
+
+// connectorID is the ID of the single connector of a station.
+const connectorID = 1
+
+// maxPendingCalls is the number of requests of the central system that
+// are buffered while the charge point is busy.
+const maxPendingCalls = 16
+
+// Purposes of the supported charging profiles.
+const (
+	purposeChargePointMax = "ChargePointMaxProfile"
+	purposeTxDefault      = "TxDefaultProfile"
+)
+
+// Status is the status of a connector as reported to the central system.
+type Status string
+
+const (
+	StatusAvailable     Status = "Available"
+	StatusPreparing     Status = "Preparing"
+	StatusCharging      Status = "Charging"
+	StatusSuspendedEV   Status = "SuspendedEV"
+	StatusSuspendedEVSE Status = "SuspendedEVSE"
+	StatusUnavailable   Status = "Unavailable"
+)
+
+// statusOf maps the state of a station to the status of its connector.
+func statusOf(state smartme.ChargeStationState, inTransaction bool) Status {
+	switch state {
+	case smartme.ReadyNoCarConnected:
+		return StatusAvailable
+	case smartme.Charging:
+		return StatusCharging
+	case smartme.StartedWaitForCar:
+		if inTransaction {
+			return StatusSuspendedEV
+		}
+		return StatusPreparing
+	case smartme.ReadyCarConnected, smartme.Authorize:
+		if inTransaction {
+			return StatusSuspendedEVSE
+		}
+		return StatusPreparing
+	}
+	return StatusUnavailable
+}
+
+// ChargePoint is an OCPP 1.6J charge point backed by a smart-me charging
+// station. Set the fields before calling Run.
+type ChargePoint struct {
+	// URL is the WebSocket endpoint of the central system; the charge
+	// point ID is appended as last path segment. User information in the
+	// URL is sent as basic authentication.
+	URL string
+	// ID is the charge point identity (default the device ID).
+	ID string
+	// Vendor and Model are sent in the BootNotification (default
+	// "smart-me" and "Pico").
+	Vendor, Model string
+	// Header is sent with the WebSocket handshake, e.g. for
+	// authentication.
+	Header http.Header
+	// MeterValueInterval is how often the station is polled for its
+	// status and meter values (default DefaultMeterValueInterval).
+	MeterValueInterval time.Duration
+	// ReconnectDelay is the wait before reconnecting after the
+	// connection was lost (default DefaultReconnectDelay).
+	ReconnectDelay time.Duration
+	// Clock is used for the intervals and timestamps. Defaults to the
+	// system clock.
+	Clock smartme.Clock
+	// OnError is called for errors that do not stop the charge point,
+	// such as lost connections and failed API calls.
+	OnError func(error)
+
+	api      smartme.API
+	deviceID string
+
+	// The state below is only used by the goroutine of Run and survives
+	// reconnects, except for status.
+	conn          *conn
+	nextID        int
+	status        Status
+	inTransaction bool
+	transactionID int
+	idTag         string
+	meterWh       int64
+	// profiles are the installed charging profiles and baseCurrent the
+	// highest charging current the station accepts. paused is set while
+	// the profiles limit the current below what the station can charge
+	// with, so charging is stopped.
+	profiles    []profile
+	baseCurrent float64
+	paused      bool
+
+	mu        sync.Mutex
+	pendingID string
+	pending   chan frame
+}
+
+// NewChargePoint returns a charge point for the charging station deviceID
+// that connects to the central system at url.
+func NewChargePoint(api smartme.API, deviceID, url string) *ChargePoint {
+	return &ChargePoint{URL: url, api: api, deviceID: deviceID}
+}
+
+// Run connects to the central system and serves it until ctx is done,
+// reconnecting after ReconnectDelay when the connection is lost.
+func (cp *ChargePoint) Run(ctx context.Context) error {
+	clock := cp.Clock
+	if clock == nil {
+		clock = smartme.SystemClock{}
+	}
+	delay := cp.ReconnectDelay
+	if delay <= 0 {
+		delay = DefaultReconnectDelay
+	}
+	for {
+		err := cp.session(ctx, clock)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		cp.onError(fmt.Errorf("ocpp: connection lost: %w", err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(delay):
+		}
+	}
+}
+
+// session serves one connection until it fails.
+func (cp *ChargePoint) session(ctx context.Context, clock smartme.Clock) error {
+	id := cp.ID
+	if id == "" {
+		id = cp.deviceID
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, callTimeout)
+	c, err := dial(dialCtx, strings.TrimSuffix(cp.URL, "/")+"/"+url.PathEscape(id), cp.Header)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	cp.conn = c
+	cp.status = ""
+
+	calls := make(chan frame, maxPendingCalls)
+	readErr := make(chan error, 1)
+	go cp.read(c, calls, readErr)
+
+	vendor, model := cp.Vendor, cp.Model
+	if vendor == "" {
+		vendor = "smart-me"
+	}
+	if model == "" {
+		model = "Pico"
+	}
+	var boot bootNotificationConf
+	if err := cp.call(ctx, "BootNotification", bootNotificationRequest{ChargePointVendor: vendor, ChargePointModel: model}, &boot); err != nil {
+		return err
+	}
+	if boot.Status != "Accepted" {
+		return fmt.Errorf("boot notification %s", strings.ToLower(boot.Status))
+	}
+	heartbeat := time.Duration(boot.Interval) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeatInterval
+	}
+	interval := cp.MeterValueInterval
+	if interval <= 0 {
+		interval = DefaultMeterValueInterval
+	}
+
+	now := clock.Now()
+	nextPoll, nextHeartbeat := now, now.Add(heartbeat)
+	for {
+		now = clock.Now()
+		if !now.Before(nextPoll) {
+			if err := cp.poll(ctx, clock); err != nil {
+				return err
+			}
+			nextPoll = now.Add(interval)
+		}
+		if !now.Before(nextHeartbeat) {
+			if err := cp.call(ctx, "Heartbeat", heartbeatRequest{}, nil); err != nil {
+				return err
+			}
+			nextHeartbeat = now.Add(heartbeat)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case f := <-calls:
+			if err := cp.handle(ctx, clock, f); err != nil {
+				return err
+			}
+		case <-clock.After(min(nextPoll.Sub(now), nextHeartbeat.Sub(now))):
+		}
+	}
+}
+
+// read receives the messages of the central system until the connection
+// fails: confirmations go to the pending call, requests to calls.
+func (cp *ChargePoint) read(c *conn, calls chan<- frame, readErr chan<- error) {
+	for {
+		data, err := c.readMessage()
+		if err != nil {
+			readErr <- err
+			close(c.done)
+			return
+		}
+		f, err := decodeFrame(data)
+		if err != nil {
+			// Without a message ID there is nothing to answer.
+			continue
+		}
+		if f.typ == typeCall {
+			select {
+			case calls <- f:
+			default:
+				cp.replyError(c, f.id, errInternalError, "charge point is busy")
+			}
+			continue
+		}
+		cp.mu.Lock()
+		if f.id == cp.pendingID && cp.pending != nil {
+			cp.pending <- f
+			cp.pending = nil
+		}
+		cp.mu.Unlock()
+	}
+}
+
+// call sends a request and decodes its confirmation into conf, if not
+// nil. Only one request is outstanding at a time, as OCPP-J requires.
+func (cp *ChargePoint) call(ctx context.Context, action string, req, conf interface{}) error {
+	cp.nextID++
+	id := strconv.Itoa(cp.nextID)
+	data, err := json.Marshal([]interface{}{typeCall, id, action, req})
+	if err != nil {
+		return err
+	}
+	result := make(chan frame, 1)
+	cp.mu.Lock()
+	cp.pendingID, cp.pending = id, result
+	cp.mu.Unlock()
+	defer func() {
+		cp.mu.Lock()
+		cp.pendingID, cp.pending = "", nil
+		cp.mu.Unlock()
+	}()
+
+	if err := cp.conn.write(websocket.OpText, data); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	select {
+	case f := <-result:
+		if f.typ == typeCallError {
+			return &CallError{Action: action, Code: f.code, Description: f.description}
+		}
+		if conf == nil {
+			return nil
+		}
+		if err := json.Unmarshal(f.payload, conf); err != nil {
+			return fmt.Errorf("ocpp: %s: %w", action, err)
+		}
+		return nil
+	case <-cp.conn.done:
+		return fmt.Errorf("ocpp: %s: connection closed", action)
+	case <-ctx.Done():
+		return fmt.Errorf("ocpp: %s: %w", action, ctx.Err())
+	}
+}
+
+// reply sends the confirmation of a request of the central system.
+func (cp *ChargePoint) reply(id string, conf interface{}) error {
+	data, err := json.Marshal([]interface{}{typeCallResult, id, conf})
+	if err != nil {
+		return err
+	}
+	return cp.conn.write(websocket.OpText, data)
+}
+
+// replyError answers a request of the central system with an error.
+func (cp *ChargePoint) replyError(c *conn, id, code, description string) error {
+	data, err := json.Marshal([]interface{}{typeCallError, id, code, description, struct{}{}})
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.OpText, data)
+}
+
+// handle serves a request of the central system.
+func (cp *ChargePoint) handle(ctx context.Context, clock smartme.Clock, f frame) error {
+	var err error
+	switch f.action {
+	case "RemoteStartTransaction":
+		var req remoteStartTransactionRequest
+		if err = json.Unmarshal(f.payload, &req); err == nil {
+			return cp.remoteStart(ctx, clock, f.id, req)
+		}
+	case "RemoteStopTransaction":
+		var req remoteStopTransactionRequest
+		if err = json.Unmarshal(f.payload, &req); err == nil {
+			return cp.remoteStop(ctx, clock, f.id, req)
+		}
+	case "SetChargingProfile":
+		var req setChargingProfileRequest
+		if err = json.Unmarshal(f.payload, &req); err == nil {
+			return cp.setChargingProfile(ctx, clock, f.id, req)
+		}
+	case "ClearChargingProfile":
+		var req clearChargingProfileRequest
+		if err = json.Unmarshal(f.payload, &req); err == nil {
+			return cp.clearChargingProfile(ctx, f.id, req)
+		}
+	default:
+		return cp.replyError(cp.conn, f.id, errNotImplemented, f.action+" is not supported")
+	}
+	return cp.replyError(cp.conn, f.id, errFormationViolation, err.Error())
+}
+
+// remoteStart starts charging and a transaction for the ID tag.
+func (cp *ChargePoint) remoteStart(ctx context.Context, clock smartme.Clock, id string, req remoteStartTransactionRequest) error {
+	if cp.inTransaction || (req.ConnectorID != nil && *req.ConnectorID != connectorID) {
+		return cp.reply(id, statusConf{Status: "Rejected"})
+	}
+	// While paused by a charging profile, the transaction starts
+	// suspended and charging begins with the next valid limit.
+	if !cp.paused {
+		if err := cp.api.StartCharging(ctx, cp.deviceID); err != nil {
+			cp.onError(fmt.Errorf("ocpp: remote start: %w", err))
+			return cp.reply(id, statusConf{Status: "Rejected"})
+		}
+	}
+	if err := cp.reply(id, statusConf{Status: "Accepted"}); err != nil {
+		return err
+	}
+
+	cp.readMeter(ctx)
+	var conf startTransactionConf
+	err := cp.call(ctx, "StartTransaction", startTransactionRequest{
+		ConnectorID: connectorID,
+		IDTag:       req.IDTag,
+		MeterStart:  cp.meterWh,
+		Timestamp:   clock.Now().UTC(),
+	}, &conf)
+	if err != nil {
+		return err
+	}
+	cp.inTransaction, cp.transactionID, cp.idTag = true, conf.TransactionID, req.IDTag
+	if conf.IDTagInfo.Status != "Accepted" {
+		cp.onError(fmt.Errorf("ocpp: id tag %s is %s", req.IDTag, strings.ToLower(conf.IDTagInfo.Status)))
+		if err := cp.api.StopCharging(ctx, cp.deviceID); err != nil {
+			cp.onError(fmt.Errorf("ocpp: stop charging: %w", err))
+		}
+		return cp.stopTransaction(ctx, clock, "DeAuthorized")
+	}
+	return cp.poll(ctx, clock)
+}
+
+// remoteStop stops charging and the running transaction.
+func (cp *ChargePoint) remoteStop(ctx context.Context, clock smartme.Clock, id string, req remoteStopTransactionRequest) error {
+	if !cp.inTransaction || req.TransactionID != cp.transactionID {
+		return cp.reply(id, statusConf{Status: "Rejected"})
+	}
+	if err := cp.api.StopCharging(ctx, cp.deviceID); err != nil {
+		cp.onError(fmt.Errorf("ocpp: remote stop: %w", err))
+		return cp.reply(id, statusConf{Status: "Rejected"})
+	}
+	if err := cp.reply(id, statusConf{Status: "Accepted"}); err != nil {
+		return err
+	}
+	if err := cp.stopTransaction(ctx, clock, "Remote"); err != nil {
+		return err
+	}
+	return cp.poll(ctx, clock)
+}
+
+// stopTransaction reports the end of the running transaction.
+func (cp *ChargePoint) stopTransaction(ctx context.Context, clock smartme.Clock, reason string) error {
+	cp.readMeter(ctx)
+	err := cp.call(ctx, "StopTransaction", stopTransactionRequest{
+		TransactionID: cp.transactionID,
+		IDTag:         cp.idTag,
+		MeterStop:     cp.meterWh,
+		Timestamp:     clock.Now().UTC(),
+		Reason:        reason,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	cp.inTransaction, cp.transactionID, cp.idTag = false, 0, ""
+	return nil
+}
+
+// profile is an installed charging profile.
+type profile struct {
+	id         int
+	purpose    string
+	stackLevel int
+	limit      float64
+}
+
+// setChargingProfile installs a charging profile and applies the limit of
+// the installed profiles as maximum charging current. Transaction profiles
+// and schedules that change over time cannot be applied to the single
+// limit of the station and are rejected.
+func (cp *ChargePoint) setChargingProfile(ctx context.Context, clock smartme.Clock, id string, req setChargingProfileRequest) error {
+	p := req.CsChargingProfiles
+	schedule := p.ChargingSchedule
+	switch {
+	case req.ConnectorID > connectorID,
+		p.ChargingProfilePurpose != purposeTxDefault && p.ChargingProfilePurpose != purposeChargePointMax,
+		p.ValidFrom != nil && p.ValidFrom.After(clock.Now()),
+		p.ValidTo != nil,
+		schedule.Duration != nil,
+		schedule.ChargingRateUnit != "A",
+		len(schedule.ChargingSchedulePeriod) != 1,
+		schedule.ChargingSchedulePeriod[0].StartPeriod != 0:
+		return cp.reply(id, statusConf{Status: "Rejected"})
+	}
+
+	// A profile replaces the one with the same ID or the same purpose and
+	// stack level.
+	profiles := []profile{{
+		id:         p.ChargingProfileID,
+		purpose:    p.ChargingProfilePurpose,
+		stackLevel: p.StackLevel,
+		limit:      schedule.ChargingSchedulePeriod[0].Limit,
+	}}
+	for _, q := range cp.profiles {
+		if q.id != p.ChargingProfileID && (q.purpose != p.ChargingProfilePurpose || q.stackLevel != p.StackLevel) {
+			profiles = append(profiles, q)
+		}
+	}
+	if err := cp.applyProfiles(ctx, profiles); err != nil {
+		cp.onError(fmt.Errorf("ocpp: set charging profile: %w", err))
+		return cp.reply(id, statusConf{Status: "Rejected"})
+	}
+	return cp.reply(id, statusConf{Status: "Accepted"})
+}
+
+// clearChargingProfile removes the profile with the ID of the request or,
+// without an ID, the profiles that match its purpose and stack level.
+func (cp *ChargePoint) clearChargingProfile(ctx context.Context, id string, req clearChargingProfileRequest) error {
+	if req.ConnectorID != nil && *req.ConnectorID > connectorID {
+		return cp.reply(id, statusConf{Status: "Unknown"})
+	}
+	var profiles []profile
+	for _, p := range cp.profiles {
+		match := req.ID != nil && p.id == *req.ID
+		if req.ID == nil {
+			match = (req.ChargingProfilePurpose == "" || p.purpose == req.ChargingProfilePurpose) &&
+				(req.StackLevel == nil || p.stackLevel == *req.StackLevel)
+		}
+		if !match {
+			profiles = append(profiles, p)
+		}
+	}
+	if len(profiles) == len(cp.profiles) {
+		return cp.reply(id, statusConf{Status: "Unknown"})
+	}
+	if err := cp.applyProfiles(ctx, profiles); err != nil {
+		// The profiles stay installed; there is no status for failures.
+		cp.onError(fmt.Errorf("ocpp: clear charging profile: %w", err))
+		return cp.reply(id, statusConf{Status: "Unknown"})
+	}
+	return cp.reply(id, statusConf{Status: "Accepted"})
+}
+
+// applyProfiles sets the maximum charging current to the limit of the
+// profiles and installs them. Of each purpose the profile with the highest
+// stack level is in effect, and the lower of the limits of both purposes
+// applies. Without profiles, the limit is the maximum of the station, since
+// the limit from before the first profile cannot be read back.
+//
+// A station cannot charge with less than smartme.MinChargingCurrent, so a
+// lower limit, like the 0 A of a profile that pauses charging, stops
+// charging. The next limit the station can charge with resumes it.
+func (cp *ChargePoint) applyProfiles(ctx context.Context, profiles []profile) error {
+	if len(cp.profiles) == 0 {
+		action, err := smartme.ChargingCurrentAction(ctx, cp.api, cp.deviceID)
+		if err != nil {
+			return err
+		}
+		cp.baseCurrent = action.MaxValue
+	}
+
+	limit := cp.baseCurrent
+	if len(profiles) > 0 {
+		top := make(map[string]profile)
+		for _, p := range profiles {
+			if q, ok := top[p.purpose]; !ok || p.stackLevel > q.stackLevel {
+				top[p.purpose] = p
+			}
+		}
+		limit = math.Inf(1)
+		for _, p := range top {
+			limit = math.Min(limit, p.limit)
+		}
+	}
+	if limit < smartme.MinChargingCurrent {
+		if err := cp.api.StopCharging(ctx, cp.deviceID); err != nil {
+			return err
+		}
+		cp.profiles, cp.paused = profiles, true
+		return nil
+	}
+	if err := cp.api.SetMaxChargingCurrent(ctx, cp.deviceID, limit); err != nil {
+		return err
+	}
+	if cp.paused {
+		// Without a car there is nothing to resume.
+		err := cp.api.StartCharging(ctx, cp.deviceID)
+		if err != nil && !errors.Is(err, smartme.ErrInvalidChargeState) {
+			return err
+		}
+	}
+	cp.profiles, cp.paused = profiles, false
+	return nil
+}
+
+// poll reads the station and reports status changes and, during a
+// transaction, meter values. A transaction ends when the car is unplugged.
+// Only errors of the connection are returned.
+func (cp *ChargePoint) poll(ctx context.Context, clock smartme.Clock) error {
+	d, err := cp.api.GetDevice(ctx, cp.deviceID)
+	if err != nil {
+		cp.onError(fmt.Errorf("ocpp: read station: %w", err))
+		return nil
+	}
+	now := clock.Now().UTC()
+	state := smartme.Offline
+	if d.ChargeStationState != nil {
+		state = *d.ChargeStationState
+	}
+	values := cp.sample(d)
+
+	if cp.inTransaction && !state.CarConnected() {
+		if err := cp.stopTransaction(ctx, clock, "EVDisconnected"); err != nil {
+			return err
+		}
+	}
+	if status := statusOf(state, cp.inTransaction); status != cp.status {
+		err := cp.call(ctx, "StatusNotification", statusNotificationRequest{
+			ConnectorID: connectorID,
+			ErrorCode:   "NoError",
+			Status:      status,
+			Timestamp:   now,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		cp.status = status
+	}
+	if !cp.inTransaction || len(values) == 0 {
+		return nil
+	}
+	transactionID := cp.transactionID
+	return cp.call(ctx, "MeterValues", meterValuesRequest{
+		ConnectorID:   connectorID,
+		TransactionID: &transactionID,
+		MeterValue:    []meterValue{{Timestamp: now, SampledValue: values}},
+	}, nil)
+}
+
+// readMeter updates the last known meter reading for the start and end of
+// a transaction.
+func (cp *ChargePoint) readMeter(ctx context.Context) {
+	d, err := cp.api.GetDevice(ctx, cp.deviceID)
+	if err != nil {
+		cp.onError(fmt.Errorf("ocpp: read meter: %w", err))
+		return
+	}
+	cp.sample(d)
+}
+
+// sample returns the meter values of d and updates the last known meter
+// reading.
+func (cp *ChargePoint) sample(d *smartme.Device) []sampledValue {
+	var values []sampledValue
+	if kwh, err := d.CounterReadingValue(); err == nil {
+		cp.meterWh = int64(math.Round(kwh * 1000))
+		values = append(values, sampledValue{
+			Value:     strconv.FormatInt(cp.meterWh, 10),
+			Context:   "Sample.Periodic",
+			Measurand: "Energy.Active.Import.Register",
+			Unit:      "Wh",
+		})
+	}
+	if w, err := d.ActivePowerWatts(); err == nil {
+		values = append(values, sampledValue{
+			Value:     strconv.FormatFloat(w, 'f', -1, 64),
+			Context:   "Sample.Periodic",
+			Measurand: "Power.Active.Import",
+			Unit:      "W",
+		})
+	}
+	if d.Current != nil {
+		values = append(values, sampledValue{
+			Value:     strconv.FormatFloat(*d.Current, 'f', -1, 64),
+			Context:   "Sample.Periodic",
+			Measurand: "Current.Import",
+			Unit:      "A",
+		})
+	}
+	return values
+}
+
+func (cp *ChargePoint) onError(err error) {
+	if cp.OnError != nil && err != nil && !errors.Is(err, context.Canceled) {
+		cp.OnError(err)
+	}
+}
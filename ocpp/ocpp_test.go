@@ -0,0 +1,472 @@
+package ocpp_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/ocpp"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// message is an OCPP-J message received by the central system.
+type message struct {
+	Type    int
+	ID      string
+	Action  string // the error code of call errors
+	Payload map[string]interface{}
+}
+
+// csms is a minimal central system that confirms every request of the
+// charge point and records it.
+type csms struct {
+	t        *testing.T
+	srv      *httptest.Server
+	path     chan string
+	received chan message
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newCSMS(t *testing.T) *csms {
+	c := &csms{t: t, path: make(chan string, 4), received: make(chan message, 64)}
+	c.srv = httptest.NewServer(http.HandlerFunc(c.serve))
+	t.Cleanup(func() {
+		c.mu.Lock()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		c.mu.Unlock()
+		c.srv.Close()
+	})
+	return c
+}
+
+func (c *csms) url() string {
+	return "ws" + strings.TrimPrefix(c.srv.URL, "http") + "/ocpp"
+}
+
+func (c *csms) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Sec-WebSocket-Protocol") != "ocpp1.6" {
+		http.Error(w, "subprotocol ocpp1.6 required", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	h := sha1.New()
+	h.Write([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Protocol: ocpp1.6\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(h.Sum(nil)) + "\r\n\r\n")
+	rw.Flush()
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	c.path <- r.URL.Path
+
+	for {
+		data, err := readMasked(rw.Reader)
+		if err != nil {
+			return
+		}
+		var parts []json.RawMessage
+		if json.Unmarshal(data, &parts) != nil || len(parts) < 3 {
+			continue
+		}
+		var m message
+		json.Unmarshal(parts[0], &m.Type)
+		json.Unmarshal(parts[1], &m.ID)
+		payload := parts[len(parts)-1]
+		if m.Type == 2 {
+			json.Unmarshal(parts[2], &m.Action)
+			c.confirm(m)
+		} else if m.Type == 4 {
+			json.Unmarshal(parts[2], &m.Action)
+		}
+		json.Unmarshal(payload, &m.Payload)
+		c.received <- m
+	}
+}
+
+// confirm answers a request of the charge point.
+func (c *csms) confirm(m message) {
+	var conf interface{} = struct{}{}
+	switch m.Action {
+	case "BootNotification":
+		conf = map[string]interface{}{"status": "Accepted", "currentTime": time.Now().UTC(), "interval": 300}
+	case "StartTransaction":
+		conf = map[string]interface{}{"transactionId": 7, "idTagInfo": map[string]string{"status": "Accepted"}}
+	}
+	c.write([]interface{}{3, m.ID, conf})
+}
+
+// call sends a request to the charge point.
+func (c *csms) call(id, action string, payload interface{}) {
+	c.write([]interface{}{2, id, action, payload})
+}
+
+func (c *csms) write(v interface{}) {
+	data, _ := json.Marshal(v)
+	frame := []byte{0x81}
+	if len(data) < 126 {
+		frame = append(frame, byte(len(data)))
+	} else {
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(data)))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.Write(append(frame, data...))
+}
+
+// expect returns the next request with the action, or the next result
+// if action is empty. Meter values and heartbeats sent in between are
+// skipped.
+func (c *csms) expect(action string) message {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-c.received:
+			if action == "" && m.Type != 2 {
+				return m
+			}
+			if m.Type == 2 && m.Action == action {
+				return m
+			}
+			if m.Type == 2 && (m.Action == "MeterValues" || m.Action == "Heartbeat") {
+				continue
+			}
+			c.t.Fatalf("got %+v, want %q", m, action)
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %q", action)
+		}
+	}
+}
+
+// readMasked reads a message of masked frames.
+func readMasked(r *bufio.Reader) ([]byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	if head[0]&0x0f == 0x8 {
+		return nil, io.EOF
+	}
+	return payload, nil
+}
+
+// station is a charging station behind a mock API.
+type station struct {
+	mu      sync.Mutex
+	state   smartme.ChargeStationState
+	counter float64
+	amps    float64
+}
+
+func (s *station) set(state smartme.ChargeStationState, counter float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state, s.counter = state, counter
+}
+
+func (s *station) api() *smartmemock.API {
+	return &smartmemock.API{
+		GetDeviceFunc: func(ctx context.Context, id string) (*smartme.Device, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return &smartme.Device{
+				Id:                 ptr(id),
+				ChargeStationState: ptr(s.state),
+				CounterReading:     ptr(s.counter),
+				CounterReadingUnit: ptr("kWh"),
+				ActivePower:        ptr(7.4),
+				ActivePowerUnit:    ptr("kW"),
+				Current:            ptr(10.5),
+			}, nil
+		},
+		StartChargingFunc: func(ctx context.Context, id string) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.state = smartme.Charging
+			return nil
+		},
+		StopChargingFunc: func(ctx context.Context, id string) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.state = smartme.ReadyCarConnected
+			return nil
+		},
+		SetMaxChargingCurrentFunc: func(ctx context.Context, id string, amps float64) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.amps = amps
+			return nil
+		},
+		GetActionsFunc: func(ctx context.Context, id string) ([]smartme.ActionInfo, error) {
+			return []smartme.ActionInfo{{ObisCode: "current", ActionType: smartme.ActionTypeAnalog, MinValue: 6, MaxValue: 32}}, nil
+		},
+	}
+}
+
+// start runs a charge point for the station until the test ends.
+func start(t *testing.T, s *station, c *csms, clock *smartmetest.Clock) {
+	cp := ocpp.NewChargePoint(s.api(), "dev-1", c.url())
+	cp.Clock = clock
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cp.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestChargePoint_RemoteTransaction(t *testing.T) {
+	s := &station{state: smartme.ReadyCarConnected, counter: 1}
+	c := newCSMS(t)
+	clock := smartmetest.NewClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	start(t, s, c, clock)
+
+	if path := <-c.path; path != "/ocpp/dev-1" {
+		t.Errorf("path = %q, want /ocpp/dev-1", path)
+	}
+	boot := c.expect("BootNotification")
+	if boot.Payload["chargePointVendor"] != "smart-me" {
+		t.Errorf("BootNotification = %v", boot.Payload)
+	}
+	if m := c.expect("StatusNotification"); m.Payload["status"] != "Preparing" {
+		t.Errorf("status = %v, want Preparing", m.Payload["status"])
+	}
+
+	c.call("c1", "RemoteStartTransaction", map[string]interface{}{"idTag": "TAG1"})
+	if m := c.expect(""); m.ID != "c1" || m.Payload["status"] != "Accepted" {
+		t.Fatalf("RemoteStartTransaction = %+v", m)
+	}
+	m := c.expect("StartTransaction")
+	if m.Payload["idTag"] != "TAG1" || m.Payload["meterStart"] != 1000.0 || m.Payload["connectorId"] != 1.0 {
+		t.Errorf("StartTransaction = %v", m.Payload)
+	}
+	if m := c.expect("StatusNotification"); m.Payload["status"] != "Charging" {
+		t.Errorf("status = %v, want Charging", m.Payload["status"])
+	}
+	m = c.expect("MeterValues")
+	if m.Payload["transactionId"] != 7.0 {
+		t.Errorf("transactionId = %v, want 7", m.Payload["transactionId"])
+	}
+	values := m.Payload["meterValue"].([]interface{})[0].(map[string]interface{})["sampledValue"].([]interface{})
+	want := map[string]string{"Energy.Active.Import.Register": "1000", "Power.Active.Import": "7400", "Current.Import": "10.5"}
+	for _, v := range values {
+		v := v.(map[string]interface{})
+		if w := want[v["measurand"].(string)]; v["value"] != w {
+			t.Errorf("%v = %v, want %v", v["measurand"], v["value"], w)
+		}
+	}
+
+	// Meter values are sent every minute during the transaction.
+	s.set(smartme.Charging, 1.5)
+	clock.Advance(time.Minute)
+	m = c.expect("MeterValues")
+	values = m.Payload["meterValue"].([]interface{})[0].(map[string]interface{})["sampledValue"].([]interface{})
+	if v := values[0].(map[string]interface{})["value"]; v != "1500" {
+		t.Errorf("energy = %v, want 1500", v)
+	}
+
+	c.call("c2", "RemoteStopTransaction", map[string]interface{}{"transactionId": 8})
+	if m := c.expect(""); m.Payload["status"] != "Rejected" {
+		t.Errorf("stop of unknown transaction = %v, want Rejected", m.Payload["status"])
+	}
+	c.call("c3", "RemoteStopTransaction", map[string]interface{}{"transactionId": 7})
+	if m := c.expect(""); m.Payload["status"] != "Accepted" {
+		t.Fatalf("RemoteStopTransaction = %+v", m)
+	}
+	m = c.expect("StopTransaction")
+	if m.Payload["transactionId"] != 7.0 || m.Payload["meterStop"] != 1500.0 || m.Payload["reason"] != "Remote" {
+		t.Errorf("StopTransaction = %v", m.Payload)
+	}
+	if m := c.expect("StatusNotification"); m.Payload["status"] != "Preparing" {
+		t.Errorf("status = %v, want Preparing", m.Payload["status"])
+	}
+}
+
+func TestChargePoint_EVDisconnected(t *testing.T) {
+	s := &station{state: smartme.ReadyCarConnected, counter: 2}
+	c := newCSMS(t)
+	clock := smartmetest.NewClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	start(t, s, c, clock)
+	c.expect("BootNotification")
+	c.expect("StatusNotification")
+
+	c.call("c1", "RemoteStartTransaction", map[string]interface{}{"connectorId": 1, "idTag": "TAG1"})
+	c.expect("")
+	c.expect("StartTransaction")
+	c.expect("StatusNotification")
+
+	s.set(smartme.ReadyNoCarConnected, 3)
+	clock.Advance(time.Minute)
+	m := c.expect("StopTransaction")
+	if m.Payload["reason"] != "EVDisconnected" || m.Payload["meterStop"] != 3000.0 {
+		t.Errorf("StopTransaction = %v", m.Payload)
+	}
+	if m := c.expect("StatusNotification"); m.Payload["status"] != "Available" {
+		t.Errorf("status = %v, want Available", m.Payload["status"])
+	}
+}
+
+func TestChargePoint_ChargingProfiles(t *testing.T) {
+	s := &station{state: smartme.ReadyNoCarConnected, amps: 16}
+	c := newCSMS(t)
+	start(t, s, c, smartmetest.NewClock(time.Now()))
+	c.expect("BootNotification")
+	c.expect("StatusNotification")
+
+	profile := func(id int, purpose, unit string, limit float64) map[string]interface{} {
+		return map[string]interface{}{
+			"connectorId": 0,
+			"csChargingProfiles": map[string]interface{}{
+				"chargingProfileId":      id,
+				"stackLevel":             0,
+				"chargingProfilePurpose": purpose,
+				"chargingProfileKind":    "Absolute",
+				"chargingSchedule": map[string]interface{}{
+					"chargingRateUnit":       unit,
+					"chargingSchedulePeriod": []interface{}{map[string]interface{}{"startPeriod": 0, "limit": limit}},
+				},
+			},
+		}
+	}
+	steps := []struct {
+		action  string
+		payload map[string]interface{}
+		status  string
+		amps    float64
+	}{
+		{"SetChargingProfile", profile(1, "TxDefaultProfile", "A", 10), "Accepted", 10},
+		{"SetChargingProfile", profile(2, "TxDefaultProfile", "W", 7000), "Rejected", 10},
+		// A transaction profile would outlive its transaction.
+		{"SetChargingProfile", profile(3, "TxProfile", "A", 6), "Rejected", 10},
+		{"SetChargingProfile", profile(4, "ChargePointMaxProfile", "A", 8), "Accepted", 8},
+		{"ClearChargingProfile", map[string]interface{}{"chargingProfilePurpose": "ChargePointMaxProfile"}, "Accepted", 10},
+		// Without profiles, the limit is lifted to the maximum of the station.
+		{"ClearChargingProfile", map[string]interface{}{"id": 1}, "Accepted", 32},
+		{"ClearChargingProfile", map[string]interface{}{}, "Unknown", 32},
+	}
+	for i, step := range steps {
+		c.call(strconv.Itoa(i), step.action, step.payload)
+		if m := c.expect(""); m.Payload["status"] != step.status {
+			t.Errorf("step %d: %s = %+v, want %s", i, step.action, m, step.status)
+		}
+		s.mu.Lock()
+		if s.amps != step.amps {
+			t.Errorf("step %d: max charging current = %v, want %v", i, s.amps, step.amps)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestChargePoint_PauseProfile(t *testing.T) {
+	s := &station{state: smartme.Charging, amps: 16}
+	c := newCSMS(t)
+	start(t, s, c, smartmetest.NewClock(time.Now()))
+	c.expect("BootNotification")
+	c.expect("StatusNotification")
+
+	profile := func(id int, purpose string, limit float64) map[string]interface{} {
+		return map[string]interface{}{
+			"connectorId": 0,
+			"csChargingProfiles": map[string]interface{}{
+				"chargingProfileId":      id,
+				"stackLevel":             0,
+				"chargingProfilePurpose": purpose,
+				"chargingProfileKind":    "Absolute",
+				"chargingSchedule": map[string]interface{}{
+					"chargingRateUnit":       "A",
+					"chargingSchedulePeriod": []interface{}{map[string]interface{}{"startPeriod": 0, "limit": limit}},
+				},
+			},
+		}
+	}
+	steps := []struct {
+		payload map[string]interface{}
+		state   smartme.ChargeStationState
+		amps    float64
+	}{
+		// Below 6 A the station cannot charge, so charging stops and the
+		// current is left alone.
+		{profile(1, "TxDefaultProfile", 0), smartme.ReadyCarConnected, 16},
+		{profile(1, "TxDefaultProfile", 10), smartme.Charging, 10},
+		{profile(2, "ChargePointMaxProfile", 3), smartme.ReadyCarConnected, 10},
+		{profile(2, "ChargePointMaxProfile", 8), smartme.Charging, 8},
+	}
+	for i, step := range steps {
+		c.call(strconv.Itoa(i), "SetChargingProfile", step.payload)
+		if m := c.expect(""); m.Payload["status"] != "Accepted" {
+			t.Errorf("step %d: SetChargingProfile = %+v, want Accepted", i, m)
+		}
+		s.mu.Lock()
+		if s.state != step.state || s.amps != step.amps {
+			t.Errorf("step %d: state %v at %v A, want %v at %v A", i, s.state, s.amps, step.state, step.amps)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestChargePoint_Requests(t *testing.T) {
+	s := &station{state: smartme.ReadyNoCarConnected}
+	c := newCSMS(t)
+	start(t, s, c, smartmetest.NewClock(time.Now()))
+	c.expect("BootNotification")
+	c.expect("StatusNotification")
+
+	c.call("c1", "Reset", map[string]interface{}{"type": "Soft"})
+	if m := c.expect(""); m.Type != 4 || m.Action != "NotImplemented" {
+		t.Errorf("Reset = %+v, want NotImplemented error", m)
+	}
+	c.call("c2", "RemoteStopTransaction", map[string]interface{}{"transactionId": "x"})
+	if m := c.expect(""); m.Type != 4 || m.Action != "FormationViolation" {
+		t.Errorf("invalid request = %+v, want FormationViolation error", m)
+	}
+}
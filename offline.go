@@ -0,0 +1,235 @@
+package smartme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrOffline is returned in offline mode by calls that cannot be answered
+// from the offline store while the API is unreachable, including all calls
+// that change devices.
+var ErrOffline = errors.New("smart-me API is unreachable")
+
+// offlineProbeInterval is how long calls are answered from the offline
+// store without trying the API after it became unreachable.
+const offlineProbeInterval = 30 * time.Second
+
+// maxOfflineEntries is the number of responses kept by the in-memory
+// offline store.
+const maxOfflineEntries = 1000
+
+// WithOfflineMode keeps the last response of every read call and answers
+// read calls from it while the API is unreachable, so edge controllers keep
+// working through internet outages. The responses are kept in the cache of
+// WithCache, which survives restarts with NewDiskCache, or in memory.
+//
+// The API counts as unreachable after a network error or a 502, 503 or
+// 504 status. Calls are then answered from the store for 30 seconds before
+// the API is tried again. Calls without a stored response, and all calls
+// that change devices, fail with ErrOffline. StreamValuesInPastMultiple is
+// answered from the responses stored by GetValuesInPastMultiple, but does
+// not store its own. Use ContextWithStaleness to
+// learn whether a response came from the store, and OfflineSince for the
+// state of the client.
+func WithOfflineMode() Option {
+	return func(c *Client) error {
+		if c.offline == nil {
+			c.offline = &offlineState{mem: make(map[string][]byte)}
+		}
+		return nil
+	}
+}
+
+// Staleness tells whether a response was served from the offline store.
+type Staleness struct {
+	// Stale is set if the response came from the offline store.
+	Stale bool
+	// StoredAt is when the stored response was received from the API.
+	StoredAt time.Time
+}
+
+type stalenessKey struct{}
+
+// ContextWithStaleness returns a context that makes the calls done with it
+// record in s whether their response came from the offline store:
+//
+//	var st smartme.Staleness
+//	device, err := client.GetDevice(smartme.ContextWithStaleness(ctx, &st), id)
+//	if st.Stale {
+//		log.Printf("offline, showing values from %v", st.StoredAt)
+//	}
+func ContextWithStaleness(ctx context.Context, s *Staleness) context.Context {
+	return context.WithValue(ctx, stalenessKey{}, s)
+}
+
+// OfflineSince returns when the API became unreachable, if it is in
+// offline mode and currently unreachable.
+func (c *Client) OfflineSince() (time.Time, bool) {
+	if c.offline == nil {
+		return time.Time{}, false
+	}
+	c.offline.mu.Lock()
+	defer c.offline.mu.Unlock()
+	return c.offline.since, !c.offline.since.IsZero()
+}
+
+// offlineState is the connectivity state and the in-memory store of
+// offline mode, shared by derived clients.
+type offlineState struct {
+	mu        sync.Mutex
+	since     time.Time
+	lastProbe time.Time
+	mem       map[string][]byte
+}
+
+// skipAPI reports whether calls should be answered from the store without
+// trying the API.
+func (o *offlineState) skipAPI(now time.Time) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return !o.since.IsZero() && now.Sub(o.lastProbe) < offlineProbeInterval
+}
+
+func (o *offlineState) setOffline(now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.since.IsZero() {
+		o.since = now
+	}
+	o.lastProbe = now
+}
+
+func (o *offlineState) setOnline() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.since = time.Time{}
+}
+
+// unreachable reports whether err means that the API cannot be reached:
+// a failed HTTP round trip, a gateway error or ErrOffline. Errors of a done
+// context are returned by send as they are, not as *url.Error.
+func unreachable(err error) bool {
+	if errors.Is(err, ErrOffline) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// offlineKey returns the store key of a read request. Like the cache key,
+// it includes the content type of the codec.
+func (c *Client) offlineKey(req *http.Request) string {
+	username, _, _ := req.BasicAuth()
+	return "offline " + username + " " + c.codec.ContentType() + " " + req.URL.String()
+}
+
+// storeOffline keeps the response to a read request. Entries start with
+// the time they were stored.
+func (c *Client) storeOffline(req *http.Request, data []byte) {
+	if c.offline == nil || req.Method != http.MethodGet {
+		return
+	}
+	entry := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(entry, uint64(c.clock.Now().UnixNano()))
+	copy(entry[8:], data)
+
+	key := c.offlineKey(req)
+	if c.cache != nil {
+		c.cache.Set(key, entry, 0)
+		return
+	}
+	o := c.offline
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.mem[key]; !ok && len(o.mem) >= maxOfflineEntries {
+		o.evictOldest()
+	}
+	o.mem[key] = entry
+}
+
+// evictOldest removes the entry stored first. o.mu must be held.
+func (o *offlineState) evictOldest() {
+	var (
+		oldestKey string
+		oldest    uint64
+	)
+	for key, entry := range o.mem {
+		if t := binary.BigEndian.Uint64(entry); oldestKey == "" || t < oldest {
+			oldestKey, oldest = key, t
+		}
+	}
+	delete(o.mem, oldestKey)
+}
+
+func (c *Client) loadOffline(req *http.Request) ([]byte, time.Time, bool) {
+	key := c.offlineKey(req)
+	var entry []byte
+	if c.cache != nil {
+		entry, _ = c.cache.Get(key)
+	} else {
+		c.offline.mu.Lock()
+		entry = c.offline.mem[key]
+		c.offline.mu.Unlock()
+	}
+	if len(entry) < 8 {
+		return nil, time.Time{}, false
+	}
+	return entry[8:], time.Unix(0, int64(binary.BigEndian.Uint64(entry))), true
+}
+
+// serveOffline answers a request from the offline store. cause is the
+// reason the API was not used.
+func (c *Client) serveOffline(req *http.Request, v interface{}, cause error) (*http.Response, error) {
+	offlineErr := ErrOffline
+	if cause != ErrOffline {
+		offlineErr = fmt.Errorf("%w: %v", ErrOffline, cause)
+	}
+	if req.Method != http.MethodGet || v == nil {
+		return nil, offlineErr
+	}
+	data, storedAt, ok := c.loadOffline(req)
+	if !ok || c.codec.Unmarshal(data, v) != nil {
+		return nil, offlineErr
+	}
+	if s, ok := req.Context().Value(stalenessKey{}).(*Staleness); ok {
+		*s = Staleness{Stale: true, StoredAt: storedAt}
+	}
+	return nil, c.postprocess(req, v)
+}
+
+// streamOffline is serveOffline for StreamValuesInPastMultiple.
+func (c *Client) streamOffline(req *http.Request, fn func(Value) error, cause error) error {
+	offlineErr := ErrOffline
+	if cause != ErrOffline {
+		offlineErr = fmt.Errorf("%w: %v", ErrOffline, cause)
+	}
+	data, storedAt, ok := c.loadOffline(req)
+	if !ok {
+		return offlineErr
+	}
+	if s, ok := req.Context().Value(stalenessKey{}).(*Staleness); ok {
+		*s = Staleness{Stale: true, StoredAt: storedAt}
+	}
+	return c.decodeValues(bytes.NewReader(data), fn)
+}
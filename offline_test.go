@@ -0,0 +1,156 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestWithOfflineMode(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("a"), ActivePower: ptr(1.5)})
+	srv.AddDevice(smartme.Device{Id: ptr("b"), ActivePower: ptr(2.5)})
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := smartmetest.NewClock(start)
+	client, _ := srv.Client(smartme.WithOfflineMode(), smartme.WithClock(clock))
+	ctx := context.Background()
+
+	if _, err := client.GetDevice(ctx, "a"); err != nil {
+		t.Fatalf("GetDevice failed: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	srv.FailEndpoint("/api", http.StatusServiceUnavailable)
+
+	var st smartme.Staleness
+	device, err := client.GetDevice(smartme.ContextWithStaleness(ctx, &st), "a")
+	if err != nil {
+		t.Fatalf("GetDevice while offline failed: %v", err)
+	}
+	if *device.ActivePower != 1.5 {
+		t.Errorf("ActivePower = %v, want the stored 1.5", *device.ActivePower)
+	}
+	if !st.Stale || !st.StoredAt.Equal(start) {
+		t.Errorf("Staleness = %+v, want stale from %v", st, start)
+	}
+	if since, ok := client.OfflineSince(); !ok || !since.Equal(start.Add(time.Minute)) {
+		t.Errorf("OfflineSince = %v, %v", since, ok)
+	}
+
+	// Without a stored response and for writes the call fails.
+	if _, err := client.GetDevice(ctx, "b"); !errors.Is(err, smartme.ErrOffline) {
+		t.Errorf("GetDevice without stored response: err = %v, want ErrOffline", err)
+	}
+	if err := client.PerformActions(ctx, "a", []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 1}}); !errors.Is(err, smartme.ErrOffline) {
+		t.Errorf("PerformActions while offline: err = %v, want ErrOffline", err)
+	}
+
+	// Within the probe interval the API is not tried again.
+	requests := srv.Requests("")
+	srv.FailEndpoint("/api", 0)
+	if _, err := client.GetDevice(ctx, "a"); err != nil {
+		t.Fatalf("GetDevice failed: %v", err)
+	}
+	if n := srv.Requests(""); n != requests {
+		t.Errorf("%d requests sent within the probe interval", n-requests)
+	}
+
+	clock.Advance(time.Minute)
+	st = smartme.Staleness{}
+	if _, err := client.GetDevice(smartme.ContextWithStaleness(ctx, &st), "b"); err != nil {
+		t.Fatalf("GetDevice after the outage failed: %v", err)
+	}
+	if st.Stale {
+		t.Error("response after the outage is stale")
+	}
+	if _, ok := client.OfflineSince(); ok {
+		t.Error("client is still offline after the outage")
+	}
+}
+
+func TestWithOfflineMode_Unreachable(t *testing.T) {
+	srv := smartmetest.NewServer()
+	srv.AddDevice(smartme.Device{Id: ptr("a"), ActivePower: ptr(1.5)})
+	client, _ := srv.Client(smartme.WithOfflineMode())
+	ctx := context.Background()
+
+	if _, err := client.GetDevices(ctx); err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	srv.Close()
+
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		t.Fatalf("GetDevices with the server down failed: %v", err)
+	}
+	if len(devices) != 1 {
+		t.Errorf("got %d devices, want the stored one", len(devices))
+	}
+}
+
+func TestWithOfflineMode_Stream(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	id := srv.AddDevice(smartme.Device{CounterReading: ptr(101.0)})
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	srv.AddHistory(id,
+		smartme.Value{Date: start, Value: 100},
+		smartme.Value{Date: start.Add(time.Hour), Value: 101},
+	)
+
+	clock := smartmetest.NewClock(start.Add(24 * time.Hour))
+	client, _ := srv.Client(smartme.WithOfflineMode(), smartme.WithClock(clock))
+	ctx := context.Background()
+	ignore := func(smartme.Value) error { return nil }
+
+	if _, err := client.GetValuesInPastMultiple(ctx, id, start, end); err != nil {
+		t.Fatalf("GetValuesInPastMultiple failed: %v", err)
+	}
+	srv.FailEndpoint("/api", http.StatusServiceUnavailable)
+
+	var (
+		st       smartme.Staleness
+		streamed int
+	)
+	err := client.StreamValuesInPastMultiple(smartme.ContextWithStaleness(ctx, &st), id, start, end, func(smartme.Value) error {
+		streamed++
+		return nil
+	})
+	if err != nil || streamed != 2 {
+		t.Fatalf("StreamValuesInPastMultiple while offline = %d values, %v, want the 2 stored", streamed, err)
+	}
+	if !st.Stale {
+		t.Error("streamed response is not marked stale")
+	}
+	if _, ok := client.OfflineSince(); !ok {
+		t.Error("client is not offline after a 503")
+	}
+
+	// Other ranges have no stored response and the API is not tried
+	// within the probe interval.
+	requests := srv.Requests("")
+	err = client.StreamValuesInPastMultiple(ctx, id, start, start.Add(time.Hour), ignore)
+	if !errors.Is(err, smartme.ErrOffline) {
+		t.Errorf("StreamValuesInPastMultiple without stored response: err = %v, want ErrOffline", err)
+	}
+	if n := srv.Requests(""); n != requests {
+		t.Errorf("%d requests sent within the probe interval", n-requests)
+	}
+
+	srv.FailEndpoint("/api", 0)
+	clock.Advance(time.Minute)
+	if err := client.StreamValuesInPastMultiple(ctx, id, start, start.Add(time.Hour), ignore); err != nil {
+		t.Fatalf("StreamValuesInPastMultiple after the outage failed: %v", err)
+	}
+	if _, ok := client.OfflineSince(); ok {
+		t.Error("client is still offline after the outage")
+	}
+}
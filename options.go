@@ -2,36 +2,188 @@
 package smartme
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
 // Option is a functional option for configuring the client.
-type Option func(*Client)
+// An option returns an error if its arguments are invalid; NewClient
+// reports all such errors at once.
+type Option func(*Client) error
 
 // WithHTTPClient sets a custom http.Client.
 func WithHTTPClient(httpClient *http.Client) Option {
-	return func(c *Client) {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("http client must not be nil")
+		}
 		c.httpClient = httpClient
+		return nil
 	}
 }
 
 // WithBaseURL sets a custom base URL (useful for testing).
+// The URL must be absolute with an http or https scheme. A missing
+// trailing slash is added, so API paths are resolved below it.
 func WithBaseURL(baseURL string) Option {
-	return func(c *Client) {
-		if u, err := url.Parse(baseURL); err == nil {
-			c.baseURL = u
+	return func(c *Client) error {
+		u, err := parseBaseURL(baseURL)
+		if err != nil {
+			return err
 		}
+		c.baseURL = u
+		return nil
+	}
+}
+
+// parseBaseURL validates a base URL for WithBaseURL.
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
 	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: host is missing", baseURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
 }
 
 // WithTimeout sets a custom timeout for the HTTP client.
 func WithTimeout(timeout time.Duration) Option {
-	return func(c *Client) {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive, got %s", timeout)
+		}
 		if c.httpClient == nil {
 			c.httpClient = &http.Client{}
 		}
 		c.httpClient.Timeout = timeout
+		return nil
+	}
+}
+
+// WithoutCompression disables gzip compression of responses.
+// By default the client asks for gzip encoded responses and decompresses
+// them itself, also when a custom transport is used.
+// Requests then ask for uncompressed responses with
+// "Accept-Encoding: identity".
+func WithoutCompression() Option {
+	return func(c *Client) error {
+		c.disableCompression = true
+		return nil
+	}
+}
+
+// WithTransportTuning tunes the connection pool of the default transport for
+// clients that fetch many devices concurrently. maxIdleConns limits the idle
+// connections kept open, maxConnsPerHost limits all connections to the API
+// host and idleTimeout closes idle connections after that duration.
+// Values <= 0 keep the defaults of http.DefaultTransport.
+// The tuning has no effect if a client with its own transport is set with
+// WithHTTPClient.
+func WithTransportTuning(maxIdleConns, maxConnsPerHost int, idleTimeout time.Duration) Option {
+	return func(c *Client) error {
+		if c.transport == nil {
+			c.transport = &transportConfig{}
+		}
+		c.transport.maxIdleConns = maxIdleConns
+		c.transport.maxConnsPerHost = maxConnsPerHost
+		c.transport.idleTimeout = idleTimeout
+		return nil
+	}
+}
+
+// WithHTTP2 enables or disables HTTP/2 on the default transport.
+// HTTP/2 is attempted by default; disabling it forces HTTP/1.1, which opens
+// one connection per concurrent request instead of multiplexing.
+// Like WithTransportTuning, it has no effect on a custom transport.
+func WithHTTP2(enabled bool) Option {
+	return func(c *Client) error {
+		if c.transport == nil {
+			c.transport = &transportConfig{}
+		}
+		c.transport.disableHTTP2 = !enabled
+		return nil
+	}
+}
+
+// WithCache enables a response cache for historical values.
+// Only queries for dates that lie more than a day in the past are cached,
+// since that data no longer changes. Entries expire after ttl.
+// Use NewDiskCache for a cache that survives process restarts.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(c *Client) error {
+		if cache == nil {
+			return errors.New("cache must not be nil")
+		}
+		c.cache = cache
+		c.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithClock sets the clock used wherever the client needs the current time,
+// for example to decide whether history may be cached and when cache
+// entries expire. It is meant for tests; see smartmetest.Clock.
+func WithClock(clock Clock) Option {
+	return func(c *Client) error {
+		if clock == nil {
+			return errors.New("clock must not be nil")
+		}
+		c.clock = clock
+		return nil
+	}
+}
+
+// WithLocation sets the time zone in which dates are sent to the API.
+// Dates are always sent as RFC3339 with an offset, so the API sees the same
+// instant; the location decides which offset and thereby which local day
+// the API uses for its day boundaries. Use it together with DayRange and
+// MonthRange. By default the location of each date is kept.
+func WithLocation(loc *time.Location) Option {
+	return func(c *Client) error {
+		if loc == nil {
+			return errors.New("location must not be nil")
+		}
+		c.location = loc
+		return nil
+	}
+}
+
+// WithReadOnly makes every call that would change a device, such as
+// switching a relay, fail with ErrReadOnlyClient before anything is sent.
+// Use it for dashboards and exporters that must never act on devices.
+func WithReadOnly() Option {
+	return func(c *Client) error {
+		c.readOnly = true
+		return nil
+	}
+}
+
+// WithAcceptLanguage sends an Accept-Language header like "de-CH" or
+// "fr, en;q=0.8" with every request, so localized strings in responses,
+// such as error messages, come back in that language. An Accept-Language
+// header set with WithHeader takes precedence for that call.
+func WithAcceptLanguage(lang string) Option {
+	return func(c *Client) error {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			return errors.New("language must not be empty")
+		}
+		if strings.ContainsAny(lang, "\r\n") {
+			return fmt.Errorf("invalid language %q", lang)
+		}
+		c.acceptLanguage = lang
+		return nil
 	}
 }
@@ -0,0 +1,93 @@
+package smartme_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestNewClient_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		opts     []smartme.Option
+		wantErr  []string
+	}{
+		{"valid", "user", "pass", []smartme.Option{smartme.WithBaseURL("https://example.com/api"), smartme.WithTimeout(time.Second)}, nil},
+		{"empty username", "", "pass", nil, []string{"username must not be empty"}},
+		{"empty password", "user", "", nil, []string{"password must not be empty"}},
+		{"relative base URL", "user", "pass", []smartme.Option{smartme.WithBaseURL("api.smart-me.com")}, []string{"scheme must be http or https"}},
+		{"unparsable base URL", "user", "pass", []smartme.Option{smartme.WithBaseURL("http://[::1")}, []string{"invalid base URL"}},
+		{"missing host", "user", "pass", []smartme.Option{smartme.WithBaseURL("https:///api")}, []string{"host is missing"}},
+		{"nil http client", "user", "pass", []smartme.Option{smartme.WithHTTPClient(nil)}, []string{"http client must not be nil"}},
+		{"nil location", "user", "pass", []smartme.Option{smartme.WithLocation(nil)}, []string{"location must not be nil"}},
+		{"empty language", "user", "pass", []smartme.Option{smartme.WithAcceptLanguage(" ")}, []string{"language must not be empty"}},
+		{"nil clock", "user", "pass", []smartme.Option{smartme.WithClock(nil)}, []string{"clock must not be nil"}},
+		{"empty API version", "user", "pass", []smartme.Option{smartme.WithAPIVersion("")}, []string{"API version must not be empty"}},
+		{"invalid API version", "user", "pass", []smartme.Option{smartme.WithAPIVersion("/v2")}, []string{"invalid API version"}},
+		{"invalid endpoint", "user", "pass", []smartme.Option{smartme.WithEndpointVersion("Values/x", "v2")}, []string{"invalid endpoint"}},
+		{"nil codec", "user", "pass", []smartme.Option{smartme.WithCodec(nil)}, []string{"codec must not be nil"}},
+		{"nil bounds", "user", "pass", []smartme.Option{smartme.WithBounds(nil)}, []string{"bounds must not be nil"}},
+		{"nil audit writer", "user", "pass", []smartme.Option{smartme.WithAuditWriter(nil)}, []string{"audit writer must not be nil"}},
+		{"zero concurrency", "user", "pass", []smartme.Option{smartme.WithConcurrency(0)}, []string{"concurrency must be positive"}},
+		{"nil calibration", "user", "pass", []smartme.Option{smartme.WithCalibration(nil)}, []string{"calibration must not be nil"}},
+		{"nil decode hook", "user", "pass", []smartme.Option{smartme.WithDecodeHook(nil)}, []string{"decode hook must not be nil"}},
+		{
+			"all errors reported", "user", "pass",
+			[]smartme.Option{smartme.WithBaseURL("ftp://example.com"), smartme.WithTimeout(0)},
+			[]string{"scheme must be http or https", "timeout must be positive"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := smartme.NewClient(tt.username, tt.password, tt.opts...)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("NewClient returned an unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("NewClient should have returned an error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithAcceptLanguage(t *testing.T) {
+	mux := http.NewServeMux()
+	var got []string
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Accept-Language"))
+		w.Write([]byte("[]"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client, err := smartme.NewClient("test-user", "test-pass", smartme.WithBaseURL(srv.URL), smartme.WithAcceptLanguage("de-CH"))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := client.GetDevices(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetDevices(ctx, smartme.WithHeader("Accept-Language", "fr")); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "de-CH" || got[1] != "fr" {
+		t.Errorf("Accept-Language = %q, want [de-CH fr]", got)
+	}
+}
@@ -0,0 +1,55 @@
+package smartme
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize is the largest buffer returned to the pool.
+// Larger buffers (e.g. from a month of per-minute history) are left to the
+// garbage collector so a single big response does not pin memory forever.
+const maxPooledBufferSize = 1 << 20
+
+// Approximate encoded size of a single element, used to size slices before
+// decoding. They are on the small side, so the slice usually does not have
+// to grow while decoding.
+const (
+	approxValueSize  = 48  // {"date":"2025-01-01T00:00:00Z","value":1234.5},
+	approxDeviceSize = 900 // a device with most fields set
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// preallocate sizes the slice v points to based on the response length,
+// so decoding a large array does not repeatedly grow it.
+func preallocate(v interface{}, contentLength int64) {
+	if contentLength <= 0 {
+		return
+	}
+	switch s := v.(type) {
+	case *[]Value:
+		if *s == nil {
+			*s = make([]Value, 0, contentLength/approxValueSize)
+		}
+	case *[]Device:
+		if *s == nil {
+			*s = make([]Device, 0, contentLength/approxDeviceSize)
+		}
+	}
+}
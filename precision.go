@@ -0,0 +1,138 @@
+package smartme
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// RoundingMode decides how values are rounded to a number of decimals.
+type RoundingMode int
+
+const (
+	// RoundHalfAwayFromZero rounds to the nearest value, halves away from zero.
+	RoundHalfAwayFromZero RoundingMode = iota
+	// RoundTowardZero truncates the digits beyond the precision, as meters
+	// do with their registers.
+	RoundTowardZero
+)
+
+// maxDecimals is the highest precision accepted by WithPrecision.
+const maxDecimals = 9
+
+// rounding is the rounding policy configured with WithPrecision.
+type rounding struct {
+	decimals int
+	mode     RoundingMode
+}
+
+// WithPrecision rounds all measurements returned by the client to the given
+// number of decimals, e.g. 3 for Wh resolution of a kWh counter. This removes
+// float artifacts like 1234.5600000000002 from responses before they end up
+// in reports. It applies to Value, ObisValue and the measurements of Device.
+func WithPrecision(decimals int, mode RoundingMode) Option {
+	return func(c *Client) error {
+		if decimals < 0 || decimals > maxDecimals {
+			return fmt.Errorf("precision must be between 0 and %d decimals, got %d", maxDecimals, decimals)
+		}
+		if mode != RoundHalfAwayFromZero && mode != RoundTowardZero {
+			return fmt.Errorf("invalid rounding mode %d", mode)
+		}
+		c.rounding = &rounding{decimals: decimals, mode: mode}
+		return nil
+	}
+}
+
+// Round rounds v to the given number of decimals. The result is the float
+// closest to the decimal number, so it prints without artifacts.
+func Round(v float64, decimals int, mode RoundingMode) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	p := math.Pow10(decimals)
+	n := math.Round(v * p)
+	// v*p may be off by one ulp, so truncate from the rounded value.
+	if mode == RoundTowardZero && math.Abs(n/p) > math.Abs(v) {
+		n -= math.Copysign(1, n)
+	}
+	return n / p
+}
+
+// Milli is a fixed-point number in thousandths of a unit, e.g. Wh for a
+// reading in kWh. Sums of Milli values are exact, unlike sums of floats.
+type Milli int64
+
+// ToMilli converts v to thousandths, rounding halves away from zero.
+func ToMilli(v float64) Milli {
+	return Milli(math.Round(v * 1000))
+}
+
+// Float64 returns m in units.
+func (m Milli) Float64() float64 {
+	return float64(m) / 1000
+}
+
+// String formats m in units with three decimals, e.g. "1234.560".
+func (m Milli) String() string {
+	return strconv.FormatFloat(m.Float64(), 'f', 3, 64)
+}
+
+// Milli returns the value in thousandths of its unit.
+func (v Value) Milli() Milli {
+	return ToMilli(v.Value)
+}
+
+// Milli returns the value in thousandths of its unit.
+func (o ObisValue) Milli() Milli {
+	return ToMilli(o.Value)
+}
+
+// round applies the rounding policy to a decoded response.
+func (r *rounding) round(v interface{}) {
+	if r == nil {
+		return
+	}
+	switch v := v.(type) {
+	case *Value:
+		v.Value = r.float(v.Value)
+	case *[]Value:
+		for i := range *v {
+			(*v)[i].Value = r.float((*v)[i].Value)
+		}
+	case *DeviceValues:
+		r.obisValues(v.Values)
+	case *[]DeviceValues:
+		for i := range *v {
+			r.obisValues((*v)[i].Values)
+		}
+	case *Device:
+		r.device(v)
+	case *[]Device:
+		for i := range *v {
+			r.device(&(*v)[i])
+		}
+	}
+}
+
+func (r *rounding) float(v float64) float64 {
+	return Round(v, r.decimals, r.mode)
+}
+
+func (r *rounding) obisValues(values []ObisValue) {
+	for i := range values {
+		values[i].Value = r.float(values[i].Value)
+	}
+}
+
+// device rounds all *float64 fields of d.
+func (r *rounding) device(d *Device) {
+	rv := reflect.ValueOf(d).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Field(i)
+		if f.Kind() != reflect.Ptr || f.IsNil() || f.Elem().Kind() != reflect.Float64 {
+			continue
+		}
+		f.Elem().SetFloat(r.float(f.Elem().Float()))
+	}
+}
@@ -0,0 +1,102 @@
+package smartme_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		v        float64
+		decimals int
+		mode     smartme.RoundingMode
+		want     float64
+	}{
+		{1234.5600000000002, 2, smartme.RoundHalfAwayFromZero, 1234.56},
+		{1234.5600000000002, 3, smartme.RoundTowardZero, 1234.56},
+		{1234.567, 2, smartme.RoundHalfAwayFromZero, 1234.57},
+		{1234.567, 2, smartme.RoundTowardZero, 1234.56},
+		{-1.237, 2, smartme.RoundTowardZero, -1.23},
+		{-1.235, 2, smartme.RoundHalfAwayFromZero, -1.24},
+		{0.1 + 0.2, 3, smartme.RoundTowardZero, 0.3},
+		{2.5, 0, smartme.RoundHalfAwayFromZero, 3},
+	}
+	for _, tt := range tests {
+		if got := smartme.Round(tt.v, tt.decimals, tt.mode); got != tt.want {
+			t.Errorf("Round(%v, %d, %d) = %v, want %v", tt.v, tt.decimals, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestMilli(t *testing.T) {
+	var sum smartme.Milli
+	for i := 0; i < 10; i++ {
+		sum += smartme.ToMilli(0.1)
+	}
+	if sum != 1000 || sum.String() != "1.000" {
+		t.Errorf("sum = %d (%s), want 1000 (1.000)", sum, sum)
+	}
+	if got := (smartme.Value{Value: 1234.5600000000002}).Milli().String(); got != "1234.560" {
+		t.Errorf("Milli = %s, want 1234.560", got)
+	}
+}
+
+func TestWithPrecision(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/Values/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"deviceId":"dev-1","date":"2025-01-01T00:00:00Z","values":[{"obis":"1-0:1.8.0*255","value":1234.5678}]}`)
+	})
+	mux.HandleFunc("/api/Devices/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"dev-1","counterReading":1234.5600000000002,"activePower":0.1239}`)
+	})
+	mux.HandleFunc("/api/ValuesInPastMultiple/dev-1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"date":"2025-01-01T00:00:00Z","value":1.0009}]`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client, err := smartme.NewClient("test-user", "test-pass",
+		smartme.WithBaseURL(srv.URL), smartme.WithPrecision(3, smartme.RoundTowardZero))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	values, err := client.GetValues(ctx, "dev-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := values.Values[0].Value; got != 1234.567 {
+		t.Errorf("obis value = %v, want 1234.567", got)
+	}
+
+	device, err := client.GetDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *device.CounterReading != 1234.56 || *device.ActivePower != 0.123 {
+		t.Errorf("device = %v, %v, want 1234.56, 0.123", *device.CounterReading, *device.ActivePower)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = client.StreamValuesInPastMultiple(ctx, "dev-1", start, start.Add(time.Hour), func(v smartme.Value) error {
+		if v.Value != 1 {
+			t.Errorf("streamed value = %v, want 1", v.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := smartme.NewClient("u", "p", smartme.WithPrecision(10, smartme.RoundTowardZero)); err == nil {
+		t.Error("WithPrecision(10) should be rejected")
+	}
+}
@@ -0,0 +1,223 @@
+package smartme
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Price is the energy price of a time slot, e.g. one hour of a day-ahead
+// market, in currency units per kWh.
+type Price struct {
+	Start, End time.Time
+	Value      float64
+}
+
+// PriceSource provides dynamic electricity prices, e.g. EPEX spot prices
+// or the prices of Tibber or aWATTar. Implementations return the slots
+// that overlap [from, to) sorted by start, and omit slots whose prices are
+// not published yet.
+type PriceSource interface {
+	Prices(ctx context.Context, from, to time.Time) ([]Price, error)
+}
+
+// StaticPrices is a PriceSource with a fixed list of prices, e.g. for
+// tests or time-of-use tariffs.
+type StaticPrices []Price
+
+// Prices returns the prices overlapping [from, to).
+func (p StaticPrices) Prices(ctx context.Context, from, to time.Time) ([]Price, error) {
+	var out []Price
+	for _, price := range p {
+		if price.End.After(from) && price.Start.Before(to) {
+			out = append(out, price)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+// PriceWindow is a period of consecutive price slots.
+type PriceWindow struct {
+	Start, End time.Time
+	// Average is the time-weighted average price of the slots.
+	Average float64
+}
+
+// Contains reports whether t lies in [Start, End).
+func (w PriceWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// CheapestHours selects the cheapest slots of prices until they add up to
+// n hours and returns them as windows sorted by start; slots that follow
+// each other are merged. Of equally priced slots the earlier is chosen.
+// The result is shorter if prices do not cover n hours.
+func CheapestHours(prices []Price, n int) []PriceWindow {
+	order := make([]int, len(prices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := prices[order[i]], prices[order[j]]
+		if a.Value != b.Value {
+			return a.Value < b.Value
+		}
+		return a.Start.Before(b.Start)
+	})
+
+	var picked []Price
+	var total time.Duration
+	for _, i := range order {
+		if total >= time.Duration(n)*time.Hour {
+			break
+		}
+		picked = append(picked, prices[i])
+		total += prices[i].End.Sub(prices[i].Start)
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Start.Before(picked[j].Start) })
+
+	var windows []PriceWindow
+	for i := 0; i < len(picked); {
+		j := i + 1
+		for j < len(picked) && picked[j].Start.Equal(picked[j-1].End) {
+			j++
+		}
+		windows = append(windows, window(picked[i:j]))
+		i = j
+	}
+	return windows
+}
+
+// CheapestWindow returns the consecutive slots of prices that span at
+// least n hours with the lowest average price, e.g. to run a dishwasher
+// or charge a car in one go. ok is false if no consecutive slots span n
+// hours.
+func CheapestWindow(prices []Price, n int) (w PriceWindow, ok bool) {
+	sorted := append([]Price(nil), prices...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	for i := range sorted {
+		var span time.Duration
+		for j := i; j < len(sorted); j++ {
+			if j > i && !sorted[j].Start.Equal(sorted[j-1].End) {
+				break
+			}
+			span += sorted[j].End.Sub(sorted[j].Start)
+			if span < time.Duration(n)*time.Hour {
+				continue
+			}
+			if c := window(sorted[i : j+1]); !ok || c.Average < w.Average {
+				w, ok = c, true
+			}
+			break
+		}
+	}
+	return w, ok
+}
+
+// window returns the window of consecutive slots.
+func window(slots []Price) PriceWindow {
+	w := PriceWindow{Start: slots[0].Start, End: slots[len(slots)-1].End}
+	var sum, hours float64
+	for _, p := range slots {
+		h := p.End.Sub(p.Start).Hours()
+		sum += p.Value * h
+		hours += h
+	}
+	if hours > 0 {
+		w.Average = sum / hours
+	}
+	return w
+}
+
+// PricePlan selects the cheapest hours of every day from a PriceSource and
+// caches them, so schedules and controllers can ask whether a time is
+// cheap without fetching the prices every time:
+//
+//	plan := &smartme.PricePlan{Source: tibber, Hours: 4}
+//	cheap, err := plan.Cheap(ctx, time.Now())
+type PricePlan struct {
+	Source PriceSource
+	// Hours is the number of cheap hours per day.
+	Hours int
+	// Contiguous selects one block of consecutive hours with
+	// CheapestWindow instead of the cheapest hours with CheapestHours.
+	Contiguous bool
+	// Location defines the days. A nil Location uses the location of the
+	// times passed to the methods.
+	Location *time.Location
+
+	mu   sync.Mutex
+	days map[int64][]PriceWindow // keyed by the Unix time of the day start
+}
+
+// Windows returns the cheap windows of the day containing t. Without
+// published prices for the day it returns no windows and no error. Days
+// whose prices are complete are cached.
+func (p *PricePlan) Windows(ctx context.Context, t time.Time) ([]PriceWindow, error) {
+	start, end := DayRange(t, p.Location)
+	p.mu.Lock()
+	windows, ok := p.days[start.Unix()]
+	p.mu.Unlock()
+	if ok {
+		return windows, nil
+	}
+
+	fetched, err := p.Source.Prices(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	// Slots crossing midnight are clipped to the day.
+	prices := make([]Price, 0, len(fetched))
+	var covered time.Duration
+	for _, price := range fetched {
+		if price.Start.Before(start) {
+			price.Start = start
+		}
+		if price.End.After(end) {
+			price.End = end
+		}
+		if price.End.After(price.Start) {
+			prices = append(prices, price)
+			covered += price.End.Sub(price.Start)
+		}
+	}
+	if p.Contiguous {
+		if w, ok := CheapestWindow(prices, p.Hours); ok {
+			windows = []PriceWindow{w}
+		}
+	} else {
+		windows = CheapestHours(prices, p.Hours)
+	}
+
+	if covered >= end.Sub(start) {
+		p.mu.Lock()
+		if p.days == nil {
+			p.days = make(map[int64][]PriceWindow)
+		}
+		for day := range p.days {
+			if day < start.AddDate(0, 0, -2).Unix() {
+				delete(p.days, day)
+			}
+		}
+		p.days[start.Unix()] = windows
+		p.mu.Unlock()
+	}
+	return windows, nil
+}
+
+// Cheap reports whether t lies in a cheap window of its day.
+func (p *PricePlan) Cheap(ctx context.Context, t time.Time) (bool, error) {
+	windows, err := p.Windows(ctx, t)
+	if err != nil {
+		return false, err
+	}
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
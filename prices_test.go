@@ -0,0 +1,100 @@
+package smartme_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// hourly returns prices for consecutive hours from start.
+func hourly(start time.Time, values ...float64) smartme.StaticPrices {
+	prices := make(smartme.StaticPrices, len(values))
+	for i, v := range values {
+		from := start.Add(time.Duration(i) * time.Hour)
+		prices[i] = smartme.Price{Start: from, End: from.Add(time.Hour), Value: v}
+	}
+	return prices
+}
+
+func TestCheapestHours(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	prices := hourly(day, 30, 10, 12, 40, 10, 50)
+
+	windows := smartme.CheapestHours(prices, 3)
+	if len(windows) != 2 {
+		t.Fatalf("got %d windows, want 2: %+v", len(windows), windows)
+	}
+	if !windows[0].Start.Equal(day.Add(time.Hour)) || !windows[0].End.Equal(day.Add(3*time.Hour)) || windows[0].Average != 11 {
+		t.Errorf("first window = %+v, want 01:00-03:00 at 11", windows[0])
+	}
+	if !windows[1].Start.Equal(day.Add(4*time.Hour)) || !windows[1].End.Equal(day.Add(5*time.Hour)) {
+		t.Errorf("second window = %+v, want 04:00-05:00", windows[1])
+	}
+	if got := smartme.CheapestHours(prices, 10); len(got) != 1 || !got[0].End.Equal(day.Add(6*time.Hour)) {
+		t.Errorf("more hours than prices = %+v, want the whole day", got)
+	}
+
+	w, ok := smartme.CheapestWindow(prices, 2)
+	if !ok || !w.Start.Equal(day.Add(time.Hour)) || w.Average != 11 {
+		t.Errorf("CheapestWindow(2) = %+v, %v, want 01:00 at 11", w, ok)
+	}
+	if _, ok := smartme.CheapestWindow(append(hourly(day, 1), hourly(day.Add(2*time.Hour), 1)...), 2); ok {
+		t.Error("CheapestWindow should not bridge a gap")
+	}
+}
+
+type countingSource struct {
+	smartme.StaticPrices
+	calls int
+}
+
+func (s *countingSource) Prices(ctx context.Context, from, to time.Time) ([]smartme.Price, error) {
+	s.calls++
+	return s.StaticPrices.Prices(ctx, from, to)
+}
+
+func TestPricePlan(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := make([]float64, 24)
+	for i := range values {
+		values[i] = float64(100 - i)
+	}
+	// Only 1 January is published.
+	source := &countingSource{StaticPrices: hourly(day, values...)}
+	plan := &smartme.PricePlan{Source: source, Hours: 2}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		at   time.Time
+		want bool
+	}{
+		{day.Add(21 * time.Hour), false},
+		{day.Add(22 * time.Hour), true},
+		{day.Add(23*time.Hour + 59*time.Minute), true},
+	} {
+		if got, err := plan.Cheap(ctx, tt.at); err != nil || got != tt.want {
+			t.Errorf("Cheap(%s) = %v, %v, want %v", tt.at, got, err, tt.want)
+		}
+	}
+	if source.calls != 1 {
+		t.Errorf("complete day fetched %d times, want 1", source.calls)
+	}
+
+	// The next day is not published yet and asked for again.
+	for i := 0; i < 2; i++ {
+		if windows, err := plan.Windows(ctx, day.AddDate(0, 0, 1)); err != nil || len(windows) != 0 {
+			t.Errorf("Windows of the next day = %v, %v, want none", windows, err)
+		}
+	}
+	if source.calls != 3 {
+		t.Errorf("source called %d times, want 3", source.calls)
+	}
+
+	plan = &smartme.PricePlan{Source: hourly(day, 5, 1, 9, 1, 1, 9), Hours: 2, Contiguous: true}
+	windows, _ := plan.Windows(ctx, day)
+	if len(windows) != 1 || !windows[0].Start.Equal(day.Add(3*time.Hour)) {
+		t.Errorf("contiguous windows = %+v, want 03:00-05:00", windows)
+	}
+}
@@ -0,0 +1,265 @@
+// Package provisioning rolls out devices from a declarative list. A plan
+// compares the desired devices with the account, like this:
+//
+//	specs, err := provisioning.Load(file)
+//	plan, err := provisioning.NewPlan(ctx, client, specs)
+//	plan.Write(os.Stdout)
+//	err = plan.Apply(ctx, client, os.Stdout)
+//
+// Devices are matched by ID if the spec has one, otherwise by name. Devices
+// of the account that are not in the list are left alone.
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Spec describes a desired device.
+type Spec struct {
+	// ID is optional; without it the device is matched by Name.
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// EnergyType is required; a spec without it would reset the type of
+	// the device.
+	EnergyType smartme.MeterEnergyType `json:"energyType"`
+	SubType    smartme.MeterSubType    `json:"subType,omitempty"`
+	Serial     int64                   `json:"serial,omitempty"`
+}
+
+// Load reads a JSON array of specs and validates it.
+func Load(r io.Reader) ([]Spec, error) {
+	var specs []Spec
+	if err := json.NewDecoder(r).Decode(&specs); err != nil {
+		return nil, fmt.Errorf("invalid device list: %w", err)
+	}
+	if err := validate(specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+func validate(specs []Spec) error {
+	var errs []error
+	names := make(map[string]bool)
+	ids := make(map[string]string)
+	for i, s := range specs {
+		switch {
+		case s.Name == "":
+			errs = append(errs, fmt.Errorf("device %d: name must not be empty", i+1))
+		case names[s.Name]:
+			errs = append(errs, fmt.Errorf("device %q is listed twice", s.Name))
+		case s.EnergyType == smartme.MeterTypeUnknown:
+			errs = append(errs, fmt.Errorf("device %q: energyType must be set", s.Name))
+		}
+		names[s.Name] = true
+		if s.ID == "" {
+			continue
+		}
+		if other, ok := ids[s.ID]; ok {
+			errs = append(errs, fmt.Errorf("devices %q and %q have the same ID %s", other, s.Name, s.ID))
+			continue
+		}
+		ids[s.ID] = s.Name
+	}
+	return errors.Join(errs...)
+}
+
+// Action is what a plan does with a device.
+type Action int
+
+const (
+	// Keep leaves a device that already matches its spec alone.
+	Keep Action = iota
+	// Create creates a missing device.
+	Create
+	// Update changes an existing device.
+	Update
+)
+
+func (a Action) String() string {
+	switch a {
+	case Create:
+		return "create"
+	case Update:
+		return "update"
+	default:
+		return "keep"
+	}
+}
+
+// Change is the planned change of one device.
+type Change struct {
+	Action Action
+	Spec   Spec
+	// Current is the existing device, nil for Create.
+	Current *smartme.Device
+	// Diff lists the changed fields of an update, e.g. "serial: 1 -> 2".
+	Diff []string
+}
+
+// Plan is the ordered list of changes to reach the desired devices.
+type Plan struct {
+	Changes []Change
+}
+
+// NewPlan compares the specs with the devices of the account.
+func NewPlan(ctx context.Context, api smartme.API, specs []Spec) (*Plan, error) {
+	if err := validate(specs); err != nil {
+		return nil, err
+	}
+	devices, err := api.GetDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*smartme.Device)
+	byName := make(map[string][]*smartme.Device)
+	for i := range devices {
+		d := &devices[i]
+		if d.Id != nil {
+			byID[*d.Id] = d
+		}
+		if d.Name != nil {
+			byName[*d.Name] = append(byName[*d.Name], d)
+		}
+	}
+
+	plan := &Plan{Changes: make([]Change, 0, len(specs))}
+	for _, spec := range specs {
+		var current *smartme.Device
+		if spec.ID != "" {
+			current = byID[spec.ID]
+			if current == nil {
+				return nil, fmt.Errorf("device %q: no device with ID %s", spec.Name, spec.ID)
+			}
+		} else {
+			matches := byName[spec.Name]
+			if len(matches) > 1 {
+				return nil, fmt.Errorf("device %q: %d devices have this name, set the ID", spec.Name, len(matches))
+			}
+			if len(matches) == 1 {
+				current = matches[0]
+			}
+		}
+
+		change := Change{Spec: spec, Current: current}
+		switch {
+		case current == nil:
+			change.Action = Create
+		default:
+			if change.Diff = diff(spec, current); len(change.Diff) > 0 {
+				change.Action = Update
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
+
+// diff returns the fields of d that differ from the spec.
+func diff(spec Spec, d *smartme.Device) []string {
+	var changes []string
+	if d.Name == nil || *d.Name != spec.Name {
+		changes = append(changes, fmt.Sprintf("name: %q -> %q", deref(d.Name), spec.Name))
+	}
+	if d.DeviceEnergyType == nil || *d.DeviceEnergyType != spec.EnergyType {
+		changes = append(changes, fmt.Sprintf("energyType: %d -> %d", deref(d.DeviceEnergyType), spec.EnergyType))
+	}
+	if spec.SubType != 0 && (d.MeterSubType == nil || *d.MeterSubType != spec.SubType) {
+		changes = append(changes, fmt.Sprintf("subType: %d -> %d", deref(d.MeterSubType), spec.SubType))
+	}
+	if spec.Serial != 0 && (d.Serial == nil || *d.Serial != spec.Serial) {
+		changes = append(changes, fmt.Sprintf("serial: %d -> %d", deref(d.Serial), spec.Serial))
+	}
+	return changes
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// Count returns the number of changes with the given action.
+func (p *Plan) Count(a Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == a {
+			n++
+		}
+	}
+	return n
+}
+
+// Write prints the plan, one line per device that is created or updated.
+func (p *Plan) Write(w io.Writer) error {
+	for _, c := range p.Changes {
+		var err error
+		switch c.Action {
+		case Create:
+			_, err = fmt.Fprintf(w, "+ %s\n", c.Spec.Name)
+		case Update:
+			_, err = fmt.Fprintf(w, "~ %s (%s)\n", c.Spec.Name, deref(c.Current.Id))
+			for _, d := range c.Diff {
+				if err == nil {
+					_, err = fmt.Fprintf(w, "    %s\n", d)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "Plan: %d to create, %d to update, %d unchanged.\n", p.Count(Create), p.Count(Update), p.Count(Keep))
+	return err
+}
+
+// Apply creates and updates the devices in the order of the plan and logs
+// each change to log, which may be nil. It stops at the first error; the
+// devices changed before stay changed, so running a new plan continues
+// where it stopped.
+func (p *Plan) Apply(ctx context.Context, api smartme.API, log io.Writer) error {
+	for _, c := range p.Changes {
+		if c.Action == Keep {
+			continue
+		}
+
+		device := smartme.Device{
+			Name:             &c.Spec.Name,
+			DeviceEnergyType: &c.Spec.EnergyType,
+		}
+		if c.Current != nil {
+			device.Id = c.Current.Id
+		}
+		if c.Spec.SubType != 0 {
+			device.MeterSubType = &c.Spec.SubType
+		}
+		if c.Spec.Serial != 0 {
+			device.Serial = &c.Spec.Serial
+		}
+
+		stored, err := api.CreateOrUpdateDevice(ctx, device)
+		if err != nil {
+			return fmt.Errorf("%s device %q: %w", c.Action, c.Spec.Name, err)
+		}
+		if log != nil {
+			fmt.Fprintf(log, "%s %s (%s)\n", pastTense(c.Action), c.Spec.Name, deref(stored.Id))
+		}
+	}
+	return nil
+}
+
+func pastTense(a Action) string {
+	if a == Create {
+		return "created"
+	}
+	return "updated"
+}
@@ -0,0 +1,89 @@
+package provisioning_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/provisioning"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestPlanAndApply(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("m-1"), Name: ptr("Flat 1"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity), Serial: ptr(int64(100))})
+	srv.AddDevice(smartme.Device{Id: ptr("m-2"), Name: ptr("Flat 2"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity)})
+	srv.AddDevice(smartme.Device{Id: ptr("other"), Name: ptr("Not managed")})
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	specs, err := provisioning.Load(strings.NewReader(`[
+		{"name": "Flat 1", "energyType": 1, "serial": 100},
+		{"name": "Flat 2", "energyType": 1, "serial": 200},
+		{"name": "Water 1", "energyType": 2}
+	]`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	plan, err := provisioning.NewPlan(ctx, client, specs)
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := plan.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := "~ Flat 2 (m-2)\n    serial: 0 -> 200\n+ Water 1\nPlan: 1 to create, 1 to update, 1 unchanged.\n"
+	if out.String() != want {
+		t.Errorf("plan output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	if err := plan.Apply(ctx, client, nil); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// A new plan after applying is empty.
+	plan, err = provisioning.NewPlan(ctx, client, specs)
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+	if n := plan.Count(provisioning.Keep); n != 3 {
+		t.Errorf("%d devices unchanged after apply, want 3: %+v", n, plan.Changes)
+	}
+	devices, _ := client.GetDevices(ctx)
+	if len(devices) != 4 {
+		t.Errorf("%d devices, want 4", len(devices))
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := provisioning.Load(strings.NewReader(`[
+		{"name": "A", "energyType": 1},
+		{"name": "A", "energyType": 1},
+		{"energyType": 1},
+		{"name": "B"},
+		{"id": "x", "name": "C", "energyType": 1},
+		{"id": "x", "name": "D", "energyType": 1}
+	]`))
+	if err == nil {
+		t.Fatal("Load should fail")
+	}
+	for _, want := range []string{
+		`"A" is listed twice`,
+		"device 3: name must not be empty",
+		`device "B": energyType must be set`,
+		`devices "C" and "D" have the same ID x`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package queue
+
+// SetCompactThreshold sets compactThreshold for a test and returns a
+// function that restores it.
+func SetCompactThreshold(n int64) (restore func()) {
+	old := compactThreshold
+	compactThreshold = n
+	return func() { compactThreshold = old }
+}
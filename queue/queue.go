@@ -0,0 +1,476 @@
+// Package queue provides a bounded queue between the sources of meter data,
+// like a Watcher or a history backfill, and slow sinks, like a database.
+// When the sink falls behind, the overflow policy decides whether
+// producers wait, the oldest items are dropped, or items are spooled to
+// disk until the sink catches up.
+//
+//	q, err := queue.New[smartme.Value](queue.Config{Capacity: 10000, Overflow: queue.SpillToDisk, SpillDir: dir})
+//	go func() { for { v, err := q.Get(ctx); ...; writeToSink(v) } }()
+//	q.Put(ctx, value)
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned by Put and Get after Close.
+var ErrClosed = errors.New("queue closed")
+
+// OverflowPolicy decides what Put does when the queue is full.
+type OverflowPolicy int
+
+const (
+	// Block makes Put wait until there is room.
+	Block OverflowPolicy = iota
+	// DropOldest removes the oldest item to make room.
+	DropOldest
+	// SpillToDisk appends items to a file in Config.SpillDir. Memory only
+	// holds the oldest Capacity items. Items are only written to disk on
+	// overflow and on Close, so the items held in memory are lost if the
+	// process crashes.
+	SpillToDisk
+)
+
+// Config configures a Queue.
+type Config struct {
+	// Capacity is the number of items held in memory (default 1024).
+	Capacity int
+	Overflow OverflowPolicy
+	// SpillDir is the directory of the spool file, required for
+	// SpillToDisk. Items spooled by an earlier process are delivered first.
+	// Lines of the spool file that cannot be decoded are moved to
+	// queue.jsonl.corrupt in the same directory. The read position is kept
+	// in queue.jsonl.offset, so items moved to memory are not delivered
+	// again after a crash.
+	SpillDir string
+}
+
+const defaultCapacity = 1024
+
+// spoolFile is the name of the spool file in Config.SpillDir.
+const spoolFile = "queue.jsonl"
+
+// corruptSuffix is appended to the spool file name for the file of lines
+// that could not be decoded.
+const corruptSuffix = ".corrupt"
+
+// offsetSuffix is appended to the spool file name for the file holding the
+// read position.
+const offsetSuffix = ".offset"
+
+// compactThreshold is the number of read bytes after which the read lines
+// are removed from the spool file, so it does not grow without bound
+// under a sustained backlog.
+var compactThreshold int64 = 1 << 20
+
+// Queue is a bounded FIFO queue. It is safe for concurrent use.
+type Queue[T any] struct {
+	capacity int
+	overflow OverflowPolicy
+
+	mu      sync.Mutex
+	items   []T
+	dropped int64
+	closed  bool
+	// changed is closed and replaced whenever items are added or removed.
+	changed chan struct{}
+	spool   *spool
+}
+
+// New creates a queue. With SpillToDisk the spool file is opened (or
+// created) in cfg.SpillDir.
+func New[T any](cfg Config) (*Queue[T], error) {
+	if cfg.Capacity < 0 {
+		return nil, fmt.Errorf("capacity must not be negative, got %d", cfg.Capacity)
+	}
+	if cfg.Capacity == 0 {
+		cfg.Capacity = defaultCapacity
+	}
+	q := &Queue[T]{capacity: cfg.Capacity, overflow: cfg.Overflow, changed: make(chan struct{})}
+
+	switch cfg.Overflow {
+	case Block, DropOldest:
+	case SpillToDisk:
+		if cfg.SpillDir == "" {
+			return nil, errors.New("SpillDir is required for SpillToDisk")
+		}
+		s, err := openSpool(filepath.Join(cfg.SpillDir, spoolFile))
+		if err != nil {
+			return nil, err
+		}
+		q.spool = s
+		if err := q.refill(); err != nil {
+			s.f.Close()
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid overflow policy %d", cfg.Overflow)
+	}
+	return q, nil
+}
+
+// Put adds an item. With Block it waits for room until ctx is done.
+func (q *Queue[T]) Put(ctx context.Context, item T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.closed {
+			return ErrClosed
+		}
+		if q.spool != nil && q.spool.count > 0 {
+			// Older items are on disk, so this one goes there too.
+			return q.spillLocked(item)
+		}
+		if len(q.items) < q.capacity {
+			q.items = append(q.items, item)
+			q.notifyLocked()
+			return nil
+		}
+
+		switch q.overflow {
+		case DropOldest:
+			var zero T
+			q.items[0] = zero
+			q.items = append(q.items[1:], item)
+			q.dropped++
+			q.notifyLocked()
+			return nil
+		case SpillToDisk:
+			return q.spillLocked(item)
+		}
+
+		if err := q.waitLocked(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// Get removes and returns the oldest item, waiting until ctx is done if
+// the queue is empty. After Close, Get returns the items left in memory
+// and then ErrClosed; with SpillToDisk they stay on disk instead.
+func (q *Queue[T]) Get(ctx context.Context) (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var zero T
+	for {
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items[0] = zero
+			q.items = q.items[1:]
+			if err := q.refill(); err != nil {
+				// Keep the item, so a failing disk does not lose it.
+				q.items = append([]T{item}, q.items...)
+				return zero, err
+			}
+			q.notifyLocked()
+			return item, nil
+		}
+		if q.closed {
+			return zero, ErrClosed
+		}
+		if err := q.waitLocked(ctx); err != nil {
+			return zero, err
+		}
+	}
+}
+
+// Len returns the number of queued items, in memory and on disk.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.items)
+	if q.spool != nil {
+		n += q.spool.count
+	}
+	return n
+}
+
+// Dropped returns the number of items removed by DropOldest.
+func (q *Queue[T]) Dropped() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Close closes the queue and wakes up waiting callers. With SpillToDisk,
+// the items in memory are written to the spool file ahead of the items
+// already there, so a new queue on the same directory delivers all of them
+// in order.
+func (q *Queue[T]) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.notifyLocked()
+
+	if q.spool == nil {
+		return nil
+	}
+	head := make([][]byte, 0, len(q.items))
+	for _, item := range q.items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			q.spool.f.Close()
+			return fmt.Errorf("failed to spill item: %w", err)
+		}
+		head = append(head, data)
+	}
+	q.items = nil
+	err := q.spool.rewrite(head)
+	return errors.Join(err, q.spool.f.Close())
+}
+
+func (q *Queue[T]) spillLocked(item T) error {
+	if err := q.spool.append(item); err != nil {
+		return fmt.Errorf("failed to spill item: %w", err)
+	}
+	q.notifyLocked()
+	return nil
+}
+
+// refill moves items from disk to memory while there is room. Lines that
+// cannot be decoded are quarantined instead of failing every Get.
+func (q *Queue[T]) refill() error {
+	for q.spool != nil && q.spool.count > 0 && len(q.items) < q.capacity {
+		line, err := q.spool.next()
+		if err != nil {
+			return fmt.Errorf("failed to read spooled item: %w", err)
+		}
+		var item T
+		if err := json.Unmarshal(line, &item); err != nil {
+			if err := q.spool.quarantine(line); err != nil {
+				return fmt.Errorf("failed to quarantine spooled item: %w", err)
+			}
+			continue
+		}
+		q.items = append(q.items, item)
+	}
+	if q.spool == nil {
+		return nil
+	}
+	if err := q.spool.commit(); err != nil {
+		return fmt.Errorf("failed to update spool file: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue[T]) notifyLocked() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// waitLocked waits for a change of the queue. q.mu must be held; it is
+// released while waiting.
+func (q *Queue[T]) waitLocked(ctx context.Context) error {
+	changed := q.changed
+	q.mu.Unlock()
+	defer q.mu.Lock()
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// spool is an append-only file of JSON lines with a read position. The
+// position is saved by commit, and the read lines are removed once the
+// position passes compactThreshold.
+type spool struct {
+	path  string
+	f     *os.File
+	r     *bufio.Reader
+	off   int64 // of the first unread line
+	saved int64 // off as last saved
+	count int
+}
+
+func openSpool(path string) (*spool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool file: %w", err)
+	}
+	s := &spool{path: path, f: f}
+
+	// Cut off a partial last line from a crash, so the next append
+	// starts on a line of its own.
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, math.MaxInt64))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read spool file: %w", err)
+	}
+	if n := int64(bytes.LastIndexByte(data, '\n') + 1); n < int64(len(data)) {
+		if err := f.Truncate(n); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to truncate spool file: %w", err)
+		}
+		data = data[:n]
+	}
+
+	off, err := s.loadOffset()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read spool offset: %w", err)
+	}
+	// An offset that is not at the start of a line belongs to an older
+	// file, e.g. if a crash hit after the file was emptied.
+	if off < 0 || off > int64(len(data)) || (off > 0 && data[off-1] != '\n') {
+		off = 0
+	}
+	s.seek(off)
+	s.saved = off
+	s.count = bytes.Count(data[off:], []byte("\n"))
+	return s, nil
+}
+
+// seek makes the reader continue at off.
+func (s *spool) seek(off int64) {
+	s.r = bufio.NewReader(io.NewSectionReader(s.f, off, math.MaxInt64))
+	s.off = off
+}
+
+func (s *spool) append(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// next returns the next unread line.
+func (s *spool) next() ([]byte, error) {
+	// The reader keeps an io.EOF seen before the last append and returns
+	// it once, so read again when count says there is a complete line.
+	line, err := s.r.ReadBytes('\n')
+	if err == io.EOF {
+		var more []byte
+		more, err = s.r.ReadBytes('\n')
+		line = append(line, more...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.off += int64(len(line))
+	s.count--
+	return line, nil
+}
+
+// commit saves the read position. If everything was read, the file is
+// emptied; if the position passed compactThreshold, the read lines are
+// removed.
+func (s *spool) commit() error {
+	switch {
+	case s.off == s.saved:
+		return nil
+	case s.count == 0:
+		// Start over with an empty file. An offset saved before is
+		// ignored by openSpool once it is past the end of the file.
+		if err := s.f.Truncate(0); err != nil {
+			return err
+		}
+		s.seek(0)
+	case s.off >= compactThreshold:
+		if err := s.rewrite(nil); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		s.f.Close()
+		s.f = f
+		s.seek(0)
+		return nil
+	}
+	return s.saveOffset(s.off)
+}
+
+func (s *spool) loadOffset() (int64, error) {
+	data, err := os.ReadFile(s.path + offsetSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	off, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		// A torn write of the offset file; read the spool from the start.
+		return 0, nil
+	}
+	return off, nil
+}
+
+// saveOffset writes the read position through a temporary file, so a
+// crash keeps the old position.
+func (s *spool) saveOffset(off int64) error {
+	tmp := s.path + offsetSuffix + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(off, 10)), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path+offsetSuffix); err != nil {
+		return err
+	}
+	s.saved = off
+	return nil
+}
+
+// quarantine appends a line that could not be decoded to the corrupt file.
+func (s *spool) quarantine(line []byte) error {
+	f, err := os.OpenFile(s.path+corruptSuffix, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(line)
+	return errors.Join(err, f.Close())
+}
+
+// rewrite replaces the file with the given encoded items followed by the
+// unread lines, through a temporary file so a crash keeps the old file.
+func (s *spool) rewrite(head [][]byte) error {
+	rest, err := io.ReadAll(io.NewSectionReader(s.f, s.off, math.MaxInt64))
+	if err != nil {
+		return err
+	}
+	if i := bytes.LastIndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i+1]
+	} else {
+		rest = nil
+	}
+
+	var buf bytes.Buffer
+	for _, item := range head {
+		buf.Write(item)
+		buf.WriteByte('\n')
+	}
+	buf.Write(rest)
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	// Reset the offset first: a crash in between delivers the read lines
+	// again rather than skipping unread ones.
+	if err := s.saveOffset(0); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,223 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client/queue"
+)
+
+func drain(t *testing.T, q *queue.Queue[int], n int) []int {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var got []int
+	for i := 0; i < n; i++ {
+		v, err := q.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get %d failed: %v", i, err)
+		}
+		got = append(got, v)
+	}
+	return got
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueue_Block(t *testing.T) {
+	q, err := queue.New[int](queue.Config{Capacity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	q.Put(ctx, 1)
+	q.Put(ctx, 2)
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := q.Put(short, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Put on a full queue returned %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- q.Put(ctx, 3) }()
+	if got := drain(t, q, 3); !equal(got, []int{1, 2, 3}) {
+		t.Errorf("got %v", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("blocked Put returned %v", err)
+	}
+
+	q.Put(ctx, 4)
+	q.Close()
+	if got := drain(t, q, 1); got[0] != 4 {
+		t.Errorf("items left after Close = %v", got)
+	}
+	if _, err := q.Get(ctx); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("Get after drain returned %v, want ErrClosed", err)
+	}
+}
+
+func TestQueue_DropOldest(t *testing.T) {
+	q, _ := queue.New[int](queue.Config{Capacity: 2, Overflow: queue.DropOldest})
+	for i := 1; i <= 5; i++ {
+		q.Put(context.Background(), i)
+	}
+	if got := drain(t, q, 2); !equal(got, []int{4, 5}) {
+		t.Errorf("got %v, want [4 5]", got)
+	}
+	if q.Dropped() != 3 {
+		t.Errorf("Dropped = %d, want 3", q.Dropped())
+	}
+}
+
+func TestQueue_SpillToDisk(t *testing.T) {
+	dir := t.TempDir()
+	cfg := queue.Config{Capacity: 2, Overflow: queue.SpillToDisk, SpillDir: dir}
+	ctx := context.Background()
+
+	q, err := queue.New[int](cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 6; i++ {
+		if err := q.Put(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.Len() != 6 {
+		t.Errorf("Len = %d, want 6", q.Len())
+	}
+	if got := drain(t, q, 3); !equal(got, []int{1, 2, 3}) {
+		t.Errorf("got %v, want [1 2 3]", got)
+	}
+	q.Put(ctx, 7)
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A new queue continues with the items left by the old one.
+	q, err = queue.New[int](cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if q.Len() != 4 {
+		t.Errorf("Len after reopen = %d, want 4", q.Len())
+	}
+	if got := drain(t, q, 4); !equal(got, []int{4, 5, 6, 7}) {
+		t.Errorf("got %v, want [4 5 6 7]", got)
+	}
+	q.Put(ctx, 8)
+	if got := drain(t, q, 1); got[0] != 8 {
+		t.Errorf("got %v after the spool was emptied", got)
+	}
+}
+
+func TestQueue_SpillToDiskRecovery(t *testing.T) {
+	dir := t.TempDir()
+	// A crash left a partial last line and an earlier corrupt line.
+	if err := os.WriteFile(filepath.Join(dir, "queue.jsonl"), []byte("1\nxx\n2\n{\"par"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	q, err := queue.New[int](queue.Config{Capacity: 1, Overflow: queue.SpillToDisk, SpillDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if err := q.Put(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(t, q, 3); !equal(got, []int{1, 2, 3}) {
+		t.Errorf("got %v, want [1 2 3]", got)
+	}
+	corrupt, err := os.ReadFile(filepath.Join(dir, "queue.jsonl.corrupt"))
+	if err != nil || string(corrupt) != "xx\n" {
+		t.Errorf("corrupt file = %q, %v; want \"xx\\n\"", corrupt, err)
+	}
+}
+
+func TestQueue_SpillToDiskCrash(t *testing.T) {
+	dir := t.TempDir()
+	cfg := queue.Config{Capacity: 2, Overflow: queue.SpillToDisk, SpillDir: dir}
+	ctx := context.Background()
+
+	q, err := queue.New[int](cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 8; i++ {
+		if err := q.Put(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := drain(t, q, 2); !equal(got, []int{1, 2}) {
+		t.Errorf("got %v, want [1 2]", got)
+	}
+
+	// The process crashes without Close: the items in memory are lost,
+	// but the items read from disk before are not delivered again.
+	q, err = queue.New[int](cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if got := drain(t, q, q.Len()); !equal(got, []int{5, 6, 7, 8}) {
+		t.Errorf("got %v after the crash, want [5 6 7 8]", got)
+	}
+}
+
+func TestQueue_SpillToDiskCompaction(t *testing.T) {
+	defer queue.SetCompactThreshold(16)()
+	dir := t.TempDir()
+	q, err := queue.New[int](queue.Config{Capacity: 1, Overflow: queue.SpillToDisk, SpillDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	ctx := context.Background()
+
+	// A sustained backlog: the spool never runs empty.
+	next, want := 1, 1
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 3; i++ {
+			if err := q.Put(ctx, next); err != nil {
+				t.Fatal(err)
+			}
+			next++
+		}
+		for _, v := range drain(t, q, 2) {
+			if v != want {
+				t.Fatalf("got %d, want %d", v, want)
+			}
+			want++
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 49 items are left, about 150 bytes; without compaction the file
+	// would hold all 150 items.
+	if info.Size() > 250 {
+		t.Errorf("spool file has %d bytes, want it compacted", info.Size())
+	}
+	if got := drain(t, q, q.Len()); len(got) != 50 || got[0] != want || got[49] != 150 {
+		t.Errorf("got %d items from %v, want %d to 150", len(got), got[:1], want)
+	}
+}
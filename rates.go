@@ -0,0 +1,111 @@
+package smartme
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Rate is the consumption between two consecutive counter readings.
+type Rate struct {
+	Start, End time.Time
+	// Delta is the consumption in the interval, in the unit of the counter.
+	Delta float64
+	// Power is the average rate in units per hour, e.g. kW for a kWh
+	// counter.
+	Power float64
+	// Gap is set if the interval is more than twice as long as the usual
+	// interval of the series, so readings are missing.
+	Gap bool
+	// Rollover is set if the counter wrapped around, e.g. from 99998 to 3.
+	Rollover bool
+	// Reset is set if the counter went back for another reason, e.g. a
+	// replaced meter. Delta and Power are 0 since the consumption is
+	// unknown.
+	Reset bool
+}
+
+// Rates converts cumulative counter readings, e.g. from
+// GetValuesInPastMultiple, into the consumption and average power of each
+// interval. The readings are sorted by date first; readings with the same
+// date as the previous one are skipped.
+//
+// The size of the register is not known, so a decrease is a rollover only
+// if the counter jumps from the top tenth of the decade of the previous
+// reading to its bottom tenth, e.g. from 99998 to 3; any other decrease is
+// a reset. A new meter that starts at 0 after a reading of 9500 therefore
+// looks like a rollover at 10000. Use RatesForRegister if the size of the
+// register is known.
+func Rates(values []Value) []Rate {
+	return rates(values, decadeDigits)
+}
+
+// RatesForRegister is like Rates for a register with the given number of
+// integer digits, e.g. 6 for a register that wraps from 999999.9 to 0. A
+// decrease is a rollover only if both readings fit the register and it
+// wraps from its top tenth to its bottom tenth; any other decrease is a
+// reset. If digits is 0, every decrease is a reset.
+func RatesForRegister(values []Value, digits int) []Rate {
+	return rates(values, func(float64) int { return digits })
+}
+
+// rates computes the rates for a register whose number of integer digits
+// is given by digits for the reading before a decrease.
+func rates(values []Value, digits func(prev float64) int) []Rate {
+	sorted := make([]Value, len(values))
+	copy(sorted, values)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	var rates []Rate
+	var intervals []time.Duration
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		d := cur.Date.Sub(prev.Date)
+		if d <= 0 {
+			sorted[i] = prev
+			continue
+		}
+
+		r := Rate{Start: prev.Date, End: cur.Date, Delta: cur.Value - prev.Value}
+		if r.Delta < 0 {
+			if modulus, ok := rolloverModulus(prev.Value, cur.Value, digits(prev.Value)); ok {
+				r.Delta += modulus
+				r.Rollover = true
+			} else {
+				r.Delta = 0
+				r.Reset = true
+			}
+		}
+		r.Power = r.Delta / d.Hours()
+		rates = append(rates, r)
+		intervals = append(intervals, d)
+	}
+
+	if len(intervals) > 0 {
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+		usual := intervals[len(intervals)/2]
+		for i := range rates {
+			rates[i].Gap = rates[i].End.Sub(rates[i].Start) > 2*usual
+		}
+	}
+	return rates
+}
+
+// rolloverModulus reports whether a decrease from prev to cur looks like a
+// register of the given digits wrapping: prev is in the top tenth and cur
+// in the bottom tenth of the register.
+func rolloverModulus(prev, cur float64, digits int) (float64, bool) {
+	if digits <= 0 || cur < 0 {
+		return 0, false
+	}
+	modulus := math.Pow(10, float64(digits))
+	return modulus, prev < modulus && prev >= 0.9*modulus && cur < 0.1*modulus
+}
+
+// decadeDigits returns the number of integer digits of v, at least 1.
+func decadeDigits(v float64) int {
+	if v < 10 {
+		return 1
+	}
+	return int(math.Floor(math.Log10(v))) + 1
+}
@@ -0,0 +1,95 @@
+package smartme_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestRates(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(min int, v float64) smartme.Value {
+		return smartme.Value{Date: t0.Add(time.Duration(min) * time.Minute), Value: v}
+	}
+	values := []smartme.Value{
+		at(15, 99999.5),
+		at(0, 99999),
+		at(30, 0.5), // rollover
+		at(30, 0.5), // duplicate
+		at(45, 1.0),
+		at(105, 2.5), // two readings missing
+		at(120, 0.2), // meter replaced
+	}
+
+	want := []smartme.Rate{
+		{Start: t0, End: t0.Add(15 * time.Minute), Delta: 0.5, Power: 2},
+		{Start: t0.Add(15 * time.Minute), End: t0.Add(30 * time.Minute), Delta: 1, Power: 4, Rollover: true},
+		{Start: t0.Add(30 * time.Minute), End: t0.Add(45 * time.Minute), Delta: 0.5, Power: 2},
+		{Start: t0.Add(45 * time.Minute), End: t0.Add(105 * time.Minute), Delta: 1.5, Power: 1.5, Gap: true},
+		{Start: t0.Add(105 * time.Minute), End: t0.Add(120 * time.Minute), Reset: true},
+	}
+	got := smartme.Rates(values)
+	if len(got) != len(want) {
+		t.Fatalf("got %d rates, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if rates := smartme.Rates(values[:1]); len(rates) != 0 {
+		t.Errorf("a single reading gave %d rates", len(rates))
+	}
+}
+
+func TestRates_RegisterDigits(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := []smartme.Value{
+		{Date: t0, Value: 9500},
+		{Date: t0.Add(time.Hour), Value: 0},
+	}
+
+	tests := []struct {
+		digits       int
+		rollover     bool
+		reset        bool
+		delta        float64
+		wantExchange bool
+	}{
+		{digits: 4, rollover: true, delta: 500},
+		{digits: 6, reset: true, wantExchange: true}, // a new meter, not a wrap at 10000
+		{digits: 0, reset: true, wantExchange: true},
+		{digits: 3, reset: true, wantExchange: true}, // 9500 does not fit the register
+	}
+	for _, tt := range tests {
+		rates := smartme.RatesForRegister(values, tt.digits)
+		if len(rates) != 1 {
+			t.Fatalf("digits %d: got %d rates", tt.digits, len(rates))
+		}
+		r := rates[0]
+		if r.Rollover != tt.rollover || r.Reset != tt.reset || r.Delta != tt.delta {
+			t.Errorf("digits %d: rate = %+v", tt.digits, r)
+		}
+		if got := len(smartme.DetectExchanges(values, tt.digits)) == 1; got != tt.wantExchange {
+			t.Errorf("digits %d: exchange detected = %v, want %v", tt.digits, got, tt.wantExchange)
+		}
+	}
+}
+
+func TestRates_UnknownRegister(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := []smartme.Value{
+		{Date: t0, Value: 9500},
+		{Date: t0.Add(time.Hour), Value: 0},
+		{Date: t0.Add(2 * time.Hour), Value: 5},
+		{Date: t0.Add(3 * time.Hour), Value: 4},
+	}
+	// Without the register size, 9500 to 0 is taken as a wrap at 10000
+	// and 5 to 4 as a reset.
+	rates := smartme.Rates(values)
+	if len(rates) != 3 || !rates[0].Rollover || rates[0].Delta != 500 || !rates[2].Reset {
+		t.Errorf("rates = %+v", rates)
+	}
+}
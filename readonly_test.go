@@ -0,0 +1,25 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestWithReadOnly(t *testing.T) {
+	c, err := NewClient("user", "pass", WithReadOnly())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if _, err := c.newRequest(ctx, method, "api/Actions", nil); !errors.Is(err, ErrReadOnlyClient) {
+			t.Errorf("%s: error = %v, want ErrReadOnlyClient", method, err)
+		}
+	}
+	if _, err := c.newRequest(ctx, http.MethodGet, "api/Devices", nil); err != nil {
+		t.Errorf("GET failed on a read-only client: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestClient_RequestID(t *testing.T) {
+	client, mux, teardown := setup(t)
+	defer teardown()
+
+	var ids []string
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(smartme.RequestIDHeader))
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	ctx := context.Background()
+	_, err1 := client.GetDevices(ctx)
+	_, err2 := client.GetDevices(smartme.ContextWithRequestID(ctx, "ticket-4711"))
+
+	if len(ids) != 2 || len(ids[0]) != 32 || ids[1] != "ticket-4711" {
+		t.Fatalf("unexpected request IDs: %q", ids)
+	}
+
+	for i, err := range []error{err1, err2} {
+		var apiErr *smartme.APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("error %d is %T, want *APIError", i, err)
+		}
+		if apiErr.RequestID != ids[i] {
+			t.Errorf("APIError.RequestID = %q, want %q", apiErr.RequestID, ids[i])
+		}
+	}
+}
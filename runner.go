@@ -0,0 +1,180 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Service is a long-running part of an application, such as a Watcher or
+// a surplus.Controller. Run blocks until ctx is done or the service fails.
+// It should flush buffered data before it returns.
+type Service interface {
+	Run(ctx context.Context) error
+}
+
+// ServiceFunc adapts a function to a Service, e.g. a closure calling
+// RunTariffSchedule or ReportStats.
+type ServiceFunc func(ctx context.Context) error
+
+// Run calls f(ctx).
+func (f ServiceFunc) Run(ctx context.Context) error { return f(ctx) }
+
+const (
+	defaultRestartDelay    = time.Second
+	defaultMaxRestartDelay = 5 * time.Minute
+)
+
+// Runner supervises services: it starts them together, restarts a service
+// that fails, and stops them together. A service that returns nil has
+// finished and is not restarted.
+//
+//	r := &smartme.Runner{OnError: logError}
+//	r.Add("watcher", watcher)
+//	r.Add("tariffs", smartme.ServiceFunc(func(ctx context.Context) error {
+//		return client.RunTariffSchedule(ctx, meterID, schedule, nil)
+//	}))
+//	r.Start(ctx)
+//	...
+//	r.Stop(shutdownCtx)
+type Runner struct {
+	// RestartDelay is the delay before a failed service is restarted. It
+	// doubles with every failure in a row up to MaxRestartDelay (defaults
+	// 1s and 5m), and is reset once a service ran for MaxRestartDelay.
+	RestartDelay    time.Duration
+	MaxRestartDelay time.Duration
+	// Clock is used for the restart delays (default: the system clock).
+	Clock Clock
+	// OnError, if set, is called with the name of a failed service and its
+	// error, from the goroutine of the service.
+	OnError func(name string, err error)
+
+	mu       sync.Mutex
+	services []namedService
+	started  bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+type namedService struct {
+	name    string
+	service Service
+}
+
+// Add adds a service. It must be called before Start.
+func (r *Runner) Add(name string, s Service) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services = append(r.services, namedService{name, s})
+}
+
+// Start runs all services in the background until ctx is done or Stop is
+// called. It fails if the runner was already started.
+func (r *Runner) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return errors.New("runner already started")
+	}
+	r.started = true
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for _, s := range r.services {
+		wg.Add(1)
+		go func(s namedService) {
+			defer wg.Done()
+			r.supervise(ctx, s)
+		}(s)
+	}
+	go func() {
+		wg.Wait()
+		close(r.done)
+	}()
+	return nil
+}
+
+// Stop cancels the services and waits until all have returned or ctx is
+// done, in which case ctx.Err() is returned.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.started {
+		r.mu.Unlock()
+		return errors.New("runner not started")
+	}
+	r.cancel()
+	done := r.done
+	r.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel that is closed when all services have returned.
+// It is nil before Start.
+func (r *Runner) Done() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
+// supervise runs a service and restarts it with backoff when it fails.
+func (r *Runner) supervise(ctx context.Context, s namedService) {
+	clock := r.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	delay := r.RestartDelay
+	if delay <= 0 {
+		delay = defaultRestartDelay
+	}
+	maxDelay := r.MaxRestartDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxRestartDelay
+	}
+
+	wait := delay
+	for {
+		started := clock.Now()
+		err := runService(ctx, s.service)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		// A service that ran for a while before failing starts over with
+		// the initial delay.
+		if clock.Now().Sub(started) >= maxDelay {
+			wait = delay
+		}
+		if r.OnError != nil {
+			r.OnError(s.name, err)
+		}
+
+		select {
+		case <-clock.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		wait = min(2*wait, maxDelay)
+	}
+}
+
+// runService runs a service and turns a panic into an error, so a bug in
+// one service does not take down the others.
+func runService(ctx context.Context, s Service) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return s.Run(ctx)
+}
+
+// Ensure the long-running types of this package can be supervised.
+var _ Service = (*Watcher)(nil)
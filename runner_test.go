@@ -0,0 +1,90 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestRunner(t *testing.T) {
+	clock := smartmetest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var flakyRuns atomic.Int32
+	flaky := smartme.ServiceFunc(func(ctx context.Context) error {
+		if flakyRuns.Add(1) == 1 {
+			panic("boom")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var flushed atomic.Bool
+	steady := smartme.ServiceFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		flushed.Store(true)
+		return nil
+	})
+
+	errs := make(chan string, 1)
+	r := &smartme.Runner{
+		Clock:   clock,
+		OnError: func(name string, err error) { errs <- name + ": " + err.Error() },
+	}
+	r.Add("flaky", flaky)
+	r.Add("steady", steady)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := r.Start(context.Background()); err == nil {
+		t.Error("second Start should fail")
+	}
+
+	if got := <-errs; got != "flaky: panic: boom" {
+		t.Errorf("OnError got %q", got)
+	}
+	clock.WaitForTimers(t, 1)
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for flakyRuns.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("flaky service was not restarted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	select {
+	case <-r.Done():
+	default:
+		t.Error("Done is not closed after Stop")
+	}
+	if !flushed.Load() {
+		t.Error("steady service did not return before Stop")
+	}
+}
+
+func TestRunner_StopTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	r := &smartme.Runner{}
+	r.Add("stuck", smartme.ServiceFunc(func(ctx context.Context) error {
+		<-block
+		return nil
+	}))
+	r.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop returned %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,337 @@
+// Package scenes switches many devices into a named state at once, like a
+// "night mode" or "holiday mode" of a building. A state sets the relay,
+// the tariff, and other registers such as the digital and analog outputs:
+//
+//	list, err := scenes.Load(file)
+//	engine := scenes.NewEngine(client, list...)
+//	err = engine.Apply(ctx, "night")
+//
+// Apply reads the current state of all devices of the scene, performs the
+// actions, and reads the devices again to verify them. If a device cannot
+// be switched or does not reach its target, all devices are switched back
+// to their previous state.
+package scenes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Target is the desired state of a device in a scene. Nil fields are left
+// as they are.
+type Target struct {
+	DeviceID string `json:"deviceId"`
+	// Switch is the state of the relay.
+	Switch *bool `json:"switch,omitempty"`
+	// Tariff is the active tariff.
+	Tariff *int32 `json:"tariff,omitempty"`
+	// Actions set further registers by OBIS code, such as the outputs.
+	// The device must report the registers in GetValues, so they can be
+	// verified and switched back.
+	Actions []smartme.Action `json:"actions,omitempty"`
+}
+
+// Scene is a named set of device states.
+type Scene struct {
+	Name    string   `json:"name"`
+	Targets []Target `json:"targets"`
+}
+
+// Load reads a JSON array of scenes and validates it.
+func Load(r io.Reader) ([]Scene, error) {
+	var list []Scene
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid scene list: %w", err)
+	}
+	var errs []error
+	for _, s := range list {
+		if err := s.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s Scene) validate() error {
+	if s.Name == "" {
+		return errors.New("scene name must not be empty")
+	}
+	seen := make(map[string]bool)
+	for _, t := range s.Targets {
+		switch {
+		case t.DeviceID == "":
+			return fmt.Errorf("scene %q: deviceId must not be empty", s.Name)
+		case seen[t.DeviceID]:
+			return fmt.Errorf("scene %q: device %s is listed twice", s.Name, t.DeviceID)
+		case t.Switch == nil && t.Tariff == nil && len(t.Actions) == 0:
+			return fmt.Errorf("scene %q: device %s has no target state", s.Name, t.DeviceID)
+		}
+		for _, a := range t.Actions {
+			if a.ObisCode == "" {
+				return fmt.Errorf("scene %q: device %s has an action without obisCode", s.Name, t.DeviceID)
+			}
+		}
+		seen[t.DeviceID] = true
+	}
+	return nil
+}
+
+// ApplyError is returned by Apply if the scene could not be applied. The
+// devices that were changed have been switched back, except for those in
+// RollbackErrors.
+type ApplyError struct {
+	Scene string
+	// Errors maps the device ID to the reason it failed. The key "*" holds
+	// an error of the whole call, e.g. of ctx.
+	Errors map[string]error
+	// RollbackErrors maps the device ID to the error of switching it back.
+	RollbackErrors map[string]error
+}
+
+func (e *ApplyError) Error() string {
+	msg := fmt.Sprintf("scene %q: %s", e.Scene, joinErrors(e.Errors))
+	if len(e.RollbackErrors) > 0 {
+		msg += fmt.Sprintf("; rollback failed: %s", joinErrors(e.RollbackErrors))
+	}
+	return msg
+}
+
+// Unwrap returns the errors of the devices, so errors.Is finds e.g. an
+// expired context.
+func (e *ApplyError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func joinErrors(errs map[string]error) string {
+	ids := make([]string, 0, len(errs))
+	for id := range errs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%s: %v", id, errs[id])
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Engine applies scenes. Set the fields before calling Apply.
+type Engine struct {
+	// VerifyDelay is the time between performing the actions and reading
+	// the devices to verify them, so the meters can report the new state.
+	VerifyDelay time.Duration
+	// Clock is used to wait for VerifyDelay. Defaults to the system clock.
+	Clock smartme.Clock
+
+	api    smartme.API
+	scenes map[string]Scene
+}
+
+// NewEngine returns an Engine for the given scenes.
+func NewEngine(api smartme.API, scenes ...Scene) *Engine {
+	e := &Engine{api: api, scenes: make(map[string]Scene, len(scenes))}
+	for _, s := range scenes {
+		e.scenes[s.Name] = s
+	}
+	return e
+}
+
+// Scenes returns the names of all scenes, sorted.
+func (e *Engine) Scenes() []string {
+	names := make([]string, 0, len(e.scenes))
+	for name := range e.scenes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply switches all devices of the scene into their target state. If the
+// current state of a device cannot be read, nothing is changed. If a
+// device cannot be switched or does not reach its target, or ctx ends
+// while switching, the changed devices are switched back and an
+// *ApplyError is returned.
+func (e *Engine) Apply(ctx context.Context, name string) error {
+	scene, ok := e.scenes[name]
+	if !ok {
+		return fmt.Errorf("unknown scene %q", name)
+	}
+
+	// The previous states of the fields the scene changes, to roll back to.
+	previous := make(map[string]Target, len(scene.Targets))
+	for _, t := range scene.Targets {
+		p, err := e.current(ctx, t)
+		if err != nil {
+			return fmt.Errorf("scene %q: reading device %s: %w", name, t.DeviceID, err)
+		}
+		previous[t.DeviceID] = p
+	}
+
+	actions := make(map[string][]smartme.Action, len(scene.Targets))
+	for _, t := range scene.Targets {
+		actions[t.DeviceID] = t.actions()
+	}
+	failed := make(map[string]error)
+	if err := e.api.ExecuteActionsBulk(ctx, actions); err != nil {
+		// Any other error, e.g. of ctx, may come after some devices were
+		// switched, so it is rolled back as well.
+		var bulkErr *smartme.BulkError
+		if !errors.As(err, &bulkErr) {
+			failed["*"] = err
+		} else {
+			for id, err := range bulkErr.Errors {
+				failed[id] = err
+			}
+		}
+	}
+
+	if len(failed) == 0 {
+		waitErr := e.wait(ctx)
+		for _, t := range scene.Targets {
+			err := waitErr
+			if err == nil {
+				err = e.verify(ctx, t)
+			}
+			if err != nil {
+				failed[t.DeviceID] = err
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	// Roll back all devices, also the failed ones, which may have been
+	// switched partially.
+	rollback := make(map[string][]smartme.Action, len(previous))
+	for id, t := range previous {
+		if a := t.actions(); len(a) > 0 {
+			rollback[id] = a
+		}
+	}
+	applyErr := &ApplyError{Scene: name, Errors: failed}
+	if err := e.api.ExecuteActionsBulk(context.WithoutCancel(ctx), rollback); err != nil {
+		var bulkErr *smartme.BulkError
+		if errors.As(err, &bulkErr) {
+			applyErr.RollbackErrors = bulkErr.Errors
+		} else {
+			applyErr.RollbackErrors = map[string]error{"*": err}
+		}
+	}
+	return applyErr
+}
+
+// current reads the state of the fields that t sets.
+func (e *Engine) current(ctx context.Context, t Target) (Target, error) {
+	cur := Target{DeviceID: t.DeviceID}
+	if t.Switch != nil || t.Tariff != nil {
+		d, err := e.api.GetDevice(ctx, t.DeviceID)
+		if err != nil {
+			return cur, err
+		}
+		if t.Switch != nil {
+			cur.Switch = d.SwitchOn
+		}
+		if t.Tariff != nil {
+			cur.Tariff = d.ActiveTariff
+		}
+	}
+	if len(t.Actions) > 0 {
+		dv, err := e.api.GetValues(ctx, t.DeviceID)
+		if err != nil {
+			return cur, err
+		}
+		for _, a := range t.Actions {
+			v, ok := obisValue(dv, a.ObisCode)
+			if !ok {
+				return cur, fmt.Errorf("register %s is not reported", a.ObisCode)
+			}
+			cur.Actions = append(cur.Actions, smartme.Action{ObisCode: a.ObisCode, Value: v})
+		}
+	}
+	return cur, nil
+}
+
+func obisValue(dv *smartme.DeviceValues, obis string) (float64, bool) {
+	obis = smartme.NormalizeObis(obis)
+	for _, v := range dv.Values {
+		if smartme.NormalizeObis(v.Obis) == obis {
+			return v.Value, true
+		}
+	}
+	return 0, false
+}
+
+// actions returns the actions that set the target state.
+func (t Target) actions() []smartme.Action {
+	var actions []smartme.Action
+	if t.Switch != nil {
+		value := 0.0
+		if *t.Switch {
+			value = 1
+		}
+		actions = append(actions, smartme.Action{ObisCode: smartme.ObisSwitchState, Value: value})
+	}
+	if t.Tariff != nil {
+		actions = append(actions, smartme.Action{ObisCode: smartme.ObisActiveTariff, Value: float64(*t.Tariff)})
+	}
+	return append(actions, t.Actions...)
+}
+
+// verify reads a device and checks that it reached its target.
+func (e *Engine) verify(ctx context.Context, t Target) error {
+	cur, err := e.current(ctx, t)
+	if err != nil {
+		return fmt.Errorf("verification read: %w", err)
+	}
+	if t.Switch != nil && (cur.Switch == nil || *cur.Switch != *t.Switch) {
+		return fmt.Errorf("switch is not %s", onOff(*t.Switch))
+	}
+	if t.Tariff != nil && (cur.Tariff == nil || *cur.Tariff != *t.Tariff) {
+		return fmt.Errorf("active tariff is not %d", *t.Tariff)
+	}
+	for i, a := range t.Actions {
+		if cur.Actions[i].Value != a.Value {
+			return fmt.Errorf("register %s is not %g", a.ObisCode, a.Value)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) wait(ctx context.Context) error {
+	if e.VerifyDelay <= 0 {
+		return nil
+	}
+	after := time.After
+	if e.Clock != nil {
+		after = e.Clock.After
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-after(e.VerifyDelay):
+		return nil
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
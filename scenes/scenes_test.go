@@ -0,0 +1,193 @@
+package scenes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/scenes"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+const sceneList = `[
+	{"name": "night", "targets": [
+		{"deviceId": "hall", "switch": false},
+		{"deviceId": "boiler", "switch": true, "tariff": 2}
+	]}
+]`
+
+func TestEngine_Apply(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("hall"), SwitchOn: ptr(true)})
+	srv.AddDevice(smartme.Device{Id: ptr("boiler"), SwitchOn: ptr(false), ActiveTariff: ptr(int32(1))})
+	client, _ := srv.Client()
+
+	list, err := scenes.Load(strings.NewReader(sceneList))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	engine := scenes.NewEngine(client, list...)
+	if err := engine.Apply(context.Background(), "night"); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	hall, _ := client.GetDevice(context.Background(), "hall")
+	boiler, _ := client.GetDevice(context.Background(), "boiler")
+	if *hall.SwitchOn || !*boiler.SwitchOn || *boiler.ActiveTariff != 2 {
+		t.Errorf("devices not in scene state: hall %v, boiler %v tariff %d", *hall.SwitchOn, *boiler.SwitchOn, *boiler.ActiveTariff)
+	}
+
+	if err := engine.Apply(context.Background(), "holiday"); err == nil {
+		t.Error("Apply of an unknown scene should fail")
+	}
+}
+
+func TestEngine_Rollback(t *testing.T) {
+	var mu sync.Mutex
+	switches := map[string]bool{"hall": true, "boiler": false}
+	mock := &smartmemock.API{
+		GetDeviceFunc: func(ctx context.Context, id string) (*smartme.Device, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return &smartme.Device{Id: ptr(id), SwitchOn: ptr(switches[id])}, nil
+		},
+		ExecuteActionsBulkFunc: func(ctx context.Context, actions map[string][]smartme.Action) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for id, a := range actions {
+				// The boiler relay is stuck.
+				if id != "boiler" {
+					switches[id] = a[0].Value != 0
+				}
+			}
+			return nil
+		},
+	}
+
+	night := scenes.Scene{Name: "night", Targets: []scenes.Target{
+		{DeviceID: "hall", Switch: ptr(false)},
+		{DeviceID: "boiler", Switch: ptr(true)},
+	}}
+	err := scenes.NewEngine(mock, night).Apply(context.Background(), "night")
+
+	var applyErr *scenes.ApplyError
+	if !errors.As(err, &applyErr) {
+		t.Fatalf("error = %v, want *ApplyError", err)
+	}
+	if len(applyErr.Errors) != 1 || applyErr.Errors["boiler"] == nil || applyErr.RollbackErrors != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !switches["hall"] {
+		t.Error("hall was not switched back on")
+	}
+}
+
+// cancelAfterAction is a transport that cancels a context after the first
+// actions request.
+type cancelAfterAction struct {
+	cancel context.CancelFunc
+}
+
+func (c *cancelAfterAction) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if req.Method == http.MethodPost && req.URL.Path == "/api/Actions" {
+		c.cancel()
+	}
+	return resp, err
+}
+
+func TestEngine_RollbackCanceled(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("hall"), SwitchOn: ptr(true)})
+	srv.AddDevice(smartme.Device{Id: ptr("boiler"), SwitchOn: ptr(false)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, _ := srv.Client(smartme.WithConcurrency(1),
+		smartme.WithHTTPClient(&http.Client{Transport: &cancelAfterAction{cancel: cancel}}))
+
+	night := scenes.Scene{Name: "night", Targets: []scenes.Target{
+		{DeviceID: "hall", Switch: ptr(false)},
+		{DeviceID: "boiler", Switch: ptr(true)},
+	}}
+	err := scenes.NewEngine(client, night).Apply(ctx, "night")
+
+	var applyErr *scenes.ApplyError
+	if !errors.As(err, &applyErr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want *ApplyError for the canceled context", err)
+	}
+	// The device switched before ctx ended is switched back.
+	hall, _ := client.GetDevice(context.Background(), "hall")
+	boiler, _ := client.GetDevice(context.Background(), "boiler")
+	if !*hall.SwitchOn || *boiler.SwitchOn {
+		t.Errorf("devices not rolled back: hall %v, boiler %v", *hall.SwitchOn, *boiler.SwitchOn)
+	}
+	if n := len(srv.Actions("hall")) + len(srv.Actions("boiler")); n < 2 {
+		t.Errorf("%d actions performed, want the switch and its rollback", n)
+	}
+}
+
+func TestEngine_RollbackOutputs(t *testing.T) {
+	const output = "0-1:96.3.10*255"
+	var mu sync.Mutex
+	var rollback map[string][]smartme.Action
+	mock := &smartmemock.API{
+		GetDeviceFunc: func(ctx context.Context, id string) (*smartme.Device, error) {
+			return &smartme.Device{Id: ptr(id), SwitchOn: ptr(true), ActiveTariff: ptr(int32(1))}, nil
+		},
+		GetValuesFunc: func(ctx context.Context, id string) (*smartme.DeviceValues, error) {
+			// The output of the garage is stuck at 0.
+			return &smartme.DeviceValues{DeviceID: id, Values: []smartme.ObisValue{{Obis: output, Value: 0}}}, nil
+		},
+		ExecuteActionsBulkFunc: func(ctx context.Context, actions map[string][]smartme.Action) error {
+			mu.Lock()
+			defer mu.Unlock()
+			rollback = actions
+			return nil
+		},
+	}
+
+	night := scenes.Scene{Name: "night", Targets: []scenes.Target{
+		{DeviceID: "hall", Switch: ptr(true)},
+		{DeviceID: "garage", Actions: []smartme.Action{{ObisCode: output, Value: 1}}},
+	}}
+	err := scenes.NewEngine(mock, night).Apply(context.Background(), "night")
+
+	var applyErr *scenes.ApplyError
+	if !errors.As(err, &applyErr) || len(applyErr.Errors) != 1 || applyErr.Errors["garage"] == nil {
+		t.Fatalf("error = %v, want the garage output to fail", err)
+	}
+	// Only the fields of the scene are switched back; the tariff of the
+	// hall is left alone.
+	want := map[string][]smartme.Action{
+		"hall":   {{ObisCode: smartme.ObisSwitchState, Value: 1}},
+		"garage": {{ObisCode: output, Value: 0}},
+	}
+	if !reflect.DeepEqual(rollback, want) {
+		t.Errorf("rollback = %v, want %v", rollback, want)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := scenes.Load(strings.NewReader(`[
+		{"name": "night", "targets": [{"deviceId": "hall"}]},
+		{"targets": []},
+		{"name": "away", "targets": [{"deviceId": "hall", "actions": [{"value": 1}]}]}
+	]`))
+	if err == nil || !strings.Contains(err.Error(), "no target state") || !strings.Contains(err.Error(), "name must not be empty") ||
+		!strings.Contains(err.Error(), "action without obisCode") {
+		t.Errorf("Load error = %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trigger computes the times a rule fires.
+type Trigger interface {
+	// Next returns the first time after t the trigger fires, or the zero
+	// time if it never fires again.
+	Next(t time.Time) time.Time
+}
+
+// cron is a Trigger for a cron expression.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set for a "*" day field. If both day fields
+	// are restricted, a day matches if either matches, as in cron.
+	domAny, dowAny bool
+	loc            *time.Location
+}
+
+// Cron returns a Trigger for a standard five-field cron expression
+// ("minute hour day-of-month month day-of-week") in the given location,
+// e.g. "30 6 * * 1-5" for 06:30 on weekdays. Fields may contain "*",
+// lists ("1,15"), ranges ("1-5") and steps ("*/15"). Day of week 0 and 7
+// are Sunday.
+func Cron(expr string, loc *time.Location) (Trigger, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+	c := &cron{loc: loc, domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	parts := []*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	for i, field := range fields {
+		if *parts[i], err = parseField(field, bounds[i][0], bounds[i][1]); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseField parses a cron field into a bit set.
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			var err error
+			from, to, isRange := strings.Cut(rangePart, "-")
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// Next implements Trigger.
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.loc)
+	// Every valid expression matches within a few years (29 February).
+	for i := 0; i < 5*366; i++ {
+		if c.matchDay(day) {
+			for h := 0; h < 24; h++ {
+				if c.hour&(1<<h) == 0 {
+					continue
+				}
+				for m := 0; m < 60; m++ {
+					if c.minute&(1<<m) == 0 {
+						continue
+					}
+					at := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, c.loc)
+					if at.After(t) {
+						return at
+					}
+				}
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+func (c *cron) matchDay(day time.Time) bool {
+	if c.month&(1<<int(day.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<day.Day()) != 0
+	dow := c.dow&(1<<int(day.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
@@ -0,0 +1,76 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// priceTimeout limits fetching prices in a price trigger.
+const priceTimeout = 30 * time.Second
+
+// cheap is a Trigger at the edges of the cheap windows of a price plan.
+type cheap struct {
+	plan  *smartme.PricePlan
+	start bool
+}
+
+// CheapStart returns a Trigger at the start of every cheap window of plan,
+// e.g. to switch on a boiler in the cheapest hours of the day. Pair it with
+// CheapEnd to switch off again:
+//
+//	plan := &smartme.PricePlan{Source: prices, Hours: 3}
+//	rules := []schedule.Rule{
+//		{Name: "boiler on", DeviceID: id, Trigger: schedule.CheapStart(plan), Actions: schedule.Switch(true)},
+//		{Name: "boiler off", DeviceID: id, Trigger: schedule.CheapEnd(plan), Actions: schedule.Switch(false)},
+//	}
+//
+// Windows that continue over midnight do not end and start again. The
+// trigger only knows the published prices, usually until the end of the
+// next day; the Scheduler looks again when more prices may be available.
+// Errors of the price source are treated like missing prices.
+func CheapStart(plan *smartme.PricePlan) Trigger {
+	return cheap{plan: plan, start: true}
+}
+
+// CheapEnd returns a Trigger at the end of every cheap window of plan. See
+// CheapStart.
+func CheapEnd(plan *smartme.PricePlan) Trigger {
+	return cheap{plan: plan, start: false}
+}
+
+// Next implements Trigger.
+func (c cheap) Next(t time.Time) time.Time {
+	ctx, cancel := context.WithTimeout(context.Background(), priceTimeout)
+	defer cancel()
+
+	// The windows of the day before and after are needed to merge windows
+	// over midnight.
+	var windows []smartme.PriceWindow
+	day, _ := smartme.DayRange(t, c.plan.Location)
+	for i := -1; i <= 2; i++ {
+		ws, err := c.plan.Windows(ctx, day.AddDate(0, 0, i))
+		if err != nil {
+			continue
+		}
+		for _, w := range ws {
+			if n := len(windows); n > 0 && windows[n-1].End.Equal(w.Start) {
+				windows[n-1].End = w.End
+				continue
+			}
+			windows = append(windows, w)
+		}
+	}
+
+	for _, w := range windows {
+		edge := w.End
+		if c.start {
+			edge = w.Start
+		}
+		if edge.After(t) {
+			return edge
+		}
+	}
+	return time.Time{}
+}
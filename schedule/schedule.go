@@ -0,0 +1,294 @@
+// Package schedule switches devices at fixed times, like "30 6 * * 1-5",
+// relative to sunrise and sunset, or in the cheapest hours of dynamic
+// electricity prices, optionally skipping holidays:
+//
+//	on, _ := schedule.Cron("0 7 * * 1-5", loc)
+//	s := schedule.New(client, []schedule.Rule{
+//		{Name: "lights off", DeviceID: id, Trigger: schedule.Sunrise(47.4, 8.5, 30*time.Minute), Actions: schedule.Switch(false)},
+//		{Name: "heating on", DeviceID: id, Trigger: on, Actions: schedule.Switch(true), SkipHolidays: true},
+//	})
+//	s.StateFile = "schedule.json"
+//	err := s.Run(ctx)
+//
+// With a StateFile, the scheduler remembers when each rule fired. After a
+// restart it catches up by performing, for every rule, the last occurrence
+// it missed while it was down, in the order they were due.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// recheckInterval is the longest time the Scheduler waits before it asks
+// the triggers for their next occurrence again.
+const recheckInterval = time.Hour
+
+// Switch returns the action that switches the relay of a device.
+func Switch(on bool) []smartme.Action {
+	value := 0.0
+	if on {
+		value = 1
+	}
+	return []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: value}}
+}
+
+// Rule performs actions on a device whenever its trigger fires.
+type Rule struct {
+	// Name identifies the rule in the state file; it must be unique.
+	Name     string
+	DeviceID string
+	Trigger  Trigger
+	// Actions are performed on the device, e.g. Switch(true) or outputs.
+	Actions []smartme.Action
+	// SkipHolidays skips occurrences on days of the Holidays calendar.
+	SkipHolidays bool
+}
+
+// Calendar reports holidays.
+type Calendar interface {
+	IsHoliday(t time.Time) bool
+}
+
+// Dates is a Calendar of dates in the form "2006-01-02", compared with the
+// date of the occurrence in its location.
+type Dates []string
+
+// IsHoliday implements Calendar.
+func (d Dates) IsHoliday(t time.Time) bool {
+	date := t.Format("2006-01-02")
+	for _, h := range d {
+		if h == date {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a performed or failed occurrence of a rule.
+type Event struct {
+	Rule string
+	// Time is the scheduled time of the occurrence.
+	Time time.Time
+	// CatchUp is set for occurrences missed while the scheduler was down.
+	CatchUp bool
+	Err     error
+}
+
+// Scheduler runs rules. Set the fields before calling Run.
+type Scheduler struct {
+	// Holidays is used by rules with SkipHolidays.
+	Holidays Calendar
+	// StateFile stores when each rule fired last, for catching up after a
+	// restart. Without it, missed occurrences are not performed.
+	StateFile string
+	// Clock is used to wait for the occurrences. Defaults to the system
+	// clock.
+	Clock smartme.Clock
+	// OnEvent is called for every occurrence.
+	OnEvent func(Event)
+
+	api   smartme.API
+	rules []Rule
+
+	mu   sync.Mutex
+	last map[string]time.Time
+	// checked is the time up to which the occurrences were handled before
+	// a restart, zero without state.
+	checked time.Time
+}
+
+// New returns a Scheduler for the given rules.
+func New(api smartme.API, rules []Rule) *Scheduler {
+	return &Scheduler{api: api, rules: rules, last: make(map[string]time.Time)}
+}
+
+// LastRun returns when the rule fired last.
+func (s *Scheduler) LastRun(rule string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.last[rule]
+	return t, ok
+}
+
+// Run performs the rules until ctx is done and returns ctx.Err(). Failed
+// actions are reported with OnEvent and not retried.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	clock := s.Clock
+	if clock == nil {
+		clock = smartme.SystemClock{}
+	}
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	// Missed occurrences are performed in the order they were due, so a
+	// device ends up in the state of the latest one.
+	now := clock.Now()
+	type occurrence struct {
+		rule Rule
+		at   time.Time
+	}
+	var missed []occurrence
+	for _, r := range s.rules {
+		if at, ok := s.missed(r, now); ok {
+			missed = append(missed, occurrence{r, at})
+		}
+	}
+	sort.SliceStable(missed, func(i, j int) bool { return missed[i].at.Before(missed[j].at) })
+	for _, o := range missed {
+		s.fire(ctx, o.rule, o.at, true)
+	}
+	s.save(now)
+
+	for {
+		now := clock.Now()
+		var next time.Time
+		for _, r := range s.rules {
+			if at := s.next(r, now); !at.IsZero() && (next.IsZero() || at.Before(next)) {
+				next = at
+			}
+		}
+		// Triggers like CheapStart learn about later occurrences over time,
+		// so the rules are looked at again after recheckInterval.
+		wake := now.Add(recheckInterval)
+		if !next.IsZero() && next.Before(wake) {
+			wake = next
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(wake.Sub(now)):
+		}
+		for _, r := range s.rules {
+			if at := s.next(r, now); !at.IsZero() && !at.After(wake) {
+				s.fire(ctx, r, at, false)
+			}
+		}
+		s.save(wake)
+	}
+}
+
+func (s *Scheduler) validate() error {
+	names := make(map[string]bool)
+	var errs []error
+	for i, r := range s.rules {
+		switch {
+		case r.Name == "":
+			errs = append(errs, fmt.Errorf("rule %d: name must not be empty", i+1))
+		case names[r.Name]:
+			errs = append(errs, fmt.Errorf("rule %q is listed twice", r.Name))
+		case r.DeviceID == "" || r.Trigger == nil || len(r.Actions) == 0:
+			errs = append(errs, fmt.Errorf("rule %q needs a device, a trigger and actions", r.Name))
+		}
+		names[r.Name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// next returns the first occurrence of r after t that is not skipped.
+func (s *Scheduler) next(r Rule, t time.Time) time.Time {
+	for i := 0; i < 1000; i++ {
+		t = r.Trigger.Next(t)
+		if t.IsZero() || !s.skip(r, t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// missed returns the last occurrence of r that was missed before now.
+func (s *Scheduler) missed(r Rule, now time.Time) (time.Time, bool) {
+	if s.checked.IsZero() {
+		return time.Time{}, false
+	}
+	var missed time.Time
+	for at := s.next(r, s.checked); !at.IsZero() && !at.After(now); at = s.next(r, at) {
+		missed = at
+	}
+	return missed, !missed.IsZero()
+}
+
+func (s *Scheduler) skip(r Rule, t time.Time) bool {
+	return r.SkipHolidays && s.Holidays != nil && s.Holidays.IsHoliday(t)
+}
+
+func (s *Scheduler) fire(ctx context.Context, r Rule, at time.Time, catchUp bool) {
+	err := s.api.PerformActions(ctx, r.DeviceID, r.Actions)
+	s.mu.Lock()
+	s.last[r.Name] = at
+	s.mu.Unlock()
+	if s.OnEvent != nil {
+		s.OnEvent(Event{Rule: r.Name, Time: at, CatchUp: catchUp, Err: err})
+	}
+}
+
+// state is the content of the state file.
+type state struct {
+	// Checked is the time up to which all occurrences were handled.
+	Checked time.Time            `json:"checked"`
+	LastRun map[string]time.Time `json:"lastRun"`
+}
+
+// load reads the state file.
+func (s *Scheduler) load() error {
+	if s.StateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("invalid schedule state %s: %w", s.StateFile, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checked = st.Checked
+	for name, t := range st.LastRun {
+		s.last[name] = t
+	}
+	return nil
+}
+
+// save writes the state file atomically. Errors are reported as events,
+// since the scheduler can go on without it.
+func (s *Scheduler) save(checked time.Time) {
+	if s.StateFile == "" {
+		return
+	}
+	s.mu.Lock()
+	st := state{Checked: checked, LastRun: make(map[string]time.Time, len(s.last))}
+	for name, t := range s.last {
+		st.LastRun[name] = t
+	}
+	s.mu.Unlock()
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(s.StateFile), "."+filepath.Base(s.StateFile)+".tmp")
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, s.StateFile)
+		}
+	}
+	if err != nil && s.OnEvent != nil {
+		s.OnEvent(Event{Time: checked, Err: fmt.Errorf("saving schedule state: %w", err)})
+	}
+}
@@ -0,0 +1,223 @@
+package schedule_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/schedule"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestCron(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"30 6 * * 1-5", "2025-01-03T07:00:00+01:00", "2025-01-06T06:30:00+01:00"},
+		{"*/15 * * * *", "2025-01-03T07:00:00+01:00", "2025-01-03T07:15:00+01:00"},
+		{"0 0 13 * 5", "2025-06-02T00:00:00+02:00", "2025-06-06T00:00:00+02:00"},
+		{"0 12 29 2 *", "2025-03-01T00:00:00+01:00", "2028-02-29T12:00:00+01:00"},
+		{"0 8 * * 7", "2025-03-29T09:00:00+01:00", "2025-03-30T08:00:00+02:00"},
+	}
+	for _, tt := range tests {
+		trigger, err := schedule.Cron(tt.expr, zurich)
+		if err != nil {
+			t.Fatalf("Cron(%q) failed: %v", tt.expr, err)
+		}
+		from, _ := time.Parse(time.RFC3339, tt.from)
+		want, _ := time.Parse(time.RFC3339, tt.want)
+		if got := trigger.Next(from); !got.Equal(want) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.from, got, want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := schedule.Cron(expr, nil); err == nil {
+			t.Errorf("Cron(%q) should fail", expr)
+		}
+	}
+}
+
+func TestSunriseSunset(t *testing.T) {
+	// Zurich at the summer solstice: 05:29 and 21:26 CEST.
+	day := time.Date(2025, 6, 21, 0, 0, 0, 0, time.UTC)
+	check := func(name string, got, want time.Time) {
+		if d := got.Sub(want); d < -5*time.Minute || d > 5*time.Minute {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+	check("sunrise", schedule.Sunrise(47.37, 8.54, 0).Next(day), time.Date(2025, 6, 21, 3, 29, 0, 0, time.UTC))
+	check("sunset", schedule.Sunset(47.37, 8.54, 0).Next(day), time.Date(2025, 6, 21, 19, 26, 0, 0, time.UTC))
+	check("sunset with offset", schedule.Sunset(47.37, 8.54, -time.Hour).Next(day), time.Date(2025, 6, 21, 18, 26, 0, 0, time.UTC))
+
+	// No sunrise during the polar night.
+	polar := schedule.Sunrise(78.2, 15.6, 0).Next(time.Date(2025, 12, 21, 0, 0, 0, 0, time.UTC))
+	if polar.Before(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("sunrise in Svalbard after the solstice = %s, want in February", polar)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	var mu sync.Mutex
+	var performed []string
+	mock := &smartmemock.API{
+		PerformActionsFunc: func(ctx context.Context, id string, actions []smartme.Action) error {
+			mu.Lock()
+			defer mu.Unlock()
+			performed = append(performed, id)
+			return nil
+		},
+	}
+
+	daily, _ := schedule.Cron("0 7 * * *", time.UTC)
+	rules := []schedule.Rule{{Name: "heating", DeviceID: "boiler", Trigger: daily, Actions: schedule.Switch(true), SkipHolidays: true}}
+	stateFile := filepath.Join(t.TempDir(), "schedule.json")
+
+	// Runs the scheduler from start for the given number of days and
+	// returns its events.
+	run := func(start time.Time, days int) []schedule.Event {
+		clock := smartmetest.NewClock(start)
+		s := schedule.New(mock, rules)
+		s.Holidays = schedule.Dates{"2025-01-02"}
+		s.StateFile = stateFile
+		s.Clock = clock
+		var events []schedule.Event
+		s.OnEvent = func(e schedule.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Run(ctx) }()
+		for i := 0; i < days*24; i++ {
+			clock.WaitForTimers(t, 1)
+			clock.Advance(time.Hour)
+		}
+		clock.WaitForTimers(t, 1)
+		cancel()
+		<-done
+		mu.Lock()
+		defer mu.Unlock()
+		return events
+	}
+
+	// Three days, the second is a holiday.
+	events := run(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 3)
+	if len(events) != 2 || !events[0].Time.Equal(time.Date(2025, 1, 1, 7, 0, 0, 0, time.UTC)) || !events[1].Time.Equal(time.Date(2025, 1, 3, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("events = %+v, want 1 and 3 January", events)
+	}
+	if _, err := os.Stat(stateFile); err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+
+	// Restart after two days of downtime: only the last missed occurrence
+	// is caught up.
+	events = run(time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC), 0)
+	if len(events) != 1 || !events[0].CatchUp || !events[0].Time.Equal(time.Date(2025, 1, 6, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("catch-up events = %+v, want 6 January", events)
+	}
+	if len(performed) != 3 {
+		t.Errorf("performed %d actions, want 3", len(performed))
+	}
+}
+
+func TestScheduler_CatchUpOrder(t *testing.T) {
+	var mu sync.Mutex
+	var switched []float64
+	mock := &smartmemock.API{
+		PerformActionsFunc: func(ctx context.Context, id string, actions []smartme.Action) error {
+			mu.Lock()
+			defer mu.Unlock()
+			switched = append(switched, actions[0].Value)
+			return nil
+		},
+	}
+
+	// The off rule is listed first, but due after the on rule.
+	off, _ := schedule.Cron("0 22 * * *", time.UTC)
+	on, _ := schedule.Cron("0 7 * * *", time.UTC)
+	rules := []schedule.Rule{
+		{Name: "off", DeviceID: "boiler", Trigger: off, Actions: schedule.Switch(false)},
+		{Name: "on", DeviceID: "boiler", Trigger: on, Actions: schedule.Switch(true)},
+	}
+	stateFile := filepath.Join(t.TempDir(), "schedule.json")
+	os.WriteFile(stateFile, []byte(`{"checked": "2025-01-01T06:00:00Z"}`), 0o600)
+
+	clock := smartmetest.NewClock(time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC))
+	s := schedule.New(mock, rules)
+	s.StateFile = stateFile
+	s.Clock = clock
+	var events []schedule.Event
+	s.OnEvent = func(e schedule.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	clock.WaitForTimers(t, 1)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || events[0].Rule != "on" || events[1].Rule != "off" {
+		t.Errorf("events = %+v, want on before off", events)
+	}
+	if len(switched) != 2 || switched[1] != 0 {
+		t.Errorf("switched = %v, want the boiler off at the end", switched)
+	}
+}
+
+func TestCheapStartEnd(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var prices smartme.StaticPrices
+	for i := 0; i < 48; i++ {
+		// The two cheapest hours are 02:00 and 23:00 on the first day,
+		// and 00:00 and 03:00 on the second.
+		value := 10.0
+		switch i {
+		case 2, 23, 24, 27:
+			value = 1
+		}
+		from := day.Add(time.Duration(i) * time.Hour)
+		prices = append(prices, smartme.Price{Start: from, End: from.Add(time.Hour), Value: value})
+	}
+	plan := &smartme.PricePlan{Source: prices, Hours: 2}
+	start, end := schedule.CheapStart(plan), schedule.CheapEnd(plan)
+
+	tests := []struct {
+		trigger schedule.Trigger
+		from    time.Time
+		want    time.Time
+	}{
+		{start, day, day.Add(2 * time.Hour)},
+		{end, day, day.Add(3 * time.Hour)},
+		{start, day.Add(2 * time.Hour), day.Add(23 * time.Hour)},
+		// The window from 23:00 continues over midnight.
+		{end, day.Add(3 * time.Hour), day.Add(25 * time.Hour)},
+		{start, day.Add(23 * time.Hour), day.Add(27 * time.Hour)},
+		{end, day.Add(27 * time.Hour), day.Add(28 * time.Hour)},
+		// No prices for the third day yet.
+		{start, day.Add(27 * time.Hour), time.Time{}},
+	}
+	for i, tt := range tests {
+		if got := tt.trigger.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%d: Next(%s) = %s, want %s", i, tt.from, got, tt.want)
+		}
+	}
+}
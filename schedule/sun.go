@@ -0,0 +1,87 @@
+package schedule
+
+import (
+	"math"
+	"time"
+)
+
+// sunZenith is the zenith angle of the sun at sunrise and sunset, including
+// refraction and the radius of the sun.
+const sunZenith = 90.833
+
+// sun is a Trigger at sunrise or sunset.
+type sun struct {
+	lat, lon float64
+	rise     bool
+	offset   time.Duration
+}
+
+// Sunrise returns a Trigger at sunrise, shifted by offset, at the given
+// latitude and longitude in degrees (north and east positive). The times
+// are accurate to a few minutes. On days without sunrise, as in polar
+// regions, the trigger does not fire.
+func Sunrise(lat, lon float64, offset time.Duration) Trigger {
+	return sun{lat: lat, lon: lon, rise: true, offset: offset}
+}
+
+// Sunset returns a Trigger at sunset, shifted by offset. See Sunrise.
+func Sunset(lat, lon float64, offset time.Duration) Trigger {
+	return sun{lat: lat, lon: lon, rise: false, offset: offset}
+}
+
+// Next implements Trigger.
+func (s sun) Next(t time.Time) time.Time {
+	u := t.UTC()
+	day := time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	for i := 0; i < 367; i++ {
+		if at, ok := s.on(day); ok {
+			if at = at.Add(s.offset); at.After(t) {
+				return at
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// on computes the event on the given UTC day with the algorithm of the
+// Almanac for Computers (1990).
+func (s sun) on(day time.Time) (time.Time, bool) {
+	rad := math.Pi / 180
+	lngHour := s.lon / 15
+	hour := 18.0
+	if s.rise {
+		hour = 6
+	}
+	t := float64(day.YearDay()) + (hour-lngHour)/24
+
+	m := 0.9856*t - 3.289
+	l := normDegrees(m + 1.916*math.Sin(m*rad) + 0.020*math.Sin(2*m*rad) + 282.634)
+	ra := normDegrees(math.Atan(0.91764*math.Tan(l*rad)) / rad)
+	ra += math.Floor(l/90)*90 - math.Floor(ra/90)*90
+	ra /= 15
+
+	sinDec := 0.39782 * math.Sin(l*rad)
+	cosDec := math.Cos(math.Asin(sinDec))
+	cosH := (math.Cos(sunZenith*rad) - sinDec*math.Sin(s.lat*rad)) / (cosDec * math.Cos(s.lat*rad))
+	if cosH > 1 || cosH < -1 {
+		return time.Time{}, false
+	}
+	h := math.Acos(cosH) / rad
+	if s.rise {
+		h = 360 - h
+	}
+	h /= 15
+
+	local := h + ra - 0.06571*t - 6.622
+	ut := math.Mod(local-lngHour+48, 24)
+	return day.Add(time.Duration(ut * float64(time.Hour))).Truncate(time.Second), true
+}
+
+func normDegrees(d float64) float64 {
+	d = math.Mod(d, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
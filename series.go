@@ -0,0 +1,93 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ConsumptionPoint is the consumption of a device in one period of a
+// DailyConsumption or MonthlyConsumption series.
+type ConsumptionPoint struct {
+	Period
+	// Consumption is the difference of the counter readings at the period
+	// boundaries, in kWh or m³.
+	Consumption float64
+	// Partial is set for the running period, whose consumption ends at the
+	// latest reading.
+	Partial bool
+}
+
+// DailyConsumption returns the consumption of a device for every day of
+// the month containing month, ready for charting. Days start at midnight
+// in the location of the client (WithLocation), or of month if none is
+// set. The series ends with the running day; days that start before the
+// first reading of the device are left out.
+func (c *Client) DailyConsumption(ctx context.Context, deviceID string, month time.Time, opts ...CallOption) ([]ConsumptionPoint, error) {
+	start, end := MonthRange(month, c.location)
+	var bounds []time.Time
+	for t := start; t.Before(end); t = t.AddDate(0, 0, 1) {
+		bounds = append(bounds, t)
+	}
+	return c.consumptionSeries(ctx, deviceID, append(bounds, end), opts)
+}
+
+// MonthlyConsumption returns the consumption of a device for every month
+// of a year, like DailyConsumption. Months start at midnight in the
+// location of the client, or in the local time zone if none is set.
+func (c *Client) MonthlyConsumption(ctx context.Context, deviceID string, year int, opts ...CallOption) ([]ConsumptionPoint, error) {
+	loc := c.location
+	if loc == nil {
+		loc = time.Local
+	}
+	bounds := make([]time.Time, 13)
+	for i := range bounds {
+		bounds[i] = time.Date(year, time.Month(i+1), 1, 0, 0, 0, 0, loc)
+	}
+	return c.consumptionSeries(ctx, deviceID, bounds, opts)
+}
+
+// consumptionSeries reads the counter at every boundary and returns the
+// consumption between consecutive boundaries. A boundary in the future is
+// replaced by the latest reading, which ends the series.
+func (c *Client) consumptionSeries(ctx context.Context, deviceID string, bounds []time.Time, opts []CallOption) ([]ConsumptionPoint, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("deviceID must not be empty")
+	}
+
+	now := c.clock.Now()
+	var (
+		points []ConsumptionPoint
+		prev   *Value
+	)
+	for i, t := range bounds {
+		partial := t.After(now)
+		if partial {
+			t = now
+		}
+		v, err := c.GetValuesInPast(ctx, deviceID, t, opts...)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// No reading yet, the device was installed later.
+			prev = nil
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading at %s: %w", t.Format(time.RFC3339), err)
+		}
+		if prev != nil {
+			points = append(points, ConsumptionPoint{
+				Period:      Period{Start: bounds[i-1], End: bounds[i]},
+				Consumption: v.Value - prev.Value,
+				Partial:     partial,
+			})
+		}
+		if partial {
+			break
+		}
+		prev = v
+	}
+	return points, nil
+}
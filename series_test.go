@@ -0,0 +1,93 @@
+package smartme_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_ConsumptionSeries(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	// Hourly readings at 0.5 kWh per hour, from the installation on
+	// 15 December until now.
+	installed := time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	var history []smartme.Value
+	for d := installed; !d.After(now); d = d.Add(time.Hour) {
+		history = append(history, smartme.Value{Date: d, Value: d.Sub(installed).Hours() * 0.5})
+	}
+	srv.AddHistory("meter", history...)
+
+	client, _ := srv.Client(smartme.WithClock(smartmetest.NewClock(now)), smartme.WithLocation(time.UTC))
+	ctx := context.Background()
+
+	check := func(t *testing.T, points []smartme.ConsumptionPoint, first time.Time, want []float64) {
+		t.Helper()
+		if len(points) != len(want) {
+			t.Fatalf("got %d points, want %d: %+v", len(points), len(want), points)
+		}
+		if !points[0].Start.Equal(first) {
+			t.Errorf("series starts at %v, want %v", points[0].Start, first)
+		}
+		for i, p := range points {
+			if math.Abs(p.Consumption-want[i]) > 1e-9 {
+				t.Errorf("point %d (%v) = %g kWh, want %g", i, p.Start, p.Consumption, want[i])
+			}
+			if last := i == len(points)-1; p.Partial != (last && p.End.After(now)) {
+				t.Errorf("point %d Partial = %v", i, p.Partial)
+			}
+		}
+	}
+
+	t.Run("daily", func(t *testing.T) {
+		points, err := client.DailyConsumption(ctx, "meter", time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("DailyConsumption failed: %v", err)
+		}
+		want := make([]float64, 15)
+		for i := range want {
+			want[i] = 12
+		}
+		want[14] = 6
+		check(t, points, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), want)
+		if !points[14].Partial {
+			t.Error("running day is not partial")
+		}
+	})
+
+	t.Run("daily before installation", func(t *testing.T) {
+		points, err := client.DailyConsumption(ctx, "meter", installed)
+		if err != nil {
+			t.Fatalf("DailyConsumption failed: %v", err)
+		}
+		want := make([]float64, 17)
+		for i := range want {
+			want[i] = 12
+		}
+		check(t, points, installed, want)
+	})
+
+	t.Run("monthly", func(t *testing.T) {
+		points, err := client.MonthlyConsumption(ctx, "meter", 2025)
+		if err != nil {
+			t.Fatalf("MonthlyConsumption failed: %v", err)
+		}
+		check(t, points, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), []float64{31 * 12, 28 * 12, 14.5 * 12})
+	})
+
+	t.Run("monthly before installation", func(t *testing.T) {
+		points, err := client.MonthlyConsumption(ctx, "meter", 2024)
+		if err != nil {
+			t.Fatalf("MonthlyConsumption failed: %v", err)
+		}
+		if len(points) != 0 {
+			t.Errorf("got %+v, want no points for the month of the installation", points)
+		}
+	})
+}
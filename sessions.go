@@ -0,0 +1,122 @@
+package smartme
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// sessionPowerThreshold is the default average power in W above which an
+// interval of the counter history counts as charging. Cars draw at least
+// 1.4 kW (6 A on one phase), while idle stations draw a few W.
+const sessionPowerThreshold = 1000
+
+// WithSessionThreshold sets the average power in W above which
+// GetChargingSessions counts an interval as charging (1000 W by default).
+// Lower it for stations that charge light vehicles.
+func WithSessionThreshold(watts float64) CallOption {
+	return func(cfg *callConfig) {
+		cfg.sessionThreshold = watts
+	}
+}
+
+// ChargingSession is a period in which a charging station delivered energy.
+type ChargingSession struct {
+	Start time.Time
+	End   time.Time
+	// Energy is the delivered energy in kWh.
+	Energy float64
+	// PeakPower is the highest average power of an interval in W.
+	PeakPower float64
+}
+
+// Duration returns the length of the session.
+func (s ChargingSession) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// GetChargingSessions reconstructs the charging sessions of a charging
+// station between start and end from its counter history. The API keeps
+// no history of the ChargeStationState, so a session is a run of intervals
+// in which the average power exceeds the threshold of WithSessionThreshold.
+// Sessions separated by less than one history interval are merged and the
+// times are only as precise as the history resolution. The counter is
+// converted to kWh from the counter unit of the device.
+func (c *Client) GetChargingSessions(ctx context.Context, deviceID string, start, end time.Time, opts ...CallOption) ([]ChargingSession, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("deviceID must not be empty")
+	}
+
+	threshold := c.callConfig(opts).sessionThreshold
+	if threshold < 0 {
+		return nil, fmt.Errorf("session threshold must not be negative, got %g", threshold)
+	}
+	if threshold == 0 {
+		threshold = sessionPowerThreshold
+	}
+	d, err := c.GetDevice(ctx, deviceID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := unitFactor("counter reading", deviceID, d.CounterReadingUnit, "kWh", counterUnits)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := sessionSegmenter{threshold: threshold, factor: factor}
+	err = c.StreamValuesInPastMultiple(ctx, deviceID, start, end, func(v Value) error {
+		seg.add(v)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return seg.finish(), nil
+}
+
+// sessionSegmenter splits a counter history into charging sessions.
+type sessionSegmenter struct {
+	// threshold is the power in W above which an interval counts as
+	// charging, factor converts the counter to kWh.
+	threshold float64
+	factor    float64
+
+	prev     *Value
+	current  *ChargingSession
+	sessions []ChargingSession
+}
+
+func (s *sessionSegmenter) add(v Value) {
+	prev := s.prev
+	s.prev = &v
+	if prev == nil || !v.Date.After(prev.Date) {
+		return
+	}
+
+	energy := (v.Value - prev.Value) * s.factor
+	power := energy / v.Date.Sub(prev.Date).Hours() * 1000
+	if power < s.threshold {
+		s.close()
+		return
+	}
+
+	if s.current == nil {
+		s.current = &ChargingSession{Start: prev.Date}
+	}
+	s.current.End = v.Date
+	s.current.Energy += energy
+	s.current.PeakPower = max(s.current.PeakPower, power)
+}
+
+func (s *sessionSegmenter) close() {
+	if s.current != nil {
+		s.sessions = append(s.sessions, *s.current)
+		s.current = nil
+	}
+}
+
+// finish closes an open session and returns all sessions.
+func (s *sessionSegmenter) finish() []ChargingSession {
+	s.close()
+	return s.sessions
+}
@@ -0,0 +1,88 @@
+package smartme_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_GetChargingSessions(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	charger := smartme.Device{Id: ptr("charger"), CounterReading: ptr(100.0)}
+	srv.AddDevice(charger)
+
+	// Monday to Wednesday: the EV profile charges on weekday evenings.
+	start := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	end := start.Add(3 * 24 * time.Hour)
+	srv.AddHistory("charger", smartmetest.GenerateHistory(charger, start, end, smartmetest.ProfileEVCharging)...)
+
+	client, _ := srv.Client()
+	sessions, err := client.GetChargingSessions(context.Background(), "charger", start, end)
+	if err != nil {
+		t.Fatalf("GetChargingSessions failed: %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("found %d sessions, want 3: %+v", len(sessions), sessions)
+	}
+
+	for i, s := range sessions {
+		day := start.AddDate(0, 0, i)
+		if want := day.Add(18 * time.Hour); !s.Start.Equal(want) {
+			t.Errorf("session %d starts at %v, want %v", i, s.Start, want)
+		}
+		if s.Duration() != 3*time.Hour+30*time.Minute {
+			t.Errorf("session %d lasts %v, want 3h30m", i, s.Duration())
+		}
+		// 11 kW for 3.5 hours, with up to 5% less power.
+		if s.Energy < 36 || s.Energy > 38.6 {
+			t.Errorf("session %d delivered %.2f kWh", i, s.Energy)
+		}
+		if s.PeakPower < 10000 || s.PeakPower > 11100 {
+			t.Errorf("session %d peak power %.0f W", i, s.PeakPower)
+		}
+	}
+}
+
+func TestClient_GetChargingSessionsThreshold(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	id := srv.AddDevice(smartme.Device{CounterReading: ptr(4000.0), CounterReadingUnit: ptr("Wh")})
+	start := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	// 2 kW, 0.5 kW and 1.5 kW.
+	srv.AddHistory(id,
+		smartme.Value{Date: start, Value: 0},
+		smartme.Value{Date: start.Add(time.Hour), Value: 2000},
+		smartme.Value{Date: start.Add(2 * time.Hour), Value: 2500},
+		smartme.Value{Date: end, Value: 4000},
+	)
+
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	sessions, err := client.GetChargingSessions(ctx, id, start, end)
+	if err != nil {
+		t.Fatalf("GetChargingSessions failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Energy != 2 || sessions[1].Energy != 1.5 || sessions[0].PeakPower != 2000 {
+		t.Errorf("sessions = %+v, want 2 kWh and 1.5 kWh", sessions)
+	}
+
+	sessions, err = client.GetChargingSessions(ctx, id, start, end, smartme.WithSessionThreshold(400))
+	if err != nil {
+		t.Fatalf("GetChargingSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Energy != 4 {
+		t.Errorf("sessions = %+v, want one of 4 kWh", sessions)
+	}
+
+	if _, err := client.GetChargingSessions(ctx, id, start, end, smartme.WithSessionThreshold(-1)); err == nil {
+		t.Error("GetChargingSessions accepted a negative threshold")
+	}
+}
@@ -0,0 +1,407 @@
+// Package smartmemock provides a mock implementation of smartme.API.
+//
+// Each method of API is backed by a function field. Unset functions return
+// ErrNotImplemented, so a test only has to provide the calls it expects:
+//
+//	mock := &smartmemock.API{
+//		GetDevicesFunc: func(ctx context.Context) ([]smartme.Device, error) {
+//			return devices, nil
+//		},
+//	}
+//	report, err := buildReport(ctx, mock)
+package smartmemock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// ErrNotImplemented is returned by methods whose function field is nil.
+var ErrNotImplemented = errors.New("smartmemock: method not implemented")
+
+// Call records a single invocation of a mocked method.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// API is a mock implementation of smartme.API.
+// It is safe for concurrent use. Call options are accepted but not passed
+// to the funcs, since they only affect the HTTP request.
+type API struct {
+	GetDevicesFunc              func(ctx context.Context) ([]smartme.Device, error)
+	GetDeviceFunc               func(ctx context.Context, deviceID string) (*smartme.Device, error)
+	CreateOrUpdateDeviceFunc    func(ctx context.Context, device smartme.Device) (*smartme.Device, error)
+	GetValuesFunc               func(ctx context.Context, deviceID string) (*smartme.DeviceValues, error)
+	GetDevicesWithValuesFunc    func(ctx context.Context) ([]smartme.DeviceSnapshot, error)
+	GetValuesConcurrentlyFunc   func(ctx context.Context, deviceIDs []string) ([]smartme.DeviceValues, error)
+	GetValuesInPastFunc         func(ctx context.Context, deviceID string, date time.Time) (*smartme.Value, error)
+	GetValuesAtFunc             func(ctx context.Context, deviceID string, at time.Time) (*smartme.DeviceValues, error)
+	GetValuesInPastMultipleFunc func(ctx context.Context, deviceID string, startDate, endDate time.Time) ([]smartme.Value, error)
+
+	// StreamValuesInPastMultipleFunc is optional; if nil, the values returned
+	// by GetValuesInPastMultipleFunc are streamed.
+	StreamValuesInPastMultipleFunc func(ctx context.Context, deviceID string, startDate, endDate time.Time, fn func(smartme.Value) error) error
+
+	GetActionsFunc         func(ctx context.Context, deviceID string) ([]smartme.ActionInfo, error)
+	PerformActionsFunc     func(ctx context.Context, deviceID string, actions []smartme.Action) error
+	ExecuteActionsBulkFunc func(ctx context.Context, actions map[string][]smartme.Action) error
+	CheckActionsBulkFunc   func(ctx context.Context, actions map[string][]smartme.Action) error
+	SetActiveTariffFunc    func(ctx context.Context, deviceID string, tariff int32) error
+	StartChargingFunc      func(ctx context.Context, deviceID string) error
+	StopChargingFunc       func(ctx context.Context, deviceID string) error
+
+	SetMaxChargingCurrentFunc func(ctx context.Context, deviceID string, amps float64) error
+	CompareConsumptionFunc    func(ctx context.Context, deviceIDs []string, periodA, periodB smartme.Period) (*smartme.ConsumptionReport, error)
+	GetBatteryStateFunc       func(ctx context.Context, deviceID string, capacity float64) (*smartme.BatteryState, error)
+	GetBatteryHistoryFunc     func(ctx context.Context, deviceID string, start, end time.Time, capacity float64) ([]smartme.BatterySample, error)
+	GetChargingSessionsFunc   func(ctx context.Context, deviceID string, start, end time.Time) ([]smartme.ChargingSession, error)
+	DailyConsumptionFunc      func(ctx context.Context, deviceID string, month time.Time) ([]smartme.ConsumptionPoint, error)
+	MonthlyConsumptionFunc    func(ctx context.Context, deviceID string, year int) ([]smartme.ConsumptionPoint, error)
+
+	// FindDeviceByNameFunc is optional; if nil, the device is searched in
+	// the result of GetDevicesFunc.
+	FindDeviceByNameFunc func(ctx context.Context, name string) (*smartme.Device, error)
+
+	// GetActivePowerFunc, GetCounterReadingFunc and GetTemperatureFunc are
+	// optional; if nil, the quantity is taken from GetDeviceFunc without
+	// unit conversion.
+	GetActivePowerFunc    func(ctx context.Context, deviceID string) (float64, error)
+	GetCounterReadingFunc func(ctx context.Context, deviceID string) (float64, error)
+	GetTemperatureFunc    func(ctx context.Context, deviceID string) (float64, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Ensure API implements smartme.API.
+var _ smartme.API = (*API)(nil)
+
+// Calls returns all recorded invocations in the order they happened.
+func (m *API) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]Call, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// CallCount returns how often the given method was called.
+func (m *API) CallCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, c := range m.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *API) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+// GetDevices calls GetDevicesFunc.
+func (m *API) GetDevices(ctx context.Context, opts ...smartme.CallOption) ([]smartme.Device, error) {
+	m.record("GetDevices")
+	if m.GetDevicesFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetDevicesFunc(ctx)
+}
+
+// GetDevice calls GetDeviceFunc.
+func (m *API) GetDevice(ctx context.Context, deviceID string, opts ...smartme.CallOption) (*smartme.Device, error) {
+	m.record("GetDevice", deviceID)
+	if m.GetDeviceFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetDeviceFunc(ctx, deviceID)
+}
+
+// GetActivePower calls GetActivePowerFunc or reads the active power of the
+// device returned by GetDeviceFunc.
+func (m *API) GetActivePower(ctx context.Context, deviceID string, opts ...smartme.CallOption) (float64, error) {
+	m.record("GetActivePower", deviceID)
+	if m.GetActivePowerFunc != nil {
+		return m.GetActivePowerFunc(ctx, deviceID)
+	}
+	return m.deviceField(ctx, deviceID, func(d *smartme.Device) *float64 { return d.ActivePower })
+}
+
+// GetCounterReading calls GetCounterReadingFunc or reads the counter of the
+// device returned by GetDeviceFunc.
+func (m *API) GetCounterReading(ctx context.Context, deviceID string, opts ...smartme.CallOption) (float64, error) {
+	m.record("GetCounterReading", deviceID)
+	if m.GetCounterReadingFunc != nil {
+		return m.GetCounterReadingFunc(ctx, deviceID)
+	}
+	return m.deviceField(ctx, deviceID, func(d *smartme.Device) *float64 { return d.CounterReading })
+}
+
+// GetTemperature calls GetTemperatureFunc or reads the temperature of the
+// device returned by GetDeviceFunc.
+func (m *API) GetTemperature(ctx context.Context, deviceID string, opts ...smartme.CallOption) (float64, error) {
+	m.record("GetTemperature", deviceID)
+	if m.GetTemperatureFunc != nil {
+		return m.GetTemperatureFunc(ctx, deviceID)
+	}
+	return m.deviceField(ctx, deviceID, func(d *smartme.Device) *float64 { return d.Temperature })
+}
+
+func (m *API) deviceField(ctx context.Context, deviceID string, field func(*smartme.Device) *float64) (float64, error) {
+	if m.GetDeviceFunc == nil {
+		return 0, ErrNotImplemented
+	}
+	d, err := m.GetDeviceFunc(ctx, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	v := field(d)
+	if v == nil {
+		return 0, smartme.ErrValueNotAvailable
+	}
+	return *v, nil
+}
+
+// FindDeviceByName calls FindDeviceByNameFunc or searches the devices
+// returned by GetDevicesFunc.
+func (m *API) FindDeviceByName(ctx context.Context, name string, opts ...smartme.CallOption) (*smartme.Device, error) {
+	m.record("FindDeviceByName", name)
+	if m.FindDeviceByNameFunc != nil {
+		return m.FindDeviceByNameFunc(ctx, name)
+	}
+	if m.GetDevicesFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	devices, err := m.GetDevicesFunc(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		if devices[i].Name != nil && *devices[i].Name == name {
+			return &devices[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", smartme.ErrDeviceNotFound, name)
+}
+
+// GetValues calls GetValuesFunc.
+func (m *API) GetValues(ctx context.Context, deviceID string, opts ...smartme.CallOption) (*smartme.DeviceValues, error) {
+	m.record("GetValues", deviceID)
+	if m.GetValuesFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetValuesFunc(ctx, deviceID)
+}
+
+// GetDevicesWithValues calls GetDevicesWithValuesFunc.
+func (m *API) GetDevicesWithValues(ctx context.Context, opts ...smartme.CallOption) ([]smartme.DeviceSnapshot, error) {
+	m.record("GetDevicesWithValues")
+	if m.GetDevicesWithValuesFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetDevicesWithValuesFunc(ctx)
+}
+
+// GetValuesConcurrently calls GetValuesConcurrentlyFunc.
+func (m *API) GetValuesConcurrently(ctx context.Context, deviceIDs []string, opts ...smartme.CallOption) ([]smartme.DeviceValues, error) {
+	m.record("GetValuesConcurrently", deviceIDs)
+	if m.GetValuesConcurrentlyFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetValuesConcurrentlyFunc(ctx, deviceIDs)
+}
+
+// GetValuesInPast calls GetValuesInPastFunc.
+func (m *API) GetValuesInPast(ctx context.Context, deviceID string, date time.Time, opts ...smartme.CallOption) (*smartme.Value, error) {
+	m.record("GetValuesInPast", deviceID, date)
+	if m.GetValuesInPastFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetValuesInPastFunc(ctx, deviceID, date)
+}
+
+// GetValuesAt calls GetValuesAtFunc.
+func (m *API) GetValuesAt(ctx context.Context, deviceID string, at time.Time, opts ...smartme.CallOption) (*smartme.DeviceValues, error) {
+	m.record("GetValuesAt", deviceID, at)
+	if m.GetValuesAtFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetValuesAtFunc(ctx, deviceID, at)
+}
+
+// GetValuesInPastMultiple calls GetValuesInPastMultipleFunc.
+func (m *API) GetValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, opts ...smartme.CallOption) ([]smartme.Value, error) {
+	m.record("GetValuesInPastMultiple", deviceID, startDate, endDate)
+	if m.GetValuesInPastMultipleFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetValuesInPastMultipleFunc(ctx, deviceID, startDate, endDate)
+}
+
+// StreamValuesInPastMultiple calls StreamValuesInPastMultipleFunc or streams
+// the result of GetValuesInPastMultipleFunc.
+func (m *API) StreamValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, fn func(smartme.Value) error, opts ...smartme.CallOption) error {
+	m.record("StreamValuesInPastMultiple", deviceID, startDate, endDate)
+	if m.StreamValuesInPastMultipleFunc != nil {
+		return m.StreamValuesInPastMultipleFunc(ctx, deviceID, startDate, endDate, fn)
+	}
+	if m.GetValuesInPastMultipleFunc == nil {
+		return ErrNotImplemented
+	}
+	values, err := m.GetValuesInPastMultipleFunc(ctx, deviceID, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateOrUpdateDevice calls CreateOrUpdateDeviceFunc.
+func (m *API) CreateOrUpdateDevice(ctx context.Context, device smartme.Device, opts ...smartme.CallOption) (*smartme.Device, error) {
+	m.record("CreateOrUpdateDevice", device)
+	if m.CreateOrUpdateDeviceFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.CreateOrUpdateDeviceFunc(ctx, device)
+}
+
+// GetActions calls GetActionsFunc.
+func (m *API) GetActions(ctx context.Context, deviceID string, opts ...smartme.CallOption) ([]smartme.ActionInfo, error) {
+	m.record("GetActions", deviceID)
+	if m.GetActionsFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetActionsFunc(ctx, deviceID)
+}
+
+// PerformActions calls PerformActionsFunc.
+func (m *API) PerformActions(ctx context.Context, deviceID string, actions []smartme.Action, opts ...smartme.CallOption) error {
+	m.record("PerformActions", deviceID, actions)
+	if m.PerformActionsFunc == nil {
+		return ErrNotImplemented
+	}
+	return m.PerformActionsFunc(ctx, deviceID, actions)
+}
+
+// ExecuteActionsBulk calls ExecuteActionsBulkFunc.
+func (m *API) ExecuteActionsBulk(ctx context.Context, actions map[string][]smartme.Action, opts ...smartme.CallOption) error {
+	m.record("ExecuteActionsBulk", actions)
+	if m.ExecuteActionsBulkFunc == nil {
+		return ErrNotImplemented
+	}
+	return m.ExecuteActionsBulkFunc(ctx, actions)
+}
+
+// CheckActionsBulk calls CheckActionsBulkFunc.
+func (m *API) CheckActionsBulk(ctx context.Context, actions map[string][]smartme.Action, opts ...smartme.CallOption) error {
+	m.record("CheckActionsBulk", actions)
+	if m.CheckActionsBulkFunc == nil {
+		return ErrNotImplemented
+	}
+	return m.CheckActionsBulkFunc(ctx, actions)
+}
+
+// SetActiveTariff calls SetActiveTariffFunc.
+func (m *API) SetActiveTariff(ctx context.Context, deviceID string, tariff int32, opts ...smartme.CallOption) error {
+	m.record("SetActiveTariff", deviceID, tariff)
+	if m.SetActiveTariffFunc == nil {
+		return ErrNotImplemented
+	}
+	return m.SetActiveTariffFunc(ctx, deviceID, tariff)
+}
+
+// StartCharging calls StartChargingFunc.
+func (m *API) StartCharging(ctx context.Context, deviceID string, opts ...smartme.CallOption) error {
+	m.record("StartCharging", deviceID)
+	if m.StartChargingFunc == nil {
+		return ErrNotImplemented
+	}
+	return m.StartChargingFunc(ctx, deviceID)
+}
+
+// StopCharging calls StopChargingFunc.
+func (m *API) StopCharging(ctx context.Context, deviceID string, opts ...smartme.CallOption) error {
+	m.record("StopCharging", deviceID)
+	if m.StopChargingFunc == nil {
+		return ErrNotImplemented
+	}
+	return m.StopChargingFunc(ctx, deviceID)
+}
+
+// SetMaxChargingCurrent calls SetMaxChargingCurrentFunc.
+func (m *API) SetMaxChargingCurrent(ctx context.Context, deviceID string, amps float64, opts ...smartme.CallOption) error {
+	m.record("SetMaxChargingCurrent", deviceID, amps)
+	if m.SetMaxChargingCurrentFunc == nil {
+		return ErrNotImplemented
+	}
+	return m.SetMaxChargingCurrentFunc(ctx, deviceID, amps)
+}
+
+// GetChargingSessions calls GetChargingSessionsFunc.
+func (m *API) GetChargingSessions(ctx context.Context, deviceID string, start, end time.Time, opts ...smartme.CallOption) ([]smartme.ChargingSession, error) {
+	m.record("GetChargingSessions", deviceID, start, end)
+	if m.GetChargingSessionsFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetChargingSessionsFunc(ctx, deviceID, start, end)
+}
+
+// DailyConsumption calls DailyConsumptionFunc.
+func (m *API) DailyConsumption(ctx context.Context, deviceID string, month time.Time, opts ...smartme.CallOption) ([]smartme.ConsumptionPoint, error) {
+	m.record("DailyConsumption", deviceID, month)
+	if m.DailyConsumptionFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.DailyConsumptionFunc(ctx, deviceID, month)
+}
+
+// MonthlyConsumption calls MonthlyConsumptionFunc.
+func (m *API) MonthlyConsumption(ctx context.Context, deviceID string, year int, opts ...smartme.CallOption) ([]smartme.ConsumptionPoint, error) {
+	m.record("MonthlyConsumption", deviceID, year)
+	if m.MonthlyConsumptionFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.MonthlyConsumptionFunc(ctx, deviceID, year)
+}
+
+// GetBatteryState calls GetBatteryStateFunc.
+func (m *API) GetBatteryState(ctx context.Context, deviceID string, capacity float64, opts ...smartme.CallOption) (*smartme.BatteryState, error) {
+	m.record("GetBatteryState", deviceID, capacity)
+	if m.GetBatteryStateFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetBatteryStateFunc(ctx, deviceID, capacity)
+}
+
+// GetBatteryHistory calls GetBatteryHistoryFunc.
+func (m *API) GetBatteryHistory(ctx context.Context, deviceID string, start, end time.Time, capacity float64, opts ...smartme.CallOption) ([]smartme.BatterySample, error) {
+	m.record("GetBatteryHistory", deviceID, start, end, capacity)
+	if m.GetBatteryHistoryFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.GetBatteryHistoryFunc(ctx, deviceID, start, end, capacity)
+}
+
+// CompareConsumption calls CompareConsumptionFunc.
+func (m *API) CompareConsumption(ctx context.Context, deviceIDs []string, periodA, periodB smartme.Period, opts ...smartme.CallOption) (*smartme.ConsumptionReport, error) {
+	m.record("CompareConsumption", deviceIDs, periodA, periodB)
+	if m.CompareConsumptionFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return m.CompareConsumptionFunc(ctx, deviceIDs, periodA, periodB)
+}
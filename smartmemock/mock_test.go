@@ -0,0 +1,62 @@
+package smartmemock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmemock"
+)
+
+func TestAPI_GetValues(t *testing.T) {
+	mock := &smartmemock.API{
+		GetValuesFunc: func(ctx context.Context, deviceID string) (*smartme.DeviceValues, error) {
+			return &smartme.DeviceValues{DeviceID: deviceID}, nil
+		},
+	}
+
+	var api smartme.API = mock
+	values, err := api.GetValues(context.Background(), "dev-1")
+	if err != nil {
+		t.Fatalf("GetValues returned an unexpected error: %v", err)
+	}
+	if values.DeviceID != "dev-1" {
+		t.Errorf("DeviceID = %q, want %q", values.DeviceID, "dev-1")
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 1 || calls[0].Method != "GetValues" || calls[0].Args[0] != "dev-1" {
+		t.Errorf("unexpected calls recorded: %+v", calls)
+	}
+}
+
+func TestAPI_NotImplemented(t *testing.T) {
+	mock := &smartmemock.API{}
+
+	_, err := mock.GetDevices(context.Background())
+	if !errors.Is(err, smartmemock.ErrNotImplemented) {
+		t.Errorf("GetDevices error = %v, want ErrNotImplemented", err)
+	}
+	if n := mock.CallCount("GetDevices"); n != 1 {
+		t.Errorf("CallCount = %d, want 1", n)
+	}
+}
+
+func TestAPI_FindDeviceByNameFallback(t *testing.T) {
+	name := "Garage"
+	mock := &smartmemock.API{
+		GetDevicesFunc: func(ctx context.Context) ([]smartme.Device, error) {
+			return []smartme.Device{{Name: &name}}, nil
+		},
+	}
+
+	d, err := mock.FindDeviceByName(context.Background(), "Garage")
+	if err != nil || d.Name == nil || *d.Name != "Garage" {
+		t.Errorf("FindDeviceByName = %+v, %v", d, err)
+	}
+	_, err = mock.FindDeviceByName(context.Background(), "Cellar")
+	if !errors.Is(err, smartme.ErrDeviceNotFound) {
+		t.Errorf("FindDeviceByName error = %v, want ErrDeviceNotFound", err)
+	}
+}
@@ -0,0 +1,116 @@
+package smartmetest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Clock is a manually advanced smartme.Clock for deterministic tests.
+// Time only moves when Advance or Set is called. It is safe for concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+	// added is closed when After adds a timer.
+	added chan struct{}
+}
+
+type clockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewClock returns a Clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now implements smartme.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After implements smartme.Clock. The channel fires once the clock has been
+// advanced by at least d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, clockWaiter{at: c.now.Add(d), ch: ch})
+	if c.added != nil {
+		close(c.added)
+		c.added = nil
+	}
+	return ch
+}
+
+// Advance moves the clock forward by d and fires all timers that are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	t := c.now.Add(d)
+	c.mu.Unlock()
+	c.Set(t)
+}
+
+// Set moves the clock to t and fires all timers that are due.
+// Timers fire in order of their deadline.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].at.Before(c.waiters[j].at)
+	})
+	n := 0
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			c.waiters[n] = w
+			n++
+			continue
+		}
+		w.ch <- t
+	}
+	c.waiters = c.waiters[:n]
+}
+
+// Timers returns the number of pending timers created by After. Tests use it
+// to wait until the code under test is blocked before advancing the clock.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// WaitForTimers blocks until at least n timers created by After are
+// pending, i.e. the code under test is blocked on the clock and the test can
+// advance it. It fails the test if that takes more than 5 seconds.
+func (c *Clock) WaitForTimers(tb testing.TB, n int) {
+	tb.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters)
+		if c.added == nil {
+			c.added = make(chan struct{})
+		}
+		added := c.added
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		select {
+		case <-added:
+		case <-timeout:
+			tb.Fatalf("timed out waiting for %d timers, %d pending", n, pending)
+		}
+	}
+}
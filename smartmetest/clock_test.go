@@ -0,0 +1,62 @@
+package smartmetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+var _ smartme.Clock = (*smartmetest.Clock)(nil)
+
+func TestClock_AfterFiresOnAdvance(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := smartmetest.NewClock(start)
+
+	late := clock.After(2 * time.Minute)
+	early := clock.After(time.Minute)
+	if n := clock.Timers(); n != 2 {
+		t.Fatalf("Timers() = %d, want 2", n)
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case got := <-early:
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Errorf("early timer fired at %v", got)
+		}
+	default:
+		t.Fatal("early timer did not fire")
+	}
+	select {
+	case <-late:
+		t.Fatal("late timer fired too early")
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case <-late:
+	default:
+		t.Fatal("late timer did not fire")
+	}
+	if n := clock.Timers(); n != 0 {
+		t.Errorf("Timers() = %d after firing, want 0", n)
+	}
+	if got := clock.Now(); !got.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Now() = %v", got)
+	}
+}
+
+func TestClock_WaitForTimers(t *testing.T) {
+	clock := smartmetest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	go func() {
+		clock.After(time.Minute)
+		clock.After(time.Hour)
+	}()
+	clock.WaitForTimers(t, 2)
+	if n := clock.Timers(); n != 2 {
+		t.Errorf("Timers() = %d after waiting, want 2", n)
+	}
+}
@@ -0,0 +1,153 @@
+package smartmetest
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Faults describes randomized and scripted failures injected by the Server.
+// Rates are probabilities between 0 and 1 evaluated per request. Request
+// counts refer to requests that passed authentication.
+type Faults struct {
+	// Seed makes the random faults reproducible.
+	Seed int64
+
+	// ErrorRate is the probability of answering with a random 5xx status.
+	ErrorRate float64
+
+	// SlowRate is the probability of delaying a response by SlowLatency.
+	SlowRate    float64
+	SlowLatency time.Duration
+
+	// TruncateRate is the probability of cutting the response body in half
+	// while announcing the full Content-Length.
+	TruncateRate float64
+
+	// RateLimitAfter starts a 429 storm after this many requests. The storm
+	// lasts RateLimitCount requests and announces RetryAfter.
+	RateLimitAfter int
+	RateLimitCount int
+	RetryAfter     time.Duration
+
+	// AuthExpiresAfter makes all requests after this many fail with 401,
+	// as if the credentials had been revoked during the run.
+	AuthExpiresAfter int
+}
+
+// chaos holds the runtime state of the configured faults.
+type chaos struct {
+	faults Faults
+	rng    *rand.Rand
+	count  int
+}
+
+// fault is the decision taken for a single request.
+type fault struct {
+	status     int
+	retryAfter time.Duration
+	delay      time.Duration
+	truncate   bool
+}
+
+var serverErrors = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// SetFaults enables fault injection. Passing the zero Faults disables it.
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f == (Faults{}) {
+		s.chaos = nil
+		return
+	}
+	s.chaos = &chaos{faults: f, rng: rand.New(rand.NewSource(f.Seed))}
+}
+
+// nextFault decides which fault, if any, applies to the next request.
+func (s *Server) nextFault() fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.chaos
+	if c == nil {
+		return fault{}
+	}
+	c.count++
+	f := c.faults
+
+	var ft fault
+	if f.SlowRate > 0 && c.rng.Float64() < f.SlowRate {
+		ft.delay = f.SlowLatency
+	}
+	switch {
+	case f.AuthExpiresAfter > 0 && c.count > f.AuthExpiresAfter:
+		ft.status = http.StatusUnauthorized
+	case f.RateLimitAfter > 0 && c.count > f.RateLimitAfter && c.count <= f.RateLimitAfter+f.RateLimitCount:
+		ft.status = http.StatusTooManyRequests
+		ft.retryAfter = f.RetryAfter
+	case f.ErrorRate > 0 && c.rng.Float64() < f.ErrorRate:
+		ft.status = serverErrors[c.rng.Intn(len(serverErrors))]
+	case f.TruncateRate > 0 && c.rng.Float64() < f.TruncateRate:
+		ft.truncate = true
+	}
+	return ft
+}
+
+// apply delays and writes an error response if required.
+// It reports whether the request should still be served.
+func (f fault) apply(w http.ResponseWriter, r *http.Request) bool {
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-r.Context().Done():
+			return false
+		}
+	}
+	switch f.status {
+	case 0:
+		return true
+	case http.StatusUnauthorized:
+		http.Error(w, "Authorization has been denied for this request.", f.status)
+	case http.StatusTooManyRequests:
+		if f.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(f.retryAfter.Seconds()+0.5)))
+		}
+		http.Error(w, http.StatusText(f.status), f.status)
+	default:
+		http.Error(w, http.StatusText(f.status), f.status)
+	}
+	return false
+}
+
+// truncatingWriter buffers the response and sends only half of the body
+// while announcing the full length, so the client sees an unexpected EOF.
+type truncatingWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *truncatingWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *truncatingWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	body := w.buf.Bytes()
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body[:len(body)/2])
+}
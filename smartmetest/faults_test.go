@@ -0,0 +1,87 @@
+package smartmetest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func statusOf(err error) int {
+	var apiErr *smartme.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+func TestFaults_RateLimitStormAndAuthExpiry(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.SetFaults(smartmetest.Faults{
+		RateLimitAfter:   1,
+		RateLimitCount:   2,
+		RetryAfter:       time.Second,
+		AuthExpiresAfter: 4,
+	})
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	want := []int{0, http.StatusTooManyRequests, http.StatusTooManyRequests, 0, http.StatusUnauthorized}
+	for i, w := range want {
+		_, err := client.GetDevices(ctx)
+		if got := statusOf(err); got != w {
+			t.Errorf("request %d: status %d (err %v), want %d", i+1, got, err, w)
+		}
+	}
+}
+
+func TestFaults_Truncate(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.Populate(3, time.Now().Add(-time.Hour), time.Now())
+	srv.SetFaults(smartmetest.Faults{TruncateRate: 1})
+	client, _ := srv.Client()
+
+	_, err := client.GetDevices(context.Background())
+	if err == nil {
+		t.Fatal("GetDevices should fail on a truncated body")
+	}
+	if statusOf(err) != 0 {
+		t.Errorf("expected a transport or decode error, got %v", err)
+	}
+}
+
+func TestFaults_ErrorRateIsReproducible(t *testing.T) {
+	run := func() []int {
+		srv := smartmetest.NewServer()
+		defer srv.Close()
+		srv.SetFaults(smartmetest.Faults{Seed: 42, ErrorRate: 0.5})
+		client, _ := srv.Client()
+
+		var statuses []int
+		for i := 0; i < 20; i++ {
+			_, err := client.GetDevices(context.Background())
+			statuses = append(statuses, statusOf(err))
+		}
+		return statuses
+	}
+
+	a, b := run(), run()
+	failures := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("fault sequence differs at request %d: %d vs %d", i, a[i], b[i])
+		}
+		if a[i] >= 500 {
+			failures++
+		}
+	}
+	if failures == 0 || failures == len(a) {
+		t.Errorf("got %d failures out of %d, expected a mix", failures, len(a))
+	}
+}
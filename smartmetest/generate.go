@@ -0,0 +1,174 @@
+package smartmetest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// HistoryInterval is the spacing of the values created by GenerateHistory.
+const HistoryInterval = 15 * time.Minute
+
+// Profile selects the load curve created by GenerateHistory.
+type Profile int
+
+const (
+	// ProfileHousehold is a typical residential consumption curve with
+	// morning and evening peaks.
+	ProfileHousehold Profile = iota
+	// ProfilePV is the production of a 5 kWp photovoltaic system.
+	ProfilePV
+	// ProfileEVCharging is an 11 kW charging station used on weekday evenings.
+	ProfileEVCharging
+)
+
+// GenerateDevices creates n realistic devices. The result is deterministic:
+// the i-th device is always the same, whatever n is.
+func GenerateDevices(n int) []smartme.Device {
+	rng := rand.New(rand.NewSource(1))
+	devices := make([]smartme.Device, 0, n)
+	valueDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+
+	for i := 0; i < n; i++ {
+		d := smartme.Device{
+			Id:        ptr(fmt.Sprintf("00000000-0000-4000-8000-%012d", i+1)),
+			Serial:    ptr(int64(10000000 + rng.Intn(89999999))),
+			ValueDate: ptr(valueDate),
+		}
+
+		switch {
+		case i%10 == 8:
+			d.Name = ptr(fmt.Sprintf("Water %d", i+1))
+			d.DeviceEnergyType = ptr(smartme.MeterTypeWater)
+			d.CounterReading = ptr(round(rng.Float64()*500, 3))
+			d.CounterReadingUnit = ptr("m³")
+			d.FlowRate = ptr(round(rng.Float64()*0.5, 3))
+		case i%10 == 9:
+			d.Name = ptr(fmt.Sprintf("Heat %d", i+1))
+			d.DeviceEnergyType = ptr(smartme.MeterTypeHeat)
+			d.CounterReading = ptr(round(rng.Float64()*20000, 2))
+			d.CounterReadingUnit = ptr("kWh")
+			d.Temperature = ptr(round(40+rng.Float64()*20, 1))
+		default:
+			voltage := round(225+rng.Float64()*10, 1)
+			power := round(200+rng.Float64()*3000, 1)
+			d.Name = ptr(fmt.Sprintf("Apartment %d", i+1))
+			d.DeviceEnergyType = ptr(smartme.MeterTypeElectricity)
+			d.MeterSubType = ptr(smartme.MeterSubTypeElectricity)
+			d.FamilyType = ptr(smartme.MeterFamilyTypeSmartMe3PhaseMeter80A)
+			d.ActivePower = ptr(power)
+			d.ActivePowerUnit = ptr("W")
+			d.CounterReading = ptr(round(rng.Float64()*50000, 2))
+			d.CounterReadingUnit = ptr("kWh")
+			d.Voltage = ptr(voltage)
+			d.Current = ptr(round(power/voltage/3, 2))
+			d.PowerFactor = ptr(round(0.9+rng.Float64()*0.1, 2))
+			d.ActiveTariff = ptr(int32(1))
+			d.SwitchOn = ptr(true)
+		}
+
+		devices = append(devices, d)
+	}
+
+	return devices
+}
+
+// GenerateHistory creates cumulative counter readings (in kWh) for a device
+// between start and end, spaced by HistoryInterval. The result depends only
+// on the device ID, the time range and the profile.
+func GenerateHistory(device smartme.Device, start, end time.Time, profile Profile) []smartme.Value {
+	h := fnv.New64a()
+	if device.Id != nil {
+		h.Write([]byte(*device.Id))
+	}
+	rng := rand.New(rand.NewSource(int64(h.Sum64()) + int64(profile)))
+
+	counter := 0.0
+	if device.CounterReading != nil {
+		counter = *device.CounterReading
+	}
+
+	start = start.Truncate(HistoryInterval)
+	var values []smartme.Value
+	var cloudiness float64
+	var day time.Time
+	for t := start; !t.After(end); t = t.Add(HistoryInterval) {
+		if d := t.Truncate(24 * time.Hour); !d.Equal(day) {
+			day = d
+			cloudiness = rng.Float64() * 0.7
+		}
+		values = append(values, smartme.Value{Date: t, Value: round(counter, 3)})
+
+		power := profilePower(profile, t, cloudiness, rng)
+		counter += power / 1000 * HistoryInterval.Hours()
+	}
+
+	return values
+}
+
+// profilePower returns the average power in W of a profile at time t.
+func profilePower(p Profile, t time.Time, cloudiness float64, rng *rand.Rand) float64 {
+	hour := float64(t.Hour()) + float64(t.Minute())/60
+
+	switch p {
+	case ProfilePV:
+		if hour < 6 || hour > 20 {
+			return 0
+		}
+		bell := math.Sin((hour - 6) / 14 * math.Pi)
+		return 5000 * bell * bell * (1 - cloudiness) * (0.9 + rng.Float64()*0.2)
+	case ProfileEVCharging:
+		weekday := t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+		if weekday && hour >= 18 && hour < 21.5 {
+			return 11000 * (0.95 + rng.Float64()*0.05)
+		}
+		return 5
+	default:
+		base := 150 + rng.Float64()*100
+		switch {
+		case hour >= 6.5 && hour < 8.5:
+			base += 1200
+		case hour >= 11.5 && hour < 13:
+			base += 800
+		case hour >= 18 && hour < 21:
+			base += 2000
+		}
+		return base * (0.8 + rng.Float64()*0.4)
+	}
+}
+
+// Populate fills the server with n generated devices, their current values
+// and a household history between start and end. It returns the devices.
+func (s *Server) Populate(n int, start, end time.Time) []smartme.Device {
+	devices := GenerateDevices(n)
+	for _, d := range devices {
+		id := s.AddDevice(d)
+		history := GenerateHistory(d, start, end, ProfileHousehold)
+		s.AddHistory(id, history...)
+
+		values := smartme.DeviceValues{DeviceID: id, Date: end}
+		if len(history) > 0 {
+			last := history[len(history)-1]
+			values.Date = last.Date
+			values.Values = append(values.Values, smartme.ObisValue{Obis: smartme.ObisActiveEnergyImport, Value: last.Value})
+		}
+		if d.ActivePower != nil {
+			values.Values = append(values.Values, smartme.ObisValue{Obis: smartme.ObisActivePower, Value: *d.ActivePower})
+		}
+		s.SetValues(values)
+	}
+	return devices
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,70 @@
+package smartmetest_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestGenerateDevices_Deterministic(t *testing.T) {
+	a := smartmetest.GenerateDevices(20)
+	b := smartmetest.GenerateDevices(10)
+
+	if len(a) != 20 || len(b) != 10 {
+		t.Fatalf("got %d and %d devices", len(a), len(b))
+	}
+	if !reflect.DeepEqual(a[:10], b) {
+		t.Error("the first devices differ between calls")
+	}
+	seen := make(map[string]bool)
+	for _, d := range a {
+		if d.Id == nil || seen[*d.Id] {
+			t.Fatalf("missing or duplicate device ID in %+v", d)
+		}
+		seen[*d.Id] = true
+	}
+}
+
+func TestGenerateHistory(t *testing.T) {
+	device := smartmetest.GenerateDevices(1)[0]
+	start := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC) // a Monday
+	end := start.Add(24 * time.Hour)
+
+	for _, profile := range []smartmetest.Profile{smartmetest.ProfileHousehold, smartmetest.ProfilePV, smartmetest.ProfileEVCharging} {
+		values := smartmetest.GenerateHistory(device, start, end, profile)
+		if want := int(24*time.Hour/smartmetest.HistoryInterval) + 1; len(values) != want {
+			t.Fatalf("profile %d: got %d values, want %d", profile, len(values), want)
+		}
+		for i := 1; i < len(values); i++ {
+			if values[i].Value < values[i-1].Value {
+				t.Fatalf("profile %d: counter decreases at %s", profile, values[i].Date)
+			}
+		}
+		if consumed := values[len(values)-1].Value - values[0].Value; consumed <= 0 {
+			t.Errorf("profile %d: no energy over a day", profile)
+		}
+		if again := smartmetest.GenerateHistory(device, start, end, profile); !reflect.DeepEqual(values, again) {
+			t.Errorf("profile %d: history is not deterministic", profile)
+		}
+	}
+}
+
+func TestServer_Populate(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	end := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	devices := srv.Populate(5, end.Add(-24*time.Hour), end)
+	client, _ := srv.Client()
+
+	values, err := client.GetValuesInPastMultiple(context.Background(), *devices[0].Id, end.Add(-time.Hour), end)
+	if err != nil {
+		t.Fatalf("GetValuesInPastMultiple returned an unexpected error: %v", err)
+	}
+	if len(values) != 5 {
+		t.Errorf("got %d values for one hour, want 5", len(values))
+	}
+}
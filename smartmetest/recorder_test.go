@@ -0,0 +1,71 @@
+package smartmetest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	name := "Recorded meter"
+	srv.AddDevice(smartme.Device{Name: &name})
+
+	fixture := filepath.Join(t.TempDir(), "devices.json")
+
+	// Record against the fake server.
+	rec, err := smartmetest.NewRecorder(fixture, smartmetest.ModeAuto, nil)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	if !rec.Recording() {
+		t.Fatal("recorder should record when the fixture does not exist")
+	}
+	client, _ := srv.Client(smartme.WithHTTPClient(&http.Client{Transport: rec}))
+	if _, err := client.GetDevices(context.Background()); err != nil {
+		t.Fatalf("GetDevices while recording failed: %v", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(fixture)
+	if strings.Contains(string(data), "Authorization") || strings.Contains(string(data), smartmetest.Password) {
+		t.Errorf("fixture contains credentials: %s", data)
+	}
+
+	// Replay without any server.
+	srv.Close()
+	rep, err := smartmetest.NewRecorder(fixture, smartmetest.ModeAuto, nil)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	if rep.Recording() {
+		t.Fatal("recorder should replay when the fixture exists")
+	}
+	client, _ = smartme.NewClient("anyone", "secret",
+		smartme.WithBaseURL("http://replay.invalid/"),
+		smartme.WithHTTPClient(&http.Client{Transport: rep}))
+
+	devices, err := client.GetDevices(context.Background())
+	if err != nil {
+		t.Fatalf("GetDevices while replaying failed: %v", err)
+	}
+	if len(devices) != 1 || *devices[0].Name != name {
+		t.Errorf("replayed devices = %+v", devices)
+	}
+
+	// Each interaction is replayed only once.
+	_, err = client.GetDevices(context.Background())
+	if !errors.Is(err, smartmetest.ErrNoInteraction) {
+		t.Errorf("second GetDevices error = %v, want ErrNoInteraction", err)
+	}
+}
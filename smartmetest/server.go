@@ -0,0 +1,523 @@
+// Package smartmetest provides an in-memory fake of the smart-me API for tests.
+//
+// The fake server runs as an httptest.Server and can be seeded with devices,
+// current values and history. Latency and errors can be injected per endpoint:
+//
+//	srv := smartmetest.NewServer()
+//	defer srv.Close()
+//
+//	srv.AddDevice(smartme.Device{Id: &id, Name: &name})
+//	client, _ := srv.Client()
+//	devices, _ := client.GetDevices(ctx)
+package smartmetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Default credentials accepted by a new Server.
+const (
+	Username = "test-user"
+	Password = "test-pass"
+)
+
+// Server is a programmable fake of the smart-me API.
+// All methods are safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	username string
+	password string
+	devices  []smartme.Device
+	values   map[string]smartme.DeviceValues
+	history  map[string][]smartme.Value
+	past     map[string][]smartme.DeviceValues
+	latency  time.Duration
+	failures map[string]int
+	requests map[string]int
+	nextID   int
+	chaos    *chaos
+	actions  map[string][]smartme.Action
+	currents map[string]float64
+}
+
+// NewServer starts a new fake server accepting the default credentials.
+// The caller must call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		username: Username,
+		password: Password,
+		values:   make(map[string]smartme.DeviceValues),
+		history:  make(map[string][]smartme.Value),
+		past:     make(map[string][]smartme.DeviceValues),
+		failures: make(map[string]int),
+		requests: make(map[string]int),
+		actions:  make(map[string][]smartme.Action),
+		currents: make(map[string]float64),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a smartme.Client configured to talk to the fake server
+// with the credentials it currently accepts.
+func (s *Server) Client(opts ...smartme.Option) (*smartme.Client, error) {
+	s.mu.Lock()
+	username, password := s.username, s.password
+	s.mu.Unlock()
+
+	opts = append([]smartme.Option{smartme.WithBaseURL(s.URL + "/")}, opts...)
+	return smartme.NewClient(username, password, opts...)
+}
+
+// SetCredentials changes the credentials accepted by the server.
+func (s *Server) SetCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+// AddDevice adds a device or replaces the device with the same ID.
+// Devices without an ID are assigned one. The ID is returned.
+func (s *Server) AddDevice(d smartme.Device) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d.Id == nil {
+		s.nextID++
+		id := fmt.Sprintf("device-%d", s.nextID)
+		d.Id = &id
+	}
+	for i := range s.devices {
+		if *s.devices[i].Id == *d.Id {
+			s.devices[i] = d
+			return *d.Id
+		}
+	}
+	s.devices = append(s.devices, d)
+	return *d.Id
+}
+
+// SetValues sets the current values returned by /api/Values for a device.
+func (s *Server) SetValues(v smartme.DeviceValues) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[v.DeviceID] = v
+}
+
+// AddHistory adds historical values for a device.
+// Values are kept sorted by date.
+func (s *Server) AddHistory(deviceID string, values ...smartme.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := append(s.history[deviceID], values...)
+	sort.SliceStable(h, func(i, j int) bool {
+		return h[i].Date.Before(h[j].Date)
+	})
+	s.history[deviceID] = h
+}
+
+// AddPastValues adds snapshots of all values of a device, as returned by
+// /api/MeterValues for a date. Snapshots are kept sorted by date.
+func (s *Server) AddPastValues(values ...smartme.DeviceValues) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range values {
+		p := append(s.past[v.DeviceID], v)
+		sort.SliceStable(p, func(i, j int) bool {
+			return p[i].Date.Before(p[j].Date)
+		})
+		s.past[v.DeviceID] = p
+	}
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailEndpoint makes all requests whose path starts with prefix
+// (e.g. "/api/Values") respond with the given status code.
+// A status code of 0 removes the failure again.
+func (s *Server) FailEndpoint(prefix string, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if statusCode == 0 {
+		delete(s.failures, prefix)
+		return
+	}
+	s.failures[prefix] = statusCode
+}
+
+// Requests returns how many requests were received for the given path prefix.
+// An empty prefix returns the total.
+func (s *Server) Requests(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for path, count := range s.requests {
+		if strings.HasPrefix(path, prefix) {
+			n += count
+		}
+	}
+	return n
+}
+
+// Actions returns the actions performed on a device, in order.
+func (s *Server) Actions(deviceID string) []smartme.Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smartme.Action(nil), s.actions[deviceID]...)
+}
+
+// ChargingCurrent returns the charging current limit of a charging station
+// in A, as set with the charging current action.
+func (s *Server) ChargingCurrent(deviceID string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.currents[deviceID]; ok {
+		return current
+	}
+	return defaultChargingCurrent
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	latency := s.latency
+	username, password := s.username, s.password
+	status := 0
+	for prefix, code := range s.failures {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			status = code
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+		http.Error(w, "Authorization has been denied for this request.", http.StatusUnauthorized)
+		return
+	}
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	fault := s.nextFault()
+	if !fault.apply(w, r) {
+		return
+	}
+	if fault.truncate {
+		tw := &truncatingWriter{ResponseWriter: w}
+		defer tw.flush()
+		w = tw
+	}
+
+	s.route(w, r)
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/")
+	if r.Method == http.MethodPost {
+		switch {
+		case path == "Actions":
+			s.handleActions(w, r)
+		case path == "Devices":
+			s.handleCreateOrUpdateDevice(w, r)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case path == "Devices":
+		s.handleDevices(w, r)
+	case strings.HasPrefix(path, "Devices/"):
+		s.handleDevice(w, r, strings.TrimPrefix(path, "Devices/"))
+	case strings.HasPrefix(path, "Actions/"):
+		s.handleGetActions(w, r, strings.TrimPrefix(path, "Actions/"))
+	case strings.HasPrefix(path, "Values/"):
+		s.handleValues(w, r, strings.TrimPrefix(path, "Values/"))
+	case strings.HasPrefix(path, "ValuesInPast/"):
+		s.handleValuesInPast(w, r, strings.TrimPrefix(path, "ValuesInPast/"))
+	case strings.HasPrefix(path, "ValuesInPastMultiple/"):
+		s.handleValuesInPastMultiple(w, r, strings.TrimPrefix(path, "ValuesInPastMultiple/"))
+	case strings.HasPrefix(path, "MeterValues/"):
+		s.handleMeterValues(w, r, strings.TrimPrefix(path, "MeterValues/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	devices := make([]smartme.Device, len(s.devices))
+	copy(devices, s.devices)
+	s.mu.Unlock()
+
+	writeJSON(w, devices)
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request, deviceID string) {
+	s.mu.Lock()
+	var found *smartme.Device
+	if d := s.device(deviceID); d != nil {
+		copied := *d
+		found = &copied
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, found)
+}
+
+// handleCreateOrUpdateDevice replaces the device with the same ID or adds a
+// new device with a generated ID.
+func (s *Server) handleCreateOrUpdateDevice(w http.ResponseWriter, r *http.Request) {
+	var d smartme.Device
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if d.Id != nil && *d.Id == "" {
+		d.Id = nil
+	}
+	id := s.AddDevice(d)
+	d.Id = &id
+	writeJSON(w, d)
+}
+
+// device returns the device with the given ID. The caller must hold s.mu.
+func (s *Server) device(deviceID string) *smartme.Device {
+	for i := range s.devices {
+		if s.devices[i].Id != nil && *s.devices[i].Id == deviceID {
+			return &s.devices[i]
+		}
+	}
+	return nil
+}
+
+// handleActions records actions and applies switch and tariff actions to
+// the device. Switching a charging station starts or stops charging.
+func (s *Server) handleActions(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DeviceID string           `json:"deviceID"`
+		Actions  []smartme.Action `json:"actions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.device(body.DeviceID)
+	if d == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.actions[body.DeviceID] = append(s.actions[body.DeviceID], body.Actions...)
+	for _, a := range body.Actions {
+		if a.ObisCode == ChargingCurrentObis && d.ChargeStationState != nil {
+			s.currents[body.DeviceID] = math.Min(a.Value, defaultChargingCurrent)
+			continue
+		}
+		if a.ObisCode == smartme.ObisActiveTariff {
+			tariff := int32(a.Value)
+			d.ActiveTariff = &tariff
+			continue
+		}
+		if a.ObisCode != smartme.ObisSwitchState {
+			continue
+		}
+		on := a.Value != 0
+		d.SwitchOn = &on
+		if d.ChargeStationState == nil {
+			continue
+		}
+		state := *d.ChargeStationState
+		switch {
+		case on && (state == smartme.ReadyCarConnected || state == smartme.Authorize):
+			state = smartme.Charging
+		case !on && (state == smartme.Charging || state == smartme.StartedWaitForCar):
+			state = smartme.ReadyCarConnected
+		}
+		d.ChargeStationState = &state
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// defaultChargingCurrent is the limit of a charging station before it is
+// changed with the charging current action, and its maximum.
+const defaultChargingCurrent = 32
+
+// ChargingCurrentObis is the code of the analog action that sets the
+// charging current on charging stations of the server. Clients find it
+// through api/Actions.
+const ChargingCurrentObis = "0-0:96.3.100*255"
+
+// handleGetActions lists the actions of a device: switching for devices
+// with a switch or a charging station, and the charging current for
+// charging stations.
+func (s *Server) handleGetActions(w http.ResponseWriter, r *http.Request, deviceID string) {
+	s.mu.Lock()
+	d := s.device(deviceID)
+	var station, switchable bool
+	if d != nil {
+		station = d.ChargeStationState != nil
+		switchable = station || d.SwitchOn != nil
+	}
+	s.mu.Unlock()
+
+	if d == nil {
+		http.NotFound(w, r)
+		return
+	}
+	actions := []smartme.ActionInfo{}
+	if switchable {
+		actions = append(actions, smartme.ActionInfo{
+			Name: "Switch", ObisCode: smartme.ObisSwitchState, ActionType: smartme.ActionTypeOnOff, MaxValue: 1,
+		})
+	}
+	if station {
+		actions = append(actions, smartme.ActionInfo{
+			Name: "Charging current", ObisCode: ChargingCurrentObis, ActionType: smartme.ActionTypeAnalog,
+			MinValue: smartme.MinChargingCurrent, MaxValue: defaultChargingCurrent,
+		})
+	}
+	writeJSON(w, actions)
+}
+
+func (s *Server) handleValues(w http.ResponseWriter, r *http.Request, deviceID string) {
+	s.mu.Lock()
+	values, ok := s.values[deviceID]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, values)
+}
+
+func (s *Server) handleValuesInPast(w http.ResponseWriter, r *http.Request, deviceID string) {
+	date, err := parseDate(r, "date")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	history := s.history[deviceID]
+	var found *smartme.Value
+	for i := range history {
+		if history[i].Date.After(date) {
+			break
+		}
+		v := history[i]
+		found = &v
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, found)
+}
+
+func (s *Server) handleMeterValues(w http.ResponseWriter, r *http.Request, deviceID string) {
+	date, err := parseDate(r, "date")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	past := s.past[deviceID]
+	var found *smartme.DeviceValues
+	for i := range past {
+		if past[i].Date.After(date) {
+			break
+		}
+		v := past[i]
+		found = &v
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, found)
+}
+
+func (s *Server) handleValuesInPastMultiple(w http.ResponseWriter, r *http.Request, deviceID string) {
+	start, err := parseDate(r, "startDate")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := parseDate(r, "endDate")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	values := []smartme.Value{}
+	for _, v := range s.history[deviceID] {
+		if !v.Date.Before(start) && !v.Date.After(end) {
+			values = append(values, v)
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, values)
+}
+
+func parseDate(r *http.Request, name string) (time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	date, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return date, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
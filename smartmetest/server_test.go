@@ -0,0 +1,112 @@
+package smartmetest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestServer_DevicesAndValues(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	name := "Main meter"
+	id := srv.AddDevice(smartme.Device{Name: &name})
+	srv.SetValues(smartme.DeviceValues{
+		DeviceID: id,
+		Values:   []smartme.ObisValue{{Obis: smartme.ObisActivePower, Value: 1200}},
+	})
+
+	client, err := srv.Client()
+	if err != nil {
+		t.Fatalf("Client failed: %v", err)
+	}
+
+	devices, err := client.GetDevices(context.Background())
+	if err != nil {
+		t.Fatalf("GetDevices returned an unexpected error: %v", err)
+	}
+	if len(devices) != 1 || *devices[0].Id != id || *devices[0].Name != name {
+		t.Errorf("GetDevices returned %+v", devices)
+	}
+
+	values, err := client.GetValues(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetValues returned an unexpected error: %v", err)
+	}
+	if len(values.Values) != 1 || values.Values[0].Value != 1200 {
+		t.Errorf("GetValues returned %+v", values)
+	}
+}
+
+func TestServer_History(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddHistory("dev",
+		smartme.Value{Date: start.Add(2 * time.Hour), Value: 3},
+		smartme.Value{Date: start, Value: 1},
+		smartme.Value{Date: start.Add(time.Hour), Value: 2},
+	)
+	client, _ := srv.Client()
+
+	v, err := client.GetValuesInPast(context.Background(), "dev", start.Add(90*time.Minute))
+	if err != nil {
+		t.Fatalf("GetValuesInPast returned an unexpected error: %v", err)
+	}
+	if v.Value != 2 {
+		t.Errorf("GetValuesInPast value = %v, want 2", v.Value)
+	}
+
+	values, err := client.GetValuesInPastMultiple(context.Background(), "dev", start.Add(time.Hour), start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("GetValuesInPastMultiple returned an unexpected error: %v", err)
+	}
+	if len(values) != 2 || values[0].Value != 2 || values[1].Value != 3 {
+		t.Errorf("GetValuesInPastMultiple returned %+v", values)
+	}
+}
+
+func TestServer_Injection(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	srv.FailEndpoint("/api/Devices", http.StatusServiceUnavailable)
+	_, err := client.GetDevices(context.Background())
+	var apiErr *smartme.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("GetDevices error = %v, want 503 APIError", err)
+	}
+
+	srv.FailEndpoint("/api/Devices", 0)
+	srv.SetLatency(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetDevices(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetDevices error = %v, want context.DeadlineExceeded", err)
+	}
+
+	if n := srv.Requests("/api/Devices"); n != 2 {
+		t.Errorf("Requests = %d, want 2", n)
+	}
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	srv.SetCredentials("other", "secret")
+	_, err := client.GetDevices(context.Background())
+	var apiErr *smartme.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GetDevices error = %v, want 401 APIError", err)
+	}
+}
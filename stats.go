@@ -0,0 +1,244 @@
+package smartme
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is the API usage of a client since it was created.
+type Stats struct {
+	Since time.Time
+	// Endpoints maps endpoints like "GET /api/Values/{id}" to their usage.
+	Endpoints map[string]EndpointStats
+	// RateLimit is the last rate-limit state reported by the API, or nil
+	// if the API has not sent any rate-limit headers.
+	RateLimit *RateLimit
+}
+
+// EndpointStats is the usage of a single endpoint.
+type EndpointStats struct {
+	Calls int64
+	// Errors counts failed calls, including API errors.
+	Errors int64
+	// BytesSent and BytesReceived count request and response bodies as
+	// transferred, i.e. compressed if the response was compressed.
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// RateLimit is the rate-limit state reported in the X-RateLimit-* and
+// Retry-After response headers.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	// Reset is when the limit is reset, zero if unknown.
+	Reset time.Time
+	// Observed is when the headers were received.
+	Observed time.Time
+}
+
+// Total returns the sum over all endpoints.
+func (s Stats) Total() EndpointStats {
+	var total EndpointStats
+	for _, e := range s.Endpoints {
+		total.Calls += e.Calls
+		total.Errors += e.Errors
+		total.BytesSent += e.BytesSent
+		total.BytesReceived += e.BytesReceived
+	}
+	return total
+}
+
+// String summarizes the stats in one line, e.g. for a log.
+func (s Stats) String() string {
+	t := s.Total()
+	line := fmt.Sprintf("%d calls, %d errors, %d bytes sent, %d bytes received since %s",
+		t.Calls, t.Errors, t.BytesSent, t.BytesReceived, s.Since.Format(time.RFC3339))
+	if rl := s.RateLimit; rl != nil {
+		line += fmt.Sprintf("; rate limit %d/%d remaining", rl.Remaining, rl.Limit)
+	}
+	return line
+}
+
+// Stats returns the API usage of the client. Clients derived with With
+// share the stats of their parent.
+func (c *Client) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ReportStats calls fn with the stats of the client every interval until
+// ctx is done, and returns ctx.Err(). Use it to log the usage, e.g. hourly,
+// and notice approaching fair-use limits before requests are throttled.
+func (c *Client) ReportStats(ctx context.Context, interval time.Duration, fn func(Stats)) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	for {
+		select {
+		case <-c.clock.After(interval):
+			fn(c.Stats())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// statsRecorder collects the Stats of a client.
+type statsRecorder struct {
+	since time.Time
+
+	mu        sync.Mutex
+	endpoints map[endpointKey]*endpointCounters
+	rateLimit *RateLimit
+}
+
+type endpointCounters struct {
+	calls, errors, sent, received atomic.Int64
+}
+
+func newStatsRecorder(now time.Time) *statsRecorder {
+	return &statsRecorder{since: now, endpoints: make(map[endpointKey]*endpointCounters)}
+}
+
+// endpoint returns the counters of the endpoint of req. Looking up a known
+// endpoint does not allocate.
+func (r *statsRecorder) endpoint(req *http.Request) *endpointCounters {
+	key := endpointOf(req)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.endpoints[key]
+	if !ok {
+		e = &endpointCounters{}
+		// The key refers to the path of the request; keep a copy.
+		key.method, key.prefix = strings.Clone(key.method), strings.Clone(key.prefix)
+		r.endpoints[key] = e
+	}
+	return e
+}
+
+func (r *statsRecorder) snapshot() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Stats{Since: r.since, Endpoints: make(map[string]EndpointStats, len(r.endpoints))}
+	for key, e := range r.endpoints {
+		s.Endpoints[key.name()] = EndpointStats{
+			Calls:         e.calls.Load(),
+			Errors:        e.errors.Load(),
+			BytesSent:     e.sent.Load(),
+			BytesReceived: e.received.Load(),
+		}
+	}
+	if r.rateLimit != nil {
+		rl := *r.rateLimit
+		s.RateLimit = &rl
+	}
+	return s
+}
+
+// observeRateLimit records the rate-limit headers of a response, if any.
+func (r *statsRecorder) observeRateLimit(h http.Header, now time.Time) {
+	// The keys are in canonical form so that Get does not allocate.
+	limitHeader, remainingHeader := h.Get("X-Ratelimit-Limit"), h.Get("X-Ratelimit-Remaining")
+	resetHeader, retryHeader := h.Get("X-Ratelimit-Reset"), h.Get("Retry-After")
+	if limitHeader == "" && remainingHeader == "" && resetHeader == "" && retryHeader == "" {
+		return
+	}
+	limit, errLimit := strconv.Atoi(limitHeader)
+	remaining, errRemaining := strconv.Atoi(remainingHeader)
+	reset := parseReset(resetHeader, now)
+	if retry := parseReset(retryHeader, now); !retry.IsZero() {
+		reset, remaining, errRemaining = retry, 0, nil
+	}
+	if errLimit != nil && errRemaining != nil && reset.IsZero() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rl := RateLimit{Limit: limit, Remaining: remaining, Reset: reset, Observed: now}
+	if errLimit != nil && r.rateLimit != nil {
+		rl.Limit = r.rateLimit.Limit
+	}
+	r.rateLimit = &rl
+}
+
+// parseReset parses a reset time given in seconds from now, as a Unix
+// timestamp or as an HTTP date.
+func parseReset(v string, now time.Time) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		// Values that cannot be a delay are Unix timestamps.
+		if n > 1e9 {
+			return time.Unix(n, 0)
+		}
+		return now.Add(time.Duration(n) * time.Second)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// endpointKey identifies an endpoint by substrings of the request, so it
+// can be looked up without building its name on every call.
+type endpointKey struct {
+	method string
+	// prefix is the path without leading and trailing slashes, and
+	// without the device ID if id is set.
+	prefix string
+	id     bool
+}
+
+// name returns the endpoint with the device ID replaced, e.g.
+// "GET /api/Values/{id}".
+func (k endpointKey) name() string {
+	if k.id {
+		return k.method + " /" + k.prefix + "{id}"
+	}
+	return k.method + " /" + k.prefix
+}
+
+// endpointOf returns the endpoint of a request.
+func endpointOf(req *http.Request) endpointKey {
+	path := req.URL.EscapedPath()
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		path = path[i:]
+	}
+	path = strings.Trim(path, "/")
+	key := endpointKey{method: req.Method, prefix: path}
+
+	first, rest, _ := strings.Cut(path, "/")
+	second, _, _ := strings.Cut(rest, "/")
+	n := 2
+	if rest != "" && versionPattern.MatchString(second) {
+		n++
+	}
+	// All calls with more than one segment after "api" (and the version)
+	// end with an ID.
+	if first == "api" && strings.Count(path, "/")+1 > n {
+		key.prefix = path[:strings.LastIndexByte(path, '/')+1]
+		key.id = true
+	}
+	return key
+}
+
+// countingReader counts the bytes read into n.
+type countingReader struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
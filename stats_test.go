@@ -0,0 +1,75 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_Stats(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := smartmetest.NewClock(start)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/Values/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "1000")
+		w.Header().Set("X-RateLimit-Remaining", "998")
+		w.Header().Set("X-RateLimit-Reset", "60")
+		w.Write([]byte(`{"deviceId":"a","values":[]}`))
+	})
+	mux.HandleFunc("/api/Devices", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client, err := smartme.NewClient("test-user", "test-pass", smartme.WithBaseURL(srv.URL), smartme.WithClock(clock), smartme.WithoutCompression())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	derived, _ := client.With(smartme.WithTimeout(time.Minute))
+
+	ctx := context.Background()
+	client.GetValues(ctx, "a")
+	derived.GetValues(ctx, "b")
+	client.GetDevices(ctx)
+
+	stats := client.Stats()
+	values := stats.Endpoints["GET /api/Values/{id}"]
+	if values.Calls != 2 || values.Errors != 0 || values.BytesReceived != 2*int64(len(`{"deviceId":"a","values":[]}`)) {
+		t.Errorf("Values stats = %+v", values)
+	}
+	if devices := stats.Endpoints["GET /api/Devices"]; devices.Calls != 1 || devices.Errors != 1 {
+		t.Errorf("Devices stats = %+v", devices)
+	}
+	if total := stats.Total(); total.Calls != 3 {
+		t.Errorf("total calls = %d, want 3", total.Calls)
+	}
+	rl := stats.RateLimit
+	if rl == nil || rl.Limit != 1000 || rl.Remaining != 998 || !rl.Reset.Equal(start.Add(time.Minute)) {
+		t.Errorf("RateLimit = %+v", rl)
+	}
+
+	// ReportStats reports at every interval.
+	ctx, cancel := context.WithCancel(ctx)
+	reports := make(chan smartme.Stats)
+	done := make(chan error)
+	go func() {
+		done <- client.ReportStats(ctx, time.Hour, func(s smartme.Stats) { reports <- s })
+	}()
+	clock.WaitForTimers(t, 1)
+	clock.Advance(time.Hour)
+	if s := <-reports; s.Total().Calls != 3 {
+		t.Errorf("reported %d calls, want 3", s.Total().Calls)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("ReportStats returned %v", err)
+	}
+}
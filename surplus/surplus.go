@@ -0,0 +1,288 @@
+// Package surplus charges a car with the surplus of a photovoltaic system.
+//
+// The Controller watches a grid meter and a charging station and adjusts
+// the charging current, so the car uses the power that would otherwise be
+// exported and the grid import stays near zero:
+//
+//	ctrl := surplus.NewController(client, surplus.Config{
+//		GridMeterID: gridID,
+//		ChargerID:   chargerID,
+//	})
+//	err := ctrl.Run(ctx)
+//
+// The grid meter must report import as positive and export as negative
+// active power, as smart-me meters do.
+package surplus
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+// Defaults of the Config fields.
+const (
+	DefaultInterval   = 30 * time.Second
+	DefaultMaxCurrent = 16.0
+	DefaultVoltage    = 230.0
+	DefaultHysteresis = 300.0
+)
+
+// Config configures a Controller. Zero values select the defaults.
+type Config struct {
+	// GridMeterID is the meter at the grid connection point.
+	GridMeterID string
+	// ChargerID is the charging station.
+	ChargerID string
+
+	// Interval is the time between two adjustments (default 30s).
+	Interval time.Duration
+	// MinCurrent and MaxCurrent limit the charging current in A per phase
+	// (defaults smartme.MinChargingCurrent and 16 A). Charging stops when
+	// the surplus does not reach MinCurrent.
+	MinCurrent, MaxCurrent float64
+	// Voltage is the phase voltage used to convert power to current
+	// (default 230 V).
+	Voltage float64
+	// Phases is the number of phases the car charges with (default 1).
+	Phases int
+	// Hysteresis in W keeps the current unchanged while the surplus moves
+	// less than this, and delays starting and stopping by the same margin
+	// (default 300 W).
+	Hysteresis float64
+
+	// SwitchPhases enables phase switching: the controller charges on three
+	// phases when the surplus allows MinCurrent on all of them, and on one
+	// phase otherwise. The API has no call to switch phases, so the
+	// function has to do it for the installed hardware.
+	SwitchPhases func(ctx context.Context, phases int) error
+
+	// Prices enables charging from the grid: in the cheap windows of the
+	// plan the car charges with MaxCurrent whatever the surplus.
+	Prices *smartme.PricePlan
+
+	// Clock is used by the watcher and for the decision timestamps.
+	// Defaults to the system clock.
+	Clock smartme.Clock
+	// OnDecision is called after every adjustment round.
+	OnDecision func(Decision)
+	// OnError is called for errors that do not stop the controller.
+	OnError func(err error)
+}
+
+// Decision describes one adjustment round.
+type Decision struct {
+	Time time.Time
+	// GridPower and ChargingPower are the measured powers in W.
+	GridPower, ChargingPower float64
+	// Surplus is the power in W available for charging.
+	Surplus float64
+	// Current is the charging current limit in A, 0 if not charging.
+	Current float64
+	Phases  int
+	// Cheap is true if the time is in a cheap window of Config.Prices.
+	Cheap bool
+	// Changed is true if the controller changed the charging station.
+	Changed bool
+}
+
+// Controller adjusts the charging current to the PV surplus.
+type Controller struct {
+	api smartme.API
+	cfg Config
+
+	grid    *smartme.Device
+	current float64
+	phases  int
+	// charging is true while the station charges or waits for the car
+	// to draw power; armed is true from our StartCharging to our
+	// StopCharging or until the car is unplugged, since the station may
+	// report the new state only later.
+	charging bool
+	armed    bool
+}
+
+// Controller can be supervised by a smartme.Runner.
+var _ smartme.Service = (*Controller)(nil)
+
+// NewController returns a Controller for the devices in cfg.
+func NewController(api smartme.API, cfg Config) *Controller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.MinCurrent <= 0 {
+		cfg.MinCurrent = smartme.MinChargingCurrent
+	}
+	if cfg.MaxCurrent <= 0 {
+		cfg.MaxCurrent = DefaultMaxCurrent
+	}
+	if cfg.Voltage <= 0 {
+		cfg.Voltage = DefaultVoltage
+	}
+	if cfg.Phases <= 0 {
+		cfg.Phases = 1
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = DefaultHysteresis
+	}
+	return &Controller{api: api, cfg: cfg, phases: cfg.Phases}
+}
+
+// Run controls the charging station until ctx is done and returns
+// ctx.Err(). The grid meter is polled before the charger, so every
+// adjustment uses a fresh pair of readings.
+func (c *Controller) Run(ctx context.Context) error {
+	w := smartme.NewWatcher(c.api, c.cfg.GridMeterID, c.cfg.ChargerID)
+	w.Interval = c.cfg.Interval
+	w.Clock = c.cfg.Clock
+	w.OnError = func(deviceID string, err error) {
+		if deviceID == c.cfg.GridMeterID {
+			c.grid = nil
+		}
+		c.report(err)
+	}
+	w.OnDevice = func(d smartme.Device) {
+		if d.Id == nil {
+			return
+		}
+		switch *d.Id {
+		case c.cfg.GridMeterID:
+			c.grid = &d
+		case c.cfg.ChargerID:
+			if c.grid != nil {
+				c.adjust(ctx, c.grid, &d)
+				c.grid = nil
+			}
+		}
+	}
+	return w.Run(ctx)
+}
+
+// adjust runs one adjustment round.
+func (c *Controller) adjust(ctx context.Context, grid, charger *smartme.Device) {
+	gridPower, err := grid.ActivePowerWatts()
+	if err != nil {
+		c.report(err)
+		return
+	}
+	chargingPower, err := charger.ActivePowerWatts()
+	if err != nil {
+		chargingPower = 0
+	}
+
+	d := Decision{
+		Time:          c.now(),
+		GridPower:     gridPower,
+		ChargingPower: chargingPower,
+		Surplus:       chargingPower - gridPower,
+	}
+	connected := charger.ChargeStationState != nil && charger.ChargeStationState.CarConnected()
+	if !connected {
+		c.armed = false
+	}
+	c.charging = c.armed
+	if s := charger.ChargeStationState; s != nil && (*s == smartme.Charging || *s == smartme.StartedWaitForCar) {
+		c.charging = true
+	}
+	available := d.Surplus
+	if c.cfg.Prices != nil {
+		cheap, err := c.cfg.Prices.Cheap(ctx, d.Time)
+		if err != nil {
+			c.report(err)
+		}
+		if d.Cheap = cheap; cheap {
+			available = math.Inf(1)
+		}
+	}
+	if !connected {
+		c.current = 0
+	} else {
+		d.Changed = c.apply(ctx, available)
+	}
+
+	d.Current, d.Phases = c.current, c.phases
+	if c.cfg.OnDecision != nil {
+		c.cfg.OnDecision(d)
+	}
+}
+
+// apply adapts phases, current and charging state to the surplus and
+// reports whether the charging station was changed.
+func (c *Controller) apply(ctx context.Context, surplus float64) bool {
+	changed := false
+	if phases := c.choosePhases(surplus); phases != c.phases {
+		if err := c.cfg.SwitchPhases(ctx, phases); err != nil {
+			c.report(err)
+		} else {
+			c.phases = phases
+			changed = true
+		}
+	}
+
+	perAmp := c.cfg.Voltage * float64(c.phases)
+	target := surplus / perAmp
+	margin := c.cfg.Hysteresis / perAmp
+
+	switch {
+	case c.charging && target < c.cfg.MinCurrent-margin:
+		if err := c.api.StopCharging(ctx, c.cfg.ChargerID); err != nil {
+			c.report(err)
+			return changed
+		}
+		c.current, c.armed = 0, false
+		return true
+	case !c.charging && target < c.cfg.MinCurrent+margin:
+		c.current = 0
+		return changed
+	}
+
+	current := math.Round(math.Min(math.Max(target, c.cfg.MinCurrent), c.cfg.MaxCurrent)*10) / 10
+	if !c.charging || math.Abs(current-c.current) >= margin {
+		if err := c.api.SetMaxChargingCurrent(ctx, c.cfg.ChargerID, current); err != nil {
+			c.report(err)
+			return changed
+		}
+		c.current = current
+		changed = true
+	}
+	if !c.charging {
+		if err := c.api.StartCharging(ctx, c.cfg.ChargerID); err != nil {
+			c.report(err)
+			return changed
+		}
+		c.armed = true
+		changed = true
+	}
+	return changed
+}
+
+// choosePhases returns the number of phases for the surplus.
+func (c *Controller) choosePhases(surplus float64) int {
+	if c.cfg.SwitchPhases == nil {
+		return c.phases
+	}
+	threePhaseMin := c.cfg.MinCurrent * c.cfg.Voltage * 3
+	switch {
+	case c.phases != 3 && surplus >= threePhaseMin+c.cfg.Hysteresis:
+		return 3
+	case c.phases == 3 && surplus < threePhaseMin-c.cfg.Hysteresis:
+		return 1
+	}
+	return c.phases
+}
+
+func (c *Controller) report(err error) {
+	if c.cfg.OnError != nil {
+		c.cfg.OnError(err)
+	}
+}
+
+// now returns the current time of the configured clock.
+func (c *Controller) now() time.Time {
+	if c.cfg.Clock == nil {
+		return smartme.SystemClock{}.Now()
+	}
+	return c.cfg.Clock.Now()
+}
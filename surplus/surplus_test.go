@@ -0,0 +1,219 @@
+package surplus_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+	"github.com/rolacher/go-smartme-client/surplus"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestController(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	setGrid := func(w float64) {
+		srv.AddDevice(smartme.Device{Id: ptr("grid"), ActivePower: ptr(w), ActivePowerUnit: ptr("W")})
+	}
+	// The fake server does not simulate the car, so the charger power is set
+	// to what the current limit of the last step would draw.
+	setCharger := func(w float64) {
+		state := smartme.ReadyCarConnected
+		for _, d := range mustDevices(t, client) {
+			if *d.Id == "charger" {
+				state = *d.ChargeStationState
+			}
+		}
+		srv.AddDevice(smartme.Device{Id: ptr("charger"), ActivePower: ptr(w), ChargeStationState: &state})
+	}
+
+	clock := smartmetest.NewClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	var mu sync.Mutex
+	var decisions []surplus.Decision
+	ctrl := surplus.NewController(client, surplus.Config{
+		GridMeterID: "grid",
+		ChargerID:   "charger",
+		Interval:    time.Minute,
+		Clock:       clock,
+		OnDecision: func(d surplus.Decision) {
+			mu.Lock()
+			defer mu.Unlock()
+			decisions = append(decisions, d)
+		},
+		OnError: func(err error) { t.Errorf("controller error: %v", err) },
+	})
+
+	steps := []struct {
+		name          string
+		grid, charger float64
+		wantCurrent   float64
+		wantChanged   bool
+	}{
+		{"export starts charging", -3000, 0, 13, true},
+		{"balanced stays", 10, 2990, 13, false},
+		{"cloud lowers current", 1500, 2990, 6.5, true},
+		{"small change within hysteresis", 1350 - 2990 + 1495, 1495, 6.5, false},
+		{"import stops charging", 1000, 1495, 0, true},
+		{"small surplus does not restart", -1500, 0, 0, false},
+	}
+
+	setGrid(steps[0].grid)
+	setCharger(steps[0].charger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ctrl.Run(ctx) }()
+
+	for i, step := range steps {
+		clock.WaitForTimers(t, 1)
+		mu.Lock()
+		if len(decisions) != i+1 {
+			mu.Unlock()
+			t.Fatalf("step %q: %d decisions, want %d", step.name, len(decisions), i+1)
+		}
+		d := decisions[i]
+		mu.Unlock()
+		if d.Current != step.wantCurrent || d.Changed != step.wantChanged {
+			t.Errorf("step %q: current %v changed %v, want %v %v (surplus %v W)",
+				step.name, d.Current, d.Changed, step.wantCurrent, step.wantChanged, d.Surplus)
+		}
+		if i+1 < len(steps) {
+			setGrid(steps[i+1].grid)
+			setCharger(steps[i+1].charger)
+		}
+		clock.Advance(time.Minute)
+	}
+	cancel()
+	<-done
+
+	if limit := srv.ChargingCurrent("charger"); limit != 6.5 {
+		t.Errorf("charger limit = %v A, want 6.5 A", limit)
+	}
+}
+
+func TestController_Prices(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+	srv.AddDevice(smartme.Device{Id: ptr("grid"), ActivePower: ptr(500.0), ActivePowerUnit: ptr("W")})
+	srv.AddDevice(smartme.Device{Id: ptr("charger"), ActivePower: ptr(0.0), ChargeStationState: ptr(smartme.ReadyCarConnected)})
+
+	// The hour from 12:00 is the cheapest of the day.
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	var prices smartme.StaticPrices
+	for i := 0; i < 24; i++ {
+		from := day.Add(time.Duration(i) * time.Hour)
+		prices = append(prices, smartme.Price{Start: from, End: from.Add(time.Hour), Value: float64(1 + (i+12)%24)})
+	}
+
+	clock := smartmetest.NewClock(day.Add(12 * time.Hour))
+	decisions := make(chan surplus.Decision, 1)
+	ctrl := surplus.NewController(client, surplus.Config{
+		GridMeterID: "grid",
+		ChargerID:   "charger",
+		Prices:      &smartme.PricePlan{Source: prices, Hours: 1},
+		Clock:       clock,
+		OnDecision:  func(d surplus.Decision) { decisions <- d },
+		OnError:     func(err error) { t.Errorf("controller error: %v", err) },
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ctrl.Run(ctx) }()
+	d := <-decisions
+	cancel()
+	<-done
+
+	if !d.Cheap || d.Current != surplus.DefaultMaxCurrent || !d.Changed {
+		t.Errorf("decision = %+v, want charging with the maximum current in a cheap hour", d)
+	}
+}
+
+// runRounds runs the controller for the given number of rounds and
+// returns the decisions. before is called ahead of every round.
+func runRounds(t *testing.T, client *smartme.Client, rounds int, before func(round int)) []surplus.Decision {
+	t.Helper()
+	clock := smartmetest.NewClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	decisions := make(chan surplus.Decision, rounds)
+	ctrl := surplus.NewController(client, surplus.Config{
+		GridMeterID: "grid",
+		ChargerID:   "charger",
+		Interval:    time.Minute,
+		Clock:       clock,
+		OnDecision:  func(d surplus.Decision) { decisions <- d },
+		OnError:     func(err error) { t.Errorf("controller error: %v", err) },
+	})
+	before(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ctrl.Run(ctx) }()
+	var got []surplus.Decision
+	for i := 0; i < rounds; i++ {
+		got = append(got, <-decisions)
+		if i+1 < rounds {
+			clock.WaitForTimers(t, 1)
+			before(i + 1)
+			clock.Advance(time.Minute)
+		}
+	}
+	cancel()
+	<-done
+	return got
+}
+
+func TestController_StopsWhileWaitingForCar(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	// The station was started earlier and waits for the car to draw power.
+	decisions := runRounds(t, client, 1, func(int) {
+		srv.AddDevice(smartme.Device{Id: ptr("grid"), ActivePower: ptr(500.0), ActivePowerUnit: ptr("W")})
+		srv.AddDevice(smartme.Device{Id: ptr("charger"), ActivePower: ptr(0.0), ChargeStationState: ptr(smartme.StartedWaitForCar)})
+	})
+	if d := decisions[0]; d.Current != 0 || !d.Changed {
+		t.Errorf("decision = %+v, want charging stopped", d)
+	}
+	actions := srv.Actions("charger")
+	if len(actions) != 1 || actions[0].ObisCode != smartme.ObisSwitchState || actions[0].Value != 0 {
+		t.Errorf("actions = %+v, want the station switched off", actions)
+	}
+}
+
+func TestController_StartsOnce(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	// The station still reports ReadyCarConnected after StartCharging.
+	runRounds(t, client, 3, func(int) {
+		srv.AddDevice(smartme.Device{Id: ptr("grid"), ActivePower: ptr(-3000.0), ActivePowerUnit: ptr("W")})
+		srv.AddDevice(smartme.Device{Id: ptr("charger"), ActivePower: ptr(0.0), ChargeStationState: ptr(smartme.ReadyCarConnected)})
+	})
+	var starts int
+	for _, a := range srv.Actions("charger") {
+		if a.ObisCode == smartme.ObisSwitchState {
+			starts++
+		}
+	}
+	if starts != 1 {
+		t.Errorf("actions = %+v, want one StartCharging", srv.Actions("charger"))
+	}
+}
+
+func mustDevices(t *testing.T, client *smartme.Client) []smartme.Device {
+	t.Helper()
+	devices, err := client.GetDevices(context.Background())
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	return devices
+}
@@ -0,0 +1,161 @@
+package smartme
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tags are key/value attributes of a device, like building=A or floor=3.
+// The smart-me API has no device properties for this, so tags are kept on
+// the client side in a TagStore.
+type Tags map[string]string
+
+// ParseTags parses a comma separated list like "building=A, floor=3".
+// Spaces around keys and values are removed; a key without "=" gets an
+// empty value.
+func ParseTags(s string) (Tags, error) {
+	tags := Tags{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag %q: key is empty", part)
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
+
+// String formats the tags sorted by key, in the format read by ParseTags.
+func (t Tags) String() string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + t[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+// Match reports whether t contains all tags of want. An empty value in want
+// only requires the key to be present.
+func (t Tags) Match(want Tags) bool {
+	for k, v := range want {
+		got, ok := t[k]
+		if !ok || (v != "" && got != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// TagStore holds the tags of devices, keyed by device ID. It is safe for
+// concurrent use. It encodes to JSON as an object of device IDs to tags, so
+// it can be kept in a file next to the application's configuration.
+type TagStore struct {
+	mu   sync.RWMutex
+	tags map[string]Tags
+}
+
+// NewTagStore creates an empty TagStore.
+func NewTagStore() *TagStore {
+	return &TagStore{tags: make(map[string]Tags)}
+}
+
+// Set replaces the tags of a device. Empty tags remove the device.
+func (s *TagStore) Set(deviceID string, tags Tags) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(tags) == 0 {
+		delete(s.tags, deviceID)
+		return
+	}
+	s.tags[deviceID] = copyTags(tags)
+}
+
+// Tags returns a copy of the tags of a device.
+func (s *TagStore) Tags(deviceID string) Tags {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyTags(s.tags[deviceID])
+}
+
+// Devices returns the sorted IDs of the devices whose tags match want.
+func (s *TagStore) Devices(want Tags) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []string
+	for id, tags := range s.tags {
+		if tags.Match(want) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// MarshalJSON encodes the store as an object of device IDs to tags.
+func (s *TagStore) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.Marshal(s.tags)
+}
+
+// UnmarshalJSON replaces the content of the store.
+func (s *TagStore) UnmarshalJSON(data []byte) error {
+	var tags map[string]Tags
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return err
+	}
+	if tags == nil {
+		tags = make(map[string]Tags)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags = tags
+	return nil
+}
+
+func copyTags(t Tags) Tags {
+	if t == nil {
+		return nil
+	}
+	c := make(Tags, len(t))
+	for k, v := range t {
+		c[k] = v
+	}
+	return c
+}
+
+// ByTags selects devices whose tags in the store match want, e.g.
+// ByTags(store, Tags{"building": "A"}).
+func ByTags(store *TagStore, want Tags) DeviceFilter {
+	return func(d Device) bool {
+		return d.Id != nil && store.Tags(*d.Id).Match(want)
+	}
+}
+
+// GroupByTag groups devices by the value of a tag. Devices without the tag
+// are grouped under the empty string.
+func GroupByTag(devices []Device, store *TagStore, key string) map[string][]Device {
+	groups := make(map[string][]Device)
+	for _, d := range devices {
+		var value string
+		if d.Id != nil {
+			value = store.Tags(*d.Id)[key]
+		}
+		groups[value] = append(groups[value], d)
+	}
+	return groups
+}
@@ -0,0 +1,64 @@
+package smartme_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestParseTags(t *testing.T) {
+	tags, err := smartme.ParseTags(" building = A, floor=3,,solar")
+	if err != nil {
+		t.Fatalf("ParseTags failed: %v", err)
+	}
+	want := smartme.Tags{"building": "A", "floor": "3", "solar": ""}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+	if got := tags.String(); got != "building=A,floor=3,solar=" {
+		t.Errorf("String() = %q", got)
+	}
+	if _, err := smartme.ParseTags("=A"); err == nil {
+		t.Error("ParseTags should reject an empty key")
+	}
+}
+
+func TestTagStore(t *testing.T) {
+	store := smartme.NewTagStore()
+	if err := json.Unmarshal([]byte(`{"a":{"building":"A","floor":"3"},"b":{"building":"A","floor":"1"},"c":{"building":"B"}}`), store); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if got := store.Devices(smartme.Tags{"building": "A"}); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Devices(building=A) = %v", got)
+	}
+	if got := store.Devices(smartme.Tags{"floor": ""}); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Devices(floor) = %v", got)
+	}
+
+	// The returned tags are a copy.
+	store.Tags("a")["building"] = "X"
+	if store.Tags("a")["building"] != "A" {
+		t.Error("Tags must return a copy")
+	}
+
+	store.Set("c", nil)
+	data, err := json.Marshal(store)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `{"a":{"building":"A","floor":"3"},"b":{"building":"A","floor":"1"}}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	devices := []smartme.Device{{Id: ptr("a")}, {Id: ptr("b")}, {Id: ptr("d")}}
+	if got := smartme.FilterDevices(devices, smartme.ByTags(store, smartme.Tags{"floor": "3"})); len(got) != 1 || *got[0].Id != "a" {
+		t.Errorf("ByTags selected %v", got)
+	}
+	groups := smartme.GroupByTag(devices, store, "building")
+	if len(groups["A"]) != 2 || len(groups[""]) != 1 {
+		t.Errorf("GroupByTag = %v", groups)
+	}
+}
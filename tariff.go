@@ -0,0 +1,164 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// tariffRecheckInterval is how often RunTariffSchedule verifies the active
+// tariff between two scheduled switches.
+const tariffRecheckInterval = 15 * time.Minute
+
+// SetActiveTariff switches the active tariff register (1 to 4) of a meter.
+func (c *Client) SetActiveTariff(ctx context.Context, deviceID string, tariff int32, opts ...CallOption) error {
+	if tariff < 1 || tariff > 4 {
+		return fmt.Errorf("tariff must be between 1 and 4, got %d", tariff)
+	}
+	return c.PerformActions(ctx, deviceID, []Action{{ObisCode: ObisActiveTariff, Value: float64(tariff)}}, opts...)
+}
+
+// TariffSwitch activates a tariff on the given weekdays at a time of day.
+type TariffSwitch struct {
+	Days []time.Weekday
+	// At is the time since midnight, e.g. 7*time.Hour for 07:00.
+	At     time.Duration
+	Tariff int32
+}
+
+// TariffSchedule is a weekly schedule of tariff switches, such as high
+// tariff (HT) on weekdays from 07:00 and low tariff (NT) from 20:00 and on
+// weekends.
+type TariffSchedule struct {
+	// Location is the time zone of the switch times (default UTC).
+	Location *time.Location
+	Switches []TariffSwitch
+}
+
+// TariffAt returns the tariff scheduled at t, which is the tariff of the
+// last switch at or before t. It returns false if the schedule is empty.
+func (s TariffSchedule) TariffAt(t time.Time) (int32, bool) {
+	var last time.Time
+	var tariff int32
+	found := false
+	s.each(t.AddDate(0, 0, -7), t.AddDate(0, 0, 1), func(at time.Time, sw TariffSwitch) {
+		if !at.After(t) && (!found || !at.Before(last)) {
+			last, tariff, found = at, sw.Tariff, true
+		}
+	})
+	return tariff, found
+}
+
+// NextSwitch returns the time of the first switch after t.
+func (s TariffSchedule) NextSwitch(t time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	s.each(t.AddDate(0, 0, -1), t.AddDate(0, 0, 8), func(at time.Time, _ TariffSwitch) {
+		if at.After(t) && (!found || at.Before(next)) {
+			next, found = at, true
+		}
+	})
+	return next, found
+}
+
+// each calls fn with every switch time between the days of from and to.
+func (s TariffSchedule) each(from, to time.Time, fn func(time.Time, TariffSwitch)) {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	day, _ := DayRange(from, loc)
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		for _, sw := range s.Switches {
+			for _, wd := range sw.Days {
+				if wd != day.Weekday() {
+					continue
+				}
+				// Add the time of day in wall clock time, so switches keep
+				// their local time across DST changes.
+				h, m := int(sw.At/time.Hour), int(sw.At%time.Hour/time.Minute)
+				fn(time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, loc), sw)
+			}
+		}
+	}
+}
+
+// validate checks that every switch can be applied.
+func (s TariffSchedule) validate() error {
+	if len(s.Switches) == 0 {
+		return fmt.Errorf("tariff schedule must not be empty")
+	}
+	var errs []error
+	for i, sw := range s.Switches {
+		if len(sw.Days) == 0 {
+			errs = append(errs, fmt.Errorf("switch %d: no days", i+1))
+		}
+		for _, wd := range sw.Days {
+			if wd < time.Sunday || wd > time.Saturday {
+				errs = append(errs, fmt.Errorf("switch %d: invalid weekday %d", i+1, wd))
+			}
+		}
+		if sw.At < 0 || sw.At >= 24*time.Hour {
+			errs = append(errs, fmt.Errorf("switch %d: time of day %v is not within a day", i+1, sw.At))
+		}
+		if sw.Tariff < 1 || sw.Tariff > 4 {
+			errs = append(errs, fmt.Errorf("switch %d: tariff must be between 1 and 4, got %d", i+1, sw.Tariff))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid tariff schedule: %w", err)
+	}
+	return nil
+}
+
+// RunTariffSchedule keeps the active tariff of a meter in line with the
+// schedule until ctx is done, and returns ctx.Err(). The device's
+// ActiveTariff is checked at every switch and every 15 minutes in between,
+// and the scheduled tariff is set again if it differs. Errors are passed
+// to onError, which may be nil, and retried at the next check. An invalid
+// schedule is rejected up front.
+func (c *Client) RunTariffSchedule(ctx context.Context, deviceID string, schedule TariffSchedule, onError func(error)) error {
+	if err := schedule.validate(); err != nil {
+		return err
+	}
+	report := func(err error) {
+		if onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+	}
+
+	for {
+		now := c.clock.Now()
+		if err := c.applyScheduledTariff(ctx, deviceID, schedule, now); err != nil {
+			report(err)
+		}
+
+		wait := tariffRecheckInterval
+		if next, ok := schedule.NextSwitch(now); ok && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.clock.After(wait):
+		}
+	}
+}
+
+// applyScheduledTariff sets the tariff scheduled at now if the device
+// reports a different one.
+func (c *Client) applyScheduledTariff(ctx context.Context, deviceID string, schedule TariffSchedule, now time.Time) error {
+	tariff, ok := schedule.TariffAt(now)
+	if !ok {
+		return nil
+	}
+	d, err := c.GetDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if d.ActiveTariff != nil && *d.ActiveTariff == tariff {
+		return nil
+	}
+	return c.SetActiveTariff(ctx, deviceID, tariff)
+}
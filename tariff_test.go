@@ -0,0 +1,107 @@
+package smartme_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+// htnt is a typical schedule: high tariff (1) on weekdays 07:00-20:00,
+// low tariff (2) otherwise.
+var htnt = smartme.TariffSchedule{
+	Switches: []smartme.TariffSwitch{
+		{Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, At: 7 * time.Hour, Tariff: 1},
+		{Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, At: 20 * time.Hour, Tariff: 2},
+	},
+}
+
+func TestTariffSchedule(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want int32
+		next time.Time
+	}{
+		// Monday 2025-01-06
+		{time.Date(2025, 1, 6, 6, 59, 0, 0, time.UTC), 2, time.Date(2025, 1, 6, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 1, 6, 7, 0, 0, 0, time.UTC), 1, time.Date(2025, 1, 6, 20, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 1, 10, 21, 0, 0, 0, time.UTC), 2, time.Date(2025, 1, 13, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 1, 12, 12, 0, 0, 0, time.UTC), 2, time.Date(2025, 1, 13, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, ok := htnt.TariffAt(tt.at)
+		if !ok || got != tt.want {
+			t.Errorf("TariffAt(%v) = %d, %v, want %d", tt.at, got, ok, tt.want)
+		}
+		next, ok := htnt.NextSwitch(tt.at)
+		if !ok || !next.Equal(tt.next) {
+			t.Errorf("NextSwitch(%v) = %v, want %v", tt.at, next, tt.next)
+		}
+	}
+
+	if _, ok := (smartme.TariffSchedule{}).TariffAt(time.Now()); ok {
+		t.Error("empty schedule returned a tariff")
+	}
+}
+
+func TestClient_RunTariffSchedule(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	meter := srv.AddDevice(smartme.Device{ActiveTariff: ptr(int32(1))})
+
+	// Monday 19:50, ten minutes before the switch to the low tariff.
+	clock := smartmetest.NewClock(time.Date(2025, 1, 6, 19, 50, 0, 0, time.UTC))
+	client, _ := srv.Client(smartme.WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.RunTariffSchedule(ctx, meter, htnt, func(err error) { t.Errorf("schedule error: %v", err) })
+	}()
+
+	clock.WaitForTimers(t, 1)
+	if n := len(srv.Actions(meter)); n != 0 {
+		t.Fatalf("%d actions before the switch, want none", n)
+	}
+
+	clock.Advance(10 * time.Minute)
+	clock.WaitForTimers(t, 1)
+	actions := srv.Actions(meter)
+	if len(actions) != 1 || actions[0].ObisCode != smartme.ObisActiveTariff || actions[0].Value != 2 {
+		t.Fatalf("actions after the switch = %+v", actions)
+	}
+
+	// The tariff is verified, so it is not set again.
+	clock.Advance(15 * time.Minute)
+	clock.WaitForTimers(t, 1)
+	cancel()
+	clock.Advance(15 * time.Minute)
+	<-done
+	if n := len(srv.Actions(meter)); n != 1 {
+		t.Errorf("%d actions, want 1", n)
+	}
+}
+
+func TestClient_RunTariffSchedule_Invalid(t *testing.T) {
+	client, err := smartme.NewClient("user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule := smartme.TariffSchedule{Switches: []smartme.TariffSwitch{
+		{Days: []time.Weekday{time.Monday}, At: 7 * time.Hour, Tariff: 1},
+		{At: 20 * time.Hour, Tariff: 2},
+		{Days: []time.Weekday{time.Saturday}, At: 25 * time.Hour, Tariff: 5},
+	}}
+	err = client.RunTariffSchedule(context.Background(), "meter", schedule, nil)
+	if err == nil {
+		t.Fatal("RunTariffSchedule should reject the schedule")
+	}
+	for _, want := range []string{"switch 2: no days", "switch 3: time of day", "switch 3: tariff must be between 1 and 4"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package smartme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrForbiddenDevice is returned by the API of a tenant for devices that
+// do not belong to the tenant.
+var ErrForbiddenDevice = errors.New("device does not belong to the tenant")
+
+// TenantDirectory resolves the devices a tenant may access. Backends
+// typically keep the devices of a tenant in a folder of the smart-me
+// account and resolve the folder here; StaticTenants serves a fixed map.
+type TenantDirectory interface {
+	TenantDevices(ctx context.Context, tenantID string) ([]string, error)
+}
+
+// StaticTenants is a TenantDirectory that maps tenant IDs to device IDs.
+type StaticTenants map[string][]string
+
+// TenantDevices returns the devices of the tenant, none for unknown
+// tenants.
+func (s StaticTenants) TenantDevices(ctx context.Context, tenantID string) ([]string, error) {
+	return s[tenantID], nil
+}
+
+// Tenants scopes an API to the devices of tenants, for backends that serve
+// several tenants with one smart-me account:
+//
+//	tenants := smartme.NewTenants(client, smartme.StaticTenants{"acme": {"dev-1", "dev-2"}})
+//	api := tenants.For(tenantID)
+//	values, err := api.GetValues(ctx, deviceID) // ErrForbiddenDevice for other devices
+//
+// The device set is resolved with every call, so changes in the directory
+// apply at once; cache in the directory if resolving is expensive.
+type Tenants struct {
+	api API
+	dir TenantDirectory
+}
+
+// NewTenants returns Tenants that resolve device sets with dir.
+func NewTenants(api API, dir TenantDirectory) *Tenants {
+	return &Tenants{api: api, dir: dir}
+}
+
+// For returns the API of a tenant. Calls for devices outside the tenant's
+// set fail with ErrForbiddenDevice before reaching the API; device lists
+// only contain the tenant's devices. A device the tenant may not access is
+// not found by FindDeviceByName, and creating devices is forbidden.
+func (t *Tenants) For(tenantID string) API {
+	return &tenantAPI{api: t.api, dir: t.dir, tenant: tenantID}
+}
+
+// tenantAPI is the API of one tenant.
+type tenantAPI struct {
+	api    API
+	dir    TenantDirectory
+	tenant string
+}
+
+var _ API = (*tenantAPI)(nil)
+
+// devices returns the device set of the tenant.
+func (t *tenantAPI) devices(ctx context.Context) (map[string]bool, error) {
+	ids, err := t.dir.TenantDevices(ctx, t.tenant)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", t.tenant, err)
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// check returns ErrForbiddenDevice unless all devices belong to the tenant.
+func (t *tenantAPI) check(ctx context.Context, deviceIDs ...string) error {
+	set, err := t.devices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range deviceIDs {
+		if !set[id] {
+			return fmt.Errorf("%w: %s", ErrForbiddenDevice, id)
+		}
+	}
+	return nil
+}
+
+// own returns the devices of the tenant.
+func (t *tenantAPI) own(ctx context.Context, devices []Device) ([]Device, error) {
+	set, err := t.devices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return FilterDevices(devices, func(d Device) bool { return d.Id != nil && set[*d.Id] }), nil
+}
+
+func (t *tenantAPI) GetDevices(ctx context.Context, opts ...CallOption) ([]Device, error) {
+	devices, err := t.api.GetDevices(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return t.own(ctx, devices)
+}
+
+func (t *tenantAPI) GetDevice(ctx context.Context, deviceID string, opts ...CallOption) (*Device, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetDevice(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) CreateOrUpdateDevice(ctx context.Context, device Device, opts ...CallOption) (*Device, error) {
+	if device.Id == nil || *device.Id == "" {
+		return nil, fmt.Errorf("%w: tenants cannot create devices", ErrForbiddenDevice)
+	}
+	if err := t.check(ctx, *device.Id); err != nil {
+		return nil, err
+	}
+	return t.api.CreateOrUpdateDevice(ctx, device, opts...)
+}
+
+func (t *tenantAPI) FindDeviceByName(ctx context.Context, name string, opts ...CallOption) (*Device, error) {
+	devices, err := t.GetDevices(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return findDeviceByName(devices, name)
+}
+
+func (t *tenantAPI) GetActivePower(ctx context.Context, deviceID string, opts ...CallOption) (float64, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return 0, err
+	}
+	return t.api.GetActivePower(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) GetCounterReading(ctx context.Context, deviceID string, opts ...CallOption) (float64, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return 0, err
+	}
+	return t.api.GetCounterReading(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) GetTemperature(ctx context.Context, deviceID string, opts ...CallOption) (float64, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return 0, err
+	}
+	return t.api.GetTemperature(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) GetValues(ctx context.Context, deviceID string, opts ...CallOption) (*DeviceValues, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetValues(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) GetDevicesWithValues(ctx context.Context, opts ...CallOption) ([]DeviceSnapshot, error) {
+	snapshots, err := t.api.GetDevicesWithValues(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	set, err := t.devices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var own []DeviceSnapshot
+	for _, s := range snapshots {
+		if s.Device.Id != nil && set[*s.Device.Id] {
+			own = append(own, s)
+		}
+	}
+	return own, nil
+}
+
+func (t *tenantAPI) GetValuesConcurrently(ctx context.Context, deviceIDs []string, opts ...CallOption) ([]DeviceValues, error) {
+	if err := t.check(ctx, deviceIDs...); err != nil {
+		return nil, err
+	}
+	return t.api.GetValuesConcurrently(ctx, deviceIDs, opts...)
+}
+
+func (t *tenantAPI) GetValuesInPast(ctx context.Context, deviceID string, date time.Time, opts ...CallOption) (*Value, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetValuesInPast(ctx, deviceID, date, opts...)
+}
+
+func (t *tenantAPI) GetValuesAt(ctx context.Context, deviceID string, at time.Time, opts ...CallOption) (*DeviceValues, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetValuesAt(ctx, deviceID, at, opts...)
+}
+
+func (t *tenantAPI) GetValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, opts ...CallOption) ([]Value, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetValuesInPastMultiple(ctx, deviceID, startDate, endDate, opts...)
+}
+
+func (t *tenantAPI) StreamValuesInPastMultiple(ctx context.Context, deviceID string, startDate, endDate time.Time, fn func(Value) error, opts ...CallOption) error {
+	if err := t.check(ctx, deviceID); err != nil {
+		return err
+	}
+	return t.api.StreamValuesInPastMultiple(ctx, deviceID, startDate, endDate, fn, opts...)
+}
+
+func (t *tenantAPI) GetActions(ctx context.Context, deviceID string, opts ...CallOption) ([]ActionInfo, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetActions(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) PerformActions(ctx context.Context, deviceID string, actions []Action, opts ...CallOption) error {
+	if err := t.check(ctx, deviceID); err != nil {
+		return err
+	}
+	return t.api.PerformActions(ctx, deviceID, actions, opts...)
+}
+
+func (t *tenantAPI) ExecuteActionsBulk(ctx context.Context, actions map[string][]Action, opts ...CallOption) error {
+	if err := t.check(ctx, sortedKeys(actions)...); err != nil {
+		return err
+	}
+	return t.api.ExecuteActionsBulk(ctx, actions, opts...)
+}
+
+func (t *tenantAPI) CheckActionsBulk(ctx context.Context, actions map[string][]Action, opts ...CallOption) error {
+	if err := t.check(ctx, sortedKeys(actions)...); err != nil {
+		return err
+	}
+	return t.api.CheckActionsBulk(ctx, actions, opts...)
+}
+
+func (t *tenantAPI) SetActiveTariff(ctx context.Context, deviceID string, tariff int32, opts ...CallOption) error {
+	if err := t.check(ctx, deviceID); err != nil {
+		return err
+	}
+	return t.api.SetActiveTariff(ctx, deviceID, tariff, opts...)
+}
+
+func (t *tenantAPI) StartCharging(ctx context.Context, deviceID string, opts ...CallOption) error {
+	if err := t.check(ctx, deviceID); err != nil {
+		return err
+	}
+	return t.api.StartCharging(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) StopCharging(ctx context.Context, deviceID string, opts ...CallOption) error {
+	if err := t.check(ctx, deviceID); err != nil {
+		return err
+	}
+	return t.api.StopCharging(ctx, deviceID, opts...)
+}
+
+func (t *tenantAPI) SetMaxChargingCurrent(ctx context.Context, deviceID string, amps float64, opts ...CallOption) error {
+	if err := t.check(ctx, deviceID); err != nil {
+		return err
+	}
+	return t.api.SetMaxChargingCurrent(ctx, deviceID, amps, opts...)
+}
+
+func (t *tenantAPI) CompareConsumption(ctx context.Context, deviceIDs []string, periodA, periodB Period, opts ...CallOption) (*ConsumptionReport, error) {
+	if err := t.check(ctx, deviceIDs...); err != nil {
+		return nil, err
+	}
+	return t.api.CompareConsumption(ctx, deviceIDs, periodA, periodB, opts...)
+}
+
+func (t *tenantAPI) GetBatteryState(ctx context.Context, deviceID string, capacity float64, opts ...CallOption) (*BatteryState, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetBatteryState(ctx, deviceID, capacity, opts...)
+}
+
+func (t *tenantAPI) GetBatteryHistory(ctx context.Context, deviceID string, start, end time.Time, capacity float64, opts ...CallOption) ([]BatterySample, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetBatteryHistory(ctx, deviceID, start, end, capacity, opts...)
+}
+
+func (t *tenantAPI) GetChargingSessions(ctx context.Context, deviceID string, start, end time.Time, opts ...CallOption) ([]ChargingSession, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.GetChargingSessions(ctx, deviceID, start, end, opts...)
+}
+
+func (t *tenantAPI) DailyConsumption(ctx context.Context, deviceID string, month time.Time, opts ...CallOption) ([]ConsumptionPoint, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.DailyConsumption(ctx, deviceID, month, opts...)
+}
+
+func (t *tenantAPI) MonthlyConsumption(ctx context.Context, deviceID string, year int, opts ...CallOption) ([]ConsumptionPoint, error) {
+	if err := t.check(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return t.api.MonthlyConsumption(ctx, deviceID, year, opts...)
+}
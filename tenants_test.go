@@ -0,0 +1,62 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestTenants(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	for _, id := range []string{"acme-1", "acme-2", "other-1"} {
+		srv.AddDevice(smartme.Device{Id: ptr(id), Name: ptr("Meter " + id), ActivePower: ptr(1.0)})
+	}
+	client, _ := srv.Client()
+
+	tenants := smartme.NewTenants(client, smartme.StaticTenants{"acme": {"acme-1", "acme-2"}})
+	api := tenants.For("acme")
+	ctx := context.Background()
+
+	devices, err := api.GetDevices(ctx)
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	if len(devices) != 2 || *devices[0].Id != "acme-1" || *devices[1].Id != "acme-2" {
+		t.Errorf("GetDevices returned %d devices, want acme-1 and acme-2", len(devices))
+	}
+	if _, err := api.GetDevice(ctx, "acme-1"); err != nil {
+		t.Errorf("GetDevice of own device failed: %v", err)
+	}
+
+	forbidden := []struct {
+		name string
+		call func() error
+	}{
+		{"GetDevice", func() error { _, err := api.GetDevice(ctx, "other-1"); return err }},
+		{"GetValuesConcurrently", func() error { _, err := api.GetValuesConcurrently(ctx, []string{"acme-1", "other-1"}); return err }},
+		{"PerformActions", func() error {
+			return api.PerformActions(ctx, "other-1", []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 0}})
+		}},
+		{"ExecuteActionsBulk", func() error {
+			return api.ExecuteActionsBulk(ctx, map[string][]smartme.Action{"other-1": {{ObisCode: smartme.ObisSwitchState, Value: 0}}})
+		}},
+		{"CreateOrUpdateDevice", func() error { _, err := api.CreateOrUpdateDevice(ctx, smartme.Device{Name: ptr("new")}); return err }},
+		{"unknown tenant", func() error { _, err := tenants.For("nobody").GetDevice(ctx, "acme-1"); return err }},
+	}
+	for _, tt := range forbidden {
+		if err := tt.call(); !errors.Is(err, smartme.ErrForbiddenDevice) {
+			t.Errorf("%s: error = %v, want ErrForbiddenDevice", tt.name, err)
+		}
+	}
+	if len(srv.Actions("other-1")) != 0 {
+		t.Error("forbidden actions reached the API")
+	}
+
+	if _, err := api.FindDeviceByName(ctx, "Meter other-1"); !errors.Is(err, smartme.ErrDeviceNotFound) {
+		t.Errorf("FindDeviceByName of a foreign device: error = %v, want ErrDeviceNotFound", err)
+	}
+}
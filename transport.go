@@ -0,0 +1,45 @@
+package smartme
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// transportConfig holds the settings of WithTransportTuning and WithHTTP2.
+type transportConfig struct {
+	maxIdleConns    int
+	maxConnsPerHost int
+	idleTimeout     time.Duration
+	disableHTTP2    bool
+}
+
+// build creates a transport based on http.DefaultTransport with the
+// configured settings applied.
+func (tc *transportConfig) build() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if tc.maxIdleConns > 0 {
+		t.MaxIdleConns = tc.maxIdleConns
+	}
+	if tc.maxConnsPerHost > 0 {
+		t.MaxConnsPerHost = tc.maxConnsPerHost
+		// All requests go to the same host, so keep as many idle
+		// connections for it as may be open at once.
+		t.MaxIdleConnsPerHost = tc.maxConnsPerHost
+		if tc.maxIdleConns > 0 && tc.maxIdleConns < tc.maxConnsPerHost {
+			t.MaxIdleConnsPerHost = tc.maxIdleConns
+		}
+	}
+	if tc.idleTimeout > 0 {
+		t.IdleConnTimeout = tc.idleTimeout
+	}
+	if tc.disableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	} else {
+		t.ForceAttemptHTTP2 = true
+	}
+
+	return t
+}
@@ -0,0 +1,66 @@
+package smartme
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithTransportTuning(t *testing.T) {
+	c, err := NewClient("user", "pass", WithTransportTuning(50, 20, 30*time.Second), WithHTTP2(false))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	tr, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport is %T, want *http.Transport", c.httpClient.Transport)
+	}
+	if tr.MaxIdleConns != 50 || tr.MaxConnsPerHost != 20 || tr.MaxIdleConnsPerHost != 20 || tr.IdleConnTimeout != 30*time.Second {
+		t.Errorf("unexpected transport settings: idle=%d perHost=%d idlePerHost=%d timeout=%s",
+			tr.MaxIdleConns, tr.MaxConnsPerHost, tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+	if tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil {
+		t.Error("HTTP/2 should be disabled")
+	}
+	if c.httpClient.Timeout != defaultTimeout {
+		t.Errorf("timeout = %s, want default", c.httpClient.Timeout)
+	}
+}
+
+func TestWithTransportTuning_CustomTransportUntouched(t *testing.T) {
+	custom := &http.Client{Transport: &http.Transport{}}
+	c, _ := NewClient("user", "pass", WithHTTPClient(custom), WithTransportTuning(50, 20, time.Second))
+
+	if c.httpClient.Transport.(*http.Transport).MaxConnsPerHost != 0 {
+		t.Error("a custom transport must not be modified")
+	}
+}
+
+func TestClientWith(t *testing.T) {
+	c, err := NewClient("user", "pass", WithTransportTuning(50, 20, 30*time.Second))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	d, err := c.With(WithTimeout(time.Minute), WithReadOnly())
+	if err != nil {
+		t.Fatalf("With failed: %v", err)
+	}
+	if d.httpClient.Timeout != time.Minute || !d.readOnly {
+		t.Errorf("derived client: timeout = %s, readOnly = %v", d.httpClient.Timeout, d.readOnly)
+	}
+	if c.httpClient.Timeout != defaultTimeout || c.readOnly {
+		t.Errorf("parent client changed: timeout = %s, readOnly = %v", c.httpClient.Timeout, c.readOnly)
+	}
+	if d.httpClient.Transport != c.httpClient.Transport {
+		t.Error("derived client does not share the transport")
+	}
+	if d.username != "user" || d.password != "pass" {
+		t.Error("derived client lost the credentials")
+	}
+
+	if _, err := c.With(WithTimeout(-1)); err == nil {
+		t.Error("With accepted an invalid option")
+	}
+}
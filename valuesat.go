@@ -0,0 +1,38 @@
+package smartme
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// GetValuesAt retrieves all OBIS values of a device as they were at a given
+// date, that is the last values recorded at or before it. Unlike
+// GetValuesInPast, which returns a single counter reading, the result holds
+// every register, so point-in-time audits see tariff counters, export and
+// power alike. WithObis selects registers as for GetValues.
+// Corresponds to the API call: GET /api/MeterValues/{id}?date={date}
+func (c *Client) GetValuesAt(ctx context.Context, deviceID string, at time.Time, opts ...CallOption) (*DeviceValues, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("deviceID must not be empty")
+	}
+
+	cfg := c.callConfig(opts)
+	query := url.Values{"date": {cfg.formatDate(at)}}
+	path := fmt.Sprintf("api/MeterValues/%s?%s", url.PathEscape(deviceID), query.Encode())
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var deviceValues DeviceValues
+	_, err = c.doCached(req, &deviceValues, c.historyCacheKey(req, at))
+	if err != nil {
+		return nil, err
+	}
+	cfg.filterObis(&deviceValues)
+
+	return &deviceValues, nil
+}
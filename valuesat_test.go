@@ -0,0 +1,66 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestClient_GetValuesAt(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := func(h int, energy float64) smartme.DeviceValues {
+		return smartme.DeviceValues{
+			DeviceID: "meter",
+			Date:     day.Add(time.Duration(h) * time.Hour),
+			Values: []smartme.ObisValue{
+				{Obis: smartme.ObisActiveEnergyImport, Value: energy},
+				{Obis: smartme.ObisActiveEnergyExport, Value: energy / 10},
+				{Obis: smartme.ObisActivePower, Value: 1500},
+			},
+		}
+	}
+	srv.AddPastValues(snapshot(12, 120), snapshot(0, 100), snapshot(6, 110))
+
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	values, err := client.GetValuesAt(ctx, "meter", day.Add(8*time.Hour))
+	if err != nil {
+		t.Fatalf("GetValuesAt failed: %v", err)
+	}
+	if !values.Date.Equal(day.Add(6 * time.Hour)) {
+		t.Errorf("values are from %v, want the snapshot at 06:00", values.Date)
+	}
+	if len(values.Values) != 3 {
+		t.Fatalf("got %d values, want all 3 registers", len(values.Values))
+	}
+	if values.Values[0].Value != 110 || values.Values[1].Value != 11 {
+		t.Errorf("values = %+v", values.Values)
+	}
+
+	values, err = client.GetValuesAt(ctx, "meter", day.Add(12*time.Hour), smartme.WithObis(smartme.ObisActiveEnergyExport))
+	if err != nil {
+		t.Fatalf("GetValuesAt with WithObis failed: %v", err)
+	}
+	if len(values.Values) != 1 || values.Values[0].Value != 12 {
+		t.Errorf("values with WithObis = %+v, want only the export register", values.Values)
+	}
+
+	_, err = client.GetValuesAt(ctx, "meter", day.Add(-time.Hour))
+	var apiErr *smartme.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetValuesAt before the first snapshot: err = %v, want 404", err)
+	}
+
+	if _, err := client.GetValuesAt(ctx, "", day); err == nil {
+		t.Error("GetValuesAt accepted an empty device ID")
+	}
+}
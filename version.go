@@ -0,0 +1,82 @@
+package smartme
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// versionPattern matches API version segments like "v2" or "v2.1".
+var versionPattern = regexp.MustCompile(`^v[0-9]+(\.[0-9]+)?$`)
+
+// WithAPIVersion inserts a version segment like "v2" after "api/" in the
+// paths of all calls, e.g. /api/v2/Devices instead of /api/Devices. By
+// default the unversioned paths are used. Combine it with WithBaseURL to
+// target a staging environment.
+func WithAPIVersion(version string) Option {
+	return func(c *Client) error {
+		if err := validateVersion(version); err != nil {
+			return err
+		}
+		c.apiVersion = version
+		return nil
+	}
+}
+
+// WithEndpointVersion overrides the API version for a single endpoint,
+// given by its resource name like "Values" or "ValuesInPastMultiple". An
+// empty version uses the unversioned path for that endpoint, which keeps
+// it working when WithAPIVersion moves the other calls to a version it
+// does not exist in yet.
+func WithEndpointVersion(endpoint, version string) Option {
+	return func(c *Client) error {
+		if endpoint == "" || strings.ContainsAny(endpoint, "/?") {
+			return fmt.Errorf("invalid endpoint %q", endpoint)
+		}
+		if version != "" {
+			if err := validateVersion(version); err != nil {
+				return err
+			}
+		}
+		// Copy on write, derived clients share the map.
+		versions := make(map[string]string, len(c.endpointVersions)+1)
+		for k, v := range c.endpointVersions {
+			versions[k] = v
+		}
+		versions[endpoint] = version
+		c.endpointVersions = versions
+		return nil
+	}
+}
+
+func validateVersion(version string) error {
+	if version == "" {
+		return errors.New("API version must not be empty")
+	}
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("invalid API version %q: must look like v2", version)
+	}
+	return nil
+}
+
+// versionedPath inserts the API version configured for the endpoint of
+// path, a path like "api/Devices/{id}?query".
+func (c *Client) versionedPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "api/")
+	if !ok {
+		return path
+	}
+	endpoint := rest
+	if i := strings.IndexAny(endpoint, "/?"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	version, ok := c.endpointVersions[endpoint]
+	if !ok {
+		version = c.apiVersion
+	}
+	if version == "" {
+		return path
+	}
+	return "api/" + version + "/" + rest
+}
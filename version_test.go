@@ -0,0 +1,81 @@
+package smartme_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestWithAPIVersion(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if strings.Contains(r.URL.Path, "Devices") {
+			w.Write([]byte("[]"))
+			return
+		}
+		w.Write([]byte(`{"Values":[]}`))
+	}))
+	defer srv.Close()
+
+	client, err := smartme.NewClient("test-user", "test-pass",
+		smartme.WithBaseURL(srv.URL+"/staging"),
+		smartme.WithAPIVersion("v2"),
+		smartme.WithEndpointVersion("Values", ""),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := client.GetDevices(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetValues(ctx, "dev-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/staging/api/v2/Devices /staging/api/Values/dev-1"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("paths = %q, want %q", got, want)
+	}
+	stats := client.Stats()
+	for _, name := range []string{"GET /api/v2/Devices", "GET /api/Values/{id}"} {
+		if stats.Endpoints[name].Calls != 1 {
+			t.Errorf("stats for %q = %+v, want 1 call", name, stats.Endpoints[name])
+		}
+	}
+}
+
+func TestWithEndpointVersion_DerivedClient(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	client, err := smartme.NewClient("test-user", "test-pass", smartme.WithBaseURL(srv.URL), smartme.WithEndpointVersion("Devices", "v2"))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	derived, err := client.With(smartme.WithEndpointVersion("Devices", "v3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := derived.GetDevices(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetDevices(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/api/v3/Devices /api/v2/Devices"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("paths = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,263 @@
+package smartme
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// defaultWatchInterval is the poll interval of a Watcher without Interval.
+const defaultWatchInterval = 30 * time.Second
+
+// ChargeEventType is the kind of a ChargeEvent.
+type ChargeEventType int
+
+const (
+	// CarConnected is reported when a car is plugged in.
+	CarConnected ChargeEventType = iota + 1
+	// CarDisconnected is reported when the car is unplugged.
+	CarDisconnected
+	// ChargingStarted is reported when the station starts charging.
+	ChargingStarted
+	// ChargingStopped is reported when the station stops charging.
+	ChargingStopped
+	// WentOffline is reported when the station loses its connection.
+	WentOffline
+	// CameOnline is reported when an offline station is reachable again.
+	CameOnline
+)
+
+// String returns the name of the event type.
+func (t ChargeEventType) String() string {
+	switch t {
+	case CarConnected:
+		return "CarConnected"
+	case CarDisconnected:
+		return "CarDisconnected"
+	case ChargingStarted:
+		return "ChargingStarted"
+	case ChargingStopped:
+		return "ChargingStopped"
+	case WentOffline:
+		return "WentOffline"
+	case CameOnline:
+		return "CameOnline"
+	}
+	return fmt.Sprintf("ChargeEventType(%d)", int(t))
+}
+
+// ChargeEvent is a change of a charging station derived from its
+// ChargeStationState.
+type ChargeEvent struct {
+	DeviceID string
+	Type     ChargeEventType
+	// From and To are the debounced states before and after the change.
+	From, To ChargeStationState
+	Time     time.Time
+}
+
+// Watcher polls devices and reports their data and the state changes of
+// charging stations. Set the fields before calling Run; they must not be
+// changed while it runs.
+type Watcher struct {
+	// Interval is the time between two polls of a device (default 30s).
+	Interval time.Duration
+	// Intervals overrides Interval per energy type, e.g. 15s for
+	// electricity and 5m for water meters. The energy type of a device is
+	// known after its first poll.
+	Intervals map[MeterEnergyType]time.Duration
+	// DeviceIntervals overrides Interval and Intervals per device ID.
+	DeviceIntervals map[string]time.Duration
+	// Jitter delays every poll of a device by a random duration of up to
+	// Jitter, so devices with the same interval are not polled in bursts.
+	Jitter time.Duration
+	// Debounce is how long a new charge station state must be observed
+	// before it is reported, so flapping states do not cause events.
+	// Zero reports every change at the next poll.
+	Debounce time.Duration
+	// Clock is used for timestamps and to wait between polls. Defaults to
+	// the system clock.
+	Clock Clock
+
+	// OnDevice is called with every polled device.
+	OnDevice func(Device)
+	// OnChargeEvent is called for every change of a charging station.
+	OnChargeEvent func(ChargeEvent)
+	// OnError is called when a device cannot be polled.
+	OnError func(deviceID string, err error)
+
+	api         API
+	deviceIDs   []string
+	trackers    map[string]*chargeTracker
+	energyTypes map[string]MeterEnergyType
+	next        map[string]time.Time
+}
+
+// NewWatcher returns a Watcher for the given devices.
+func NewWatcher(api API, deviceIDs ...string) *Watcher {
+	return &Watcher{
+		api:         api,
+		deviceIDs:   deviceIDs,
+		trackers:    make(map[string]*chargeTracker),
+		energyTypes: make(map[string]MeterEnergyType),
+		next:        make(map[string]time.Time),
+	}
+}
+
+// Run polls the devices until ctx is done and returns ctx.Err(). The
+// callbacks are called from the goroutine running Run. Polls have
+// PriorityWatcher unless ctx has a priority.
+func (w *Watcher) Run(ctx context.Context) error {
+	if _, ok := PriorityFromContext(ctx); !ok {
+		ctx = ContextWithPriority(ctx, PriorityWatcher)
+	}
+	clock := w.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	start := clock.Now()
+	for _, id := range w.deviceIDs {
+		w.next[id] = start.Add(w.jitter())
+	}
+
+	for {
+		w.poll(ctx, clock)
+		wait := w.interval("")
+		if len(w.deviceIDs) > 0 {
+			now := clock.Now()
+			wait = w.next[w.deviceIDs[0]].Sub(now)
+			for _, id := range w.deviceIDs[1:] {
+				wait = min(wait, w.next[id].Sub(now))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// poll fetches all devices that are due.
+func (w *Watcher) poll(ctx context.Context, clock Clock) {
+	now := clock.Now()
+	for _, id := range w.deviceIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if w.next[id].After(now) {
+			continue
+		}
+		d, err := w.api.GetDevice(ctx, id)
+		if err == nil && d.DeviceEnergyType != nil {
+			w.energyTypes[id] = *d.DeviceEnergyType
+		}
+		w.next[id] = now.Add(w.interval(id) + w.jitter())
+		if err != nil {
+			if w.OnError != nil && ctx.Err() == nil {
+				w.OnError(id, err)
+			}
+			continue
+		}
+		if w.OnDevice != nil {
+			w.OnDevice(*d)
+		}
+		if d.ChargeStationState != nil {
+			w.track(id, *d.ChargeStationState, clock.Now())
+		}
+	}
+}
+
+// interval returns the poll interval of a device.
+func (w *Watcher) interval(deviceID string) time.Duration {
+	if d := w.DeviceIntervals[deviceID]; d > 0 {
+		return d
+	}
+	if energyType, ok := w.energyTypes[deviceID]; ok {
+		if d := w.Intervals[energyType]; d > 0 {
+			return d
+		}
+	}
+	if w.Interval > 0 {
+		return w.Interval
+	}
+	return defaultWatchInterval
+}
+
+// jitter returns a random delay of up to w.Jitter.
+func (w *Watcher) jitter() time.Duration {
+	if w.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(w.Jitter) + 1))
+}
+
+// chargeTracker debounces the states of one charging station.
+type chargeTracker struct {
+	stable    ChargeStationState
+	candidate ChargeStationState
+	since     time.Time
+	pending   bool
+}
+
+// track records an observed state and reports debounced changes.
+func (w *Watcher) track(deviceID string, state ChargeStationState, now time.Time) {
+	t, ok := w.trackers[deviceID]
+	if !ok {
+		// The first observation is the baseline; there is no change yet.
+		w.trackers[deviceID] = &chargeTracker{stable: state}
+		return
+	}
+
+	if state == t.stable {
+		t.pending = false
+		return
+	}
+	if !t.pending || state != t.candidate {
+		t.candidate, t.since, t.pending = state, now, true
+	}
+	if now.Sub(t.since) < w.Debounce {
+		return
+	}
+
+	from := t.stable
+	t.stable, t.pending = state, false
+	if w.OnChargeEvent == nil {
+		return
+	}
+	for _, typ := range chargeTransitions(from, state) {
+		w.OnChargeEvent(ChargeEvent{DeviceID: deviceID, Type: typ, From: from, To: state, Time: now})
+	}
+}
+
+// chargeTransitions returns the events caused by a change between two
+// states, in the order they happened.
+func chargeTransitions(from, to ChargeStationState) []ChargeEventType {
+	var events []ChargeEventType
+	if to == Offline {
+		if from == Charging {
+			events = append(events, ChargingStopped)
+		}
+		return append(events, WentOffline)
+	}
+	if from == Offline {
+		events = append(events, CameOnline)
+	}
+
+	switch {
+	case from == Charging:
+		events = append(events, ChargingStopped)
+		if !to.CarConnected() {
+			events = append(events, CarDisconnected)
+		}
+	case !from.CarConnected() && to.CarConnected():
+		events = append(events, CarConnected)
+	case from.CarConnected() && !to.CarConnected():
+		events = append(events, CarDisconnected)
+	}
+	if to == Charging {
+		events = append(events, ChargingStarted)
+	}
+	return events
+}
@@ -0,0 +1,168 @@
+package smartme_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestWatcher_ChargeEvents(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+
+	setState := func(s smartme.ChargeStationState) {
+		srv.AddDevice(smartme.Device{Id: ptr("charger"), ChargeStationState: &s})
+	}
+	setState(smartme.ReadyNoCarConnected)
+
+	clock := smartmetest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := smartme.NewWatcher(client, "charger")
+	w.Interval = 10 * time.Second
+	w.Debounce = 10 * time.Second
+	w.Clock = clock
+
+	var mu sync.Mutex
+	var events []smartme.ChargeEventType
+	w.OnChargeEvent = func(e smartme.ChargeEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e.Type)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// Each step sets the state seen by the following polls.
+	steps := []smartme.ChargeStationState{
+		smartme.ReadyCarConnected, smartme.ReadyCarConnected, // reported once seen for 10s
+		smartme.Charging, smartme.ReadyCarConnected, // flapping is not reported
+		smartme.Charging, smartme.Charging,
+		smartme.Offline, smartme.Offline,
+	}
+	for _, s := range steps {
+		clock.WaitForTimers(t, 1)
+		setState(s)
+		clock.Advance(10 * time.Second)
+	}
+	clock.WaitForTimers(t, 1)
+	cancel()
+	clock.Advance(10 * time.Second)
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run returned %v, want context.Canceled", err)
+	}
+
+	want := []smartme.ChargeEventType{
+		smartme.CarConnected,
+		smartme.ChargingStarted,
+		smartme.ChargingStopped, smartme.WentOffline,
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestWatcher_Intervals(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+	srv.AddDevice(smartme.Device{Id: ptr("power"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity)})
+	srv.AddDevice(smartme.Device{Id: ptr("water"), DeviceEnergyType: ptr(smartme.MeterTypeWater)})
+	srv.AddDevice(smartme.Device{Id: ptr("heat"), DeviceEnergyType: ptr(smartme.MeterTypeElectricity)})
+
+	clock := smartmetest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := smartme.NewWatcher(client, "power", "water", "heat")
+	w.Intervals = map[smartme.MeterEnergyType]time.Duration{
+		smartme.MeterTypeElectricity: 15 * time.Second,
+		smartme.MeterTypeWater:       5 * time.Minute,
+	}
+	w.DeviceIntervals = map[string]time.Duration{"heat": time.Minute}
+	w.Clock = clock
+
+	var mu sync.Mutex
+	polls := make(map[string]int)
+	w.OnDevice = func(d smartme.Device) {
+		mu.Lock()
+		defer mu.Unlock()
+		polls[*d.Id]++
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	for i := 0; i < 20; i++ {
+		clock.WaitForTimers(t, 1)
+		clock.Advance(15 * time.Second)
+	}
+	clock.WaitForTimers(t, 1)
+	cancel()
+	clock.Advance(time.Minute)
+	<-done
+
+	want := map[string]int{"power": 21, "water": 2, "heat": 6}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(polls, want) {
+		t.Errorf("polls in 5 minutes = %v, want %v", polls, want)
+	}
+}
+
+func TestWatcher_Jitter(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	client, _ := srv.Client()
+	srv.AddDevice(smartme.Device{Id: ptr("a")})
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := smartmetest.NewClock(start)
+	w := smartme.NewWatcher(client, "a")
+	w.Interval = 10 * time.Second
+	w.Jitter = 5 * time.Second
+	w.Clock = clock
+
+	var mu sync.Mutex
+	var times []time.Time
+	w.OnDevice = func(smartme.Device) {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, clock.Now())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	const step = 100 * time.Millisecond
+	for i := 0; i < 600; i++ {
+		clock.WaitForTimers(t, 1)
+		clock.Advance(step)
+	}
+	clock.WaitForTimers(t, 1)
+	cancel()
+	clock.Advance(time.Minute)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) < 4 {
+		t.Fatalf("got %d polls in 60s, want at least 4", len(times))
+	}
+	if first := times[0].Sub(start); first > w.Jitter+step {
+		t.Errorf("first poll after %v, want at most %v", first, w.Jitter)
+	}
+	for i := 1; i < len(times); i++ {
+		gap := times[i].Sub(times[i-1])
+		if gap < w.Interval || gap > w.Interval+w.Jitter+step {
+			t.Errorf("gap %d = %v, want between %v and %v", i, gap, w.Interval, w.Interval+w.Jitter)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package smartme
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Aggregation is how a window reduces its values to one value.
+type Aggregation int
+
+// The aggregations of a Window.
+const (
+	Mean Aggregation = iota + 1
+	Min
+	Max
+	Sum
+	Count
+	// Last is the value with the latest date.
+	Last
+)
+
+// String returns the name of the aggregation.
+func (a Aggregation) String() string {
+	switch a {
+	case Mean:
+		return "Mean"
+	case Min:
+		return "Min"
+	case Max:
+		return "Max"
+	case Sum:
+		return "Sum"
+	case Count:
+		return "Count"
+	case Last:
+		return "Last"
+	}
+	return fmt.Sprintf("Aggregation(%d)", int(a))
+}
+
+// Window aggregates a live series, e.g. the active power of the devices
+// polled by a Watcher, over time windows, so dashboards and alerts get
+// aggregated series without storing the raw samples first:
+//
+//	avg := smartme.Tumbling(time.Minute, smartme.Mean)
+//	w.OnDevice = func(d smartme.Device) {
+//		p, _ := d.ActivePowerWatts()
+//		for _, v := range avg.Add(smartme.Value{Date: time.Now(), Value: p}) {
+//			publish(v)
+//		}
+//	}
+//
+// Use one Window per series. It is safe for concurrent use.
+type Window struct {
+	size    time.Duration
+	agg     Aggregation
+	sliding bool
+
+	mu sync.Mutex
+	// start is the start of the open tumbling window and acc accumulates
+	// its values; closed is the end of the last emitted window.
+	start, closed time.Time
+	acc           accumulator
+	// samples are the values of a sliding window, oldest first.
+	samples []Value
+}
+
+// Tumbling returns a Window that aggregates the values of consecutive,
+// non-overlapping windows of the given size, aligned to multiples of size
+// since the zero time, so minutes and hours start on the full minute and
+// hour. A window is emitted when the first value of a later window arrives
+// or by Tick; the Date of the aggregated value is the start of its window.
+// Values for windows already emitted are dropped.
+func Tumbling(size time.Duration, agg Aggregation) *Window {
+	return &Window{size: size, agg: agg}
+}
+
+// Sliding returns a Window that emits, with every value, the aggregate of
+// the values of the preceding size up to and including it, e.g. the
+// maximum of the last 15 minutes. The Date of the aggregated value is the
+// date of the newest value. Values older than the newest are dropped.
+func Sliding(size time.Duration, agg Aggregation) *Window {
+	return &Window{size: size, agg: agg, sliding: true}
+}
+
+// Add adds a value and returns the aggregated values it completes: at most
+// one for a tumbling window, exactly one for a sliding window unless the
+// value is dropped.
+func (w *Window) Add(v Value) []Value {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.sliding {
+		if n := len(w.samples); n > 0 && v.Date.Before(w.samples[n-1].Date) {
+			return nil
+		}
+		w.samples = append(w.samples, v)
+		return w.slide(v.Date)
+	}
+
+	start := v.Date.Truncate(w.size)
+	var out []Value
+	switch {
+	case start.Before(w.closed), w.acc.n > 0 && start.Before(w.start):
+		return nil
+	case w.acc.n > 0 && start.After(w.start):
+		out = append(out, w.emit())
+	}
+	if w.acc.n == 0 {
+		w.start = start
+	}
+	w.acc.add(v)
+	return out
+}
+
+// Tick emits what is complete at now without a new value: the open
+// tumbling window if it ended by now, or the aggregate of the sliding
+// window at now, if it still holds values.
+func (w *Window) Tick(now time.Time) []Value {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.sliding {
+		if n := len(w.samples); n == 0 || now.Before(w.samples[n-1].Date) {
+			return nil
+		}
+		return w.slide(now)
+	}
+	if w.acc.n == 0 || now.Before(w.start.Add(w.size)) {
+		return nil
+	}
+	return []Value{w.emit()}
+}
+
+// emit closes the open tumbling window and returns its aggregate.
+func (w *Window) emit() Value {
+	v := Value{Date: w.start, Value: w.acc.result(w.agg)}
+	w.closed, w.acc = w.start.Add(w.size), accumulator{}
+	return v
+}
+
+// slide drops the samples that left the window ending at t and returns
+// the aggregate of the rest.
+func (w *Window) slide(t time.Time) []Value {
+	drop := 0
+	for drop < len(w.samples) && !w.samples[drop].Date.After(t.Add(-w.size)) {
+		drop++
+	}
+	w.samples = append(w.samples[:0], w.samples[drop:]...)
+	if len(w.samples) == 0 {
+		return nil
+	}
+	var acc accumulator
+	for _, v := range w.samples {
+		acc.add(v)
+	}
+	return []Value{{Date: t, Value: acc.result(w.agg)}}
+}
+
+// accumulator keeps what the aggregations need of a set of values.
+type accumulator struct {
+	n             int
+	sum, min, max float64
+	last          Value
+}
+
+func (a *accumulator) add(v Value) {
+	if a.n == 0 {
+		a.min, a.max = v.Value, v.Value
+	}
+	a.n++
+	a.sum += v.Value
+	a.min = math.Min(a.min, v.Value)
+	a.max = math.Max(a.max, v.Value)
+	if a.n == 1 || !v.Date.Before(a.last.Date) {
+		a.last = v
+	}
+}
+
+func (a *accumulator) result(agg Aggregation) float64 {
+	switch agg {
+	case Min:
+		return a.min
+	case Max:
+		return a.max
+	case Sum:
+		return a.sum
+	case Count:
+		return float64(a.n)
+	case Last:
+		return a.last.Value
+	}
+	if a.n == 0 {
+		return 0
+	}
+	return a.sum / float64(a.n)
+}
@@ -0,0 +1,91 @@
+package smartme_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+)
+
+func TestTumbling(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(sec int, v float64) smartme.Value {
+		return smartme.Value{Date: base.Add(time.Duration(sec) * time.Second), Value: v}
+	}
+
+	w := smartme.Tumbling(time.Minute, smartme.Mean)
+	var got []smartme.Value
+	for _, v := range []smartme.Value{at(0, 100), at(30, 200), at(59, 300), at(65, 50), at(10, 999), at(70, 150), at(200, 10)} {
+		got = append(got, w.Add(v)...)
+	}
+	want := []smartme.Value{{Date: base, Value: 200}, {Date: base.Add(time.Minute), Value: 100}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Date.Equal(want[i].Date) || got[i].Value != want[i].Value {
+			t.Errorf("window %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if out := w.Tick(base.Add(3*time.Minute + 59*time.Second)); len(out) != 0 {
+		t.Errorf("Tick before the end of the window emitted %v", out)
+	}
+	if out := w.Tick(base.Add(4 * time.Minute)); len(out) != 1 || out[0].Value != 10 || !out[0].Date.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("Tick = %v, want the window from 12:03", out)
+	}
+	if out := w.Add(at(230, 1)); out != nil {
+		t.Errorf("value of an emitted window returned %v", out)
+	}
+}
+
+func TestSliding(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(min int, v float64) smartme.Value {
+		return smartme.Value{Date: base.Add(time.Duration(min) * time.Minute), Value: v}
+	}
+
+	w := smartme.Sliding(15*time.Minute, smartme.Max)
+	tests := []struct {
+		in   smartme.Value
+		want float64
+	}{
+		{at(0, 500), 500},
+		{at(5, 300), 500},
+		{at(14, 200), 500},
+		{at(15, 100), 300},
+		{at(21, 400), 400},
+	}
+	for _, tt := range tests {
+		out := w.Add(tt.in)
+		if len(out) != 1 || out[0].Value != tt.want || !out[0].Date.Equal(tt.in.Date) {
+			t.Errorf("Add(%v) = %v, want %v", tt.in, out, tt.want)
+		}
+	}
+	if out := w.Add(at(20, 1000)); out != nil {
+		t.Errorf("out of order value returned %v", out)
+	}
+	if out := w.Tick(base.Add(35 * time.Minute)); len(out) != 1 || out[0].Value != 400 {
+		t.Errorf("Tick = %v, want 400", out)
+	}
+	if out := w.Tick(base.Add(36 * time.Minute)); out != nil {
+		t.Errorf("Tick after all values left the window = %v", out)
+	}
+}
+
+func TestAggregation(t *testing.T) {
+	values := []float64{3, 1, 2}
+	tests := map[smartme.Aggregation]float64{
+		smartme.Mean: 2, smartme.Min: 1, smartme.Max: 3, smartme.Sum: 6, smartme.Count: 3, smartme.Last: 2,
+	}
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for agg, want := range tests {
+		w := smartme.Tumbling(time.Hour, agg)
+		for i, v := range values {
+			w.Add(smartme.Value{Date: base.Add(time.Duration(i) * time.Minute), Value: v})
+		}
+		if out := w.Tick(base.Add(time.Hour)); len(out) != 1 || out[0].Value != want {
+			t.Errorf("%s = %v, want %v", agg, out, want)
+		}
+	}
+}
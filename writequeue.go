@@ -0,0 +1,423 @@
+package smartme
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReplayInterval is the default time between two replays of Run.
+const DefaultReplayInterval = 30 * time.Second
+
+var (
+	// ErrWriteQueued is returned by a WriteQueue for a write that failed
+	// because the API was unreachable and was queued for replay.
+	ErrWriteQueued = errors.New("write queued until the API is reachable")
+	// ErrWriteConflict reports a queued write that was dropped because the
+	// state of the device changed since it was queued.
+	ErrWriteConflict = errors.New("device state changed since the write was queued")
+	// ErrWriteExpired reports a queued write that was dropped because it
+	// was older than MaxAge.
+	ErrWriteExpired = errors.New("queued write expired")
+)
+
+// QueuedWrite is a call that changes a device, queued by a WriteQueue.
+type QueuedWrite struct {
+	// Method is the name of the API method, e.g. "SetMaxChargingCurrent".
+	Method   string
+	DeviceID string
+	Queued   time.Time
+
+	// The arguments of the call, depending on Method.
+	Device  *Device
+	Actions []Action
+	Tariff  int32
+	Amps    float64
+
+	// state is the device state when the write was queued, see
+	// WriteQueue.state; it is empty if it could not be read.
+	state string
+	// values is the context of the call without its cancellation and opts
+	// are its options. Both are applied again on replay, so credentials,
+	// base URL and audit reason of the context are kept.
+	values context.Context
+	opts   []CallOption
+}
+
+// replayContext has the values of the context of a queued call and the
+// deadline and cancellation of the replay.
+type replayContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c replayContext) Value(key any) any {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// command reports whether w switches a relay or controls charging.
+func (w *QueuedWrite) command() bool {
+	switch w.Method {
+	case "StartCharging", "StopCharging":
+		return true
+	case "PerformActions":
+		for _, a := range w.Actions {
+			if NormalizeObis(a.ObisCode) == ObisSwitchState {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Conflict is a queued write whose device changed since it was queued.
+type Conflict struct {
+	Write QueuedWrite
+	// Was and Now describe the state of the device when the write was
+	// queued and before it is replayed, e.g. "max charging current 16".
+	Was, Now string
+}
+
+// ReplayResult is the outcome of a queued write.
+type ReplayResult struct {
+	Write QueuedWrite
+	// Err is nil if the write was applied. ErrWriteConflict and
+	// ErrWriteExpired tell why a write was dropped; other errors are
+	// returned by the API.
+	Err error
+}
+
+// WriteQueue wraps an API and queues calls that change devices while the
+// API is unreachable, to replay them when it is back. It complements
+// WithOfflineMode, which answers the read calls in the meantime:
+//
+//	client, err := smartme.NewClient(user, pass, smartme.WithOfflineMode())
+//	q := smartme.NewWriteQueue(client)
+//	go q.Run(ctx)
+//	err = q.SetMaxChargingCurrent(ctx, chargerID, 10) // ErrWriteQueued while offline
+//
+// Configuration changes are queued: CreateOrUpdateDevice,
+// SetMaxChargingCurrent, SetActiveTariff and actions other than switching.
+// Switch actions and charging commands are only queued with Commands,
+// since replaying them late may be unsafe. Writes to the same setting of
+// a device replace each other, so only the last one is replayed.
+//
+// Before a write is replayed, the state it changes is read again and
+// compared with the state read when it was queued. If it differs, someone
+// else changed the device in the meantime and the write is dropped unless
+// OnConflict says otherwise. The state at queue time is usually served by
+// WithOfflineMode; without it, conflicts cannot be detected. The charging
+// current cannot be read back, so limits are replayed without a check.
+//
+// A write is replayed with the CallOptions and the context values of the
+// original call, such as credentials set with WithContextCredentials and
+// the actor and reason of ContextWithAudit; only the cancellation comes
+// from the context of the replay.
+//
+// The queue is kept in memory. Reads are passed to the wrapped API.
+type WriteQueue struct {
+	API
+
+	// Commands also queues switch actions and charging commands.
+	Commands bool
+	// MaxAge drops writes that are older when they are replayed. Zero keeps
+	// them until they are replayed; set it when queueing Commands.
+	MaxAge time.Duration
+	// Interval is the time between two replays of Run (default 30s).
+	Interval time.Duration
+	// Clock is used for the queue times and by Run. Defaults to the
+	// system clock.
+	Clock Clock
+
+	// OnConflict decides whether to replay a conflicting write anyway.
+	// Without it, conflicting writes are dropped.
+	OnConflict func(Conflict) bool
+	// OnReplay is called for every write that was replayed or dropped.
+	OnReplay func(ReplayResult)
+
+	mu      sync.Mutex
+	pending []*QueuedWrite
+}
+
+var _ API = (*WriteQueue)(nil)
+
+// NewWriteQueue returns a WriteQueue for api.
+func NewWriteQueue(api API) *WriteQueue {
+	return &WriteQueue{API: api}
+}
+
+// Pending returns the queued writes in the order they will be replayed.
+func (q *WriteQueue) Pending() []QueuedWrite {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := make([]QueuedWrite, len(q.pending))
+	for i, w := range q.pending {
+		pending[i] = *w
+	}
+	return pending
+}
+
+// CreateOrUpdateDevice calls the API and queues the update if the API is
+// unreachable. Creating a device without ID is not queued.
+func (q *WriteQueue) CreateOrUpdateDevice(ctx context.Context, device Device, opts ...CallOption) (*Device, error) {
+	stored, err := q.API.CreateOrUpdateDevice(ctx, device, opts...)
+	if device.Id == nil || *device.Id == "" {
+		return stored, err
+	}
+	return stored, q.enqueue(ctx, err, &QueuedWrite{Method: "CreateOrUpdateDevice", DeviceID: *device.Id, Device: &device, opts: opts})
+}
+
+// PerformActions calls the API and queues the actions if the API is
+// unreachable.
+func (q *WriteQueue) PerformActions(ctx context.Context, deviceID string, actions []Action, opts ...CallOption) error {
+	err := q.API.PerformActions(ctx, deviceID, actions, opts...)
+	return q.enqueue(ctx, err, &QueuedWrite{Method: "PerformActions", DeviceID: deviceID, Actions: actions, opts: opts})
+}
+
+// ExecuteActionsBulk calls the API and queues the actions of the devices
+// that failed because the API is unreachable. Their errors in the returned
+// *BulkError are ErrWriteQueued.
+func (q *WriteQueue) ExecuteActionsBulk(ctx context.Context, actions map[string][]Action, opts ...CallOption) error {
+	err := q.API.ExecuteActionsBulk(ctx, actions, opts...)
+	var bulkErr *BulkError
+	if !errors.As(err, &bulkErr) {
+		if err != nil && unreachable(err) {
+			queued := &BulkError{Errors: make(map[string]error)}
+			for _, id := range sortedKeys(actions) {
+				queued.Errors[id] = q.enqueue(ctx, err, &QueuedWrite{Method: "PerformActions", DeviceID: id, Actions: actions[id], opts: opts})
+			}
+			return queued
+		}
+		return err
+	}
+	errs := make(map[string]error, len(bulkErr.Errors))
+	for _, id := range sortedKeys(bulkErr.Errors) {
+		errs[id] = q.enqueue(ctx, bulkErr.Errors[id], &QueuedWrite{Method: "PerformActions", DeviceID: id, Actions: actions[id], opts: opts})
+	}
+	return &BulkError{Errors: errs}
+}
+
+// SetActiveTariff calls the API and queues the switch if the API is
+// unreachable.
+func (q *WriteQueue) SetActiveTariff(ctx context.Context, deviceID string, tariff int32, opts ...CallOption) error {
+	err := q.API.SetActiveTariff(ctx, deviceID, tariff, opts...)
+	return q.enqueue(ctx, err, &QueuedWrite{Method: "SetActiveTariff", DeviceID: deviceID, Tariff: tariff, opts: opts})
+}
+
+// StartCharging calls the API and queues the command with Commands.
+func (q *WriteQueue) StartCharging(ctx context.Context, deviceID string, opts ...CallOption) error {
+	err := q.API.StartCharging(ctx, deviceID, opts...)
+	return q.enqueue(ctx, err, &QueuedWrite{Method: "StartCharging", DeviceID: deviceID, opts: opts})
+}
+
+// StopCharging calls the API and queues the command with Commands.
+func (q *WriteQueue) StopCharging(ctx context.Context, deviceID string, opts ...CallOption) error {
+	err := q.API.StopCharging(ctx, deviceID, opts...)
+	return q.enqueue(ctx, err, &QueuedWrite{Method: "StopCharging", DeviceID: deviceID, opts: opts})
+}
+
+// SetMaxChargingCurrent calls the API and queues the limit if the API is
+// unreachable.
+func (q *WriteQueue) SetMaxChargingCurrent(ctx context.Context, deviceID string, amps float64, opts ...CallOption) error {
+	err := q.API.SetMaxChargingCurrent(ctx, deviceID, amps, opts...)
+	return q.enqueue(ctx, err, &QueuedWrite{Method: "SetMaxChargingCurrent", DeviceID: deviceID, Amps: amps, opts: opts})
+}
+
+// enqueue queues w if err means that the API is unreachable, and returns
+// the error for the caller.
+func (q *WriteQueue) enqueue(ctx context.Context, err error, w *QueuedWrite) error {
+	if err == nil || !unreachable(err) || (w.command() && !q.Commands) {
+		return err
+	}
+	w.Queued = q.now()
+	w.values = context.WithoutCancel(ctx)
+	w.state, _ = q.state(ctx, w)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.Method == "SetMaxChargingCurrent" || w.Method == "SetActiveTariff" {
+		for i, p := range q.pending {
+			if p.Method == w.Method && p.DeviceID == w.DeviceID {
+				// Keep the state before the first write, which is what
+				// the replay compares with.
+				w.state = p.state
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+	}
+	q.pending = append(q.pending, w)
+	return fmt.Errorf("%w (%v)", ErrWriteQueued, err)
+}
+
+// state describes the state of the device that w changes. It returns ""
+// if w changes nothing that can be compared.
+func (q *WriteQueue) state(ctx context.Context, w *QueuedWrite) (string, error) {
+	if w.Method == "SetMaxChargingCurrent" {
+		// The actions API cannot read the limit back.
+		return "", nil
+	}
+
+	d, err := q.API.GetDevice(ctx, w.DeviceID)
+	if err != nil {
+		return "", err
+	}
+	switch w.Method {
+	case "SetActiveTariff":
+		return describeField("tariff", d.ActiveTariff), nil
+	case "StartCharging", "StopCharging":
+		return describeField("charge station state", d.ChargeStationState), nil
+	case "PerformActions":
+		if w.command() {
+			return describeField("switch on", d.SwitchOn), nil
+		}
+		for _, a := range w.Actions {
+			if NormalizeObis(a.ObisCode) == ObisActiveTariff {
+				return describeField("tariff", d.ActiveTariff), nil
+			}
+		}
+	case "CreateOrUpdateDevice":
+		return describeDevice(d, w.Device), nil
+	}
+	return "", nil
+}
+
+func describeField[T any](name string, v *T) string {
+	if v == nil {
+		return name + " unknown"
+	}
+	return fmt.Sprintf("%s %v", name, *v)
+}
+
+// describeDevice describes the fields of d that update sets.
+func describeDevice(d, update *Device) string {
+	fields := func(dev *Device) map[string]json.RawMessage {
+		var m map[string]json.RawMessage
+		data, _ := json.Marshal(dev)
+		_ = json.Unmarshal(data, &m)
+		return m
+	}
+	current, set := fields(d), fields(update)
+	var parts []string
+	for name, v := range set {
+		if name == "id" || string(v) == "null" {
+			continue
+		}
+		value := "unset"
+		if cur, ok := current[name]; ok && string(cur) != "null" {
+			value = string(cur)
+		}
+		parts = append(parts, name+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// Replay sends the queued writes in order. It stops when the API is
+// unreachable again, keeping the remaining writes, and returns that error.
+// Writes that fail otherwise, conflict or expired are dropped and
+// reported to OnReplay.
+func (q *WriteQueue) Replay(ctx context.Context) error {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			return nil
+		}
+		w := q.pending[0]
+		q.mu.Unlock()
+
+		err := q.replay(ctx, w)
+		if err != nil && unreachable(err) {
+			return err
+		}
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		q.mu.Lock()
+		// Writes may have been queued or replaced meanwhile.
+		for i, p := range q.pending {
+			if p == w {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		q.mu.Unlock()
+		if q.OnReplay != nil {
+			q.OnReplay(ReplayResult{Write: *w, Err: err})
+		}
+	}
+}
+
+// replay sends a queued write unless it expired or conflicts.
+func (q *WriteQueue) replay(ctx context.Context, w *QueuedWrite) error {
+	if q.MaxAge > 0 && q.now().Sub(w.Queued) > q.MaxAge {
+		return ErrWriteExpired
+	}
+	if w.values != nil {
+		ctx = replayContext{Context: ctx, values: w.values}
+	}
+	if w.state != "" {
+		now, err := q.state(ctx, w)
+		if err != nil {
+			return err
+		}
+		if now != w.state && (q.OnConflict == nil || !q.OnConflict(Conflict{Write: *w, Was: w.state, Now: now})) {
+			return fmt.Errorf("%w: was %s, now %s", ErrWriteConflict, w.state, now)
+		}
+	}
+
+	switch w.Method {
+	case "CreateOrUpdateDevice":
+		_, err := q.API.CreateOrUpdateDevice(ctx, *w.Device, w.opts...)
+		return err
+	case "PerformActions":
+		return q.API.PerformActions(ctx, w.DeviceID, w.Actions, w.opts...)
+	case "SetActiveTariff":
+		return q.API.SetActiveTariff(ctx, w.DeviceID, w.Tariff, w.opts...)
+	case "StartCharging":
+		return q.API.StartCharging(ctx, w.DeviceID, w.opts...)
+	case "StopCharging":
+		return q.API.StopCharging(ctx, w.DeviceID, w.opts...)
+	case "SetMaxChargingCurrent":
+		return q.API.SetMaxChargingCurrent(ctx, w.DeviceID, w.Amps, w.opts...)
+	}
+	return fmt.Errorf("unknown method %s", w.Method)
+}
+
+// Run replays the queued writes every Interval until ctx is done and
+// returns ctx.Err().
+func (q *WriteQueue) Run(ctx context.Context) error {
+	interval := q.Interval
+	if interval <= 0 {
+		interval = DefaultReplayInterval
+	}
+	clock := q.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(interval):
+		}
+		_ = q.Replay(ctx)
+	}
+}
+
+func (q *WriteQueue) now() time.Time {
+	if q.Clock == nil {
+		return SystemClock{}.Now()
+	}
+	return q.Clock.Now()
+}
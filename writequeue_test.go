@@ -0,0 +1,158 @@
+package smartme_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/smartmetest"
+)
+
+func TestWriteQueue(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("charger"), ChargeStationState: ptr(smartme.Charging)})
+	srv.AddDevice(smartme.Device{Id: ptr("meter"), ActiveTariff: ptr(int32(1)), SwitchOn: ptr(true)})
+
+	clock := smartmetest.NewClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	client, _ := srv.Client(smartme.WithOfflineMode(), smartme.WithClock(clock))
+	other, _ := srv.Client()
+	ctx := context.Background()
+
+	// Read the state once, so offline mode knows it during the outage.
+	if _, err := client.GetActions(ctx, "charger"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"charger", "meter"} {
+		if _, err := client.GetDevice(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := smartme.NewWriteQueue(client)
+	q.Clock = clock
+	var results []smartme.ReplayResult
+	q.OnReplay = func(r smartme.ReplayResult) { results = append(results, r) }
+
+	srv.FailEndpoint("/api", http.StatusServiceUnavailable)
+	for _, amps := range []float64{16, 10} {
+		if err := q.SetMaxChargingCurrent(ctx, "charger", amps); !errors.Is(err, smartme.ErrWriteQueued) {
+			t.Fatalf("SetMaxChargingCurrent while offline: err = %v, want ErrWriteQueued", err)
+		}
+	}
+	if err := q.SetActiveTariff(ctx, "meter", 2); !errors.Is(err, smartme.ErrWriteQueued) {
+		t.Fatalf("SetActiveTariff while offline: err = %v, want ErrWriteQueued", err)
+	}
+	// Switch commands are not queued by default.
+	err := q.PerformActions(ctx, "meter", []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 0}})
+	if err == nil || errors.Is(err, smartme.ErrWriteQueued) {
+		t.Errorf("switch action while offline: err = %v, want an error without queueing", err)
+	}
+
+	pending := q.Pending()
+	if len(pending) != 2 || pending[0].Amps != 10 || pending[1].Method != "SetActiveTariff" {
+		t.Fatalf("pending = %+v, want the last current limit and the tariff", pending)
+	}
+	if err := q.Replay(ctx); !errors.Is(err, smartme.ErrOffline) {
+		t.Errorf("Replay while offline: err = %v, want ErrOffline", err)
+	}
+	if len(q.Pending()) != 2 {
+		t.Errorf("%d writes pending after a failed replay, want 2", len(q.Pending()))
+	}
+
+	// Back online; meanwhile someone else switched the tariff.
+	srv.FailEndpoint("/api", 0)
+	if err := other.SetActiveTariff(ctx, "meter", 3); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if err := q.Replay(ctx); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if len(results) != 2 || results[0].Err != nil || !errors.Is(results[1].Err, smartme.ErrWriteConflict) {
+		t.Fatalf("results = %+v, want the limit applied and the tariff in conflict", results)
+	}
+	if amps := srv.ChargingCurrent("charger"); amps != 10 {
+		t.Errorf("charging current = %v, want the replayed 10", amps)
+	}
+	if d, _ := other.GetDevice(ctx, "meter"); *d.ActiveTariff != 3 {
+		t.Errorf("tariff = %d, want 3 kept after the conflict", *d.ActiveTariff)
+	}
+	if len(q.Pending()) != 0 {
+		t.Errorf("pending = %+v after the replay", q.Pending())
+	}
+}
+
+func TestWriteQueue_Options(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("meter"), SwitchOn: ptr(true)})
+	client, _ := srv.Client()
+	ctx := context.Background()
+
+	clock := smartmetest.NewClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	q := smartme.NewWriteQueue(client)
+	q.Commands = true
+	q.MaxAge = 10 * time.Minute
+	q.Clock = clock
+	var results []smartme.ReplayResult
+	q.OnReplay = func(r smartme.ReplayResult) { results = append(results, r) }
+
+	srv.FailEndpoint("/api/Actions", http.StatusBadGateway)
+	off := []smartme.Action{{ObisCode: smartme.ObisSwitchState, Value: 0}}
+	if err := q.PerformActions(ctx, "meter", off); !errors.Is(err, smartme.ErrWriteQueued) {
+		t.Fatalf("switch action with Commands: err = %v, want ErrWriteQueued", err)
+	}
+	srv.FailEndpoint("/api/Actions", 0)
+
+	clock.Advance(time.Hour)
+	if err := q.Replay(ctx); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(results) != 1 || !errors.Is(results[0].Err, smartme.ErrWriteExpired) {
+		t.Errorf("results = %+v, want the command expired", results)
+	}
+	if len(srv.Actions("meter")) != 0 {
+		t.Errorf("expired command was sent: %+v", srv.Actions("meter"))
+	}
+}
+
+func TestWriteQueue_ContextValues(t *testing.T) {
+	srv := smartmetest.NewServer()
+	defer srv.Close()
+	srv.AddDevice(smartme.Device{Id: ptr("meter"), ActiveTariff: ptr(int32(1))})
+	client, _ := srv.Client()
+	// Only the tenant may change the meter.
+	srv.SetCredentials("tenant", "secret")
+
+	tenant, err := smartme.WithContextCredentials(context.Background(), smartme.Credentials{Username: "tenant", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant = smartme.ContextWithAudit(tenant, "jane", "night tariff")
+	q := smartme.NewWriteQueue(client)
+	var results []smartme.ReplayResult
+	q.OnReplay = func(r smartme.ReplayResult) { results = append(results, r) }
+
+	srv.FailEndpoint("/api/Actions", http.StatusBadGateway)
+	if err := q.SetActiveTariff(tenant, "meter", 2); !errors.Is(err, smartme.ErrWriteQueued) {
+		t.Fatalf("SetActiveTariff while offline: err = %v, want ErrWriteQueued", err)
+	}
+	srv.FailEndpoint("/api/Actions", 0)
+
+	// The replay runs without the tenant context.
+	if err := q.Replay(context.Background()); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("results = %+v, want the tariff applied with the tenant credentials", results)
+	}
+	log := client.AuditLog()
+	if last := log[len(log)-1]; last.Actor != "jane" || last.Reason != "night tariff" || last.StatusCode != http.StatusOK {
+		t.Errorf("audit entry = %+v, want the replay recorded for jane", last)
+	}
+}
@@ -0,0 +1,36 @@
+package wsbridge
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// checkUpgrade validates the opening handshake of a client.
+func checkUpgrade(r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errors.New("websocket: method must be GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return errors.New("websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return errors.New("websocket: unsupported version")
+	}
+	if r.Header.Get("Sec-WebSocket-Key") == "" {
+		return errors.New("websocket: missing key")
+	}
+	return nil
+}
+
+// headerContains reports whether a comma separated header contains token.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+// Package wsbridge pushes live device data to WebSocket clients, such as
+// Node-RED flows or browser dashboards, so they do not have to poll:
+//
+//	hub := wsbridge.NewHub()
+//	http.Handle("/live", hub)
+//	w := smartme.NewWatcher(client, deviceIDs...)
+//	w.OnDevice = hub.Publish
+//
+// Clients select devices with JSON text messages, "*" selects all devices:
+//
+//	{"subscribe": ["device-id", "*"]}
+//	{"unsubscribe": ["device-id"]}
+//
+// and receive one Message per update of a subscribed device.
+package wsbridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/internal/websocket"
+)
+
+// defaultQueueSize is the number of messages buffered per client.
+const defaultQueueSize = 16
+
+// allDevices subscribes to all devices.
+const allDevices = "*"
+
+// Message is the JSON frame sent to clients for a device update.
+type Message struct {
+	DeviceID string         `json:"deviceId"`
+	Time     time.Time      `json:"time"`
+	Device   smartme.Device `json:"device"`
+}
+
+// request is a message sent by a client.
+type request struct {
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// errorMessage is sent to a client for an invalid request.
+type errorMessage struct {
+	Error string `json:"error"`
+}
+
+// Hub is an http.Handler that accepts WebSocket connections and sends
+// published device updates to the subscribed clients. Set the fields
+// before the Hub serves connections.
+type Hub struct {
+	// QueueSize is the number of messages buffered per client (default
+	// 16). Messages for a client whose queue is full are dropped, so a
+	// slow client does not hold up the others.
+	QueueSize int
+	// Clock sets the time of messages. Defaults to the system clock.
+	Clock smartme.Clock
+	// CheckOrigin reports whether a browser on the page of the Origin
+	// header may connect. By default only pages of the same host as the
+	// hub and clients without Origin header, i.e. not browsers, may
+	// connect, so other web sites cannot read the live data through the
+	// browser of a user.
+	CheckOrigin func(r *http.Request) bool
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	dropped atomic.Int64
+}
+
+// client is a connected WebSocket client.
+type client struct {
+	conn net.Conn
+	send chan []byte
+	// subs is guarded by Hub.mu.
+	subs map[string]bool
+
+	writeMu sync.Mutex
+}
+
+// NewHub returns a Hub without clients.
+func NewHub() *Hub {
+	return &Hub{clients: make(map[*client]struct{})}
+}
+
+// Publish sends an update of d to all clients subscribed to it.
+// Devices without ID are ignored.
+func (h *Hub) Publish(d smartme.Device) {
+	if d.Id == nil {
+		return
+	}
+	clock := h.Clock
+	if clock == nil {
+		clock = smartme.SystemClock{}
+	}
+	now := clock.Now()
+	data, err := json.Marshal(Message{DeviceID: *d.Id, Time: now, Device: d})
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if !c.subs[*d.Id] && !c.subs[allDevices] {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// Clients returns the number of connected clients.
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Dropped returns the number of messages dropped for slow clients.
+func (h *Hub) Dropped() int64 {
+	return h.dropped.Load()
+}
+
+// Close disconnects all clients.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		c.conn.Close()
+	}
+	return nil
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and serves it
+// until the client disconnects.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := checkUpgrade(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkOrigin := h.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "websocket: origin not allowed", http.StatusForbidden)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket: connection cannot be upgraded", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocket.AcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	size := h.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	c := &client{conn: conn, send: make(chan []byte, size), subs: make(map[string]bool)}
+	h.mu.Lock()
+	if h.clients == nil {
+		h.clients = make(map[*client]struct{})
+	}
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for data := range c.send {
+			if err := c.write(websocket.OpText, data); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	h.read(c, rw.Reader)
+
+	h.mu.Lock()
+	delete(h.clients, c)
+	close(c.send)
+	h.mu.Unlock()
+	<-done
+}
+
+// sameOrigin reports whether the request has no Origin header or one of
+// the host of the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// read handles the messages of a client until it disconnects.
+func (h *Hub) read(c *client, r *bufio.Reader) {
+	for {
+		message, err := websocket.ReadMessage(r, true, c.write)
+		if err != nil {
+			return
+		}
+		h.handle(c, message)
+	}
+}
+
+// handle applies a subscription request.
+func (h *Hub) handle(c *client, data []byte) {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		msg, _ := json.Marshal(errorMessage{Error: "invalid request: " + err.Error()})
+		c.write(websocket.OpText, msg)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, id := range req.Subscribe {
+		c.subs[id] = true
+	}
+	for _, id := range req.Unsubscribe {
+		delete(c.subs, id)
+	}
+}
+
+// write sends a frame; frames of the reader and the writer goroutine must
+// not interleave.
+func (c *client) write(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return websocket.WriteFrame(c.conn, opcode, payload, false)
+}
@@ -0,0 +1,207 @@
+package wsbridge_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolacher/go-smartme-client"
+	"github.com/rolacher/go-smartme-client/wsbridge"
+)
+
+// wsClient is a minimal WebSocket client for the tests.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, srv *httptest.Server) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req := "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d, want 101", resp.StatusCode)
+	}
+	// The accept key of the sample nonce from RFC 6455.
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	return &wsClient{t: t, conn: conn, r: r}
+}
+
+// send writes a masked frame.
+func (c *wsClient) send(opcode byte, payload []byte) {
+	c.t.Helper()
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// receive reads an unmasked frame.
+func (c *wsClient) receive() (byte, []byte) {
+	c.t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(c.r, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+// sync waits until the hub has handled all previous messages.
+func (c *wsClient) sync() {
+	c.t.Helper()
+	c.send(0x9, []byte("sync"))
+	if op, payload := c.receive(); op != 0xa || string(payload) != "sync" {
+		c.t.Fatalf("got frame %x %q, want pong", op, payload)
+	}
+}
+
+func (c *wsClient) receiveMessage() wsbridge.Message {
+	c.t.Helper()
+	op, payload := c.receive()
+	if op != 0x1 {
+		c.t.Fatalf("got opcode %x, want text frame", op)
+	}
+	var m wsbridge.Message
+	if err := json.Unmarshal(payload, &m); err != nil {
+		c.t.Fatalf("invalid message %s: %v", payload, err)
+	}
+	return m
+}
+
+func TestHub(t *testing.T) {
+	hub := wsbridge.NewHub()
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	c := dial(t, srv)
+	defer c.conn.Close()
+	c.send(0x1, []byte(`{"subscribe":["a","b"]}`))
+	c.send(0x1, []byte(`{"unsubscribe":["b"]}`))
+	c.sync()
+	if n := hub.Clients(); n != 1 {
+		t.Errorf("Clients() = %d, want 1", n)
+	}
+
+	power := 120.0
+	hub.Publish(smartme.Device{Id: ptr("b")})
+	hub.Publish(smartme.Device{Id: ptr("a"), ActivePower: &power})
+	m := c.receiveMessage()
+	if m.DeviceID != "a" || m.Device.ActivePower == nil || *m.Device.ActivePower != power {
+		t.Errorf("got %+v, want update of a", m)
+	}
+
+	all := dial(t, srv)
+	defer all.conn.Close()
+	all.send(0x1, []byte(`{"subscribe":["*"]}`))
+	all.sync()
+	hub.Publish(smartme.Device{Id: ptr("c")})
+	if m := all.receiveMessage(); m.DeviceID != "c" {
+		t.Errorf("got update of %q, want c", m.DeviceID)
+	}
+
+	c.send(0x1, []byte(`subscribe a`))
+	if _, payload := c.receive(); !strings.Contains(string(payload), "invalid request") {
+		t.Errorf("got %s, want error message", payload)
+	}
+
+	c.send(0x8, nil)
+	if op, _ := c.receive(); op != 0x8 {
+		t.Errorf("got opcode %x, want close", op)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Clients() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Clients() = %d after close, want 1", hub.Clients())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHub_NotWebSocket(t *testing.T) {
+	srv := httptest.NewServer(wsbridge.NewHub())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestHub_CheckOrigin(t *testing.T) {
+	hub := wsbridge.NewHub()
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	handshake := func(origin string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := handshake("http://evil.example"); code != http.StatusForbidden {
+		t.Errorf("foreign origin: status = %d, want 403", code)
+	}
+	if code := handshake(srv.URL); code != http.StatusSwitchingProtocols {
+		t.Errorf("same origin: status = %d, want 101", code)
+	}
+	hub.CheckOrigin = func(r *http.Request) bool { return r.Header.Get("Origin") == "http://dashboard.example" }
+	if code := handshake("http://dashboard.example"); code != http.StatusSwitchingProtocols {
+		t.Errorf("allowed origin: status = %d, want 101", code)
+	}
+}